@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	FromFlagName = "from"
+	ToFlagName   = "to"
+	// Google Cloud Monitoring will reject points that are more than 25 hours
+	// old.
+	MaxBackfillAge = 25 * time.Hour
+)
+
+var (
+	ErrBackfillTooOld   = errors.New("backfill start time is more than 25 hours in the past")
+	ErrBackfillInFuture = errors.New("backfill end time is in the future")
+)
+
+func newBackfillCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill [flags] WAVEFORM NAME",
+		Short: "Write historical synthetic metrics for a time range",
+		Long: `Generate synthetic metric time-series data-points for every sample interval between two timestamps, and write them to Google Cloud Monitoring in ascending time order. WAVEFORM is one of sawtooth, sine, square, triangle, ramp, or trapezoid.
+
+NOTE: Google Cloud Monitoring only accepts points that are less than 25 hours old, and a request can only contain a single point for each time-series, so the points are combined into requests of up to --batch-size time-series, with a new request started whenever a time-series already has a point in the current request.`,
+		Example:           AppName + " backfill --project ID --from $(date -Iseconds -v -4H) --sample 30s sawtooth custom.googleapis.com/syntheticScaler/cpu",
+		PreRunE:           bindBackfillFlags,
+		RunE:              backfillMain,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeWaveform,
+	}
+	addGeneratorFlags(cmd)
+	addTrapezoidShapeFlags(cmd)
+	cmd.PersistentFlags().String(FromFlagName, "", "set the start time for generated data points as RFC3339, if unspecified one period before the end time will be used")
+	cmd.PersistentFlags().String(ToFlagName, "", "set the end time for generated data points as RFC3339, if unspecified the current time will be used")
+	return cmd
+}
+
+func bindBackfillFlags(cmd *cobra.Command, args []string) error {
+	if err := bindViperFlags(cmd, args); err != nil {
+		return err
+	}
+	if err := bindTrapezoidShapeFlags(cmd); err != nil {
+		return err
+	}
+	if err := viper.BindPFlag(FromFlagName, cmd.PersistentFlags().Lookup(FromFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", FromFlagName, err)
+	}
+	if err := viper.BindPFlag(ToFlagName, cmd.PersistentFlags().Lookup(ToFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", ToFlagName, err)
+	}
+	return nil
+}
+
+//nolint:funlen // Setup of options makes the function seem long
+func backfillMain(cmd *cobra.Command, args []string) error {
+	periodicType, err := generators.ParsePeriodicType(args[0])
+	if err != nil {
+		return fmt.Errorf("failure parsing PeriodicType: %w", err)
+	}
+	project := viper.GetString(ProjectIDFlagName)
+	sample := viper.GetDuration(SampleFlagName)
+	period := viper.GetDuration(PeriodFlagName)
+	floor, ceiling, err := effectiveRange(logger)
+	if err != nil {
+		return err
+	}
+	dryRun := viper.GetBool(DryRunFlagName)
+	asInteger := viper.GetBool(IntegerFlagName)
+	location := viper.GetString(LocationFlagName)
+	namespace := viper.GetString(NamespaceFlagName)
+	validateOnly := viper.GetBool(ValidateOnlyFlagName)
+	sequenceLabel := viper.GetString(SequenceLabelFlagName)
+	now := time.Now()
+	to, err := parseTime(viper.GetString(ToFlagName), now)
+	if err != nil {
+		return err
+	}
+	from, err := parseTime(viper.GetString(FromFlagName), to.Add(-period))
+	if err != nil {
+		return err
+	}
+	if from.Before(now.Add(-MaxBackfillAge)) {
+		return ErrBackfillTooOld
+	}
+	if to.After(now) {
+		return ErrBackfillInFuture
+	}
+	logger := logger.WithValues("periodicType", periodicType.String(), "project", project, "sample", sample, "period", period, FloorFlagName, floor, CeilingFlagName, ceiling, "dryRun", dryRun, "asInteger", asInteger, "location", location, "namespace", namespace, "validateOnly", validateOnly, "sequenceLabel", sequenceLabel, "from", from, "to", to)
+	logger.V(0).Info("Building synthetic metric backfill pipeline")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	unit, err := unitCalculator(periodicType)
+	if err != nil {
+		return err
+	}
+	calculator, err := calculatorOption(floor, ceiling, unit, viper.GetFloat64(DailyAmplitudeFlagName), viper.GetFloat64(WeeklyAmplitudeFlagName))
+	if err != nil {
+		return err
+	}
+	metrics, err := generators.NewRangeMetrics(from, to, sample,
+		generators.WithLogger(logger),
+		calculator,
+		generators.WithPeriod(period),
+	)
+	if err != nil {
+		return fmt.Errorf("failure building range of metrics: %w", err)
+	}
+	pipelineOptions, err := generatorPipelineOptions(cmd, logger, args[1])
+	if err != nil {
+		return err
+	}
+	pipe, err := pipeline.NewPipeline(ctx, pipelineOptions...)
+	if err != nil {
+		return fmt.Errorf("failure creating new pipeline: %w", err)
+	}
+	defer func() {
+		logger.V(2).Info("Closing pipeline")
+		if err := pipe.Close(); err != nil {
+			logger.Error(err, "Error returned while closing pipeline")
+		}
+	}()
+	if validateOnly && len(metrics) > 0 {
+		return validatePipeline(ctx, pipe, metrics[0])
+	}
+	// The metrics are already sorted in ascending order, which EmitBatch keeps.
+	logger.V(1).Info("Writing backfill metrics", "count", len(metrics))
+	if err := pipe.EmitBatch(ctx, metrics); err != nil {
+		return fmt.Errorf("failure writing backfill metrics: %w", err)
+	}
+	logger.V(0).Info("Backfill complete", "count", len(metrics))
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// The number of attempts made to create a Cloud Monitoring client, e.g.
+	// when refreshing Application Default Credentials fails transiently.
+	ClientAttempts = 3
+	// The delay before the second attempt to create a Cloud Monitoring client;
+	// the delay doubles for each subsequent attempt.
+	ClientBackoff = 500 * time.Millisecond
+)
+
+// Returns a new Cloud Monitoring metric client, retrying transient failures, and
+// a function that closes the client and logs any error; e.g.
+//
+//	client, closeClient, err := newMetricClient(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer closeClient()
+//
+// Commands that act on the effective project should use withMetricClient.
+func newMetricClient(ctx context.Context) (*monitoring.MetricClient, func(), error) {
+	client, err := pipeline.RetryWithBackoff(ctx, logger, ClientAttempts, ClientBackoff, createMetricClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure creating new metric client: %w", err)
+	}
+	return client, func() { closeClient(client) }, nil
+}
+
+// Resolves the effective project and creates a Cloud Monitoring metric client,
+// retrying transient failures, then calls fn with the client and project; the
+// client is closed when fn returns.
+func withMetricClient(ctx context.Context, fn func(*monitoring.MetricClient, string) error) error {
+	return withProjectClient(ctx, createMetricClient, fn)
+}
+
+// Implements withMetricClient for any type of client, so that tests can
+// substitute a fake client.
+func withProjectClient[C io.Closer](ctx context.Context, newClient func(context.Context) (C, error), fn func(C, string) error) error {
+	projectID, err := effectiveProjectID(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := pipeline.RetryWithBackoff(ctx, logger, ClientAttempts, ClientBackoff, newClient)
+	if err != nil {
+		return fmt.Errorf("failure creating new metric client: %w", err)
+	}
+	defer closeClient(client)
+	return fn(client, projectID)
+}
+
+// Returns a new Cloud Monitoring metric client that connects with the endpoint,
+// CA certificates, proxy, and user-agent set by the connection flags.
+func createMetricClient(ctx context.Context) (*monitoring.MetricClient, error) {
+	options, err := connectionOptions()
+	if err != nil {
+		return nil, err
+	}
+	clientOptions, err := pipeline.ClientOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection flags: %w", err)
+	}
+	return monitoring.NewMetricClient(ctx, clientOptions...) //nolint:wrapcheck // The error is wrapped by the callers
+}
+
+// Add the flags that set how Cloud Monitoring clients connect to the API.
+func addConnectionFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String(UserAgentFlagName, AppName+"/"+version, "sets the user-agent reported to Google Cloud Monitoring, to identify synthetic writes in audit logs")
+	cmd.PersistentFlags().String(RegionFlagName, "", "if set, send time-series to the regional Google Cloud Monitoring endpoint for this region, e.g. europe-west3, to keep metric data in the region")
+	cmd.PersistentFlags().String(EndpointFlagName, "", "if set, send time-series to the Google Cloud Monitoring API at this host and port instead of the public endpoint, e.g. a Private Service Connect endpoint")
+	cmd.PersistentFlags().String(CACertFileFlagName, "", "if set, verify the Google Cloud Monitoring endpoint's TLS certificate against the CA certificates in this PEM file instead of the system roots")
+	cmd.PersistentFlags().String(ProxyFlagName, "", "if set, connect to Google Cloud Monitoring through the HTTP proxy at this URL, e.g. http://proxy.example.com:3128; the HTTPS_PROXY environment variable is used when unset")
+}
+
+// Bind the connection flags of the executing command to viper.
+func bindConnectionFlags(cmd *cobra.Command) error {
+	for _, name := range []string{UserAgentFlagName, RegionFlagName, EndpointFlagName, CACertFileFlagName, ProxyFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the pipeline options that set the Cloud Monitoring client options from
+// the connection flags.
+func connectionOptions() ([]pipeline.Option, error) {
+	options := []pipeline.Option{}
+	endpoint := viper.GetString(EndpointFlagName)
+	region := viper.GetString(RegionFlagName)
+	switch {
+	case endpoint != "" && region != "":
+		return nil, ErrConflictingEndpointFlags
+	case endpoint != "":
+		options = append(options, pipeline.WithEndpoint(endpoint))
+	case region != "":
+		options = append(options, pipeline.WithRegionalEndpoint(region))
+	}
+	if caCertFile := viper.GetString(CACertFileFlagName); caCertFile != "" {
+		options = append(options, pipeline.WithCACertFile(caCertFile))
+	}
+	if proxy := viper.GetString(ProxyFlagName); proxy != "" {
+		options = append(options, pipeline.WithProxy(proxy))
+	}
+	if userAgent := viper.GetString(UserAgentFlagName); userAgent != "" {
+		options = append(options, pipeline.WithUserAgent(userAgent))
+	}
+	return options, nil
+}
+
+// Close the client, logging any error.
+func closeClient(client io.Closer) {
+	if err := client.Close(); err != nil {
+		logger.Error(err, "Error returned while closing metric client")
+	}
+}
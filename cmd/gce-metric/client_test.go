@@ -0,0 +1,88 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	errTestAttempt  = errors.New("test attempt failed")
+	errTestCallback = errors.New("test callback failed")
+)
+
+// A fake client that records whether it has been closed.
+type fakeClient struct {
+	closed bool
+}
+
+func (f *fakeClient) Close() error {
+	f.closed = true
+	return nil
+}
+
+//nolint:paralleltest // The project identifier is read from the global viper instance
+func TestWithProjectClient(t *testing.T) {
+	viper.Set(ProjectIDFlagName, "test-project")
+	t.Cleanup(func() { viper.Set(ProjectIDFlagName, "") })
+	tests := []struct {
+		name          string
+		failures      int
+		callbackError error
+		expectedError error
+		expectedCall  bool
+	}{
+		{
+			name:         "success",
+			expectedCall: true,
+		},
+		{
+			name:          "callback-error",
+			callbackError: errTestCallback,
+			expectedError: errTestCallback,
+			expectedCall:  true,
+		},
+		{
+			name:          "client-error",
+			failures:      ClientAttempts,
+			expectedError: errTestAttempt,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			client := &fakeClient{}
+			attempts := 0
+			called := false
+			err := withProjectClient(context.Background(), func(_ context.Context) (*fakeClient, error) {
+				attempts++
+				if attempts <= tst.failures {
+					return nil, errTestAttempt
+				}
+				return client, nil
+			}, func(c *fakeClient, projectID string) error {
+				called = true
+				if c != client {
+					t.Errorf("Expected the fake client to be passed to the callback")
+				}
+				if projectID != "test-project" {
+					t.Errorf("Expected project %q, got %q", "test-project", projectID)
+				}
+				return tst.callbackError
+			})
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected withProjectClient to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("withProjectClient raised an unexpected error: %v", err)
+			}
+			if called != tst.expectedCall {
+				t.Errorf("Expected callback called to be %t, got %t", tst.expectedCall, called)
+			}
+			if client.closed != tst.expectedCall {
+				t.Errorf("Expected client closed to be %t, got %t", tst.expectedCall, client.closed)
+			}
+		})
+	}
+}
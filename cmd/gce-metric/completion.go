@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/api/iterator"
+)
+
+// The maximum time to spend listing metric types when completing a metric type
+// argument or flag.
+const CompletionTimeout = 2 * time.Second
+
+// This error will be returned if the completion command is asked for a shell
+// that cobra cannot generate a completion script for.
+var ErrUnsupportedShell = errors.New("shell must be one of bash, zsh, fish, or powershell")
+
+func newCompletionCommand() *cobra.Command {
+	completionCmd := &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script.",
+		Long: `Generate a script that provides completion of ` + AppName + ` subcommands and flags for the named shell, and write it to stdout.
+
+To load completions in the current bash session:
+
+  source <(` + AppName + ` completion bash)
+
+To load completions for every new zsh session, write the script to a directory in $fpath, e.g.:
+
+  ` + AppName + ` completion zsh > "${fpath[1]}/_` + AppName + `"
+
+To load completions for every new fish session:
+
+  ` + AppName + ` completion fish > ~/.config/fish/completions/` + AppName + `.fish
+
+To load completions in the current PowerShell session:
+
+  ` + AppName + ` completion powershell | Out-String | Invoke-Expression`,
+		Example:               AppName + " completion bash > /etc/bash_completion.d/" + AppName,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE:                  completionMain,
+	}
+	return completionCmd
+}
+
+func completionMain(cmd *cobra.Command, args []string) error {
+	root := cmd.Root()
+	out := cmd.OutOrStdout()
+	var err error
+	switch args[0] {
+	case "bash":
+		err = root.GenBashCompletionV2(out, true)
+	case "zsh":
+		err = root.GenZshCompletion(out)
+	case "fish":
+		err = root.GenFishCompletion(out, true)
+	case "powershell":
+		err = root.GenPowerShellCompletionWithDesc(out)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedShell, args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failure generating %s completion script: %w", args[0], err)
+	}
+	return nil
+}
+
+// Returns the metric types to offer as completions.
+type metricTypeLister func(context.Context) ([]string, error)
+
+// Returns a cobra completion function that offers the metric types returned by
+// lister that start with the partial argument and have not already been given.
+// The lister is cancelled after the timeout, and any error is only reported in
+// cobra's debug log, so that completion stays responsive when offline.
+func metricTypeCompletion(lister metricTypeLister, timeout time.Duration) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		metricTypes, err := lister(ctx)
+		if err != nil {
+			cobra.CompDebugln("failure listing metric types for completion: "+err.Error(), false)
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		completions := []string{}
+		for _, metricType := range metricTypes {
+			if strings.HasPrefix(metricType, toComplete) && !slices.Contains(args, metricType) {
+				completions = append(completions, metricType)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// Returns the custom metric types in the project. Metadata detection of the
+// project can be slow when offline, so nothing is returned unless the project
+// has been set explicitly.
+func listCustomMetricTypes(ctx context.Context) ([]string, error) {
+	projectID := viper.GetString(ProjectIDFlagName)
+	if projectID == "" {
+		return nil, nil
+	}
+	client, closeClient, err := newMetricClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClient()
+	it := client.ListMetricDescriptors(ctx, &monitoringpb.ListMetricDescriptorsRequest{
+		Name:      "projects/" + projectID,
+		Filter:    DefaultFilter,
+		PageSize:  0,
+		PageToken: "",
+	})
+	metricTypes := []string{}
+	for {
+		response, err := it.Next()
+		switch {
+		case errors.Is(err, iterator.Done):
+			return metricTypes, nil
+		case err != nil:
+			return nil, fmt.Errorf("failure getting list of metrics: %w", err)
+		default:
+			metricTypes = append(metricTypes, response.GetType())
+		}
+	}
+}
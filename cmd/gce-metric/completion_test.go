@@ -0,0 +1,117 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Returns a minimal root command with the completion command attached.
+func newCompletionTestRoot(out *bytes.Buffer, args ...string) *cobra.Command {
+	root := &cobra.Command{
+		Use: AppName,
+	}
+	root.AddCommand(newCompletionCommand())
+	root.SetOut(out)
+	root.SetErr(out)
+	root.SetArgs(args)
+	return root
+}
+
+func TestCompletionCommand(t *testing.T) {
+	t.Parallel()
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			if err := newCompletionTestRoot(&out, "completion", shell).Execute(); err != nil {
+				t.Fatalf("Unexpected error generating %s completion: %v", shell, err)
+			}
+			if out.Len() == 0 {
+				t.Errorf("Expected %s completion script, got empty output", shell)
+			}
+			if !bytes.Contains(out.Bytes(), []byte(AppName)) {
+				t.Errorf("Expected %s completion script to reference %s", shell, AppName)
+			}
+		})
+	}
+}
+
+func TestCompletionCommandInvalid(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{{"completion"}, {"completion", "tcsh"}, {"completion", "bash", "zsh"}} {
+		var out bytes.Buffer
+		if err := newCompletionTestRoot(&out, args...).Execute(); err == nil {
+			t.Errorf("Expected an error for arguments %v", args)
+		}
+	}
+}
+
+var errTestLister = errors.New("test lister failure")
+
+func TestMetricTypeCompletion(t *testing.T) {
+	t.Parallel()
+	metricTypes := []string{"custom.googleapis.com/gce-metric", "custom.googleapis.com/gce-metric-other", "custom.googleapis.com/other"}
+	tests := []struct {
+		name       string
+		lister     metricTypeLister
+		args       []string
+		toComplete string
+		expected   []string
+	}{
+		{
+			name: "all",
+			lister: func(context.Context) ([]string, error) {
+				return metricTypes, nil
+			},
+			expected: metricTypes,
+		},
+		{
+			name: "prefix",
+			lister: func(context.Context) ([]string, error) {
+				return metricTypes, nil
+			},
+			toComplete: "custom.googleapis.com/gce",
+			expected:   []string{"custom.googleapis.com/gce-metric", "custom.googleapis.com/gce-metric-other"},
+		},
+		{
+			name: "exclude-args",
+			lister: func(context.Context) ([]string, error) {
+				return metricTypes, nil
+			},
+			args:     []string{"custom.googleapis.com/gce-metric"},
+			expected: []string{"custom.googleapis.com/gce-metric-other", "custom.googleapis.com/other"},
+		},
+		{
+			name: "error",
+			lister: func(context.Context) ([]string, error) {
+				return nil, errTestLister
+			},
+		},
+		{
+			name: "timeout",
+			lister: func(ctx context.Context) ([]string, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			completions, directive := metricTypeCompletion(tst.lister, 10*time.Millisecond)(&cobra.Command{}, tst.args, tst.toComplete)
+			if directive != cobra.ShellCompDirectiveNoFileComp {
+				t.Errorf("Expected directive %d, got %d", cobra.ShellCompDirectiveNoFileComp, directive)
+			}
+			if !slices.Equal(completions, tst.expected) && (len(completions) != 0 || len(tst.expected) != 0) {
+				t.Errorf("Expected completions %v, got %v", tst.expected, completions)
+			}
+		})
+	}
+}
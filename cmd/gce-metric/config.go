@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RedactFlagName = "redact"
+	// The value that replaces credentials in redacted configuration.
+	RedactedValue = "xxxxx"
+)
+
+var (
+	// This error will be returned if the config command is given a name that
+	// is not a gce-metric command.
+	ErrUnknownCommand = errors.New("unknown command")
+	// Settings with a key that contains any of these strings are masked when
+	// the configuration is redacted.
+	redactedKeyFragments = []string{"token", "password", "secret", "credential", "apikey", "api-key", "api_key"}
+)
+
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config [command]",
+		Short: "Write the effective configuration as YAML.",
+		Long: `Write the configuration that results from merging command line flags, ` + strings.ToUpper(AppName) + `_ environment variables, and the configuration file to stdout as YAML, which can be used as a configuration file to reproduce a run.
+
+If a command is named, the configuration includes the defaults of that command's flags; e.g. "config sine" shows the floor, ceiling, and period that the sine command would use.`,
+		Example: AppName + " config sine --redact > .gce-metric.yaml",
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    configMain,
+	}
+	configCmd.PersistentFlags().Bool(RedactFlagName, false, "mask the values of settings that look like credentials, such as tokens and passwords in URLs")
+	return configCmd
+}
+
+func configMain(cmd *cobra.Command, args []string) error {
+	redact, err := cmd.PersistentFlags().GetBool(RedactFlagName)
+	if err != nil {
+		return fmt.Errorf("failure getting '%s' flag: %w", RedactFlagName, err)
+	}
+	if len(args) > 0 {
+		target, _, err := cmd.Root().Find(args)
+		if err != nil || target == cmd.Root() {
+			return fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+		}
+		// Bind the command's flags so that their defaults are included.
+		if target.PreRunE != nil {
+			if err := target.PreRunE(target, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return writeConfig(cmd.OutOrStdout(), viper.GetViper(), redact)
+}
+
+// Write all the settings in v to w as YAML. If redact is true the values of
+// settings that look like credentials are masked.
+func writeConfig(w io.Writer, v *viper.Viper, redact bool) error {
+	settings := normalizeSettings(v.AllSettings(), redact)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settings); err != nil {
+		return fmt.Errorf("failure encoding configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failure encoding configuration: %w", err)
+	}
+	return nil
+}
+
+// Returns the settings with durations formatted as strings that viper can parse,
+// and credentials masked if redact is true.
+func normalizeSettings(settings map[string]any, redact bool) map[string]any {
+	result := make(map[string]any, len(settings))
+	for key, value := range settings {
+		switch v := value.(type) {
+		case map[string]any:
+			result[key] = normalizeSettings(v, redact)
+		case time.Duration:
+			result[key] = v.String()
+		case string:
+			result[key] = v
+			if redact {
+				result[key] = redactString(key, v)
+			}
+		default:
+			result[key] = v
+			if redact && isCredentialKey(key) {
+				result[key] = RedactedValue
+			}
+		}
+	}
+	return result
+}
+
+// Returns true if the setting key looks like it holds a credential.
+func isCredentialKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range redactedKeyFragments {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the value masked if the key looks like a credential, or with any
+// password removed if the value is a URL with user information.
+func redactString(key, value string) string {
+	switch {
+	case value == "":
+		return value
+	case isCredentialKey(key):
+		return RedactedValue
+	case strings.Contains(value, "://"):
+		if parsed, err := url.Parse(value); err == nil {
+			return parsed.Redacted()
+		}
+	}
+	return value
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writes the prompt to out and reads a single line answer from in, returning
+// true if the answer is y or yes. The answer is read one byte at a time so that
+// any input after the line is left for other readers, e.g. the stream command.
+func confirm(in io.Reader, out io.Writer, prompt string) (bool, error) {
+	if _, err := fmt.Fprintf(out, "%s [y/N]: ", prompt); err != nil {
+		return false, fmt.Errorf("failure writing confirmation prompt: %w", err)
+	}
+	var answer strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			answer.WriteByte(buf[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("failure reading confirmation: %w", err)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(answer.String())) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,75 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  bool
+		remaining string
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: false,
+		},
+		{
+			name:     "no",
+			input:    "n\n",
+			expected: false,
+		},
+		{
+			name:     "y",
+			input:    "y\n",
+			expected: true,
+		},
+		{
+			name:     "yes-without-newline",
+			input:    "Yes",
+			expected: true,
+		},
+		{
+			name:     "yes-with-whitespace",
+			input:    "  yes \r\n",
+			expected: true,
+		},
+		{
+			name:      "remaining-input",
+			input:     "y\n1.5\n2.5\n",
+			expected:  true,
+			remaining: "1.5\n2.5\n",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			in := strings.NewReader(tst.input)
+			var out bytes.Buffer
+			confirmed, err := confirm(in, &out, "Continue?")
+			if err != nil {
+				t.Fatalf("Unexpected error from confirm: %v", err)
+			}
+			if confirmed != tst.expected {
+				t.Errorf("Expected %t, got %t", tst.expected, confirmed)
+			}
+			if prompt := out.String(); prompt != "Continue? [y/N]: " {
+				t.Errorf("Expected prompt %q, got %q", "Continue? [y/N]: ", prompt)
+			}
+			remaining, err := io.ReadAll(in)
+			if err != nil {
+				t.Fatalf("Unexpected error reading remaining input: %v", err)
+			}
+			if string(remaining) != tst.remaining {
+				t.Errorf("Expected remaining input %q, got %q", tst.remaining, remaining)
+			}
+		})
+	}
+}
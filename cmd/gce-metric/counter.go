@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+)
+
+const (
+	RateFlagName = "rate"
+)
+
+func newCounterCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "counter [flags] NAME",
+		Short: "Generate synthetic metrics from a monotonically increasing counter",
+		Long: `Generate a CUMULATIVE metric time-series whose value increases by a fixed rate per second from zero, and send it to Google Cloud Monitoring, e.g. to test rate and alignment calculations on counters.
+
+Every point shares the start time of the first point, so the time-series is a single counter for as long as the generator runs.`,
+		Example: AppName + " counter --project ID --rate 2.5 --integer custom.googleapis.com/syntheticScaler/requests",
+		PreRunE: bindCounterFlags,
+		RunE:    counterMain,
+		Args:    cobra.ExactArgs(1),
+	}
+	cmd.PersistentFlags().Duration(SampleFlagName, 60*time.Second, "sets the interval between sending metrics to Google Monitoring, must be valid Go duration string")
+	cmd.PersistentFlags().Float64(RateFlagName, 1.0, "sets the number of units per second that the counter increases by; must not be negative")
+	addPipelineFlags(cmd)
+	return cmd
+}
+
+func bindCounterFlags(cmd *cobra.Command, args []string) error {
+	for _, name := range []string{SampleFlagName, RateFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return bindPipelineFlags(cmd, args)
+}
+
+func counterMain(cmd *cobra.Command, args []string) error {
+	project := viper.GetString(ProjectIDFlagName)
+	sample := viper.GetDuration(SampleFlagName)
+	rate := viper.GetFloat64(RateFlagName)
+	if rate < 0.0 {
+		return fmt.Errorf("%w: %v", ErrInvalidRate, rate)
+	}
+	dryRun := viper.GetBool(DryRunFlagName)
+	asInteger := viper.GetBool(IntegerFlagName)
+	validateOnly := viper.GetBool(ValidateOnlyFlagName)
+	logger := logger.WithValues("project", project, "sample", sample, "rate", rate, "dryRun", dryRun, "asInteger", asInteger, "validateOnly", validateOnly)
+	logger.V(0).Info("Building synthetic counter pipeline")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+		generators.WithLogger(logger),
+		generators.WithTimedValueCalculator(generators.NewCounterCalculator(rate)),
+	)
+	if err != nil {
+		return fmt.Errorf("failure building PeriodicGenerator: %w", err)
+	}
+	pipelineOptions, err := generatorPipelineOptions(cmd, logger, args[0])
+	if err != nil {
+		return err
+	}
+	pipelineOptions = append(pipelineOptions, pipeline.WithMetricKind(metricpb.MetricDescriptor_CUMULATIVE))
+	pipe, err := pipeline.NewPipeline(ctx, pipelineOptions...)
+	if err != nil {
+		return fmt.Errorf("failure creating new pipeline: %w", err)
+	}
+	defer func() {
+		logger.V(2).Info("Closing pipeline")
+		if err := pipe.Close(); err != nil {
+			logger.Error(err, "Error returned while closing pipeline")
+		}
+	}()
+	if validateOnly {
+		return validatePipeline(ctx, pipe, generators.Metric{
+			Value:     0.0,
+			Timestamp: time.Now(),
+		})
+	}
+	ticker := time.NewTicker(sample)
+	defer ticker.Stop()
+	go func() {
+		logger.V(1).Info("Launching pipeline processor")
+		if err := pipe.Processor()(ctx, reader); err != nil {
+			logger.Error(err, "Pipeline processor returned an error")
+			cancel()
+		}
+	}()
+	logger.V(1).Info("Launching counter generator")
+	go periodicGenerator(ctx, ticker.C)
+	<-ctx.Done()
+	logger.V(1).Info("Context has been cancelled")
+	return nil
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
+	// Embed the IANA timezone database so --timezone works in minimal container
+	// images.
+	_ "time/tzdata"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
@@ -12,45 +20,100 @@ import (
 	"github.com/spf13/viper"
 	"google.golang.org/api/iterator"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
-	StartTimeFlag = "start-time"
-	EndTimeFlag   = "end-time"
+	StartTimeFlag        = "start-time"
+	EndTimeFlag          = "end-time"
+	CSVFlagName          = "csv"
+	TimezoneFlagName     = "timezone"
+	FollowFlagName       = "follow"
+	PollIntervalFlagName = "poll-interval"
 )
 
-func newDataCommand() (*cobra.Command, error) {
+var (
+	ErrFollowWithEndTime   = errors.New("end time cannot be combined with follow")
+	ErrInvalidPollInterval = errors.New("poll interval must be greater than zero")
+)
+
+func newDataCommand() *cobra.Command {
 	dataCmd := &cobra.Command{
-		Use:     "data [--verbose] [--project ID] [--filter FILTER] [--start-time ISO8601] [--end-time ISO8601]",
+		Use:     "data [--verbose] [--project ID] [--filter FILTER] [--start-time ISO8601] [--end-time ISO8601 | --follow [--poll-interval T]] [--csv [--timezone TZ]]",
 		Short:   "Return metric data from time-series that match the filter.",
-		Long:    `Returns each metric time-series that matches the supplied filter and has point-in-time data that is between the start and end times provided.`,
+		Long:    `Returns each metric time-series that matches the supplied filter and has point-in-time data that is between the start and end times provided. Use the --csv flag to print a row for each point instead of the full time-series as JSON, and the --follow flag to keep printing new points as they arrive until interrupted.`,
 		Example: AppName + ` data --project ID --filter 'metric.type = has_substring("my-resource")' --start-time $(date -Iseconds -v -4H)`,
+		PreRunE: bindDataFlags,
 		RunE:    metricData,
 		Args:    cobra.NoArgs,
 	}
-	dataCmd.PersistentFlags().String(FilterFlagName, "metric.type = starts_with(\"custom.googleapis.com/\")", "set the filter to use when listing metrics")
-	dataCmd.PersistentFlags().String(StartTimeFlag, "", "set the start time for filtering data, if unspecified matching time-series data points from 5 mins ago will be included")
-	dataCmd.PersistentFlags().String(EndTimeFlag, "", "set the end time for filtering data, if unspecified matching time-series data points up to the current time will be included")
-	if err := viper.BindPFlag(FilterFlagName, dataCmd.PersistentFlags().Lookup(FilterFlagName)); err != nil {
-		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", FilterFlagName, err)
+	addFilterFlags(dataCmd, "set the filter to use when listing metrics")
+	addTimeRangeFlags(dataCmd)
+	addConnectionFlags(dataCmd)
+	dataCmd.PersistentFlags().Bool(CSVFlagName, false, "output a CSV row of timestamp, metric type, resource type, and value for each point")
+	dataCmd.PersistentFlags().String(TimezoneFlagName, "UTC", "set the IANA timezone name used to format point timestamps in CSV output, e.g. America/Los_Angeles")
+	dataCmd.PersistentFlags().Bool(FollowFlagName, false, "keep polling for new data points and print them as they arrive, until interrupted")
+	dataCmd.PersistentFlags().Duration(PollIntervalFlagName, 30*time.Second, "set the interval between polls for new data points when following")
+	return dataCmd
+}
+
+// Add the flags that set the time range for time-series data points.
+func addTimeRangeFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String(StartTimeFlag, "", "set the start time for filtering data, if unspecified matching time-series data points from 5 mins ago will be included")
+	cmd.PersistentFlags().String(EndTimeFlag, "", "set the end time for filtering data, if unspecified matching time-series data points up to the current time will be included")
+}
+
+// Bind the time range flags of the executing command to viper.
+func bindTimeRangeFlags(cmd *cobra.Command) error {
+	if err := viper.BindPFlag(StartTimeFlag, cmd.PersistentFlags().Lookup(StartTimeFlag)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", StartTimeFlag, err)
 	}
-	if err := viper.BindPFlag(StartTimeFlag, dataCmd.PersistentFlags().Lookup(StartTimeFlag)); err != nil {
-		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", StartTimeFlag, err)
+	if err := viper.BindPFlag(EndTimeFlag, cmd.PersistentFlags().Lookup(EndTimeFlag)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", EndTimeFlag, err)
 	}
-	if err := viper.BindPFlag(EndTimeFlag, dataCmd.PersistentFlags().Lookup(EndTimeFlag)); err != nil {
-		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", EndTimeFlag, err)
+	return nil
+}
+
+func bindDataFlags(cmd *cobra.Command, _ []string) error {
+	if err := bindFilterFlags(cmd); err != nil {
+		return err
 	}
-	return dataCmd, nil
+	if err := bindTimeRangeFlags(cmd); err != nil {
+		return err
+	}
+	if err := bindConnectionFlags(cmd); err != nil {
+		return err
+	}
+	if err := viper.BindPFlag(CSVFlagName, cmd.PersistentFlags().Lookup(CSVFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", CSVFlagName, err)
+	}
+	if err := viper.BindPFlag(TimezoneFlagName, cmd.PersistentFlags().Lookup(TimezoneFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", TimezoneFlagName, err)
+	}
+	if err := viper.BindPFlag(FollowFlagName, cmd.PersistentFlags().Lookup(FollowFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", FollowFlagName, err)
+	}
+	if err := viper.BindPFlag(PollIntervalFlagName, cmd.PersistentFlags().Lookup(PollIntervalFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", PollIntervalFlagName, err)
+	}
+	return nil
 }
 
+//nolint:funlen // Setup of the request and output makes the function seem long
 func metricData(_ *cobra.Command, _ []string) error {
 	logger.V(0).Info("Preparing data client")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	setupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	projectID, err := effectiveProjectID(ctx)
-	if err != nil {
-		return err
+	follow := viper.GetBool(FollowFlagName)
+	pollInterval := viper.GetDuration(PollIntervalFlagName)
+	if follow && viper.IsSet(EndTimeFlag) {
+		return ErrFollowWithEndTime
+	}
+	if follow && pollInterval <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidPollInterval, pollInterval)
 	}
 	startTime, err := buildTimestamp(viper.GetString(StartTimeFlag), time.Now().Add(-5*time.Minute))
 	if err != nil {
@@ -60,22 +123,46 @@ func metricData(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
-	req := monitoringpb.ListTimeSeriesRequest{
-		Name:   "projects/" + projectID,
-		Filter: viper.GetString(FilterFlagName),
-		Interval: &monitoringpb.TimeInterval{
-			StartTime: startTime,
-			EndTime:   endTime,
-		},
-		PageSize:  0,
-		PageToken: "",
-	}
-	client, err := monitoring.NewMetricClient(ctx)
+	location, err := loadTimezone(viper.GetString(TimezoneFlagName))
 	if err != nil {
-		return fmt.Errorf("failure creating new metric client: %w", err)
+		return err
+	}
+	filter, err := effectiveFilter()
+	if err != nil {
+		return err
 	}
-	defer client.Close()
-	it := client.ListTimeSeries(ctx, &req)
+	writer := csv.NewWriter(os.Stdout)
+	output := func(series *monitoringpb.TimeSeries) error {
+		if !viper.GetBool(CSVFlagName) {
+			fmt.Println(protojson.Format(series)) //nolint:forbidigo // The data subcommand writes to stdout deliberately
+			return nil
+		}
+		if err := writer.WriteAll(pointRecords(series, location)); err != nil {
+			return fmt.Errorf("failure writing CSV records: %w", err)
+		}
+		return nil
+	}
+	return withMetricClient(setupCtx, func(client *monitoring.MetricClient, projectID string) error {
+		req := monitoringpb.ListTimeSeriesRequest{
+			Name:   "projects/" + projectID,
+			Filter: filter,
+			Interval: &monitoringpb.TimeInterval{
+				StartTime: startTime,
+				EndTime:   endTime,
+			},
+			PageSize:  0,
+			PageToken: "",
+		}
+		if !follow {
+			return listTimeSeries(setupCtx, client, &req, output)
+		}
+		return followTimeSeries(ctx, client, &req, pollInterval, output)
+	})
+}
+
+// Call the output function with each time-series that matches the request.
+func listTimeSeries(ctx context.Context, client *monitoring.MetricClient, req *monitoringpb.ListTimeSeriesRequest, output func(*monitoringpb.TimeSeries) error) error {
+	it := client.ListTimeSeries(ctx, req)
 	for {
 		response, err := it.Next()
 		switch {
@@ -84,21 +171,174 @@ func metricData(_ *cobra.Command, _ []string) error {
 		case err != nil:
 			return fmt.Errorf("failure getting list of metrics: %w", err)
 		default:
-			fmt.Println(protojson.Format(response)) //nolint:forbidigo // The data subcommand writes to stdout deliberately
+			if err := output(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Repeatedly list the time-series that match the request until the context is
+// cancelled, advancing the start of the request interval to the latest point
+// seen and calling the output function with only the points that have not been
+// seen before.
+func followTimeSeries(ctx context.Context, client *monitoring.MetricClient, req *monitoringpb.ListTimeSeriesRequest, pollInterval time.Duration, output func(*monitoringpb.TimeSeries) error) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	seen := newPointTracker(req.GetInterval().GetStartTime().AsTime())
+	for {
+		logger.V(2).Info("Polling for time-series", "start", req.GetInterval().GetStartTime().AsTime(), "end", req.GetInterval().GetEndTime().AsTime())
+		pollCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := listTimeSeries(pollCtx, client, req, func(series *monitoringpb.TimeSeries) error {
+			unseen := seen.unseenPoints(series)
+			if len(unseen.GetPoints()) == 0 {
+				return nil
+			}
+			return output(unseen)
+		})
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return err
+		}
+		seen.prune()
+		req.Interval.StartTime = timestamppb.New(seen.latest)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			req.Interval.EndTime = timestamppb.Now()
+		}
+	}
+}
+
+// Tracks the points that have been output while following time-series, so that
+// points repeated in the overlap between polling intervals are only output once.
+type pointTracker struct {
+	// The end time of the most recent point that has been seen.
+	latest time.Time
+	// The end times of points that have been seen, keyed by time-series identity
+	// and end time.
+	seen map[string]time.Time
+}
+
+func newPointTracker(start time.Time) *pointTracker {
+	return &pointTracker{
+		latest: start,
+		seen:   map[string]time.Time{},
+	}
+}
+
+// Returns a copy of the time-series with only the points that have not been
+// seen before, and marks those points as seen.
+func (p *pointTracker) unseenPoints(series *monitoringpb.TimeSeries) *monitoringpb.TimeSeries {
+	unseen, ok := proto.Clone(series).(*monitoringpb.TimeSeries)
+	if !ok {
+		return nil
+	}
+	unseen.Points = nil
+	id := seriesIdentity(series)
+	for _, point := range series.GetPoints() {
+		end := point.GetInterval().GetEndTime().AsTime()
+		key := id + "@" + end.Format(time.RFC3339Nano)
+		if _, ok := p.seen[key]; ok {
+			continue
+		}
+		p.seen[key] = end
+		if end.After(p.latest) {
+			p.latest = end
+		}
+		unseen.Points = append(unseen.Points, point)
+	}
+	return unseen
+}
+
+// Forget any points that are older than the latest point seen; they cannot be
+// returned by a request for an interval starting at the latest point.
+func (p *pointTracker) prune() {
+	for key, end := range p.seen {
+		if end.Before(p.latest) {
+			delete(p.seen, key)
 		}
 	}
 }
 
+// Returns a string that uniquely identifies the time-series by its metric and
+// monitored resource.
+func seriesIdentity(series *monitoringpb.TimeSeries) string {
+	return series.GetMetric().GetType() + "{" + formatLabels(series.GetMetric().GetLabels()) + "}/" +
+		series.GetResource().GetType() + "{" + formatLabels(series.GetResource().GetLabels()) + "}"
+}
+
+// Returns the time.Location for the IANA timezone name, defaulting to UTC if
+// the name is empty.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// Returns a CSV record for each point in the time-series, with the end time of
+// the point formatted as RFC3339 in the supplied location.
+func pointRecords(series *monitoringpb.TimeSeries, location *time.Location) [][]string {
+	records := make([][]string, 0, len(series.Points))
+	for _, point := range series.Points {
+		records = append(records, []string{
+			point.GetInterval().GetEndTime().AsTime().In(location).Format(time.RFC3339),
+			series.GetMetric().GetType(),
+			series.GetResource().GetType(),
+			typedValueString(point.GetValue()),
+		})
+	}
+	return records
+}
+
+// Returns a string representation of the typed value, or an empty string if the
+// value type is not supported.
+func typedValueString(value *monitoringpb.TypedValue) string {
+	switch v := value.GetValue().(type) {
+	case *monitoringpb.TypedValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *monitoringpb.TypedValue_Int64Value:
+		return strconv.FormatInt(v.Int64Value, 10)
+	case *monitoringpb.TypedValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *monitoringpb.TypedValue_StringValue:
+		return v.StringValue
+	case *monitoringpb.TypedValue_DistributionValue:
+		return strconv.FormatFloat(v.DistributionValue.GetMean(), 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
 // Attempt to parse the supplied string as RFC3339, and return a Timestamp that
 // is ready to use as a filter. The fallback value will be used if the string
 // is empty.
 func buildTimestamp(value string, fallback time.Time) (*timestamppb.Timestamp, error) {
+	ts, err := parseTime(value, fallback)
+	if err != nil {
+		return nil, err
+	}
+	return timestamppb.New(ts), nil
+}
+
+// Attempt to parse the supplied string as RFC3339, returning the fallback value
+// if the string is empty.
+func parseTime(value string, fallback time.Time) (time.Time, error) {
 	if value == "" {
-		return timestamppb.New(fallback), nil
+		return fallback, nil
 	}
 	ts, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse as RFC3339: %w", err)
+		return time.Time{}, fmt.Errorf("failed to parse as RFC3339: %w", err)
 	}
-	return timestamppb.New(ts), nil
+	return ts, nil
 }
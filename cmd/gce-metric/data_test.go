@@ -0,0 +1,135 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPointRecordsTimezone(t *testing.T) {
+	series := &monitoringpb.TimeSeries{
+		Points: []*monitoringpb.Point{
+			{
+				Interval: &monitoringpb.TimeInterval{
+					EndTime: &timestamppb.Timestamp{
+						Seconds: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC).Unix(),
+					},
+				},
+				Value: &monitoringpb.TypedValue{
+					Value: &monitoringpb.TypedValue_DoubleValue{
+						DoubleValue: 1.5,
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name        string
+		timezone    string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "default",
+			timezone: "",
+			expected: "2024-07-01T12:00:00Z",
+		},
+		{
+			name:     "utc",
+			timezone: "UTC",
+			expected: "2024-07-01T12:00:00Z",
+		},
+		{
+			name:     "los-angeles",
+			timezone: "America/Los_Angeles",
+			expected: "2024-07-01T05:00:00-07:00",
+		},
+		{
+			name:        "invalid",
+			timezone:    "Not/A_Zone",
+			expectError: true,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			location, err := loadTimezone(tst.timezone)
+			switch {
+			case tst.expectError && err == nil:
+				t.Fatalf("Expected loadTimezone to raise an error for %q", tst.timezone)
+			case tst.expectError:
+				return
+			case err != nil:
+				t.Fatalf("Unexpected error from loadTimezone: %v", err)
+			}
+			records := pointRecords(series, location)
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			if records[0][0] != tst.expected {
+				t.Errorf("Expected timestamp %q, got %q", tst.expected, records[0][0])
+			}
+			if records[0][3] != "1.5" {
+				t.Errorf("Expected value %q, got %q", "1.5", records[0][3])
+			}
+		})
+	}
+}
+
+func TestPointTrackerUnseenPoints(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	point := func(offset time.Duration) *monitoringpb.Point {
+		return &monitoringpb.Point{
+			Interval: &monitoringpb.TimeInterval{
+				EndTime: timestamppb.New(start.Add(offset)),
+			},
+			Value: &monitoringpb.TypedValue{
+				Value: &monitoringpb.TypedValue_DoubleValue{
+					DoubleValue: offset.Seconds(),
+				},
+			},
+		}
+	}
+	series := func(nodeID string, points ...*monitoringpb.Point) *monitoringpb.TimeSeries {
+		return &monitoringpb.TimeSeries{
+			Metric: &metricpb.Metric{
+				Type: "custom.googleapis.com/test",
+			},
+			Resource: &monitoredrespb.MonitoredResource{
+				Type: "generic_node",
+				Labels: map[string]string{
+					"node_id": nodeID,
+				},
+			},
+			Points: points,
+		}
+	}
+	tracker := newPointTracker(start)
+	if unseen := tracker.unseenPoints(series("a", point(time.Minute), point(0))); len(unseen.GetPoints()) != 2 {
+		t.Errorf("Expected 2 unseen points on first poll, got %d", len(unseen.GetPoints()))
+	}
+	if unseen := tracker.unseenPoints(series("b", point(time.Minute))); len(unseen.GetPoints()) != 1 {
+		t.Errorf("Expected 1 unseen point for a different time-series, got %d", len(unseen.GetPoints()))
+	}
+	if !tracker.latest.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected latest to be %v, got %v", start.Add(time.Minute), tracker.latest)
+	}
+	tracker.prune()
+	if len(tracker.seen) != 2 {
+		t.Errorf("Expected 2 points to remain after pruning, got %d", len(tracker.seen))
+	}
+	unseen := tracker.unseenPoints(series("a", point(2*time.Minute), point(time.Minute)))
+	if len(unseen.GetPoints()) != 1 {
+		t.Fatalf("Expected 1 unseen point on second poll, got %d", len(unseen.GetPoints()))
+	}
+	if value := unseen.GetPoints()[0].GetValue().GetDoubleValue(); value != 120 {
+		t.Errorf("Expected unseen point value 120, got %v", value)
+	}
+}
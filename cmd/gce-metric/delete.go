@@ -2,49 +2,131 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sync"
 	"time"
 
-	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/memes/gce-metric/pkg/pipeline"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// The default number of metric descriptors that are deleted concurrently.
+const DefaultDeleteConcurrency = 4
+
+// The subset of the Cloud Monitoring metric client needed to delete metric
+// descriptors, which allows a fake to be substituted in tests.
+type metricDescriptorDeleter interface {
+	DeleteMetricDescriptor(ctx context.Context, req *monitoringpb.DeleteMetricDescriptorRequest, opts ...gax.CallOption) error
+}
+
 func newDeleteCommand() *cobra.Command {
 	deleteCmd := &cobra.Command{
-		Use:   "delete [--verbose] [--pretty] [--project ID] NAME ...",
+		Use:   "delete [--verbose] [--pretty] [--project ID] [--concurrency N] [--dry-run] NAME ...",
 		Short: "Delete the named time-series metrics.",
-		Long: `Delete Google Cloud time-series metrics from a GCP project. One or more fully-qualified metric names (e.g. "custom.googleapis.com/my-metric") must be provided, and each will be deleted in turn.
+		Long: `Delete Google Cloud time-series metrics from a GCP project. One or more fully-qualified metric names (e.g. "custom.googleapis.com/my-metric") must be provided, and each will be deleted; up to --concurrency metrics are deleted at the same time. A failure to delete one metric does not prevent deletion of the others, and every failure is reported when all the deletions have been attempted. Use the --dry-run flag to print the names of the metric descriptors that would be deleted, without deleting them.
 
 NOTE: This command can delete any metric given, including built-in Google Cloud metrics, provided the caller has the appropriate permissions.`,
 		Example: AppName + "delete --verbose --project ID custom.googleapis.com/my-metric",
+		PreRunE: bindDeleteFlags,
 		RunE:    deleteMetrics,
 		Args:    cobra.MinimumNArgs(1),
+		// Offer the custom metric types in the project as completions.
+		ValidArgsFunction: metricTypeCompletion(listCustomMetricTypes, CompletionTimeout),
 	}
+	deleteCmd.PersistentFlags().Int(ConcurrencyFlagName, DefaultDeleteConcurrency, "sets the number of metrics that are deleted concurrently")
+	deleteCmd.PersistentFlags().Bool(DryRunFlagName, false, "print the name of each metric descriptor that would be deleted, without deleting it")
+	addConnectionFlags(deleteCmd)
 	return deleteCmd
 }
 
+// Bind the concurrency, dry-run, and connection flags of the delete command to
+// viper. The generator commands share the flag names, so binding must be
+// deferred until the command to execute is known.
+func bindDeleteFlags(cmd *cobra.Command, _ []string) error {
+	for _, name := range []string{ConcurrencyFlagName, DryRunFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return bindConnectionFlags(cmd)
+}
+
 func deleteMetrics(_ *cobra.Command, args []string) error {
 	logger.V(0).Info("Preparing delete client")
+	concurrency := viper.GetInt(ConcurrencyFlagName)
+	if concurrency < 1 {
+		return fmt.Errorf("%w: %d", pipeline.ErrInvalidConcurrency, concurrency)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	projectID, err := effectiveProjectID(ctx)
-	if err != nil {
-		return err
-	}
-	client, err := monitoring.NewMetricClient(ctx)
-	if err != nil {
-		return fmt.Errorf("failure creating new metric client: %w", err)
-	}
-	defer client.Close()
-	for _, metricType := range args {
-		request := &monitoringpb.DeleteMetricDescriptorRequest{
-			Name: "projects/" + projectID + "/metricDescriptors/" + metricType,
+	return runDelete(ctx, createMetricClient, args, concurrency, viper.GetBool(DryRunFlagName), os.Stdout)
+}
+
+// Delete the metric descriptors of each metric type from the effective project,
+// using a client returned by newClient. When dryRun is true the name of each
+// metric descriptor is written to output instead, and no client is created.
+func runDelete[C interface {
+	metricDescriptorDeleter
+	io.Closer
+}](ctx context.Context, newClient func(context.Context) (C, error), metricTypes []string, concurrency int, dryRun bool, output io.Writer) error {
+	if dryRun {
+		projectID, err := effectiveProjectID(ctx)
+		if err != nil {
+			return err
 		}
-		if err := client.DeleteMetricDescriptor(ctx, request); err != nil {
-			return fmt.Errorf("failure deleting metric descriptor: %w", err)
+		for _, metricType := range metricTypes {
+			if _, err := fmt.Fprintln(output, metricDescriptorName(projectID, metricType)); err != nil {
+				return fmt.Errorf("failure writing metric descriptor name: %w", err)
+			}
 		}
-		logger.V(0).Info("Custom metric deleted", "metricType", metricType)
+		return nil
 	}
-	return nil
+	return withProjectClient(ctx, newClient, func(client C, projectID string) error {
+		return deleteMetricDescriptors(ctx, client, projectID, metricTypes, concurrency)
+	})
+}
+
+// Delete the metric descriptors of each metric type from the project, using a
+// pool of concurrency workers. Every metric type is attempted even if some
+// deletions fail, and the returned error joins the failures in the order of the
+// metric types.
+func deleteMetricDescriptors(ctx context.Context, client metricDescriptorDeleter, projectID string, metricTypes []string, concurrency int) error {
+	errs := make([]error, len(metricTypes))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(metricTypes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				metricType := metricTypes[i]
+				request := &monitoringpb.DeleteMetricDescriptorRequest{
+					Name: metricDescriptorName(projectID, metricType),
+				}
+				if err := client.DeleteMetricDescriptor(ctx, request); err != nil {
+					logger.Error(err, "Failure deleting metric descriptor", "metricType", metricType)
+					errs[i] = fmt.Errorf("failure deleting metric descriptor %s: %w", metricType, err)
+					continue
+				}
+				logger.V(0).Info("Custom metric deleted", "metricType", metricType)
+			}
+		}()
+	}
+	for i := range metricTypes {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Returns the resource name of the metric descriptor for the metric type.
+func metricDescriptorName(projectID, metricType string) string {
+	return "projects/" + projectID + "/metricDescriptors/" + metricType
 }
@@ -0,0 +1,142 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/spf13/viper"
+)
+
+var errTestDelete = errors.New("test delete failed")
+
+// A fake metric descriptor client that records the name of each descriptor it
+// is asked to delete, and fails for names that end with failSuffix.
+type fakeDescriptorDeleter struct {
+	mu         sync.Mutex
+	failSuffix string
+	names      []string
+}
+
+func (f *fakeDescriptorDeleter) DeleteMetricDescriptor(_ context.Context, req *monitoringpb.DeleteMetricDescriptorRequest, _ ...gax.CallOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.names = append(f.names, req.GetName())
+	if strings.HasSuffix(req.GetName(), f.failSuffix) {
+		return errTestDelete
+	}
+	return nil
+}
+
+func (f *fakeDescriptorDeleter) Close() error {
+	return nil
+}
+
+func TestDeleteMetricDescriptors(t *testing.T) {
+	metricTypes := []string{"custom.googleapis.com/a", "custom.googleapis.com/b", "custom.googleapis.com/fail", "custom.googleapis.com/c", "custom.googleapis.com/d"}
+	tests := []struct {
+		name        string
+		concurrency int
+		failSuffix  string
+		expectedErr error
+	}{
+		{
+			name:        "serial",
+			concurrency: 1,
+			failSuffix:  "none",
+		},
+		{
+			name:        "concurrent",
+			concurrency: 3,
+			failSuffix:  "none",
+		},
+		{
+			name:        "serial-failure",
+			concurrency: 1,
+			failSuffix:  "/fail",
+			expectedErr: errTestDelete,
+		},
+		{
+			name:        "concurrent-failure",
+			concurrency: 10,
+			failSuffix:  "/fail",
+			expectedErr: errTestDelete,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := &fakeDescriptorDeleter{failSuffix: tst.failSuffix}
+			err := deleteMetricDescriptors(context.Background(), client, "test-project", metricTypes, tst.concurrency)
+			switch {
+			case tst.expectedErr != nil && !errors.Is(err, tst.expectedErr):
+				t.Errorf("Expected deleteMetricDescriptors to raise %v, got %v", tst.expectedErr, err)
+			case tst.expectedErr != nil && !strings.Contains(err.Error(), "custom.googleapis.com/fail"):
+				t.Errorf("Expected error to name the failed metric, got %v", err)
+			case tst.expectedErr == nil && err != nil:
+				t.Errorf("deleteMetricDescriptors raised an unexpected error: %v", err)
+			}
+			expected := make([]string, 0, len(metricTypes))
+			for _, metricType := range metricTypes {
+				expected = append(expected, "projects/test-project/metricDescriptors/"+metricType)
+			}
+			slices.Sort(expected)
+			names := slices.Sorted(slices.Values(client.names))
+			if !slices.Equal(names, expected) {
+				t.Errorf("Expected deletion of %v, got %v", expected, names)
+			}
+		})
+	}
+}
+
+//nolint:paralleltest // The project identifier is read from the global viper instance
+func TestRunDelete(t *testing.T) {
+	viper.Set(ProjectIDFlagName, "test-project")
+	t.Cleanup(func() { viper.Set(ProjectIDFlagName, "") })
+	metricTypes := []string{"custom.googleapis.com/a", "custom.googleapis.com/b"}
+	expected := []string{"projects/test-project/metricDescriptors/custom.googleapis.com/a", "projects/test-project/metricDescriptors/custom.googleapis.com/b"}
+	tests := []struct {
+		name            string
+		dryRun          bool
+		expectedOutput  []string
+		expectedDeletes []string
+	}{
+		{
+			name:            "delete",
+			expectedOutput:  []string{},
+			expectedDeletes: expected,
+		},
+		{
+			name:            "dry-run",
+			dryRun:          true,
+			expectedOutput:  expected,
+			expectedDeletes: []string{},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			client := &fakeDescriptorDeleter{failSuffix: "none", names: []string{}}
+			var output bytes.Buffer
+			err := runDelete(context.Background(), func(_ context.Context) (*fakeDescriptorDeleter, error) {
+				return client, nil
+			}, metricTypes, 1, tst.dryRun, &output)
+			if err != nil {
+				t.Fatalf("runDelete raised an unexpected error: %v", err)
+			}
+			if lines := strings.Fields(output.String()); !slices.Equal(lines, tst.expectedOutput) {
+				t.Errorf("Expected output %v, got %v", tst.expectedOutput, lines)
+			}
+			if !slices.Equal(client.names, tst.expectedDeletes) {
+				t.Errorf("Expected deletion of %v, got %v", tst.expectedDeletes, client.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	MetricTypeFlagName   = "metric-type"
+	ResourceTypeFlagName = "resource-type"
+	LabelFlagName        = "label"
+	// The default filter matches any metric with the recommended prefix for
+	// custom metrics.
+	DefaultFilter = `metric.type = starts_with("custom.googleapis.com/")`
+)
+
+var ErrInvalidLabelFilter = errors.New("label filter must be in the form key=value")
+
+// Add the filter flag, and the convenience flags that compose a filter, to the
+// command.
+func addFilterFlags(cmd *cobra.Command, usage string) {
+	cmd.PersistentFlags().StringArray(FilterFlagName, []string{DefaultFilter}, usage+"; may be repeated to match any of the filters, and takes precedence over --metric-type, --resource-type, and --label")
+	cmd.PersistentFlags().String(MetricTypeFlagName, "", "build a filter that matches metrics of this type exactly")
+	cmd.PersistentFlags().String(ResourceTypeFlagName, "", "build a filter that matches time-series with this monitored resource type")
+	cmd.PersistentFlags().StringArray(LabelFlagName, []string{}, "build a filter that matches time-series with this metric label, as key=value; may be repeated")
+	// The flag was added above, so registration cannot fail.
+	_ = cmd.RegisterFlagCompletionFunc(MetricTypeFlagName, metricTypeCompletion(listCustomMetricTypes, CompletionTimeout))
+}
+
+// Bind the filter flags of the executing command to viper.
+func bindFilterFlags(cmd *cobra.Command) error {
+	for _, name := range []string{FilterFlagName, MetricTypeFlagName, ResourceTypeFlagName, LabelFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the filter to use for the executing command. An explicit filter takes
+// precedence, followed by a filter composed from the convenience flags, and
+// finally the default filter.
+func effectiveFilter() (string, error) {
+	if viper.IsSet(FilterFlagName) {
+		// A filter set in the environment or a configuration file is a single
+		// string, which must not be split on whitespace.
+		if filter, ok := viper.Get(FilterFlagName).(string); ok {
+			return filter, nil
+		}
+		return combineFilters(viper.GetStringSlice(FilterFlagName)), nil
+	}
+	filter, err := composeFilter(viper.GetString(MetricTypeFlagName), viper.GetString(ResourceTypeFlagName), viper.GetStringSlice(LabelFlagName))
+	if err != nil {
+		return "", err
+	}
+	if filter == "" {
+		return DefaultFilter, nil
+	}
+	return filter, nil
+}
+
+// Returns a Cloud Monitoring filter that matches the metric type, resource type,
+// and metric labels given; empty values are omitted from the filter, and an
+// empty string is returned if nothing is given.
+func composeFilter(metricType, resourceType string, labels []string) (string, error) {
+	clauses := []string{}
+	if metricType != "" {
+		clauses = append(clauses, "metric.type = "+strconv.Quote(metricType))
+	}
+	if resourceType != "" {
+		clauses = append(clauses, "resource.type = "+strconv.Quote(resourceType))
+	}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return "", fmt.Errorf("%w: %q", ErrInvalidLabelFilter, label)
+		}
+		clauses = append(clauses, "metric.labels."+key+" = "+strconv.Quote(value))
+	}
+	return strings.Join(clauses, " AND "), nil
+}
+
+// Returns a Cloud Monitoring filter that matches any of the filters given; a
+// single filter is returned unchanged.
+func combineFilters(filters []string) string {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	clauses := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		clauses = append(clauses, "("+filter+")")
+	}
+	return strings.Join(clauses, " OR ")
+}
@@ -0,0 +1,119 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComposeFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		metricType   string
+		resourceType string
+		labels       []string
+		expected     string
+		expectedErr  error
+	}{
+		{
+			name:     "empty",
+			expected: "",
+		},
+		{
+			name:       "metric-type",
+			metricType: "custom.googleapis.com/test",
+			expected:   `metric.type = "custom.googleapis.com/test"`,
+		},
+		{
+			name:         "resource-type",
+			resourceType: "gce_instance",
+			expected:     `resource.type = "gce_instance"`,
+		},
+		{
+			name:     "label",
+			labels:   []string{"node_id=abc"},
+			expected: `metric.labels.node_id = "abc"`,
+		},
+		{
+			name:     "label-empty-value",
+			labels:   []string{"node_id="},
+			expected: `metric.labels.node_id = ""`,
+		},
+		{
+			name:     "label-quoted-value",
+			labels:   []string{`name=a "b" c=d`},
+			expected: `metric.labels.name = "a \"b\" c=d"`,
+		},
+		{
+			name:         "all",
+			metricType:   "custom.googleapis.com/test",
+			resourceType: "generic_node",
+			labels:       []string{"node_id=abc", "zone=us-west1-a"},
+			expected:     `metric.type = "custom.googleapis.com/test" AND resource.type = "generic_node" AND metric.labels.node_id = "abc" AND metric.labels.zone = "us-west1-a"`,
+		},
+		{
+			name:        "label-missing-separator",
+			labels:      []string{"node_id"},
+			expectedErr: ErrInvalidLabelFilter,
+		},
+		{
+			name:        "label-missing-key",
+			labels:      []string{"=abc"},
+			expectedErr: ErrInvalidLabelFilter,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := composeFilter(tst.metricType, tst.resourceType, tst.labels)
+			switch {
+			case tst.expectedErr != nil && !errors.Is(err, tst.expectedErr):
+				t.Errorf("Expected error %v, got %v", tst.expectedErr, err)
+			case tst.expectedErr == nil && err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case result != tst.expected:
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
+
+func TestCombineFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []string
+		expected string
+	}{
+		{
+			name:     "empty",
+			filters:  []string{},
+			expected: "",
+		},
+		{
+			name:     "single",
+			filters:  []string{`metric.type = "custom.googleapis.com/a"`},
+			expected: `metric.type = "custom.googleapis.com/a"`,
+		},
+		{
+			name:     "two",
+			filters:  []string{`metric.type = "custom.googleapis.com/a"`, `metric.type = "custom.googleapis.com/b"`},
+			expected: `(metric.type = "custom.googleapis.com/a") OR (metric.type = "custom.googleapis.com/b")`,
+		},
+		{
+			name:     "compound",
+			filters:  []string{`metric.type = "a" AND resource.type = "global"`, `metric.type = "b"`},
+			expected: `(metric.type = "a" AND resource.type = "global") OR (metric.type = "b")`,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if result := combineFilters(tst.filters); result != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
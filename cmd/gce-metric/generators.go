@@ -2,25 +2,123 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/memes/gce-metric/pkg/generators"
 	"github.com/memes/gce-metric/pkg/pipeline"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	distributionpb "google.golang.org/genproto/googleapis/api/distribution"
 )
 
 const (
-	SampleFlagName  = "sample"
-	PeriodFlagName  = "period"
-	FloorFlagName   = "floor"
-	CeilingFlagName = "ceiling"
-	IntegerFlagName = "integer"
-	DryRunFlagName  = "dry-run"
+	SampleFlagName             = "sample"
+	PeriodFlagName             = "period"
+	FloorFlagName              = "floor"
+	CeilingFlagName            = "ceiling"
+	IntegerFlagName            = "integer"
+	DryRunFlagName             = "dry-run"
+	LocationFlagName           = "location"
+	NamespaceFlagName          = "namespace"
+	ValidateOnlyFlagName       = "validate-only"
+	HealthAddrFlagName         = "health-addr"
+	EmitImmediatelyFlagName    = "emit-immediately"
+	SequenceLabelFlagName      = "sequence-label"
+	DistGrowthFactorFlagName   = "dist-growth-factor"
+	DistScaleFlagName          = "dist-scale"
+	DistNumBucketsFlagName     = "dist-num-buckets"
+	DistBoundsFlagName         = "dist-bounds"
+	RelativeToFlagName         = "relative-to"
+	PromoteLabelFlagName       = "promote-resource-label"
+	UserAgentFlagName          = "user-agent"
+	ConcurrencyFlagName        = "concurrency"
+	AutoPrefixFlagName         = "auto-prefix"
+	HostnameLabelFlagName      = "append-hostname-label"
+	KeepaliveFlagName          = "keepalive"
+	RPCTimeoutFlagName         = "max-rpc-timeout"
+	QuietFlagName              = "quiet"
+	ProgressFlagName           = "progress"
+	DryRunFormatFlagName       = "dry-run-format"
+	MovingAverageFlagName      = "moving-average"
+	RegionFlagName             = "region"
+	DisplayNameFlagName        = "display-name"
+	DescriptionFlagName        = "description"
+	DropLabelFlagName          = "drop-label"
+	ProxyFlagName              = "proxy"
+	LatencySummaryFlagName     = "latency-summary"
+	SkipMetadataFlagName       = "skip-metadata"
+	DeterministicFlagName      = "deterministic"
+	WriterIdentityFlagName     = "writer-identity-label"
+	RecreateDescriptorFlagName = "recreate-descriptor"
+	EndpointFlagName           = "endpoint"
+	CACertFileFlagName         = "ca-cert-file"
+	RemoteWriteURLFlagName     = "remote-write-url"
+	InfluxURLFlagName          = "influx-url"
+	InfluxBucketFlagName       = "influx-bucket"
+	InfluxOrgFlagName          = "influx-org"
+	InfluxTokenFlagName        = "influx-token"
+	DeployVersionFlagName      = "deploy-version"
+	ActiveWindowFlagName       = "active-window"
+	ActiveTimezoneFlagName     = "active-window-timezone"
+	ActiveDaysFlagName         = "active-window-days"
+	DailyAmplitudeFlagName     = "daily-amplitude"
+	WeeklyAmplitudeFlagName    = "weekly-amplitude"
+	OnceFlagName               = "once"
+	StateFileFlagName          = "state-file"
+	MinSeriesIntervalFlagName  = "min-series-interval"
+	RiseFractionFlagName       = "rise-fraction"
+	RESTTransportFlagName      = "rest-transport"
+	InstanceIDFlagName         = "instance-id"
+	ZoneFlagName               = "zone"
+	DecimalsFlagName           = "decimals"
+	LogRoundFlagName           = "log-round"
+	DriftFlagName              = "drift"
+	GMPFlagName                = "gmp"
+	HoldFractionFlagName       = "hold-fraction"
+	WallClockPhaseFlagName     = "wall-clock-phase"
+	BatchSizeFlagName          = "batch-size"
+	PartialFailureFlagName     = "continue-on-partial-failure"
+	CloseTimeoutFlagName       = "close-timeout"
+	// The metric label key used when the hostname label flag is given without a
+	// value.
+	DefaultHostnameLabel = "host"
+	// The deploy version flag value used when the flag is given without a
+	// value, to detect the version from the environment.
+	DetectDeployVersion = "detect"
+	// Configuration keys for labels given as a JSON object; these are intended
+	// to be set through the GCE_METRIC_METRIC_LABELS_JSON and
+	// GCE_METRIC_RESOURCE_LABELS_JSON environment variables in containers.
+	MetricLabelsJSONKey   = "metric-labels-json"
+	ResourceLabelsJSONKey = "resource-labels-json"
+)
+
+var (
+	ErrConflictingDistributionFlags = errors.New("explicit distribution bounds cannot be combined with exponential bucket flags")
+	ErrIntegerDistribution          = errors.New("integer values cannot be combined with distribution values")
+	ErrTransformedDistribution      = errors.New("drift, moving average, or rounded values cannot be combined with distribution values")
+	ErrPercentageWithoutBaseline    = errors.New("percentage floor or ceiling requires a baseline file")
+	ErrInvalidJSONLabels            = errors.New("labels must be a JSON object with string values")
+	ErrConflictingEndpointFlags     = errors.New("an explicit endpoint cannot be combined with a regional endpoint")
+	ErrDeterministicWithoutDryRun   = errors.New("deterministic timestamps can only be used with dry-run")
+	ErrStateFileWithDeterministic   = errors.New("a state file cannot be used with deterministic timestamps")
+	ErrRecreateNotConfirmed         = errors.New("metric descriptor recreation was not confirmed")
+	ErrConflictingEmitterFlags      = errors.New("only one of dry-run, remote-write, Managed Service for Prometheus, or InfluxDB output can be used")
+	ErrMissingInfluxFlags           = errors.New("InfluxDB output requires a bucket, organization, and token")
+	ErrInvalidAmplitude             = errors.New("seasonal amplitude must be between 0 and 1")
+	ErrInvalidRate                  = errors.New("counter rate must not be negative")
+	ErrInvalidWeekday               = errors.New("weekday must be a day name or three letter abbreviation, e.g. monday or mon")
+	ErrActiveWindowOptionsOnly      = errors.New("active window timezone and days require an active window")
+	ErrDeployVersionNotDetected     = errors.New("deploy version could not be detected; set one of DEPLOY_VERSION, GIT_COMMIT, GITHUB_SHA, CI_COMMIT_SHA, or K_REVISION")
 )
 
 func newSawtoothCommand() *cobra.Command {
@@ -29,11 +127,12 @@ func newSawtoothCommand() *cobra.Command {
 		Short:   "Generate synthetic metrics from a sawtooth function",
 		Long:    "Generate synthetic metric time-series data-points that approximate a sawtooth pattern, and send them to Google Cloud Monitoring to trigger scaling events or for other purposes.",
 		Example: AppName + "sawtooth --project ID custom.googleapis.com/syntheticScaler/cpu",
-		PreRunE: bindViperFlags,
+		PreRunE: bindWaveformFlags,
 		RunE:    generatorMain,
 		Args:    cobra.MinimumNArgs(1),
 	}
 	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
 	return cmd
 }
 
@@ -43,11 +142,12 @@ func newSineCommand() *cobra.Command {
 		Short:   "Generate synthetic metrics from a sine function",
 		Long:    "Generate synthetic metric time-series data-points that approximate a sine pattern, and send them to Google Cloud Monitoring to trigger scaling events or for other purposes.",
 		Example: AppName + "sine --project ID custom.googleapis.com/syntheticScaler/cpu",
-		PreRunE: bindViperFlags,
+		PreRunE: bindWaveformFlags,
 		RunE:    generatorMain,
 		Args:    cobra.MinimumNArgs(1),
 	}
 	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
 	return cmd
 }
 
@@ -57,11 +157,12 @@ func newSquareCommand() *cobra.Command {
 		Short:   "Generate synthetic metrics from a square function",
 		Long:    "Generate synthetic metric time-series data-points that approximate a square pattern, and send them to Google Cloud Monitoring to trigger scaling events or for other purposes.",
 		Example: AppName + "square --project ID custom.googleapis.com/syntheticScaler/cpu",
-		PreRunE: bindViperFlags,
+		PreRunE: bindWaveformFlags,
 		RunE:    generatorMain,
 		Args:    cobra.MinimumNArgs(1),
 	}
 	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
 	return cmd
 }
 
@@ -71,24 +172,179 @@ func newTriangleCommand() *cobra.Command {
 		Short:   "Generate synthetic metrics from a triangle function",
 		Long:    "Generate synthetic metric time-series data-points that approximate a triangle pattern, and send them to Google Cloud Monitoring to trigger scaling events or for other purposes.",
 		Example: AppName + "triangle --project ID custom.googleapis.com/syntheticScaler/cpu",
-		PreRunE: bindViperFlags,
+		PreRunE: bindWaveformFlags,
+		RunE:    generatorMain,
+		Args:    cobra.MinimumNArgs(1),
+	}
+	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
+	return cmd
+}
+
+func newRampCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ramp [flags] NAME",
+		Short:   "Generate synthetic metrics that ramp up once and hold",
+		Long:    "Generate synthetic metric time-series data-points that rise linearly from the floor to the ceiling over one period, then hold at the ceiling until interrupted, and send them to Google Cloud Monitoring to trigger a sustained scaling event or for other purposes.",
+		Example: AppName + " ramp --project ID --period 10m custom.googleapis.com/syntheticScaler/cpu",
+		PreRunE: bindWaveformFlags,
+		RunE:    generatorMain,
+		Args:    cobra.MinimumNArgs(1),
+	}
+	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
+	return cmd
+}
+
+func newTrapezoidCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "trapezoid [flags] NAME",
+		Short:   "Generate synthetic metrics that follow a trapezoid cycle",
+		Long:    "Generate synthetic metric time-series data-points that rise linearly from the floor to the ceiling, hold at the ceiling, fall linearly back to the floor, and hold at the floor for the rest of each period, and send them to Google Cloud Monitoring to simulate sustained load with ramps between levels, or for other purposes. The rise and fall each take the rise fraction of the period, and the hold at the ceiling takes the hold fraction.",
+		Example: AppName + " trapezoid --project ID --rise-fraction 0.1 --hold-fraction 0.5 custom.googleapis.com/syntheticScaler/cpu",
+		PreRunE: bindTrapezoidFlags,
 		RunE:    generatorMain,
 		Args:    cobra.MinimumNArgs(1),
 	}
 	addGeneratorFlags(cmd)
+	addWaveformFlags(cmd)
+	addTrapezoidShapeFlags(cmd)
 	return cmd
 }
 
+func bindTrapezoidFlags(cmd *cobra.Command, args []string) error {
+	if err := bindTrapezoidShapeFlags(cmd); err != nil {
+		return err
+	}
+	return bindWaveformFlags(cmd, args)
+}
+
+// Add the flags that set the shape of a trapezoid waveform.
+func addTrapezoidShapeFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Float64(RiseFractionFlagName, generators.DefaultRiseFraction, "sets the fraction of the period taken to rise from floor to ceiling, and to fall back again")
+	cmd.PersistentFlags().Float64(HoldFractionFlagName, generators.DefaultHoldFraction, "sets the fraction of the period that values hold at the ceiling; twice the rise fraction plus the hold fraction must not exceed 1")
+}
+
+// Bind the trapezoid shape flags of the executing command to viper.
+func bindTrapezoidShapeFlags(cmd *cobra.Command) error {
+	for _, name := range []string{RiseFractionFlagName, HoldFractionFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return nil
+}
+
 func addGeneratorFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().Duration(SampleFlagName, 60*time.Second, "sets the interval between sending metrics to Google Monitoring, must be valid Go duration string")
 	cmd.PersistentFlags().Duration(PeriodFlagName, 10*time.Minute, "sets the duration for one complete cycle from floor to ceiling, must be valid Go duration string")
-	cmd.PersistentFlags().Float64(FloorFlagName, 1.0, "sets the minimum value for the cycles, can be an integer or floating point value")
-	cmd.PersistentFlags().Float64(CeilingFlagName, 10.0, "sets the maximum value for the cycles, can be an integer of floating point value")
+	cmd.PersistentFlags().String(FloorFlagName, "1.0", "sets the minimum value for the cycles, can be an integer or floating point value, or a percentage of the --relative-to baseline, e.g. 20%")
+	cmd.PersistentFlags().String(CeilingFlagName, "10.0", "sets the maximum value for the cycles, can be an integer of floating point value, or a percentage of the --relative-to baseline, e.g. 80%")
+	cmd.PersistentFlags().String(RelativeToFlagName, "", "if set, read a baseline value from this file; floor and ceiling values given as percentages are scaled to the baseline")
+	cmd.PersistentFlags().Float64(DailyAmplitudeFlagName, 0.0, "if greater than 0, scale values by a daily cycle between 1-N at midnight and 1+N at noon, local time; must be between 0 and 1")
+	cmd.PersistentFlags().Float64(WeeklyAmplitudeFlagName, 0.0, "if greater than 0, scale values by a weekly cycle between 1-N at the start of Sunday and 1+N at noon on Wednesday, local time; must be between 0 and 1")
+	addPipelineFlags(cmd)
+}
+
+// Add the flags that control how values are turned into time-series and written
+// to Google Cloud Monitoring; these are shared by every command that writes
+// values, regardless of where the values come from.
+func addPipelineFlags(cmd *cobra.Command) {
+	addConnectionFlags(cmd)
 	cmd.PersistentFlags().Bool(IntegerFlagName, false, "forces the generated metrics to be integers, making them less smooth and more step-like")
+	cmd.PersistentFlags().Int(MovingAverageFlagName, 0, "if greater than 1, send the mean of the last N values instead of each value, to smooth jumpy integer or noisy values")
+	cmd.PersistentFlags().Float64(DriftFlagName, 0.0, "if not zero, add an offset to values that grows by this amount every hour, to simulate a slowly drifting baseline such as a memory leak")
+	cmd.PersistentFlags().String(DecimalsFlagName, "", "if set, round floating point values to this number of decimal places; a negative number rounds to tens, hundreds, etc.")
+	cmd.PersistentFlags().Bool(LogRoundFlagName, false, "round floating point values to the nearest integer when they are logged, without changing the values that are sent")
 	cmd.PersistentFlags().Bool(DryRunFlagName, false, "report metrics to stdout for review, without sending to Google Cloud Monitoring; for the curious!")
+	cmd.PersistentFlags().String(DryRunFormatFlagName, string(pipeline.WriterFormatText), "sets the format of the time-series requests written by --dry-run; one of text, protojson, json, summary, or otlp-json")
+	cmd.PersistentFlags().Bool(QuietFlagName, false, "with --dry-run, don't write the time-series requests to stdout; use with --verbose to log the value and time of each point instead")
+	cmd.PersistentFlags().String(LocationFlagName, pipeline.DefaultLocation, "sets the location label of generic_node resources used when not running on Google Cloud")
+	cmd.PersistentFlags().String(NamespaceFlagName, pipeline.DefaultNamespace, "sets the namespace label of generic_node resources used when not running on Google Cloud")
+	cmd.PersistentFlags().Bool(SkipMetadataFlagName, false, "never query the GCE metadata server, and use a generic_node resource even when running on Google Cloud; requires --project")
+	cmd.PersistentFlags().String(InstanceIDFlagName, "", "if set, use this instance identifier in the gce_instance or gke_container resource instead of the value from the GCE metadata server; ignored when not running on Google Cloud")
+	cmd.PersistentFlags().String(ZoneFlagName, "", "if set, use this zone in the gce_instance or gke_container resource instead of the value from the GCE metadata server; ignored when not running on Google Cloud")
+	cmd.PersistentFlags().String(ResourceTypeFlagName, "", "if set to 'global', use the project-scoped global monitored resource instead of detecting the resource from the environment")
+	cmd.PersistentFlags().Bool(RecreateDescriptorFlagName, false, "delete the metric descriptor and create it again before the first write, when the existing descriptor is incompatible; this deletes all existing data for the metric, and must be confirmed")
+	cmd.PersistentFlags().String(DisplayNameFlagName, "", "if set, create the metric descriptor with this display name before the first write, to make the metric easier to find in the Cloud Console")
+	cmd.PersistentFlags().String(DescriptionFlagName, "", "if set, create the metric descriptor with this description before the first write")
+	cmd.PersistentFlags().Bool(RESTTransportFlagName, false, "send time-series to Google Cloud Monitoring with the JSON REST API instead of gRPC, for networks that block gRPC; the metric descriptor is not created with --display-name or --description, and --proxy, --ca-cert-file, and --keepalive are ignored")
+	cmd.PersistentFlags().String(RemoteWriteURLFlagName, "", "if set, send samples to the Prometheus remote-write endpoint at this URL instead of Google Cloud Monitoring, e.g. http://mimir.example.com/api/v1/push")
+	cmd.PersistentFlags().Bool(GMPFlagName, false, "send samples to Google Cloud Managed Service for Prometheus with remote-write instead of creating Cloud Monitoring time-series; metric and resource labels become Prometheus labels")
+	cmd.PersistentFlags().String(InfluxURLFlagName, "", "if set, write values to the InfluxDB v2 server at this URL instead of Google Cloud Monitoring, e.g. http://influxdb.example.com:8086; requires --influx-bucket, --influx-org, and --influx-token")
+	cmd.PersistentFlags().String(InfluxBucketFlagName, "", "sets the InfluxDB bucket to write values to")
+	cmd.PersistentFlags().String(InfluxOrgFlagName, "", "sets the InfluxDB organization that owns the bucket")
+	cmd.PersistentFlags().String(InfluxTokenFlagName, "", "sets the InfluxDB API token; prefer the GCE_METRIC_INFLUX_TOKEN environment variable to keep the token out of process listings")
+	cmd.PersistentFlags().String(ActiveWindowFlagName, "", "if set, only emit values with a time of day inside this window, in the form HH:MM-HH:MM, e.g. 09:00-17:00 or 22:00-02:00 for an overnight window")
+	cmd.PersistentFlags().String(ActiveTimezoneFlagName, "", "sets the IANA timezone name of the active window times, e.g. America/New_York; default is UTC")
+	cmd.PersistentFlags().StringSlice(ActiveDaysFlagName, nil, "if set, the active window only starts on these days, e.g. mon,tue,wed,thu,fri")
+	cmd.PersistentFlags().Duration(RPCTimeoutFlagName, pipeline.DefaultRPCTimeout, "sets the maximum duration of each request to write time-series to Google Cloud Monitoring; 0 removes the limit")
+	cmd.PersistentFlags().Duration(CloseTimeoutFlagName, pipeline.DefaultCloseTimeout, "sets the maximum duration to wait for the connection to Google Cloud Monitoring to close on exit; 0 removes the limit")
+	cmd.PersistentFlags().Duration(MinSeriesIntervalFlagName, 0, "if set, drop points that are less than this duration after the last point written to the same time-series, instead of sending points that Google Cloud Monitoring will reject, e.g. 10s when --sample is shorter")
+	cmd.PersistentFlags().Duration(KeepaliveFlagName, 0, "if set, send keepalive pings on the Google Cloud Monitoring connection after it has been idle for this duration, so it is not dropped between infrequent samples; 0 disables keepalive pings")
+	cmd.PersistentFlags().Bool(AutoPrefixFlagName, false, "prefix the metric type with custom.googleapis.com/ if it is not in the custom.googleapis.com or workload.googleapis.com domain")
+	cmd.PersistentFlags().Int(ConcurrencyFlagName, 1, "sets the number of workers that send time-series requests concurrently; requests for the same time-series are always sent in order")
+	cmd.PersistentFlags().Int(BatchSizeFlagName, pipeline.MaxTimeSeriesPerRequest, "sets the maximum number of time-series sent to Google Cloud Monitoring in a single request; larger requests are split, and the value must be between 1 and 200")
+	cmd.PersistentFlags().Bool(PartialFailureFlagName, false, "log the points that Google Cloud Monitoring rejects when it accepts the rest of a request, and keep running instead of exiting with an error")
+	cmd.PersistentFlags().Bool(ValidateOnlyFlagName, false, "build a single time-series request and verify it against the metric and resource descriptors in Google Cloud Monitoring, without writing any data")
+	cmd.PersistentFlags().StringSlice(PromoteLabelFlagName, nil, "copy the resource labels with these keys to the metric labels, e.g. zone,instance_id, so they can be used to aggregate across resources")
+	cmd.PersistentFlags().String(SequenceLabelFlagName, "", "if set, add a metric label with this key that contains an incrementing sequence number for each point; for debugging lost points only, as every value creates a new time-series")
+	cmd.PersistentFlags().String(HostnameLabelFlagName, "", "if set, add a metric label with this key that contains the hostname of the machine; the key defaults to '"+DefaultHostnameLabel+"' if the flag is given without a value")
+	cmd.PersistentFlags().Lookup(HostnameLabelFlagName).NoOptDefVal = DefaultHostnameLabel
+	cmd.PersistentFlags().Bool(WriterIdentityFlagName, false, "add an '"+pipeline.WriterIdentityLabel+"' metric label that is unique to this process, so that several generators can write the same metric and resource without duplicate point errors")
+	cmd.PersistentFlags().String(DeployVersionFlagName, "", "if set, add a '"+pipeline.DeployVersionLabel+"' metric label with this version, e.g. a git commit, to correlate synthetic load with deployments; the version is detected from the environment if the flag is given without a value")
+	cmd.PersistentFlags().Lookup(DeployVersionFlagName).NoOptDefVal = DetectDeployVersion
+	cmd.PersistentFlags().StringArray(DropLabelFlagName, nil, "remove the metric or resource label with this key from every time-series, unless it is required by the monitored resource type; may be repeated")
+	cmd.PersistentFlags().Float64(DistGrowthFactorFlagName, 0.0, "if set, send each value as a distribution with exponential buckets that grow by this factor, which must be greater than 1")
+	cmd.PersistentFlags().Float64(DistScaleFlagName, 1.0, "sets the lower bound of the first finite exponential distribution bucket")
+	cmd.PersistentFlags().Int32(DistNumBucketsFlagName, 0, "sets the number of finite exponential distribution buckets")
+	cmd.PersistentFlags().StringSlice(DistBoundsFlagName, nil, "if set, send each value as a distribution with explicit buckets between these strictly increasing bounds, e.g. 0,10,50,100")
+}
+
+// Add the flags that only apply to the long-running waveform generator commands.
+func addWaveformFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String(HealthAddrFlagName, "", "if set, launch an HTTP server on this address that exposes /healthz and /readyz endpoints for liveness and readiness probes")
+	cmd.PersistentFlags().Bool(EmitImmediatelyFlagName, false, "send the first metric as soon as the generator starts, instead of waiting for the first sample interval to elapse")
+	cmd.PersistentFlags().Bool(ProgressFlagName, false, "print a status line to stderr every minute with the number of points sent, the current value, and the time until the next point; ignored if stderr is not a terminal")
+	cmd.PersistentFlags().Bool(DeterministicFlagName, false, "with --dry-run, timestamp values from a virtual clock that starts at the Unix epoch and advances by --sample for each value, so the output is the same on every run; use with --seed for golden tests")
+	cmd.PersistentFlags().Bool(LatencySummaryFlagName, false, "print the number of time-series requests and the p50, p95, and p99 emit latencies to stderr on exit")
+	cmd.PersistentFlags().String(StateFileFlagName, "", "if set, read the waveform start time and random seed from this file, creating it if necessary, so that a restarted generator continues the waveform without a discontinuity")
+	cmd.PersistentFlags().Bool(WallClockPhaseFlagName, false, "calculate the waveform phase of each value from the Unix epoch instead of from the first sample, so cycles start on wall-clock multiples of --period and a late first sample does not shift later values; takes precedence over the start time in --state-file")
+	cmd.PersistentFlags().Bool(OnceFlagName, false, "send a single metric with the value for the current time and exit, e.g. when run from cron; the waveform phase is calculated from the Unix epoch so successive runs follow the waveform")
 }
 
-func bindViperFlags(cmd *cobra.Command, _ []string) error {
+func bindWaveformFlags(cmd *cobra.Command, args []string) error {
+	if err := bindViperFlags(cmd, args); err != nil {
+		return err
+	}
+	if err := viper.BindPFlag(HealthAddrFlagName, cmd.PersistentFlags().Lookup(HealthAddrFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", HealthAddrFlagName, err)
+	}
+	if err := viper.BindPFlag(EmitImmediatelyFlagName, cmd.PersistentFlags().Lookup(EmitImmediatelyFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", EmitImmediatelyFlagName, err)
+	}
+	if err := viper.BindPFlag(ProgressFlagName, cmd.PersistentFlags().Lookup(ProgressFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", ProgressFlagName, err)
+	}
+	if err := viper.BindPFlag(DeterministicFlagName, cmd.PersistentFlags().Lookup(DeterministicFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DeterministicFlagName, err)
+	}
+	if err := viper.BindPFlag(LatencySummaryFlagName, cmd.PersistentFlags().Lookup(LatencySummaryFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", LatencySummaryFlagName, err)
+	}
+	if err := viper.BindPFlag(WallClockPhaseFlagName, cmd.PersistentFlags().Lookup(WallClockPhaseFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", WallClockPhaseFlagName, err)
+	}
+	if err := viper.BindPFlag(OnceFlagName, cmd.PersistentFlags().Lookup(OnceFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", OnceFlagName, err)
+	}
+	if err := viper.BindPFlag(StateFileFlagName, cmd.PersistentFlags().Lookup(StateFileFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", StateFileFlagName, err)
+	}
+	return nil
+}
+
+func bindViperFlags(cmd *cobra.Command, args []string) error {
 	if err := viper.BindPFlag(SampleFlagName, cmd.PersistentFlags().Lookup(SampleFlagName)); err != nil {
 		return fmt.Errorf("failed to bind '%s' pflag: %w", SampleFlagName, err)
 	}
@@ -101,15 +357,584 @@ func bindViperFlags(cmd *cobra.Command, _ []string) error {
 	if err := viper.BindPFlag(CeilingFlagName, cmd.PersistentFlags().Lookup(CeilingFlagName)); err != nil {
 		return fmt.Errorf("failed to bind '%s' pflag: %w", CeilingFlagName, err)
 	}
+	if err := viper.BindPFlag(RelativeToFlagName, cmd.PersistentFlags().Lookup(RelativeToFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", RelativeToFlagName, err)
+	}
+	if err := viper.BindPFlag(DailyAmplitudeFlagName, cmd.PersistentFlags().Lookup(DailyAmplitudeFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DailyAmplitudeFlagName, err)
+	}
+	if err := viper.BindPFlag(WeeklyAmplitudeFlagName, cmd.PersistentFlags().Lookup(WeeklyAmplitudeFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", WeeklyAmplitudeFlagName, err)
+	}
+	return bindPipelineFlags(cmd, args)
+}
+
+// Bind the flags added by addPipelineFlags to viper.
+func bindPipelineFlags(cmd *cobra.Command, _ []string) error {
+	if err := bindConnectionFlags(cmd); err != nil {
+		return err
+	}
 	if err := viper.BindPFlag(IntegerFlagName, cmd.PersistentFlags().Lookup(IntegerFlagName)); err != nil {
 		return fmt.Errorf("failed to bind '%s' pflag: %w", IntegerFlagName, err)
 	}
 	if err := viper.BindPFlag(DryRunFlagName, cmd.PersistentFlags().Lookup(DryRunFlagName)); err != nil {
 		return fmt.Errorf("failed to bind '%s' pflag: %w", DryRunFlagName, err)
 	}
+	if err := viper.BindPFlag(MovingAverageFlagName, cmd.PersistentFlags().Lookup(MovingAverageFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", MovingAverageFlagName, err)
+	}
+	if err := viper.BindPFlag(DriftFlagName, cmd.PersistentFlags().Lookup(DriftFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DriftFlagName, err)
+	}
+	if err := viper.BindPFlag(DecimalsFlagName, cmd.PersistentFlags().Lookup(DecimalsFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DecimalsFlagName, err)
+	}
+	if err := viper.BindPFlag(LogRoundFlagName, cmd.PersistentFlags().Lookup(LogRoundFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", LogRoundFlagName, err)
+	}
+	if err := viper.BindPFlag(DryRunFormatFlagName, cmd.PersistentFlags().Lookup(DryRunFormatFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DryRunFormatFlagName, err)
+	}
+	if err := viper.BindPFlag(QuietFlagName, cmd.PersistentFlags().Lookup(QuietFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", QuietFlagName, err)
+	}
+	if err := viper.BindPFlag(LocationFlagName, cmd.PersistentFlags().Lookup(LocationFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", LocationFlagName, err)
+	}
+	if err := viper.BindPFlag(NamespaceFlagName, cmd.PersistentFlags().Lookup(NamespaceFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", NamespaceFlagName, err)
+	}
+	if err := viper.BindPFlag(ResourceTypeFlagName, cmd.PersistentFlags().Lookup(ResourceTypeFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", ResourceTypeFlagName, err)
+	}
+	if err := viper.BindPFlag(RecreateDescriptorFlagName, cmd.PersistentFlags().Lookup(RecreateDescriptorFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", RecreateDescriptorFlagName, err)
+	}
+	if err := viper.BindPFlag(DisplayNameFlagName, cmd.PersistentFlags().Lookup(DisplayNameFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DisplayNameFlagName, err)
+	}
+	if err := viper.BindPFlag(DescriptionFlagName, cmd.PersistentFlags().Lookup(DescriptionFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DescriptionFlagName, err)
+	}
+	if err := viper.BindPFlag(SkipMetadataFlagName, cmd.PersistentFlags().Lookup(SkipMetadataFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", SkipMetadataFlagName, err)
+	}
+	for _, name := range []string{InstanceIDFlagName, ZoneFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	if err := viper.BindPFlag(RESTTransportFlagName, cmd.PersistentFlags().Lookup(RESTTransportFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", RESTTransportFlagName, err)
+	}
+	if err := viper.BindPFlag(RemoteWriteURLFlagName, cmd.PersistentFlags().Lookup(RemoteWriteURLFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", RemoteWriteURLFlagName, err)
+	}
+	if err := viper.BindPFlag(GMPFlagName, cmd.PersistentFlags().Lookup(GMPFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", GMPFlagName, err)
+	}
+	for _, name := range []string{InfluxURLFlagName, InfluxBucketFlagName, InfluxOrgFlagName, InfluxTokenFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	if err := viper.BindPFlag(RPCTimeoutFlagName, cmd.PersistentFlags().Lookup(RPCTimeoutFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", RPCTimeoutFlagName, err)
+	}
+	if err := viper.BindPFlag(CloseTimeoutFlagName, cmd.PersistentFlags().Lookup(CloseTimeoutFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", CloseTimeoutFlagName, err)
+	}
+	if err := viper.BindPFlag(MinSeriesIntervalFlagName, cmd.PersistentFlags().Lookup(MinSeriesIntervalFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", MinSeriesIntervalFlagName, err)
+	}
+	if err := viper.BindPFlag(KeepaliveFlagName, cmd.PersistentFlags().Lookup(KeepaliveFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", KeepaliveFlagName, err)
+	}
+	if err := viper.BindPFlag(AutoPrefixFlagName, cmd.PersistentFlags().Lookup(AutoPrefixFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", AutoPrefixFlagName, err)
+	}
+	if err := viper.BindPFlag(ConcurrencyFlagName, cmd.PersistentFlags().Lookup(ConcurrencyFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", ConcurrencyFlagName, err)
+	}
+	if err := viper.BindPFlag(BatchSizeFlagName, cmd.PersistentFlags().Lookup(BatchSizeFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", BatchSizeFlagName, err)
+	}
+	if err := viper.BindPFlag(PartialFailureFlagName, cmd.PersistentFlags().Lookup(PartialFailureFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", PartialFailureFlagName, err)
+	}
+	if err := viper.BindPFlag(ValidateOnlyFlagName, cmd.PersistentFlags().Lookup(ValidateOnlyFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", ValidateOnlyFlagName, err)
+	}
+	if err := viper.BindPFlag(PromoteLabelFlagName, cmd.PersistentFlags().Lookup(PromoteLabelFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", PromoteLabelFlagName, err)
+	}
+	if err := viper.BindPFlag(SequenceLabelFlagName, cmd.PersistentFlags().Lookup(SequenceLabelFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", SequenceLabelFlagName, err)
+	}
+	if err := viper.BindPFlag(HostnameLabelFlagName, cmd.PersistentFlags().Lookup(HostnameLabelFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", HostnameLabelFlagName, err)
+	}
+	for _, name := range []string{ActiveWindowFlagName, ActiveTimezoneFlagName, ActiveDaysFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	if err := viper.BindPFlag(DeployVersionFlagName, cmd.PersistentFlags().Lookup(DeployVersionFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DeployVersionFlagName, err)
+	}
+	if err := viper.BindPFlag(WriterIdentityFlagName, cmd.PersistentFlags().Lookup(WriterIdentityFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", WriterIdentityFlagName, err)
+	}
+	if err := viper.BindPFlag(DropLabelFlagName, cmd.PersistentFlags().Lookup(DropLabelFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DropLabelFlagName, err)
+	}
+	if err := viper.BindPFlag(DistGrowthFactorFlagName, cmd.PersistentFlags().Lookup(DistGrowthFactorFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DistGrowthFactorFlagName, err)
+	}
+	if err := viper.BindPFlag(DistScaleFlagName, cmd.PersistentFlags().Lookup(DistScaleFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DistScaleFlagName, err)
+	}
+	if err := viper.BindPFlag(DistNumBucketsFlagName, cmd.PersistentFlags().Lookup(DistNumBucketsFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DistNumBucketsFlagName, err)
+	}
+	if err := viper.BindPFlag(DistBoundsFlagName, cmd.PersistentFlags().Lookup(DistBoundsFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", DistBoundsFlagName, err)
+	}
 	return nil
 }
 
+// Returns the distribution bucket options described by the distribution flags,
+// or nil if none of the flags have been set.
+func distributionBucketOptions(growthFactor, scale float64, numBuckets int32, bounds []string) (*distributionpb.Distribution_BucketOptions, error) {
+	exponential := growthFactor != 0.0 || numBuckets != 0
+	switch {
+	case len(bounds) > 0 && exponential:
+		return nil, ErrConflictingDistributionFlags
+	case len(bounds) > 0:
+		values, err := parseBounds(bounds)
+		if err != nil {
+			return nil, err
+		}
+		options, err := pipeline.NewExplicitBucketOptions(values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' flag: %w", DistBoundsFlagName, err)
+		}
+		return options, nil
+	case exponential:
+		options, err := pipeline.NewExponentialBucketOptions(growthFactor, scale, numBuckets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponential distribution flags: %w", err)
+		}
+		return options, nil
+	default:
+		return nil, nil //nolint:nilnil // No distribution has been requested
+	}
+}
+
+// Returns an error if a distribution has been requested with a flag that changes
+// each value, as the distribution is built from the generated value and would
+// silently ignore the change.
+func checkDistributionValueFlags(integer bool, drift float64, movingAverage int, decimals string) error {
+	switch {
+	case integer:
+		return ErrIntegerDistribution
+	case drift != 0.0 || movingAverage > 1 || decimals != "":
+		return ErrTransformedDistribution
+	default:
+		return nil
+	}
+}
+
+// Parses each string as a floating point distribution bucket bound.
+func parseBounds(bounds []string) ([]float64, error) {
+	values := make([]float64, 0, len(bounds))
+	for _, bound := range bounds {
+		value, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse distribution bound %q: %w", bound, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Returns the labels encoded in value as a JSON object of string keys to string
+// values, or nil if value is empty.
+func parseJSONLabels(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil //nolint:nilnil // No labels have been provided
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(value), &labels); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJSONLabels, err)
+	}
+	if labels == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJSONLabels, value)
+	}
+	return labels, nil
+}
+
+// Returns the absolute floor and ceiling values from the flags, scaling any
+// percentage values by the baseline read from the relative-to file.
+func effectiveRange(logger logr.Logger) (float64, float64, error) {
+	var baseline *float64
+	if path := viper.GetString(RelativeToFlagName); path != "" {
+		value, err := loadBaseline(path)
+		if err != nil {
+			return 0.0, 0.0, err
+		}
+		baseline = &value
+	}
+	return parseRange(logger, viper.GetString(FloorFlagName), viper.GetString(CeilingFlagName), baseline)
+}
+
+// Parses the floor and ceiling values with parseLevel. A floor that is greater
+// than the ceiling is accepted, but a warning is logged because the values are
+// generated between the ceiling and the floor; the waveform is not inverted.
+func parseRange(logger logr.Logger, floorValue, ceilingValue string, baseline *float64) (float64, float64, error) {
+	floor, err := parseLevel(floorValue, baseline)
+	if err != nil {
+		return 0.0, 0.0, fmt.Errorf("invalid '%s' flag: %w", FloorFlagName, err)
+	}
+	ceiling, err := parseLevel(ceilingValue, baseline)
+	if err != nil {
+		return 0.0, 0.0, fmt.Errorf("invalid '%s' flag: %w", CeilingFlagName, err)
+	}
+	if floor > ceiling {
+		logger.V(0).Info("Floor is greater than ceiling; values will be generated from ceiling to floor, the waveform is not inverted", FloorFlagName, floor, CeilingFlagName, ceiling)
+	}
+	return floor, ceiling, nil
+}
+
+// Reads a single floating point baseline value from the file.
+func loadBaseline(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+	baseline, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to parse baseline value from %q: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Parses the value as an absolute floating point number, or as a percentage of
+// the baseline if the value has a '%' suffix. An error is returned if the value
+// is a percentage and baseline is nil.
+func parseLevel(value string, baseline *float64) (float64, error) {
+	number, isPercentage := strings.CutSuffix(strings.TrimSpace(value), "%")
+	level, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to parse %q: %w", value, err)
+	}
+	if !isPercentage {
+		return level, nil
+	}
+	if baseline == nil {
+		return 0.0, fmt.Errorf("%w: %q", ErrPercentageWithoutBaseline, value)
+	}
+	return *baseline * level / 100.0, nil
+}
+
+// Returns the pipeline options that are common to all generator commands, as set
+// by the flags added in addGeneratorFlags. If the metric descriptor is to be
+// recreated, the user is asked to confirm on the command's input and output.
+//
+//nolint:funlen,gocyclo // Each flag adds an option, which makes the function seem long and complex
+func generatorPipelineOptions(cmd *cobra.Command, logger logr.Logger, metricType string) ([]pipeline.Option, error) {
+	options := []pipeline.Option{
+		pipeline.WithLogger(logger),
+		pipeline.WithMetricType(metricType),
+		pipeline.WithLocation(viper.GetString(LocationFlagName)),
+		pipeline.WithNamespace(viper.GetString(NamespaceFlagName)),
+		pipeline.WithResourceType(viper.GetString(ResourceTypeFlagName)),
+		pipeline.WithConcurrency(viper.GetInt(ConcurrencyFlagName)),
+		pipeline.WithBatchSize(viper.GetInt(BatchSizeFlagName)),
+		pipeline.WithKeepalive(viper.GetDuration(KeepaliveFlagName)),
+		pipeline.WithRPCTimeout(viper.GetDuration(RPCTimeoutFlagName)),
+		pipeline.WithCloseTimeout(viper.GetDuration(CloseTimeoutFlagName)),
+		pipeline.WithMinSeriesInterval(viper.GetDuration(MinSeriesIntervalFlagName)),
+	}
+	if project := viper.GetString(ProjectIDFlagName); project != "" {
+		options = append(options, pipeline.WithProjectID(project))
+	}
+	if viper.GetBool(SkipMetadataFlagName) {
+		options = append(options, pipeline.WithSkipMetadata())
+	}
+	if viper.GetBool(PartialFailureFlagName) {
+		options = append(options, pipeline.WithContinueOnPartialFailure())
+	}
+	if viper.GetBool(LogRoundFlagName) {
+		options = append(options, pipeline.WithLogRounding())
+	}
+	if instanceID := viper.GetString(InstanceIDFlagName); instanceID != "" {
+		options = append(options, pipeline.WithInstanceID(instanceID))
+	}
+	if zone := viper.GetString(ZoneFlagName); zone != "" {
+		options = append(options, pipeline.WithZone(zone))
+	}
+	if seed := viper.GetInt64(SeedFlagName); seed != 0 {
+		options = append(options, pipeline.WithSeed(seed))
+	}
+	if viper.GetBool(AutoPrefixFlagName) {
+		options = append(options, pipeline.WithAutoPrefix())
+	}
+	if viper.GetBool(RecreateDescriptorFlagName) && !viper.GetBool(DryRunFlagName) {
+		confirmed, err := confirm(cmd.InOrStdin(), cmd.ErrOrStderr(), "Delete and recreate the metric descriptor for "+metricType+"? All existing data for the metric will be lost")
+		if err != nil {
+			return nil, err
+		}
+		if !confirmed {
+			return nil, ErrRecreateNotConfirmed
+		}
+		options = append(options, pipeline.WithRecreateDescriptor(true))
+	}
+	if displayName := viper.GetString(DisplayNameFlagName); displayName != "" {
+		options = append(options, pipeline.WithDisplayName(displayName))
+	}
+	if description := viper.GetString(DescriptionFlagName); description != "" {
+		options = append(options, pipeline.WithDescription(description))
+	}
+	connection, err := connectionOptions()
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, connection...)
+	if viper.GetBool(RESTTransportFlagName) {
+		options = append(options, pipeline.WithRESTTransport())
+	}
+	metricLabels, err := parseJSONLabels(viper.GetString(MetricLabelsJSONKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' value: %w", MetricLabelsJSONKey, err)
+	}
+	if len(metricLabels) > 0 {
+		options = append(options, pipeline.WithMetricLabels(metricLabels))
+	}
+	resourceLabels, err := parseJSONLabels(viper.GetString(ResourceLabelsJSONKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' value: %w", ResourceLabelsJSONKey, err)
+	}
+	if len(resourceLabels) > 0 {
+		options = append(options, pipeline.WithResourceLabels(resourceLabels))
+	}
+	if keys := viper.GetStringSlice(PromoteLabelFlagName); len(keys) > 0 {
+		options = append(options, pipeline.WithPromoteResourceLabels(keys...))
+	}
+	window, err := activeWindow(viper.GetString(ActiveWindowFlagName), viper.GetString(ActiveTimezoneFlagName), viper.GetStringSlice(ActiveDaysFlagName))
+	if err != nil {
+		return nil, err
+	}
+	if window != nil {
+		options = append(options, pipeline.WithActiveWindow(window))
+	}
+	transformers := []pipeline.Transformer{}
+	if viper.GetBool(IntegerFlagName) {
+		transformers = append(transformers, pipeline.NewIntegerTypedValueTransformerWithLogger(logger))
+	}
+	if drift := viper.GetFloat64(DriftFlagName); drift != 0.0 {
+		transformers = append(transformers, pipeline.NewDriftTransformer(drift))
+	}
+	if window := viper.GetInt(MovingAverageFlagName); window > 1 {
+		transformers = append(transformers, pipeline.NewMovingAverageTransformer(window))
+	}
+	if decimals := strings.TrimSpace(viper.GetString(DecimalsFlagName)); decimals != "" {
+		places, err := strconv.Atoi(decimals)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' flag: %w", DecimalsFlagName, err)
+		}
+		transformers = append(transformers, pipeline.NewRoundTransformer(places))
+	}
+	bucketOptions, err := distributionBucketOptions(viper.GetFloat64(DistGrowthFactorFlagName), viper.GetFloat64(DistScaleFlagName), viper.GetInt32(DistNumBucketsFlagName), viper.GetStringSlice(DistBoundsFlagName))
+	switch {
+	case err != nil:
+		return nil, err
+	case bucketOptions != nil:
+		if err := checkDistributionValueFlags(viper.GetBool(IntegerFlagName), viper.GetFloat64(DriftFlagName), viper.GetInt(MovingAverageFlagName), strings.TrimSpace(viper.GetString(DecimalsFlagName))); err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, pipeline.NewDistributionTypedValueTransformer(bucketOptions))
+	}
+	if sequenceLabel := viper.GetString(SequenceLabelFlagName); sequenceLabel != "" {
+		transformers = append(transformers, pipeline.NewSequenceLabelTransformer(sequenceLabel))
+	}
+	if hostnameLabel := viper.GetString(HostnameLabelFlagName); hostnameLabel != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failure getting hostname for '%s' flag: %w", HostnameLabelFlagName, err)
+		}
+		transformers = append(transformers, pipeline.NewMetricLabelTransformer(hostnameLabel, hostname))
+	}
+	if viper.GetBool(WriterIdentityFlagName) {
+		transformers = append(transformers, pipeline.NewWriterIdentityLabelTransformer())
+	}
+	deployVersion, err := effectiveDeployVersion(viper.GetString(DeployVersionFlagName), os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if deployVersion != "" {
+		transformers = append(transformers, pipeline.NewDeployVersionLabelTransformer(deployVersion))
+	}
+	// Labels must be dropped after all other labels have been added.
+	if keys := viper.GetStringSlice(DropLabelFlagName); len(keys) > 0 {
+		transformers = append(transformers, pipeline.NewLabelFilterTransformer(logger, keys))
+	}
+	if len(transformers) > 0 {
+		options = append(options, pipeline.WithTransformers(transformers))
+	}
+	if viper.GetString(RemoteWriteURLFlagName) == "" && viper.GetString(InfluxURLFlagName) == "" && !viper.GetBool(GMPFlagName) {
+		// Prometheus and InfluxDB accept label keys that Google Cloud Monitoring
+		// does not.
+		options = append(options, pipeline.WithLabelKeyValidation())
+	}
+	emitterOptions, err := alternateEmitterOptions(viper.GetBool(DryRunFlagName), viper.GetBool(GMPFlagName), viper.GetString(RemoteWriteURLFlagName), viper.GetString(InfluxURLFlagName), viper.GetString(InfluxBucketFlagName), viper.GetString(InfluxOrgFlagName), viper.GetString(InfluxTokenFlagName))
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, emitterOptions...)
+	if viper.GetBool(DryRunFlagName) {
+		var writer io.Writer = os.Stdout
+		if viper.GetBool(QuietFlagName) {
+			writer = io.Discard
+		}
+		options = append(options, pipeline.WithFormattedWriterEmitter(writer, pipeline.WriterFormat(viper.GetString(DryRunFormatFlagName))))
+	}
+	return options, nil
+}
+
+// Completes the first argument of a command with the names of the waveforms.
+func completeWaveform(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	periodicTypes := generators.AllPeriodicTypes()
+	names := make([]string, 0, len(periodicTypes))
+	for _, periodicType := range periodicTypes {
+		names = append(names, periodicType.String())
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Returns the unit value calculator for the waveform; the shape of a trapezoid
+// is taken from the rise and hold fraction settings, if present.
+func unitCalculator(periodicType generators.PeriodicType) (generators.ValueCalculator, error) {
+	if periodicType != generators.Trapezoid {
+		return periodicType.ValueCalculator(), nil
+	}
+	options := []generators.TrapezoidOption{}
+	if viper.IsSet(RiseFractionFlagName) {
+		options = append(options, generators.WithRiseFraction(viper.GetFloat64(RiseFractionFlagName)))
+	}
+	if viper.IsSet(HoldFractionFlagName) {
+		options = append(options, generators.WithHoldFraction(viper.GetFloat64(HoldFractionFlagName)))
+	}
+	calculator, err := generators.NewTrapezoidCalculator(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failure building trapezoid calculator: %w", err)
+	}
+	return calculator, nil
+}
+
+// Returns the generator option that sets the value calculator for the unit
+// waveform in the range floor through ceiling, scaled by the daily and weekly
+// seasonal cycles if either amplitude is greater than zero.
+func calculatorOption(floor, ceiling float64, unit generators.ValueCalculator, dailyAmp, weeklyAmp float64) (generators.Option, error) {
+	for _, amplitude := range []float64{dailyAmp, weeklyAmp} {
+		if amplitude < 0.0 || amplitude > 1.0 {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAmplitude, amplitude)
+		}
+	}
+	calculator := generators.NewRangeCalculator(floor, ceiling, unit)
+	if dailyAmp == 0.0 && weeklyAmp == 0.0 {
+		return generators.WithValueCalculator(calculator), nil
+	}
+	return generators.WithTimedValueCalculator(generators.NewSeasonalCalculator(calculator, dailyAmp, weeklyAmp)), nil
+}
+
+// Returns the active window described by the flags, or nil if no window was
+// given.
+func activeWindow(spec, timezone string, days []string) (*pipeline.ActiveWindow, error) {
+	if spec == "" {
+		if timezone != "" || len(days) > 0 {
+			return nil, ErrActiveWindowOptionsOnly
+		}
+		return nil, nil //nolint:nilnil // No active window has been requested
+	}
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	weekdays := make([]time.Weekday, 0, len(days))
+	for _, day := range days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return nil, err
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	window, err := pipeline.NewActiveWindow(spec, location, weekdays...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' value: %w", ActiveWindowFlagName, err)
+	}
+	return window, nil
+}
+
+// Returns the weekday with the name, or its three letter abbreviation, ignoring
+// case.
+func parseWeekday(value string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("%w: %q", ErrInvalidWeekday, value)
+}
+
+// Returns the deploy version to add as a metric label, or an empty string if
+// none was requested. If the value is DetectDeployVersion, the first non-empty
+// environment variable of DEPLOY_VERSION, GIT_COMMIT, GITHUB_SHA, CI_COMMIT_SHA,
+// and K_REVISION is used.
+func effectiveDeployVersion(value string, lookupEnv func(string) (string, bool)) (string, error) {
+	if value != DetectDeployVersion {
+		return value, nil
+	}
+	for _, name := range []string{"DEPLOY_VERSION", "GIT_COMMIT", "GITHUB_SHA", "CI_COMMIT_SHA", "K_REVISION"} {
+		if version, ok := lookupEnv(name); ok && version != "" {
+			return version, nil
+		}
+	}
+	return "", ErrDeployVersionNotDetected
+}
+
+// Returns the pipeline options for a Managed Service for Prometheus, remote-write,
+// or InfluxDB emitter, if one is requested. Only one of dry-run, Managed Service
+// for Prometheus, remote-write, or InfluxDB output can be used, and InfluxDB
+// output requires all of its connection parameters.
+func alternateEmitterOptions(dryRun, gmp bool, remoteWriteURL, influxURL, influxBucket, influxOrg, influxToken string) ([]pipeline.Option, error) {
+	requested := 0
+	for _, set := range []bool{dryRun, gmp, remoteWriteURL != "", influxURL != ""} {
+		if set {
+			requested++
+		}
+	}
+	switch {
+	case requested > 1:
+		return nil, ErrConflictingEmitterFlags
+	case gmp:
+		return []pipeline.Option{pipeline.WithGMPEmitter("")}, nil
+	case remoteWriteURL != "":
+		return []pipeline.Option{pipeline.WithPrometheusRemoteWriteEmitter(remoteWriteURL)}, nil
+	case influxURL != "" && (influxBucket == "" || influxOrg == "" || influxToken == ""):
+		return nil, ErrMissingInfluxFlags
+	case influxURL != "":
+		return []pipeline.Option{pipeline.WithInfluxEmitter(influxURL, influxBucket, influxOrg, influxToken)}, nil
+	default:
+		return []pipeline.Option{}, nil
+	}
+}
+
 //nolint:funlen // Setup of options makes the function seem long
 func generatorMain(cmd *cobra.Command, args []string) error {
 	periodicType, err := generators.ParsePeriodicType(cmd.CalledAs())
@@ -119,11 +944,30 @@ func generatorMain(cmd *cobra.Command, args []string) error {
 	project := viper.GetString(ProjectIDFlagName)
 	sample := viper.GetDuration(SampleFlagName)
 	period := viper.GetDuration(PeriodFlagName)
-	floor := viper.GetFloat64(FloorFlagName)
-	ceiling := viper.GetFloat64(CeilingFlagName)
+	floor, ceiling, err := effectiveRange(logger)
+	if err != nil {
+		return err
+	}
 	dryRun := viper.GetBool(DryRunFlagName)
 	asInteger := viper.GetBool(IntegerFlagName)
-	logger := logger.WithValues("periodicType", periodicType.String(), "project", project, "sample", sample, "period", period, FloorFlagName, floor, CeilingFlagName, ceiling, "dryRun", dryRun, "asInteger", asInteger)
+	location := viper.GetString(LocationFlagName)
+	namespace := viper.GetString(NamespaceFlagName)
+	validateOnly := viper.GetBool(ValidateOnlyFlagName)
+	healthAddr := viper.GetString(HealthAddrFlagName)
+	sequenceLabel := viper.GetString(SequenceLabelFlagName)
+	emitImmediately := viper.GetBool(EmitImmediatelyFlagName)
+	showProgress := viper.GetBool(ProgressFlagName) && isTerminal(os.Stderr)
+	latencySummary := viper.GetBool(LatencySummaryFlagName)
+	deterministic := viper.GetBool(DeterministicFlagName)
+	if deterministic && !dryRun {
+		return ErrDeterministicWithoutDryRun
+	}
+	once := viper.GetBool(OnceFlagName)
+	stateFile := viper.GetString(StateFileFlagName)
+	if stateFile != "" && deterministic {
+		return ErrStateFileWithDeterministic
+	}
+	logger := logger.WithValues("once", once, "stateFile", stateFile, "periodicType", periodicType.String(), "project", project, "sample", sample, "period", period, FloorFlagName, floor, CeilingFlagName, ceiling, "dryRun", dryRun, "asInteger", asInteger, "location", location, "namespace", namespace, "validateOnly", validateOnly, "healthAddr", healthAddr, "sequenceLabel", sequenceLabel, "emitImmediately", emitImmediately, "showProgress", showProgress, "latencySummary", latencySummary, "deterministic", deterministic)
 	logger.V(0).Info("Building synthetic metric generator pipeline")
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -131,27 +975,62 @@ func generatorMain(cmd *cobra.Command, args []string) error {
 	defer stop()
 
 	// Create the timestamped value generator
-	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+	unit, err := unitCalculator(periodicType)
+	if err != nil {
+		return err
+	}
+	calculator := generators.NewRangeCalculator(floor, ceiling, unit)
+	calculatorOpt, err := calculatorOption(floor, ceiling, unit, viper.GetFloat64(DailyAmplitudeFlagName), viper.GetFloat64(WeeklyAmplitudeFlagName))
+	if err != nil {
+		return err
+	}
+	generatorOptions := []generators.Option{
 		generators.WithLogger(logger),
-		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(floor, ceiling, periodicType)),
+		calculatorOpt,
 		generators.WithPeriod(period),
-	)
+	}
+	if emitImmediately {
+		generatorOptions = append(generatorOptions, generators.WithEmitImmediately())
+	}
+	if deterministic {
+		// Don't drop values that can't be written immediately, so the
+		// sequence is complete.
+		generatorOptions = append(generatorOptions, generators.WithVirtualClock(time.Unix(0, 0).UTC(), sample), generators.WithBlockingOutput(true))
+	}
+	var state generatorState
+	if stateFile != "" {
+		if state, err = loadGeneratorState(stateFile, time.Now(), viper.GetInt64(SeedFlagName)); err != nil {
+			return err
+		}
+		// The state does not change while the generator runs, so write it
+		// now, which also preserves it if the generator is killed.
+		if err = saveGeneratorState(stateFile, state); err != nil {
+			return err
+		}
+		logger.V(1).Info("Continuing waveform from state file", "timeZero", state.TimeZero, "seed", state.Seed)
+		generatorOptions = append(generatorOptions, generators.WithTimeZero(state.TimeZero))
+	}
+	if viper.GetBool(WallClockPhaseFlagName) {
+		generatorOptions = append(generatorOptions, generators.WithWallClockPhase())
+	}
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(generatorOptions...)
 	if err != nil {
 		return fmt.Errorf("failure building PeriodicGenerator: %w", err)
 	}
 	// Build the pipeline from options.
-	pipelineOptions := []pipeline.Option{
-		pipeline.WithLogger(logger),
-		pipeline.WithMetricType(args[0]),
-	}
-	if project != "" {
-		pipelineOptions = append(pipelineOptions, pipeline.WithProjectID(project))
+	health := &healthState{}
+	pipelineOptions, err := generatorPipelineOptions(cmd, logger, args[0])
+	if err != nil {
+		return err
 	}
-	if asInteger {
-		pipelineOptions = append(pipelineOptions, pipeline.WithTransformers([]pipeline.Transformer{pipeline.NewIntegerTypedValueTransformer()}))
+	if stateFile != "" {
+		pipelineOptions = append(pipelineOptions, pipeline.WithSeed(state.Seed))
 	}
-	if dryRun {
-		pipelineOptions = append(pipelineOptions, pipeline.WithWriterEmitter(os.Stdout))
+	progress := &progressState{}
+	pipelineOptions = append(pipelineOptions, pipeline.WithEmitObservers([]pipeline.EmitObserver{health.observeEmit, progress.observeEmit}))
+	latency := &latencyState{}
+	if latencySummary {
+		pipelineOptions = append(pipelineOptions, pipeline.WithLatencyObservers([]pipeline.LatencyObserver{latency.observeLatency}))
 	}
 	pipe, err := pipeline.NewPipeline(ctx, pipelineOptions...)
 	if err != nil {
@@ -162,11 +1041,41 @@ func generatorMain(cmd *cobra.Command, args []string) error {
 		if err := pipe.Close(); err != nil {
 			logger.Error(err, "Error returned while closing pipeline")
 		}
+		if latencySummary {
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), latency.summary())
+		}
 	}()
+	if validateOnly {
+		return validatePipeline(ctx, pipe, generators.Metric{
+			Value:     calculator(0.0),
+			Timestamp: time.Now(),
+		})
+	}
+	if once {
+		metric, err := generators.NewMetric(time.Now(), generatorOptions...)
+		if err != nil {
+			return fmt.Errorf("failure generating metric: %w", err)
+		}
+		logger.V(1).Info("Emitting a single metric", "metric", metric)
+		if err := pipe.Emit(ctx, metric); err != nil {
+			return fmt.Errorf("failure emitting metric: %w", err)
+		}
+		return nil
+	}
+	if healthAddr != "" {
+		if err := startHealthServer(ctx, healthAddr, health); err != nil {
+			return err
+		}
+	}
 	ticker := time.NewTicker(sample)
 	defer ticker.Stop()
+	if showProgress {
+		startProgress(ctx, os.Stderr, progress, time.Now(), sample)
+	}
 	go func() {
 		logger.V(1).Info("Launching pipeline processor")
+		health.running.Store(true)
+		defer health.running.Store(false)
 		processor := pipe.Processor()
 		if err := processor(ctx, reader); err != nil {
 			logger.Error(err, "Pipeline processor returned an error")
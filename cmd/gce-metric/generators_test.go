@@ -0,0 +1,629 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+)
+
+func TestDistributionBucketOptions(t *testing.T) {
+	tests := []struct {
+		name                string
+		growthFactor        float64
+		scale               float64
+		numBuckets          int32
+		bounds              []string
+		expectedNil         bool
+		expectedExplicit    bool
+		expectedError       error
+		expectedParseFailed bool
+	}{
+		{
+			name:        "unset",
+			scale:       1.0,
+			expectedNil: true,
+		},
+		{
+			name:         "exponential",
+			growthFactor: 2.0,
+			scale:        1.0,
+			numBuckets:   10,
+		},
+		{
+			name:          "exponential-missing-buckets",
+			growthFactor:  2.0,
+			scale:         1.0,
+			expectedError: pipeline.ErrInvalidNumBuckets,
+		},
+		{
+			name:          "exponential-bad-growth",
+			growthFactor:  0.5,
+			scale:         1.0,
+			numBuckets:    10,
+			expectedError: pipeline.ErrInvalidGrowthFactor,
+		},
+		{
+			name:             "explicit",
+			scale:            1.0,
+			bounds:           []string{"0", "10", "50.5"},
+			expectedExplicit: true,
+		},
+		{
+			name:          "explicit-unordered",
+			scale:         1.0,
+			bounds:        []string{"10", "0"},
+			expectedError: pipeline.ErrInvalidBounds,
+		},
+		{
+			name:                "explicit-unparseable",
+			scale:               1.0,
+			bounds:              []string{"ten"},
+			expectedParseFailed: true,
+		},
+		{
+			name:          "conflicting",
+			growthFactor:  2.0,
+			scale:         1.0,
+			numBuckets:    10,
+			bounds:        []string{"0", "10"},
+			expectedError: ErrConflictingDistributionFlags,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options, err := distributionBucketOptions(tst.growthFactor, tst.scale, tst.numBuckets, tst.bounds)
+			switch {
+			case tst.expectedParseFailed && err == nil:
+				t.Errorf("Expected distributionBucketOptions to raise a parse error")
+			case tst.expectedParseFailed:
+				return
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected distributionBucketOptions to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError != nil:
+				return
+			case err != nil:
+				t.Errorf("distributionBucketOptions raised an unexpected error: %v", err)
+			case tst.expectedNil && options != nil:
+				t.Errorf("Expected nil bucket options, got %+v", options)
+			case !tst.expectedNil && tst.expectedExplicit && options.GetExplicitBuckets() == nil:
+				t.Errorf("Expected explicit bucket options, got %+v", options)
+			case !tst.expectedNil && !tst.expectedExplicit && options.GetExponentialBuckets() == nil:
+				t.Errorf("Expected exponential bucket options, got %+v", options)
+			}
+		})
+	}
+}
+
+func TestCheckDistributionValueFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		integer       bool
+		drift         float64
+		movingAverage int
+		decimals      string
+		expectedError error
+	}{
+		{
+			name:          "none",
+			movingAverage: 1,
+		},
+		{
+			name:          "integer",
+			integer:       true,
+			expectedError: ErrIntegerDistribution,
+		},
+		{
+			name:          "drift",
+			drift:         0.5,
+			expectedError: ErrTransformedDistribution,
+		},
+		{
+			name:          "moving-average",
+			movingAverage: 5,
+			expectedError: ErrTransformedDistribution,
+		},
+		{
+			name:          "decimals",
+			decimals:      "2",
+			expectedError: ErrTransformedDistribution,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if err := checkDistributionValueFlags(tst.integer, tst.drift, tst.movingAverage, tst.decimals); !errors.Is(err, tst.expectedError) {
+				t.Errorf("Expected checkDistributionValueFlags to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	baseline := 200.0
+	tests := []struct {
+		name                string
+		value               string
+		baseline            *float64
+		expected            float64
+		expectedError       error
+		expectedParseFailed bool
+	}{
+		{
+			name:     "absolute",
+			value:    "12.5",
+			expected: 12.5,
+		},
+		{
+			name:     "absolute-with-baseline",
+			value:    "12.5",
+			baseline: &baseline,
+			expected: 12.5,
+		},
+		{
+			name:     "percentage",
+			value:    "80%",
+			baseline: &baseline,
+			expected: 160.0,
+		},
+		{
+			name:     "fractional-percentage",
+			value:    " 2.5% ",
+			baseline: &baseline,
+			expected: 5.0,
+		},
+		{
+			name:          "percentage-without-baseline",
+			value:         "80%",
+			expectedError: ErrPercentageWithoutBaseline,
+		},
+		{
+			name:                "invalid",
+			value:               "eighty",
+			baseline:            &baseline,
+			expectedParseFailed: true,
+		},
+		{
+			name:                "invalid-percentage",
+			value:               "%",
+			baseline:            &baseline,
+			expectedParseFailed: true,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			level, err := parseLevel(tst.value, tst.baseline)
+			switch {
+			case tst.expectedParseFailed && err == nil:
+				t.Errorf("Expected parseLevel to raise a parse error")
+			case tst.expectedParseFailed:
+				return
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseLevel to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("parseLevel raised an unexpected error: %v", err)
+			case level != tst.expected:
+				t.Errorf("Expected %f, got %f", tst.expected, level)
+			}
+		})
+	}
+}
+
+func TestParseJSONLabels(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      map[string]string
+		expectedError error
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: nil,
+		},
+		{
+			name:     "whitespace",
+			value:    "  ",
+			expected: nil,
+		},
+		{
+			name:     "empty-object",
+			value:    "{}",
+			expected: map[string]string{},
+		},
+		{
+			name:  "valid",
+			value: `{"env": "test", "team": "sre"}`,
+			expected: map[string]string{
+				"env":  "test",
+				"team": "sre",
+			},
+		},
+		{
+			name:          "malformed",
+			value:         `{"env": "test"`,
+			expectedError: ErrInvalidJSONLabels,
+		},
+		{
+			name:          "non-string-value",
+			value:         `{"replicas": 3}`,
+			expectedError: ErrInvalidJSONLabels,
+		},
+		{
+			name:          "array",
+			value:         `["env", "test"]`,
+			expectedError: ErrInvalidJSONLabels,
+		},
+		{
+			name:          "null",
+			value:         "null",
+			expectedError: ErrInvalidJSONLabels,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			labels, err := parseJSONLabels(tst.value)
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseJSONLabels to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("parseJSONLabels raised an unexpected error: %v", err)
+			case !reflect.DeepEqual(labels, tst.expected):
+				t.Errorf("Expected %v, got %v", tst.expected, labels)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name            string
+		floor           string
+		ceiling         string
+		expectedFloor   float64
+		expectedCeiling float64
+		expectedWarning bool
+	}{
+		{
+			name:            "ascending",
+			floor:           "1",
+			ceiling:         "10",
+			expectedFloor:   1.0,
+			expectedCeiling: 10.0,
+		},
+		{
+			name:            "equal",
+			floor:           "5",
+			ceiling:         "5",
+			expectedFloor:   5.0,
+			expectedCeiling: 5.0,
+		},
+		{
+			name:            "inverted",
+			floor:           "10",
+			ceiling:         "1",
+			expectedFloor:   10.0,
+			expectedCeiling: 1.0,
+			expectedWarning: true,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			messages := []string{}
+			logger := funcr.New(func(_, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{})
+			floor, ceiling, err := parseRange(logger, tst.floor, tst.ceiling, nil)
+			if err != nil {
+				t.Fatalf("parseRange raised an unexpected error: %v", err)
+			}
+			if floor != tst.expectedFloor || ceiling != tst.expectedCeiling {
+				t.Errorf("Expected floor %f and ceiling %f, got %f and %f", tst.expectedFloor, tst.expectedCeiling, floor, ceiling)
+			}
+			if warned := len(messages) > 0; warned != tst.expectedWarning {
+				t.Errorf("Expected warning to be %t, got %v", tst.expectedWarning, messages)
+			}
+			// The calculator generates values between the lower and higher
+			// of the floor and ceiling, starting from the lower.
+			calculator := generators.NewPeriodicRangeCalculator(floor, ceiling, generators.Sawtooth)
+			if value := calculator(0.0); value != min(floor, ceiling) {
+				t.Errorf("Expected initial value %f, got %f", min(floor, ceiling), value)
+			}
+			if value := calculator(0.5); value != (floor+ceiling)/2.0 {
+				t.Errorf("Expected mid-point value %f, got %f", (floor+ceiling)/2.0, value)
+			}
+		})
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "baseline")
+	if err := os.WriteFile(path, []byte("4096\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write baseline file: %v", err)
+	}
+	baseline, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline raised an unexpected error: %v", err)
+	}
+	if baseline != 4096.0 {
+		t.Errorf("Expected baseline 4096, got %f", baseline)
+	}
+	if _, err := loadBaseline(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected loadBaseline to raise an error for a missing file")
+	}
+}
+
+func TestAlternateEmitterOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		dryRun         bool
+		gmp            bool
+		remoteWriteURL string
+		influxURL      string
+		influxBucket   string
+		influxOrg      string
+		influxToken    string
+		expectedCount  int
+		expectedError  error
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:   "dry-run",
+			dryRun: true,
+		},
+		{
+			name:           "remote-write",
+			remoteWriteURL: "http://mimir.example.com/api/v1/push",
+			expectedCount:  1,
+		},
+		{
+			name:          "gmp",
+			gmp:           true,
+			expectedCount: 1,
+		},
+		{
+			name:          "influx",
+			influxURL:     "http://influxdb.example.com:8086",
+			influxBucket:  "bucket",
+			influxOrg:     "org",
+			influxToken:   "token",
+			expectedCount: 1,
+		},
+		{
+			name:          "influx-missing-token",
+			influxURL:     "http://influxdb.example.com:8086",
+			influxBucket:  "bucket",
+			influxOrg:     "org",
+			expectedError: ErrMissingInfluxFlags,
+		},
+		{
+			name:          "influx-missing-bucket",
+			influxURL:     "http://influxdb.example.com:8086",
+			influxOrg:     "org",
+			influxToken:   "token",
+			expectedError: ErrMissingInfluxFlags,
+		},
+		{
+			name:           "dry-run-remote-write",
+			dryRun:         true,
+			remoteWriteURL: "http://mimir.example.com/api/v1/push",
+			expectedError:  ErrConflictingEmitterFlags,
+		},
+		{
+			name:           "gmp-remote-write",
+			gmp:            true,
+			remoteWriteURL: "http://mimir.example.com/api/v1/push",
+			expectedError:  ErrConflictingEmitterFlags,
+		},
+		{
+			name:           "remote-write-influx",
+			remoteWriteURL: "http://mimir.example.com/api/v1/push",
+			influxURL:      "http://influxdb.example.com:8086",
+			influxBucket:   "bucket",
+			influxOrg:      "org",
+			influxToken:    "token",
+			expectedError:  ErrConflictingEmitterFlags,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options, err := alternateEmitterOptions(tst.dryRun, tst.gmp, tst.remoteWriteURL, tst.influxURL, tst.influxBucket, tst.influxOrg, tst.influxToken)
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected alternateEmitterOptions to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("alternateEmitterOptions raised an unexpected error: %v", err)
+			case len(options) != tst.expectedCount:
+				t.Errorf("Expected %d options, got %d", tst.expectedCount, len(options))
+			}
+		})
+	}
+}
+
+func TestEffectiveDeployVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		env           map[string]string
+		expected      string
+		expectedError error
+	}{
+		{
+			name: "unset",
+			env:  map[string]string{"GITHUB_SHA": "0123abcd"},
+		},
+		{
+			name:     "explicit",
+			value:    "v1.2.3",
+			env:      map[string]string{"GITHUB_SHA": "0123abcd"},
+			expected: "v1.2.3",
+		},
+		{
+			name:     "detect",
+			value:    DetectDeployVersion,
+			env:      map[string]string{"GITHUB_SHA": "0123abcd", "K_REVISION": "service-00001-abc"},
+			expected: "0123abcd",
+		},
+		{
+			name:     "detect-skips-empty",
+			value:    DetectDeployVersion,
+			env:      map[string]string{"DEPLOY_VERSION": "", "K_REVISION": "service-00001-abc"},
+			expected: "service-00001-abc",
+		},
+		{
+			name:          "detect-missing",
+			value:         DetectDeployVersion,
+			env:           map[string]string{},
+			expectedError: ErrDeployVersionNotDetected,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			version, err := effectiveDeployVersion(tst.value, func(name string) (string, bool) {
+				value, ok := tst.env[name]
+				return value, ok
+			})
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected effectiveDeployVersion to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("effectiveDeployVersion raised an unexpected error: %v", err)
+			case version != tst.expected:
+				t.Errorf("Expected version %q, got %q", tst.expected, version)
+			}
+		})
+	}
+}
+
+func TestActiveWindow(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          string
+		timezone      string
+		days          []string
+		expectedNil   bool
+		expectedError error
+	}{
+		{
+			name:        "unset",
+			expectedNil: true,
+		},
+		{
+			name: "window",
+			spec: "09:00-17:00",
+		},
+		{
+			name:     "timezone-and-days",
+			spec:     "22:00-02:00",
+			timezone: "America/New_York",
+			days:     []string{"Mon", "tuesday", "FRI"},
+		},
+		{
+			name:          "invalid-window",
+			spec:          "9-5",
+			expectedError: pipeline.ErrInvalidActiveWindow,
+		},
+		{
+			name:          "invalid-day",
+			spec:          "09:00-17:00",
+			days:          []string{"someday"},
+			expectedError: ErrInvalidWeekday,
+		},
+		{
+			name:          "days-without-window",
+			days:          []string{"mon"},
+			expectedError: ErrActiveWindowOptionsOnly,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			window, err := activeWindow(tst.spec, tst.timezone, tst.days)
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected activeWindow to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("activeWindow raised an unexpected error: %v", err)
+			case tst.expectedError == nil && (window == nil) != tst.expectedNil:
+				t.Errorf("Expected nil window to be %t, got %v", tst.expectedNil, window)
+			}
+		})
+	}
+}
+
+func TestCalculatorOption(t *testing.T) {
+	tests := []struct {
+		name          string
+		dailyAmp      float64
+		weeklyAmp     float64
+		expectedError error
+	}{
+		{
+			name: "no-seasonality",
+		},
+		{
+			name:     "daily",
+			dailyAmp: 0.5,
+		},
+		{
+			name:      "weekly",
+			weeklyAmp: 1.0,
+		},
+		{
+			name:          "negative-amplitude",
+			dailyAmp:      -0.1,
+			expectedError: ErrInvalidAmplitude,
+		},
+		{
+			name:          "large-amplitude",
+			weeklyAmp:     1.5,
+			expectedError: ErrInvalidAmplitude,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			option, err := calculatorOption(1.0, 10.0, generators.Sine.ValueCalculator(), tst.dailyAmp, tst.weeklyAmp)
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected calculatorOption to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("calculatorOption raised an unexpected error: %v", err)
+			case tst.expectedError == nil && option == nil:
+				t.Error("Expected calculatorOption to return an option")
+			}
+		})
+	}
+}
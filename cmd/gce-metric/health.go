@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// Tracks the liveness and readiness of the generator for reporting through the
+// health server.
+type healthState struct {
+	// Set while the pipeline processor is running.
+	running atomic.Bool
+	// Set after the first time-series request is successfully emitted.
+	ready atomic.Bool
+}
+
+// Implements pipeline.EmitObserver to mark the generator as ready after the
+// first successful emit.
+func (h *healthState) observeEmit(_ *monitoringpb.CreateTimeSeriesRequest, err error) {
+	if err == nil {
+		h.ready.Store(true)
+	}
+}
+
+// Returns a handler that responds with 200 OK if the check function returns
+// true, or 503 Service Unavailable otherwise.
+func healthHandler(check func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if check() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Launch an HTTP server on addr that exposes /healthz and /readyz endpoints
+// for the supplied health state. The server will be shutdown when the context
+// is cancelled.
+func startHealthServer(ctx context.Context, addr string, state *healthState) error {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", healthHandler(state.running.Load))
+	mux.Handle("/readyz", healthHandler(state.ready.Load))
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failure listening on health address %q: %w", addr, err)
+	}
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.V(1).Info("Launching health server", "addr", listener.Addr().String())
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err, "Health server returned an error")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		logger.V(2).Info("Shutting down health server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil { //nolint:contextcheck // The parent context is already cancelled
+			logger.Error(err, "Error shutting down health server")
+		}
+	}()
+	return nil
+}
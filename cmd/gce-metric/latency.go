@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Records the latency of every emit attempt, so that a summary can be printed
+// when the generator exits. Every latency is kept, which is a few megabytes for a
+// month of one second samples.
+type latencyState struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+// Implements pipeline.LatencyObserver to record the latency of each emit attempt,
+// whether or not it succeeded.
+func (l *latencyState) observeLatency(latency time.Duration, _ error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.latencies = append(l.latencies, latency)
+}
+
+// Returns the latency summary line for the recorded emit attempts.
+func (l *latencyState) summary() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return formatLatencySummary(l.latencies)
+}
+
+// Returns the nearest-rank percentile p, in the range (0, 100], of the sorted
+// latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100.0 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// Returns a line with the count and the p50, p95, and p99 latencies.
+func formatLatencySummary(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "Emit latency: 0 requests"
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	return fmt.Sprintf("Emit latency: %d requests, p50 %s, p95 %s, p99 %s", len(sorted), percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99))
+}
@@ -0,0 +1,68 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatLatencySummary(t *testing.T) {
+	hundred := make([]time.Duration, 0, 100)
+	// Add the latencies in reverse order to verify they are sorted.
+	for i := 100; i > 0; i-- {
+		hundred = append(hundred, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		expected  string
+	}{
+		{
+			name:      "empty",
+			latencies: nil,
+			expected:  "Emit latency: 0 requests",
+		},
+		{
+			name:      "single",
+			latencies: []time.Duration{250 * time.Millisecond},
+			expected:  "Emit latency: 1 requests, p50 250ms, p95 250ms, p99 250ms",
+		},
+		{
+			name:      "hundred",
+			latencies: hundred,
+			expected:  "Emit latency: 100 requests, p50 50ms, p95 95ms, p99 99ms",
+		},
+		{
+			name:      "ten",
+			latencies: []time.Duration{9, 3, 7, 1, 5, 2, 8, 4, 10, 6},
+			expected:  "Emit latency: 10 requests, p50 5ns, p95 10ns, p99 10ns",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			latencies := append([]time.Duration{}, tst.latencies...)
+			if summary := formatLatencySummary(tst.latencies); summary != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, summary)
+			}
+			for i := range latencies {
+				if latencies[i] != tst.latencies[i] {
+					t.Fatalf("Expected the latencies to be unchanged, got %v", tst.latencies)
+				}
+			}
+		})
+	}
+}
+
+func TestLatencyStateSummary(t *testing.T) {
+	t.Parallel()
+	state := &latencyState{}
+	for _, latency := range []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond} {
+		state.observeLatency(latency, nil)
+	}
+	expected := "Emit latency: 3 requests, p50 20ms, p95 30ms, p99 30ms"
+	if summary := state.summary(); summary != expected {
+		t.Errorf("Expected %q, got %q", expected, summary)
+	}
+}
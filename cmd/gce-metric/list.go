@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
@@ -11,64 +13,121 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/api/iterator"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
 const (
-	FilterFlagName = "filter"
-	JSONFlagName   = "json"
+	FilterFlagName    = "filter"
+	JSONFlagName      = "json"
+	KindFlagName      = "kind"
+	ValueTypeFlagName = "value-type"
 )
 
-func newListCommand() (*cobra.Command, error) {
+var (
+	ErrInvalidMetricKind = errors.New("metric kind must be one of gauge, delta, or cumulative")
+	ErrInvalidValueType  = errors.New("value type must be one of bool, int64, double, string, distribution, or money")
+)
+
+func newListCommand() *cobra.Command {
 	listCmd := &cobra.Command{
-		Use:     "list [--verbose] [--project ID] [--filter FILTER] [--json]",
+		Use:     "list [--verbose] [--project ID] [--filter FILTER] [--kind KIND] [--value-type TYPE] [--json]",
 		Short:   "List Google Cloud time-series metrics that match the filter",
-		Long:    "List any Google Cloud time-series metrics that match the filter, including those reserved for Google Cloud use. The default filter will match any time-series with the prefix name 'custom.googleapis.com', which is the recommended prefix for custom metrics. Use the --json flag to include a dump of the metric descriptor.",
+		Long:    "List any Google Cloud time-series metrics that match the filter, including those reserved for Google Cloud use. The default filter will match any time-series with the prefix name 'custom.googleapis.com', which is the recommended prefix for custom metrics. Use the --kind and --value-type flags to only list metrics with the matching metric kind or value type, and the --json flag to include a dump of the metric descriptor.",
 		Example: AppName + ` list --project ID --filter 'metric.type = has_substring("my-resource")' --json`,
+		PreRunE: bindListFlags,
 		RunE:    listMain,
 	}
-	listCmd.PersistentFlags().String(FilterFlagName, "metric.type = starts_with(\"custom.googleapis.com/\")", "set the filter to use when listing metrics")
+	addFilterFlags(listCmd, "set the filter to use when listing metrics")
+	addConnectionFlags(listCmd)
 	listCmd.PersistentFlags().Bool(JSONFlagName, false, "output the descriptor for each matching metric as JSON")
-	if err := viper.BindPFlag(FilterFlagName, listCmd.PersistentFlags().Lookup(FilterFlagName)); err != nil {
-		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", FilterFlagName, err)
+	listCmd.PersistentFlags().String(KindFlagName, "", "only list metrics with this metric kind; one of gauge, delta, or cumulative")
+	listCmd.PersistentFlags().String(ValueTypeFlagName, "", "only list metrics with this value type; one of bool, int64, double, string, distribution, or money")
+	// The flags were added above, so registration cannot fail.
+	_ = listCmd.RegisterFlagCompletionFunc(KindFlagName, cobra.FixedCompletions([]string{"gauge", "delta", "cumulative"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = listCmd.RegisterFlagCompletionFunc(ValueTypeFlagName, cobra.FixedCompletions([]string{"bool", "int64", "double", "string", "distribution", "money"}, cobra.ShellCompDirectiveNoFileComp))
+	return listCmd
+}
+
+// Bind the filter, JSON, and connection flags of the executing command to viper.
+// The list, data, and series commands share flag names, so binding must be
+// deferred until the command to execute is known.
+func bindListFlags(cmd *cobra.Command, _ []string) error {
+	if err := bindFilterFlags(cmd); err != nil {
+		return err
+	}
+	if err := bindConnectionFlags(cmd); err != nil {
+		return err
 	}
-	if err := viper.BindPFlag(JSONFlagName, listCmd.PersistentFlags().Lookup(JSONFlagName)); err != nil {
-		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", JSONFlagName, err)
+	for _, name := range []string{JSONFlagName, KindFlagName, ValueTypeFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
 	}
-	return listCmd, nil
+	return nil
 }
 
 func listMain(_ *cobra.Command, _ []string) error {
 	logger.V(0).Info("Preparing list client")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	projectID, err := effectiveProjectID(ctx)
+	filter, err := effectiveFilter()
 	if err != nil {
 		return err
 	}
-	req := monitoringpb.ListMetricDescriptorsRequest{
-		Name:      "projects/" + projectID,
-		Filter:    viper.GetString(FilterFlagName),
-		PageSize:  0,
-		PageToken: "",
-	}
-	client, err := monitoring.NewMetricClient(ctx)
+	matches, err := descriptorMatcher(viper.GetString(KindFlagName), viper.GetString(ValueTypeFlagName))
 	if err != nil {
-		return fmt.Errorf("failure creating new metric client: %w", err)
+		return err
+	}
+	return withMetricClient(ctx, func(client *monitoring.MetricClient, projectID string) error {
+		req := monitoringpb.ListMetricDescriptorsRequest{
+			Name:      "projects/" + projectID,
+			Filter:    filter,
+			PageSize:  0,
+			PageToken: "",
+		}
+		it := client.ListMetricDescriptors(ctx, &req)
+		for {
+			response, err := it.Next()
+			switch {
+			case errors.Is(err, iterator.Done):
+				return nil
+			case err != nil:
+				return fmt.Errorf("failure getting list of metrics: %w", err)
+			case !matches(response):
+				logger.V(2).Info("Skipping metric that does not match kind or value type", "metricType", response.Type)
+			case viper.GetBool(JSONFlagName):
+				fmt.Println(protojson.Format(response)) //nolint:forbidigo // The user has requested that the names of matching metrics be printed to stdout
+			default:
+				fmt.Println(response.Type) //nolint:forbidigo // The user has requested that the names of matching metrics be printed to stdout
+			}
+		}
+	})
+}
+
+// Returns a function that reports whether a metric descriptor has the metric
+// kind and value type given, ignoring case; an empty kind or value type matches
+// any descriptor. The Cloud Monitoring filter language cannot select metric
+// descriptors by kind or value type, so descriptors are matched after listing.
+func descriptorMatcher(kind, valueType string) (func(*metricpb.MetricDescriptor) bool, error) {
+	kinds := []metricpb.MetricDescriptor_MetricKind{metricpb.MetricDescriptor_GAUGE, metricpb.MetricDescriptor_DELTA, metricpb.MetricDescriptor_CUMULATIVE}
+	valueTypes := []metricpb.MetricDescriptor_ValueType{metricpb.MetricDescriptor_BOOL, metricpb.MetricDescriptor_INT64, metricpb.MetricDescriptor_DOUBLE, metricpb.MetricDescriptor_STRING, metricpb.MetricDescriptor_DISTRIBUTION, metricpb.MetricDescriptor_MONEY}
+	if kind != "" {
+		value, ok := metricpb.MetricDescriptor_MetricKind_value[strings.ToUpper(kind)]
+		if !ok || !slices.Contains(kinds, metricpb.MetricDescriptor_MetricKind(value)) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMetricKind, kind)
+		}
+		kinds = []metricpb.MetricDescriptor_MetricKind{metricpb.MetricDescriptor_MetricKind(value)}
 	}
-	defer client.Close()
-	it := client.ListMetricDescriptors(ctx, &req)
-	for {
-		response, err := it.Next()
-		switch {
-		case errors.Is(err, iterator.Done):
-			return nil
-		case err != nil:
-			return fmt.Errorf("failure getting list of metrics: %w", err)
-		case viper.GetBool(JSONFlagName):
-			fmt.Println(protojson.Format(response)) //nolint:forbidigo // The user has requested that the names of matching metrics be printed to stdout
-		default:
-			fmt.Println(response.Type) //nolint:forbidigo // The user has requested that the names of matching metrics be printed to stdout
+	if valueType != "" {
+		value, ok := metricpb.MetricDescriptor_ValueType_value[strings.ToUpper(valueType)]
+		if !ok || !slices.Contains(valueTypes, metricpb.MetricDescriptor_ValueType(value)) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidValueType, valueType)
 		}
+		valueTypes = []metricpb.MetricDescriptor_ValueType{metricpb.MetricDescriptor_ValueType(value)}
 	}
+	return func(descriptor *metricpb.MetricDescriptor) bool {
+		return (kind == "" || slices.Contains(kinds, descriptor.GetMetricKind())) &&
+			(valueType == "" || slices.Contains(valueTypes, descriptor.GetValueType()))
+	}, nil
 }
@@ -0,0 +1,88 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+)
+
+func TestDescriptorMatcher(t *testing.T) {
+	descriptors := []*metricpb.MetricDescriptor{
+		{Type: "custom.googleapis.com/gauge-double", MetricKind: metricpb.MetricDescriptor_GAUGE, ValueType: metricpb.MetricDescriptor_DOUBLE},
+		{Type: "custom.googleapis.com/gauge-int64", MetricKind: metricpb.MetricDescriptor_GAUGE, ValueType: metricpb.MetricDescriptor_INT64},
+		{Type: "custom.googleapis.com/cumulative-int64", MetricKind: metricpb.MetricDescriptor_CUMULATIVE, ValueType: metricpb.MetricDescriptor_INT64},
+		{Type: "custom.googleapis.com/delta-distribution", MetricKind: metricpb.MetricDescriptor_DELTA, ValueType: metricpb.MetricDescriptor_DISTRIBUTION},
+	}
+	tests := []struct {
+		name        string
+		kind        string
+		valueType   string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "unfiltered",
+			expected: []string{"custom.googleapis.com/gauge-double", "custom.googleapis.com/gauge-int64", "custom.googleapis.com/cumulative-int64", "custom.googleapis.com/delta-distribution"},
+		},
+		{
+			name:     "kind",
+			kind:     "cumulative",
+			expected: []string{"custom.googleapis.com/cumulative-int64"},
+		},
+		{
+			name:      "value-type",
+			valueType: "INT64",
+			expected:  []string{"custom.googleapis.com/gauge-int64", "custom.googleapis.com/cumulative-int64"},
+		},
+		{
+			name:      "kind-and-value-type",
+			kind:      "Gauge",
+			valueType: "double",
+			expected:  []string{"custom.googleapis.com/gauge-double"},
+		},
+		{
+			name:      "no-matches",
+			kind:      "delta",
+			valueType: "bool",
+			expected:  []string{},
+		},
+		{
+			name:        "invalid-kind",
+			kind:        "metric_kind_unspecified",
+			expectedErr: ErrInvalidMetricKind,
+		},
+		{
+			name:        "invalid-value-type",
+			valueType:   "float",
+			expectedErr: ErrInvalidValueType,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			matches, err := descriptorMatcher(tst.kind, tst.valueType)
+			if tst.expectedErr != nil {
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected descriptorMatcher to raise %v, got %v", tst.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("descriptorMatcher raised an unexpected error: %v", err)
+			}
+			result := []string{}
+			for _, descriptor := range descriptors {
+				if matches(descriptor) {
+					result = append(result, descriptor.GetType())
+				}
+			}
+			if !slices.Equal(result, tst.expected) {
+				t.Errorf("Expected %v, got %v", tst.expected, result)
+			}
+		})
+	}
+}
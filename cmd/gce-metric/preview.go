@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidPreviewPeriod = errors.New("period must be greater than zero")
+
+// The characters used to draw a sparkline, from lowest to highest value.
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+func newPreviewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preview [flags] WAVEFORM",
+		Short: "Preview a waveform in the terminal",
+		Long: `Calculate the values of a waveform for one period, and print them as a sparkline without sending anything to Google Cloud Monitoring. WAVEFORM is one of sawtooth, sine, square, triangle, ramp, or trapezoid.
+
+Use this to quickly tune the floor, ceiling, period, and sample flags before running a generator.`,
+		Example:           AppName + " preview --floor 0 --ceiling 100 --period 20m --sample 30s sine",
+		PreRunE:           bindPreviewFlags,
+		RunE:              previewMain,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeWaveform,
+	}
+	cmd.PersistentFlags().Duration(SampleFlagName, 60*time.Second, "sets the interval between values, must be valid Go duration string")
+	cmd.PersistentFlags().Duration(PeriodFlagName, 10*time.Minute, "sets the duration for one complete cycle from floor to ceiling, must be valid Go duration string")
+	cmd.PersistentFlags().String(FloorFlagName, "1.0", "sets the minimum value for the cycles, can be an integer or floating point value, or a percentage of the --relative-to baseline, e.g. 20%")
+	cmd.PersistentFlags().String(CeilingFlagName, "10.0", "sets the maximum value for the cycles, can be an integer of floating point value, or a percentage of the --relative-to baseline, e.g. 80%")
+	cmd.PersistentFlags().String(RelativeToFlagName, "", "if set, read a baseline value from this file; floor and ceiling values given as percentages are scaled to the baseline")
+	return cmd
+}
+
+func bindPreviewFlags(cmd *cobra.Command, _ []string) error {
+	for _, name := range []string{SampleFlagName, PeriodFlagName, FloorFlagName, CeilingFlagName, RelativeToFlagName} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			return fmt.Errorf("failed to bind '%s' pflag: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func previewMain(cmd *cobra.Command, args []string) error {
+	periodicType, err := generators.ParsePeriodicType(args[0])
+	if err != nil {
+		return fmt.Errorf("failure parsing PeriodicType: %w", err)
+	}
+	sample := viper.GetDuration(SampleFlagName)
+	period := viper.GetDuration(PeriodFlagName)
+	if period <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidPreviewPeriod, period)
+	}
+	floor, ceiling, err := effectiveRange(logger)
+	if err != nil {
+		return err
+	}
+	logger.V(1).Info("Building waveform preview", "periodicType", periodicType.String(), "sample", sample, "period", period, FloorFlagName, floor, CeilingFlagName, ceiling)
+	// The phase of each value only depends on the offset from the start, so
+	// any start time will do.
+	start := time.Unix(0, 0)
+	metrics, err := generators.NewRangeMetrics(start, start.Add(period), sample,
+		generators.WithLogger(logger),
+		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(floor, ceiling, periodicType)),
+		generators.WithPeriod(period),
+	)
+	if err != nil {
+		return fmt.Errorf("failure building preview metrics: %w", err)
+	}
+	values := make([]float64, 0, len(metrics))
+	for _, metric := range metrics {
+		values = append(values, metric.Value)
+	}
+	if _, err := fmt.Fprintln(cmd.OutOrStdout(), sparkline(values)); err != nil {
+		return fmt.Errorf("failure writing preview: %w", err)
+	}
+	if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s: floor %g, ceiling %g, period %v, %d values\n", periodicType.String(), floor, ceiling, period, len(values)); err != nil {
+		return fmt.Errorf("failure writing preview: %w", err)
+	}
+	return nil
+}
+
+// Returns a sparkline of the values, with each value scaled between the minimum
+// and maximum of the values. If every value is the same, the sparkline is drawn
+// with the lowest level character.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, value := range values[1:] {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	var builder strings.Builder
+	for _, value := range values {
+		level := 0
+		if high > low {
+			level = int(math.Round((value - low) / (high - low) * float64(len(sparklineLevels)-1)))
+		}
+		builder.WriteRune(sparklineLevels[level])
+	}
+	return builder.String()
+}
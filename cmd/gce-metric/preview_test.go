@@ -0,0 +1,77 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		expected string
+	}{
+		{
+			name:     "empty",
+			values:   []float64{},
+			expected: "",
+		},
+		{
+			name:     "single",
+			values:   []float64{5.0},
+			expected: "▁",
+		},
+		{
+			name:     "flat",
+			values:   []float64{3.5, 3.5, 3.5, 3.5, 3.5, 3.5},
+			expected: strings.Repeat("▁", 6),
+		},
+		{
+			name:     "ascending",
+			values:   []float64{0.0, 1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0},
+			expected: "▁▂▃▄▅▆▇█",
+		},
+		{
+			name:     "square",
+			values:   []float64{10.0, 10.0, 1.0, 1.0},
+			expected: "██▁▁",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result := sparkline(tst.values)
+			if result != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+			if count := utf8.RuneCountInString(result); count != len(tst.values) {
+				t.Errorf("Expected %d characters, got %d", len(tst.values), count)
+			}
+		})
+	}
+}
+
+//nolint:paralleltest // The preview flags are bound to the global viper instance
+func TestPreviewCommand(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newPreviewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--floor", "0", "--ceiling", "7", "--period", "8m", "--sample", "1m", "sawtooth"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error returned from preview: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a sparkline and a summary line, got %q", out.String())
+	}
+	if !strings.ContainsRune(lines[0], sparklineLevels[0]) {
+		t.Errorf("Expected a sparkline, got %q", lines[0])
+	}
+	if expected := "sawtooth: floor 0, ceiling 7, period 8m0s, "; !strings.HasPrefix(lines[1], expected) {
+		t.Errorf("Expected summary to start with %q, got %q", expected, lines[1])
+	}
+}
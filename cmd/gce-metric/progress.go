@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// The interval between progress line updates.
+const progressInterval = time.Minute
+
+// Tracks the number of points sent and the most recent value, for reporting in
+// the progress line.
+type progressState struct {
+	// The number of time-series requests that have been emitted successfully.
+	sent atomic.Int64
+	// The bits of the most recently emitted value, as stored by math.Float64bits.
+	value atomic.Uint64
+}
+
+// Implements pipeline.EmitObserver to count each successful emit and record the
+// value that was sent.
+func (p *progressState) observeEmit(req *monitoringpb.CreateTimeSeriesRequest, err error) {
+	if err != nil {
+		return
+	}
+	for _, series := range req.GetTimeSeries() {
+		for _, point := range series.GetPoints() {
+			switch value := point.GetValue().GetValue().(type) {
+			case *monitoringpb.TypedValue_DoubleValue:
+				p.value.Store(math.Float64bits(value.DoubleValue))
+			case *monitoringpb.TypedValue_Int64Value:
+				p.value.Store(math.Float64bits(float64(value.Int64Value)))
+			case *monitoringpb.TypedValue_DistributionValue:
+				p.value.Store(math.Float64bits(value.DistributionValue.GetMean()))
+			}
+		}
+	}
+	p.sent.Add(1)
+}
+
+// Returns true if w is a terminal; progress updates rewrite the current line,
+// which only makes sense when a person is watching.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Returns the text of the progress line for the number of points sent, the most
+// recent value, and the time until the next point will be emitted.
+func formatProgress(sent int64, value float64, next time.Duration) string {
+	points := "points"
+	if sent == 1 {
+		points = "point"
+	}
+	if sent == 0 {
+		return fmt.Sprintf("Sent 0 %s, next in %s", points, next.Round(time.Second))
+	}
+	return fmt.Sprintf("Sent %d %s, current value %g, next in %s", sent, points, value, next.Round(time.Second))
+}
+
+// Returns the time remaining until the next sample after now, for a ticker
+// started at start that fires every sample interval.
+func nextSample(start, now time.Time, sample time.Duration) time.Duration {
+	if sample <= 0 || now.Before(start) {
+		return 0
+	}
+	return sample - now.Sub(start)%sample
+}
+
+// Launch a goroutine that rewrites a single status line on w every
+// progressInterval until the context is cancelled.
+func startProgress(ctx context.Context, w io.Writer, state *progressState, start time.Time, sample time.Duration) {
+	ticker := time.NewTicker(progressInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// Leave the last status line intact.
+				_, _ = fmt.Fprintln(w)
+				return
+			case now := <-ticker.C:
+				line := formatProgress(state.sent.Load(), math.Float64frombits(state.value.Load()), nextSample(start, now, sample))
+				// Return to the start of the line and clear it before writing
+				// the new status.
+				_, _ = fmt.Fprintf(w, "\r\033[K%s", line)
+			}
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     int64
+		value    float64
+		next     time.Duration
+		expected string
+	}{
+		{
+			name:     "none-sent",
+			sent:     0,
+			value:    0.0,
+			next:     45 * time.Second,
+			expected: "Sent 0 points, next in 45s",
+		},
+		{
+			name:     "double",
+			sent:     42,
+			value:    12.5,
+			next:     90 * time.Second,
+			expected: "Sent 42 points, current value 12.5, next in 1m30s",
+		},
+		{
+			name:     "integer",
+			sent:     1,
+			value:    7.0,
+			next:     0,
+			expected: "Sent 1 point, current value 7, next in 0s",
+		},
+		{
+			name:     "rounded",
+			sent:     3,
+			value:    -0.25,
+			next:     2*time.Second + 600*time.Millisecond,
+			expected: "Sent 3 points, current value -0.25, next in 3s",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result := formatProgress(tst.sent, tst.value, tst.next)
+			if result != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
+
+func TestNextSample(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		now      time.Time
+		sample   time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "at-start",
+			now:      start,
+			sample:   time.Minute,
+			expected: time.Minute,
+		},
+		{
+			name:     "mid-interval",
+			now:      start.Add(150 * time.Second),
+			sample:   time.Minute,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "before-start",
+			now:      start.Add(-time.Second),
+			sample:   time.Minute,
+			expected: 0,
+		},
+		{
+			name:     "invalid-sample",
+			now:      start.Add(time.Second),
+			sample:   0,
+			expected: 0,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if result := nextSample(start, tst.now, tst.sample); result != tst.expected {
+				t.Errorf("Expected %v, got %v", tst.expected, result)
+			}
+		})
+	}
+}
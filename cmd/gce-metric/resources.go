@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+func newResourcesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resources",
+		Short: "List the supported monitored resource types and their labels",
+		Long: `List the monitored resource types that can be attached to generated time-series, and the label keys each type requires.
+
+No Google Cloud credentials are needed; the list is built into the application.`,
+		Example: AppName + " resources",
+		Args:    cobra.NoArgs,
+		RunE:    resourcesMain,
+	}
+}
+
+func resourcesMain(cmd *cobra.Command, _ []string) error {
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(writer, "TYPE\tLABELS"); err != nil {
+		return fmt.Errorf("failure writing resource types: %w", err)
+	}
+	for _, resourceType := range pipeline.ResourceTypes() {
+		if _, err := fmt.Fprintf(writer, "%s\t%s\n", resourceType.Type, strings.Join(resourceType.Labels, ", ")); err != nil {
+			return fmt.Errorf("failure writing resource types: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failure writing resource types: %w", err)
+	}
+	return nil
+}
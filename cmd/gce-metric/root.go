@@ -20,12 +20,17 @@ const (
 	VerboseFlagName   = "verbose"
 	PrettyFlagName    = "pretty"
 	ProjectIDFlagName = "project"
+	SeedFlagName      = "seed"
+	ConfigFlagName    = "config"
 )
 
 var (
 	// Version is updated from git tags during build.
 	version                    = "unspecified"
 	ErrFailedToDetectProjectID = errors.New("failed to determine Google project id from operating environment")
+	// Holds any error from reading the configuration file in initConfig, which
+	// cannot return an error itself.
+	errConfig error
 )
 
 func NewRootCmd() (*cobra.Command, error) {
@@ -35,10 +40,15 @@ func NewRootCmd() (*cobra.Command, error) {
 		Version: version,
 		Short:   "Generate synthetic gauge metrics for Google Cloud Monitoring",
 		Long:    `Generate synthetic gauge metrics compatible with Google Cloud Monitoring that follow a cyclic pattern, with values calculated using a range you specify.`,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			return errConfig
+		},
 	}
 	rootCmd.PersistentFlags().Count(VerboseFlagName, "enable verbose logging; can be repeated to increase verbosity")
 	rootCmd.PersistentFlags().Bool(PrettyFlagName, false, "disables structured JSON logging to stdout, making it easier to read")
 	rootCmd.PersistentFlags().String(ProjectIDFlagName, "", "the GCP project id to use; specify if not running on GCE or to override detected project id")
+	rootCmd.PersistentFlags().String(ConfigFlagName, "", "read configuration from this file, which must exist, instead of searching for ."+AppName+" in the current and home directories")
+	rootCmd.PersistentFlags().Int64(SeedFlagName, 0, "set the seed for all random values to make runs reproducible; 0 will use a time-based random seed")
 	if err := viper.BindPFlag(VerboseFlagName, rootCmd.PersistentFlags().Lookup(VerboseFlagName)); err != nil {
 		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", VerboseFlagName, err)
 	}
@@ -48,20 +58,31 @@ func NewRootCmd() (*cobra.Command, error) {
 	if err := viper.BindPFlag(ProjectIDFlagName, rootCmd.PersistentFlags().Lookup(ProjectIDFlagName)); err != nil {
 		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", ProjectIDFlagName, err)
 	}
+	if err := viper.BindPFlag(SeedFlagName, rootCmd.PersistentFlags().Lookup(SeedFlagName)); err != nil {
+		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", SeedFlagName, err)
+	}
+	if err := viper.BindPFlag(ConfigFlagName, rootCmd.PersistentFlags().Lookup(ConfigFlagName)); err != nil {
+		return nil, fmt.Errorf("failed to bind '%s' pflag: %w", ConfigFlagName, err)
+	}
 	sawtoothCmd := newSawtoothCommand()
 	sineCmd := newSineCommand()
 	squareCmd := newSquareCommand()
 	triangleCmd := newTriangleCommand()
+	rampCmd := newRampCommand()
+	trapezoidCmd := newTrapezoidCommand()
+	counterCmd := newCounterCommand()
+	backfillCmd := newBackfillCommand()
 	deleteCmd := newDeleteCommand()
-	listCmd, err := newListCommand()
-	if err != nil {
-		return nil, err
-	}
-	dataCmd, err := newDataCommand()
-	if err != nil {
-		return nil, err
-	}
-	rootCmd.AddCommand(sawtoothCmd, sineCmd, squareCmd, triangleCmd, deleteCmd, listCmd, dataCmd)
+	listCmd := newListCommand()
+	dataCmd := newDataCommand()
+	seriesCmd := newSeriesCommand()
+	selftestCmd := newSelftestCommand()
+	previewCmd := newPreviewCommand()
+	resourcesCmd := newResourcesCommand()
+	streamCmd := newStreamCommand()
+	completionCmd := newCompletionCommand()
+	configCmd := newConfigCommand()
+	rootCmd.AddCommand(sawtoothCmd, sineCmd, squareCmd, triangleCmd, rampCmd, trapezoidCmd, counterCmd, backfillCmd, streamCmd, deleteCmd, listCmd, dataCmd, seriesCmd, selftestCmd, previewCmd, resourcesCmd, completionCmd, configCmd)
 	return rootCmd, nil
 }
 
@@ -71,15 +92,15 @@ func NewRootCmd() (*cobra.Command, error) {
 func initConfig() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
 	zl := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger()
-	viper.AddConfigPath(".")
+	searchPaths := []string{"."}
 	if home, err := homedir.Dir(); err == nil {
-		viper.AddConfigPath(home)
+		searchPaths = append(searchPaths, home)
 	}
-	viper.SetConfigName("." + AppName)
 	viper.SetEnvPrefix(AppName)
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
-	err := viper.ReadInConfig()
+	configPath := viper.GetString(ConfigFlagName)
+	err := readConfig(viper.GetViper(), configPath, searchPaths)
 	verbosity := viper.GetInt(VerboseFlagName)
 	switch {
 	case verbosity > 2:
@@ -98,13 +119,41 @@ func initConfig() {
 		})
 	}
 	logger = zerologr.New(&zl)
-	if err == nil {
+	switch {
+	case err == nil:
 		return
+	case configPath != "":
+		// An explicit configuration file must be readable; fail the command.
+		errConfig = err
+	default:
+		logger.Error(err, "Error reading configuration file")
+	}
+}
+
+// Read the configuration file at path into v. If path is empty, the search paths
+// are checked for a file named .gce-metric with any supported extension, and it
+// is not an error if one cannot be found.
+func readConfig(v *viper.Viper, path string, searchPaths []string) error {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("failure reading configuration file: %w", err)
+		}
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failure reading configuration file %q: %w", path, err)
+		}
+		return nil
+	}
+	for _, searchPath := range searchPaths {
+		v.AddConfigPath(searchPath)
 	}
+	v.SetConfigName("." + AppName)
+	err := v.ReadInConfig()
 	var cfgNotFound viper.ConfigFileNotFoundError
-	if !errors.As(err, &cfgNotFound) {
-		logger.Error(err, "Error reading configuration file")
+	if err != nil && !errors.As(err, &cfgNotFound) {
+		return fmt.Errorf("failure reading configuration file: %w", err)
 	}
+	return nil
 }
 
 func effectiveProjectID(ctx context.Context) (string, error) {
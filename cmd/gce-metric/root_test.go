@@ -0,0 +1,56 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("seed: 42\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write test configuration file: %v", err)
+	}
+	tests := []struct {
+		name         string
+		path         string
+		searchPaths  []string
+		expectedErr  bool
+		expectedSeed int64
+	}{
+		{
+			name:        "default-missing",
+			searchPaths: []string{t.TempDir()},
+		},
+		{
+			name:         "explicit",
+			path:         configPath,
+			expectedSeed: 42,
+		},
+		{
+			name:        "explicit-missing",
+			path:        filepath.Join(dir, "missing.yaml"),
+			expectedErr: true,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			v := viper.New()
+			err := readConfig(v, tst.path, tst.searchPaths)
+			switch {
+			case tst.expectedErr && err == nil:
+				t.Error("Expected an error, but readConfig didn't return one")
+			case !tst.expectedErr && err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case v.GetInt64(SeedFlagName) != tst.expectedSeed:
+				t.Errorf("Expected seed %d, got %d", tst.expectedSeed, v.GetInt64(SeedFlagName))
+			}
+		})
+	}
+}
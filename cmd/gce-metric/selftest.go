@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	TimeoutFlagName = "timeout"
+	// The metric type written, read, and deleted by the selftest command.
+	SelftestMetricType = "custom.googleapis.com/gce_metric/selftest"
+)
+
+var ErrSelftestTimeout = errors.New("written point was not returned before the timeout")
+
+func newSelftestCommand() *cobra.Command {
+	selftestCmd := &cobra.Command{
+		Use:   "selftest [--verbose] [--project ID] [--timeout T] [--poll-interval T]",
+		Short: "Verify that metrics can be written to and read from Google Cloud Monitoring",
+		Long: `Write a single point to the ` + SelftestMetricType + ` metric, poll until the point can be read back, then delete the metric descriptor. The outcome and duration of each step is printed, to help diagnose connectivity and IAM problems.
+
+NOTE: The caller must be permitted to write time-series, and to read and delete metric descriptors.`,
+		Example: AppName + " selftest --project ID",
+		PreRunE: bindSelftestFlags,
+		RunE:    selftestMain,
+		Args:    cobra.NoArgs,
+	}
+	selftestCmd.PersistentFlags().Duration(TimeoutFlagName, 2*time.Minute, "set the maximum time to wait for the written point to be returned")
+	selftestCmd.PersistentFlags().Duration(PollIntervalFlagName, 5*time.Second, "set the interval between attempts to read the written point")
+	addConnectionFlags(selftestCmd)
+	return selftestCmd
+}
+
+func bindSelftestFlags(cmd *cobra.Command, _ []string) error {
+	if err := viper.BindPFlag(TimeoutFlagName, cmd.PersistentFlags().Lookup(TimeoutFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", TimeoutFlagName, err)
+	}
+	if err := viper.BindPFlag(PollIntervalFlagName, cmd.PersistentFlags().Lookup(PollIntervalFlagName)); err != nil {
+		return fmt.Errorf("failed to bind '%s' pflag: %w", PollIntervalFlagName, err)
+	}
+	return bindConnectionFlags(cmd)
+}
+
+//nolint:funlen // The sequence of steps makes the function seem long
+func selftestMain(_ *cobra.Command, _ []string) error {
+	timeout := viper.GetDuration(TimeoutFlagName)
+	pollInterval := viper.GetDuration(PollIntervalFlagName)
+	if pollInterval <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidPollInterval, pollInterval)
+	}
+	logger := logger.WithValues("metricType", SelftestMetricType, "timeout", timeout, "pollInterval", pollInterval)
+	logger.V(0).Info("Preparing selftest")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return withMetricClient(ctx, func(client *monitoring.MetricClient, projectID string) error {
+		pipe, err := pipeline.NewPipeline(ctx,
+			pipeline.WithLogger(logger),
+			pipeline.WithProjectID(projectID),
+			pipeline.WithMetricType(SelftestMetricType),
+			pipeline.WithSeed(viper.GetInt64(SeedFlagName)),
+		)
+		if err != nil {
+			return fmt.Errorf("failure creating new pipeline: %w", err)
+		}
+		defer func() {
+			logger.V(2).Info("Closing pipeline")
+			if err := pipe.Close(); err != nil {
+				logger.Error(err, "Error returned while closing pipeline")
+			}
+		}()
+
+		start := time.Now()
+		metric := generators.Metric{
+			Value:     1.0,
+			Timestamp: start,
+		}
+		err = selftestStep("write", func() error {
+			reader := make(chan generators.Metric, 1)
+			reader <- metric
+			close(reader)
+			return pipe.Processor()(ctx, reader)
+		})
+		if err == nil {
+			err = selftestStep("read", func() error {
+				return waitForSelftestPoint(ctx, client, projectID, metric.Timestamp, pollInterval)
+			})
+			// Always attempt to remove the descriptor once a point has been written,
+			// even if it could not be read back.
+			deleteCtx, deleteCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer deleteCancel()
+			deleteErr := selftestStep("delete", func() error {
+				return client.DeleteMetricDescriptor(deleteCtx, &monitoringpb.DeleteMetricDescriptorRequest{ //nolint:contextcheck // The parent context may have reached its deadline
+					Name: "projects/" + projectID + "/metricDescriptors/" + SelftestMetricType,
+				})
+			})
+			err = errors.Join(err, deleteErr)
+		}
+		if err != nil {
+			fmt.Println("FAIL\t" + time.Since(start).Round(time.Millisecond).String()) //nolint:forbidigo // The selftest outcome is written to stdout deliberately
+			return fmt.Errorf("selftest failed: %w", err)
+		}
+		fmt.Println("PASS\t" + time.Since(start).Round(time.Millisecond).String()) //nolint:forbidigo // The selftest outcome is written to stdout deliberately
+		return nil
+	})
+}
+
+// Execute the named selftest step, and print the outcome and duration of the
+// step.
+func selftestStep(name string, step func() error) error {
+	start := time.Now()
+	err := step()
+	outcome := "PASS"
+	if err != nil {
+		outcome = "FAIL"
+	}
+	fmt.Println(name + "\t" + outcome + "\t" + time.Since(start).Round(time.Millisecond).String()) //nolint:forbidigo // The selftest outcome is written to stdout deliberately
+	return err
+}
+
+// Poll Cloud Monitoring until a point of the selftest metric written at or after
+// the timestamp is returned, or the context is cancelled.
+func waitForSelftestPoint(ctx context.Context, client *monitoring.MetricClient, projectID string, timestamp time.Time, pollInterval time.Duration) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		found := false
+		req := &monitoringpb.ListTimeSeriesRequest{
+			Name:   "projects/" + projectID,
+			Filter: "metric.type = " + strconv.Quote(SelftestMetricType),
+			Interval: &monitoringpb.TimeInterval{
+				StartTime: timestamppb.New(timestamp.Truncate(time.Second)),
+				EndTime:   timestamppb.Now(),
+			},
+			PageSize:  0,
+			PageToken: "",
+		}
+		if err := listTimeSeries(ctx, client, req, func(series *monitoringpb.TimeSeries) error {
+			found = found || len(series.GetPoints()) > 0
+			return nil
+		}); err != nil && ctx.Err() == nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		logger.V(1).Info("Selftest point not found, waiting to retry")
+		select {
+		case <-ctx.Done():
+			return ErrSelftestTimeout
+		case <-ticker.C:
+		}
+	}
+}
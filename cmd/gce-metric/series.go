@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func newSeriesCommand() *cobra.Command {
+	seriesCmd := &cobra.Command{
+		Use:     "series [--verbose] [--project ID] [--filter FILTER] [--start-time ISO8601] [--end-time ISO8601] [--json]",
+		Short:   "Summarise the time-series that match the filter",
+		Long:    "Print a row for each time-series that matches the filter and has data between the start and end times, with the latest value and the resource labels; a quick way to check that a metric is flowing. Use the --json flag to output each full time-series instead.",
+		Example: AppName + ` series --project ID --filter 'metric.type = has_substring("my-resource")'`,
+		PreRunE: bindSeriesFlags,
+		RunE:    seriesMain,
+		Args:    cobra.NoArgs,
+	}
+	addFilterFlags(seriesCmd, "set the filter to use when listing time-series")
+	seriesCmd.PersistentFlags().Bool(JSONFlagName, false, "output each matching time-series as JSON")
+	addTimeRangeFlags(seriesCmd)
+	addConnectionFlags(seriesCmd)
+	return seriesCmd
+}
+
+func bindSeriesFlags(cmd *cobra.Command, args []string) error {
+	if err := bindListFlags(cmd, args); err != nil {
+		return err
+	}
+	return bindTimeRangeFlags(cmd)
+}
+
+func seriesMain(_ *cobra.Command, _ []string) error {
+	logger.V(0).Info("Preparing series client")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	startTime, err := buildTimestamp(viper.GetString(StartTimeFlag), time.Now().Add(-5*time.Minute))
+	if err != nil {
+		return err
+	}
+	endTime, err := buildTimestamp(viper.GetString(EndTimeFlag), time.Now())
+	if err != nil {
+		return err
+	}
+	filter, err := effectiveFilter()
+	if err != nil {
+		return err
+	}
+	return withMetricClient(ctx, func(client *monitoring.MetricClient, projectID string) error {
+		req := monitoringpb.ListTimeSeriesRequest{
+			Name:   "projects/" + projectID,
+			Filter: filter,
+			Interval: &monitoringpb.TimeInterval{
+				StartTime: startTime,
+				EndTime:   endTime,
+			},
+			PageSize:  0,
+			PageToken: "",
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer writer.Flush()
+		if !viper.GetBool(JSONFlagName) {
+			if _, err := fmt.Fprintln(writer, "METRIC\tRESOURCE\tLATEST\tTIMESTAMP\tLABELS"); err != nil {
+				return fmt.Errorf("failure writing series header: %w", err)
+			}
+		}
+		it := client.ListTimeSeries(ctx, &req)
+		for {
+			response, err := it.Next()
+			switch {
+			case errors.Is(err, iterator.Done):
+				return nil
+			case err != nil:
+				return fmt.Errorf("failure getting list of time-series: %w", err)
+			case viper.GetBool(JSONFlagName):
+				fmt.Println(protojson.Format(response)) //nolint:forbidigo // The user has requested that the matching time-series be printed to stdout
+			default:
+				if err := writeSeriesRow(writer, response); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// Write a tab-separated summary of the time-series, using the most recent point
+// as the latest value.
+func writeSeriesRow(writer io.Writer, series *monitoringpb.TimeSeries) error {
+	latest, timestamp := "", ""
+	// Cloud Monitoring returns points in reverse time order.
+	if len(series.Points) > 0 {
+		latest = typedValueString(series.Points[0].GetValue())
+		timestamp = series.Points[0].GetInterval().GetEndTime().AsTime().Format(time.RFC3339)
+	}
+	if _, err := fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", series.GetMetric().GetType(), series.GetResource().GetType(), latest, timestamp, formatLabels(series.GetResource().GetLabels())); err != nil {
+		return fmt.Errorf("failure writing series row: %w", err)
+	}
+	return nil
+}
+
+// Returns the labels as a comma-separated list of key=value pairs, sorted by key.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The generator state that is persisted to the --state-file, so that a restarted
+// generator continues the waveform of the previous run, with the same random
+// values, e.g. the node_id label of generic_node resources.
+type generatorState struct {
+	// The time from which the phase of the waveform is calculated.
+	TimeZero time.Time `json:"timeZero"`
+	// The seed for random values generated by the pipeline.
+	Seed int64 `json:"seed"`
+}
+
+// Returns the generator state read from the file at path, or a new state with a
+// time zero of now and a random seed if the file does not exist. If seed is not
+// zero it replaces the seed of the state.
+func loadGeneratorState(path string, now time.Time, seed int64) (generatorState, error) {
+	state := generatorState{
+		TimeZero: now,
+		Seed:     seed,
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logger.V(1).Info("State file does not exist; starting a new waveform", "path", path)
+	case err != nil:
+		return state, fmt.Errorf("failed to read state file: %w", err)
+	default:
+		var saved generatorState
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return state, fmt.Errorf("failed to parse state file %q: %w", path, err)
+		}
+		if !saved.TimeZero.IsZero() {
+			state.TimeZero = saved.TimeZero
+		}
+		if seed == 0 {
+			state.Seed = saved.Seed
+		}
+	}
+	for state.Seed == 0 {
+		state.Seed = rand.Int64() //nolint:gosec // The seed is used for synthetic values, not security
+	}
+	return state, nil
+}
+
+// Write the generator state to the file at path. The state is written to a
+// temporary file that replaces path, so that an interrupted write cannot leave a
+// truncated file.
+func saveGeneratorState(path string, state generatorState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generator state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main //nolint:testpackage // These tests need access to the unexported command helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A state saved by one run should be loaded unchanged by the next, so that the
+// waveform resumes with the same phase and random values.
+func TestGeneratorStateRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state.json")
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	state, err := loadGeneratorState(path, start, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing state file: %v", err)
+	}
+	if !state.TimeZero.Equal(start) {
+		t.Errorf("Expected new state to start at %v, got %v", start, state.TimeZero)
+	}
+	if state.Seed == 0 {
+		t.Error("Expected new state to have a random non-zero seed")
+	}
+	if err := saveGeneratorState(path, state); err != nil {
+		t.Fatalf("Unexpected error saving state: %v", err)
+	}
+	restarted, err := loadGeneratorState(path, start.Add(37*time.Minute), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error loading state: %v", err)
+	}
+	if !restarted.TimeZero.Equal(state.TimeZero) || restarted.Seed != state.Seed {
+		t.Errorf("Expected loaded state to be %+v, got %+v", state, restarted)
+	}
+	// An explicit seed takes precedence over the saved seed.
+	seeded, err := loadGeneratorState(path, start, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error loading state: %v", err)
+	}
+	if !seeded.TimeZero.Equal(state.TimeZero) || seeded.Seed != 42 {
+		t.Errorf("Expected loaded state to keep time zero %v with seed 42, got %+v", state.TimeZero, seeded)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Failed to read state directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestLoadGeneratorStateInvalid(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	if _, err := loadGeneratorState(path, time.Now(), 0); err == nil {
+		t.Error("Expected an error loading an invalid state file")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newStreamCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stream [flags] NAME",
+		Short: "Write metrics for values read from stdin",
+		Long: `Read newline-delimited values from stdin, and write each one to Google Cloud Monitoring as soon as it is received, timestamped with the time it was read. Use this when another process calculates the values to send.
+
+Lines that are not finite integer or floating point values are logged and skipped. The command exits when stdin is closed.`,
+		Example: "vmstat -n 60 | awk 'NR > 2 {print $13; fflush()}' | " + AppName + " stream --project ID custom.googleapis.com/syntheticScaler/cpu",
+		PreRunE: bindPipelineFlags,
+		RunE:    streamMain,
+		Args:    cobra.ExactArgs(1),
+	}
+	addPipelineFlags(cmd)
+	return cmd
+}
+
+func streamMain(cmd *cobra.Command, args []string) error {
+	project := viper.GetString(ProjectIDFlagName)
+	dryRun := viper.GetBool(DryRunFlagName)
+	asInteger := viper.GetBool(IntegerFlagName)
+	location := viper.GetString(LocationFlagName)
+	namespace := viper.GetString(NamespaceFlagName)
+	validateOnly := viper.GetBool(ValidateOnlyFlagName)
+	sequenceLabel := viper.GetString(SequenceLabelFlagName)
+	logger := logger.WithValues("project", project, "dryRun", dryRun, "asInteger", asInteger, "location", location, "namespace", namespace, "validateOnly", validateOnly, "sequenceLabel", sequenceLabel)
+	logger.V(0).Info("Building metric stream pipeline")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	streamGenerator, reader, err := generators.NewStreamGenerator(cmd.InOrStdin(), generators.WithLogger(logger))
+	if err != nil {
+		return fmt.Errorf("failure building StreamGenerator: %w", err)
+	}
+	pipelineOptions, err := generatorPipelineOptions(cmd, logger, args[0])
+	if err != nil {
+		return err
+	}
+	pipe, err := pipeline.NewPipeline(ctx, pipelineOptions...)
+	if err != nil {
+		return fmt.Errorf("failure creating new pipeline: %w", err)
+	}
+	defer func() {
+		logger.V(2).Info("Closing pipeline")
+		if err := pipe.Close(); err != nil {
+			logger.Error(err, "Error returned while closing pipeline")
+		}
+	}()
+	if validateOnly {
+		return validatePipeline(ctx, pipe, generators.Metric{
+			Value:     0.0,
+			Timestamp: time.Now(),
+		})
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.V(1).Info("Launching stream generator")
+		errs <- streamGenerator(ctx)
+	}()
+	if err := pipe.Processor()(ctx, reader); err != nil {
+		return fmt.Errorf("failure writing stream metrics: %w", err)
+	}
+	// The processor exits when the generator closes the channel at the end of
+	// the input, or when the context is cancelled; a cancelled generator may
+	// still be blocked reading stdin, so don't wait for it.
+	select {
+	case err := <-errs:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		logger.V(1).Info("Context has been cancelled")
+	}
+	logger.V(0).Info("Stream complete")
+	return nil
+}
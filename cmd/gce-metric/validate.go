@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+)
+
+// Build a single time-series request from the pipeline and verify it against
+// the descriptors known to Google Cloud Monitoring, connecting with the same
+// client options as the pipeline; nothing is written.
+func validatePipeline(ctx context.Context, pipe *pipeline.Pipeline, metric generators.Metric) error {
+	logger.V(0).Info("Validating time-series request")
+	if err := pipe.Validate(ctx, metric); err != nil {
+		return fmt.Errorf("time-series request failed validation: %w", err)
+	}
+	logger.V(0).Info("Time-series request is consistent with Google Cloud Monitoring descriptors")
+	return nil
+}
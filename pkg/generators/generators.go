@@ -3,13 +3,26 @@
 package generators
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 )
 
+var (
+	ErrInvalidSampleInterval = errors.New("sample interval must be greater than zero")
+	ErrInvalidTimeRange      = errors.New("end of time range must not be before the start")
+	ErrInvalidPeriod         = errors.New("period must be greater than zero")
+)
+
 // Metric represents a point-in-time generated value which will be written
 // to the output channel of the PeriodicGenerator function.
 type Metric struct {
@@ -24,13 +37,31 @@ type Metric struct {
 // on the ticker channel.
 type PeriodicGenerator func(context.Context, <-chan time.Time)
 
+// Defines a function that will block until the input is exhausted or the
+// context is cancelled, emitting a Metric value to the output channel for each
+// value read from the input.
+type StreamGenerator func(context.Context) error
+
 // Accumulates the fluent configuration options that will be used to create the
 // PeriodicGenerator function and Metic channel.
 type config struct {
 	logger     logr.Logger
-	calculator ValueCalculator
+	calculator TimedValueCalculator
 	period     time.Duration
 	bufferSize int
+	immediate  bool
+	blocking   bool
+	// When virtualStep is greater than zero, generated values are timestamped
+	// from a virtual clock that starts at virtualStart and advances by
+	// virtualStep for each value, instead of with the time of each tick.
+	virtualStart time.Time
+	virtualStep  time.Duration
+	// When timeZero is set, the phase of values generated by a PeriodicGenerator
+	// function is calculated from it instead of from the first tick.
+	timeZero time.Time
+	// When wallClockPhase is true, the phase of values generated by a
+	// PeriodicGenerator function is calculated from the Unix epoch.
+	wallClockPhase bool
 }
 
 // Defines a generator configuration option function.
@@ -46,6 +77,22 @@ func WithLogger(logger logr.Logger) Option {
 
 // Use the supplied ValueCalculator as the point-in-time generator function.
 func WithValueCalculator(calculator ValueCalculator) Option {
+	return func(c *config) error {
+		c.calculator = timed(calculator)
+		return nil
+	}
+}
+
+// Returns a TimedValueCalculator that ignores the timestamp.
+func timed(calculator ValueCalculator) TimedValueCalculator {
+	return func(phase float64, _ time.Time) float64 {
+		return calculator(phase)
+	}
+}
+
+// Use the supplied TimedValueCalculator as the point-in-time generator function,
+// e.g. to apply seasonal cycles that depend on the timestamp of each value.
+func WithTimedValueCalculator(calculator TimedValueCalculator) Option {
 	return func(c *config) error {
 		c.calculator = calculator
 		return nil
@@ -61,6 +108,68 @@ func WithPeriod(period time.Duration) Option {
 	}
 }
 
+// Generate a Metric value as soon as the PeriodicGenerator function is called,
+// without waiting for the first tick. The immediate value is treated as the
+// first tick when calculating the phase of later values.
+func WithEmitImmediately() Option {
+	return func(c *config) error {
+		c.immediate = true
+		return nil
+	}
+}
+
+// When blocking is true, the PeriodicGenerator function will wait for the output
+// channel to accept each Metric value, or for the context to be cancelled,
+// instead of dropping values that can't be written immediately. Note that ticks
+// received while waiting may be dropped by the ticker.
+func WithBlockingOutput(blocking bool) Option {
+	return func(c *config) error {
+		c.blocking = blocking
+		return nil
+	}
+}
+
+// Timestamp the values generated by a PeriodicGenerator function from a virtual
+// clock that starts at start and advances by step for every value, instead of
+// using the time of each tick. The ticker still controls when values are
+// generated, but the sequence of values and timestamps is the same on every run,
+// which makes the output suitable for golden tests. The option has no effect on
+// other generators.
+func WithVirtualClock(start time.Time, step time.Duration) Option {
+	return func(c *config) error {
+		if step <= 0 {
+			return ErrInvalidSampleInterval
+		}
+		c.virtualStart = start
+		c.virtualStep = step
+		return nil
+	}
+}
+
+// Calculate the phase of values generated by a PeriodicGenerator function from
+// timeZero instead of from the first tick, e.g. to continue the waveform of an
+// earlier run without a discontinuity. The option has no effect on other
+// generators, or when combined with WithVirtualClock.
+func WithTimeZero(timeZero time.Time) Option {
+	return func(c *config) error {
+		c.timeZero = timeZero
+		return nil
+	}
+}
+
+// Calculate the phase of values generated by a PeriodicGenerator function from
+// the timestamp of each tick relative to the Unix epoch, as NewMetric does,
+// instead of from the first tick. Cycles then start on wall-clock multiples of
+// the period, e.g. on the hour for a 60 minute period, and a late or jittered
+// first tick does not shift the phase of every later value. The option takes
+// precedence over WithTimeZero, and has no effect on other generators.
+func WithWallClockPhase() Option {
+	return func(c *config) error {
+		c.wallClockPhase = true
+		return nil
+	}
+}
+
 // Returns a PeriodicGenerator function that will generate a Metric value on each
 // tick, and a read-only channel that will receive the generated value.
 // The default generator is a sawtooth waveform in the range 0 <= value <= 100
@@ -68,22 +177,73 @@ func WithPeriod(period time.Duration) Option {
 // The various Option functions can be used to change this.
 func NewPeriodicGenerator(options ...Option) (PeriodicGenerator, <-chan Metric, error) {
 	config := &config{
-		logger:     logr.Discard(),
-		calculator: NewPeriodicRangeCalculator(0.0, 100.0, Sawtooth),
-		period:     20 * time.Minute,
-		bufferSize: 1,
+		logger:         logr.Discard(),
+		calculator:     timed(NewPeriodicRangeCalculator(0.0, 100.0, Sawtooth)),
+		period:         20 * time.Minute,
+		bufferSize:     1,
+		immediate:      false,
+		blocking:       false,
+		virtualStart:   time.Time{},
+		virtualStep:    0,
+		timeZero:       time.Time{},
+		wallClockPhase: false,
 	}
 	for _, option := range options {
 		if err := option(config); err != nil {
 			return nil, nil, err
 		}
 	}
+	if config.wallClockPhase && config.period <= 0 {
+		return nil, nil, ErrInvalidPeriod
+	}
 	config.logger.V(2).Info("Building PeriodicGenerator and channel")
 	ch := make(chan Metric, config.bufferSize)
 	return func(ctx context.Context, ticker <-chan time.Time) {
 		defer close(ch)
 		var firstTick sync.Once
 		var tZero time.Time
+		var count int64
+		generate := func(tick time.Time) {
+			if config.virtualStep > 0 {
+				tick = config.virtualStart.Add(time.Duration(count) * config.virtualStep)
+				count++
+			}
+			// Set tZero to the timestamp of the first received tick, unless
+			// an explicit time zero was given for a real-time clock
+			firstTick.Do(func() {
+				tZero = tick
+				if !config.timeZero.IsZero() && config.virtualStep == 0 {
+					tZero = config.timeZero
+				}
+			})
+			phase := tick.Sub(tZero).Seconds() / config.period.Seconds()
+			if config.wallClockPhase {
+				phase = epochPhase(tick, config.period)
+			}
+			metric := Metric{
+				Value:     config.calculator(phase, tick),
+				Timestamp: tick,
+			}
+			if config.blocking {
+				select {
+				case ch <- metric:
+					config.logger.V(2).Info("Wrote new value to output channel", "metric", metric)
+				case <-ctx.Done():
+					config.logger.V(2).Info("Context has been cancelled; dropping value", "metric", metric)
+				}
+				return
+			}
+			select {
+			case ch <- metric:
+				config.logger.V(2).Info("Wrote new value to output channel", "metric", metric)
+			default:
+				config.logger.V(2).Info("Can't write to output channel; dropping value", "metric", metric)
+			}
+		}
+		if config.immediate {
+			config.logger.V(2).Info("Generating immediate value")
+			generate(time.Now())
+		}
 		for {
 			select {
 			case <-ctx.Done():
@@ -92,19 +252,155 @@ func NewPeriodicGenerator(options ...Option) (PeriodicGenerator, <-chan Metric,
 			// NOTE: ticker channel is never closed; context must reach
 			// a deadline or be cancelled to prevent deadlock.
 			case tick := <-ticker:
-				// Set tZero to the timestamp of the first received tick
-				firstTick.Do(func() { tZero = tick })
-				metric := Metric{
-					Value:     config.calculator(tick.Sub(tZero).Seconds() / config.period.Seconds()),
-					Timestamp: tick,
-				}
-				select {
-				case ch <- metric:
-					config.logger.V(2).Info("Wrote new value to output channel", "metric", metric)
-				default:
-					config.logger.V(2).Info("Can't write to output channel; dropping value", "metric", metric)
-				}
+				generate(tick)
+			}
+		}
+	}, ch, nil
+}
+
+// Returns a slice of Metric values calculated at every sample interval from start
+// up to and including end, sorted by ascending timestamp. The phase of each value
+// is calculated relative to start, so the first Metric will always be at the
+// beginning of a cycle. The default generator configuration is the same as for
+// NewPeriodicGenerator, and the same Option functions can be used to change it.
+func NewRangeMetrics(start, end time.Time, sample time.Duration, options ...Option) ([]Metric, error) {
+	if sample <= 0 {
+		return nil, ErrInvalidSampleInterval
+	}
+	if end.Before(start) {
+		return nil, ErrInvalidTimeRange
+	}
+	config := &config{
+		logger:         logr.Discard(),
+		calculator:     timed(NewPeriodicRangeCalculator(0.0, 100.0, Sawtooth)),
+		period:         20 * time.Minute,
+		bufferSize:     1,
+		immediate:      false,
+		blocking:       false,
+		virtualStart:   time.Time{},
+		virtualStep:    0,
+		timeZero:       time.Time{},
+		wallClockPhase: false,
+	}
+	for _, option := range options {
+		if err := option(config); err != nil {
+			return nil, err
+		}
+	}
+	config.logger.V(2).Info("Building range of metrics", "start", start, "end", end, "sample", sample)
+	metrics := make([]Metric, 0, int(end.Sub(start)/sample)+1)
+	for timestamp := start; !timestamp.After(end); timestamp = timestamp.Add(sample) {
+		metrics = append(metrics, Metric{
+			Value:     config.calculator(timestamp.Sub(start).Seconds()/config.period.Seconds(), timestamp),
+			Timestamp: timestamp,
+		})
+	}
+	return metrics, nil
+}
+
+// Returns a single Metric for the timestamp. The phase of the value is calculated
+// relative to the Unix epoch, so values generated by repeated calls, e.g. from a
+// scheduled job, follow the waveform as if they came from a single generator.
+// The default generator configuration is the same as for NewPeriodicGenerator,
+// and the same Option functions can be used to change it.
+func NewMetric(timestamp time.Time, options ...Option) (Metric, error) {
+	config := &config{
+		logger:         logr.Discard(),
+		calculator:     timed(NewPeriodicRangeCalculator(0.0, 100.0, Sawtooth)),
+		period:         20 * time.Minute,
+		bufferSize:     1,
+		immediate:      false,
+		blocking:       false,
+		virtualStart:   time.Time{},
+		virtualStep:    0,
+		timeZero:       time.Time{},
+		wallClockPhase: false,
+	}
+	for _, option := range options {
+		if err := option(config); err != nil {
+			return Metric{}, err
+		}
+	}
+	if config.period <= 0 {
+		return Metric{}, ErrInvalidPeriod
+	}
+	metric := Metric{
+		Value:     config.calculator(epochPhase(timestamp, config.period), timestamp),
+		Timestamp: timestamp,
+	}
+	config.logger.V(2).Info("Built single metric", "metric", metric)
+	return metric, nil
+}
+
+// Returns the phase of the timestamp within a cycle of period that starts at the
+// Unix epoch, in the range 0 <= phase < 1.
+func epochPhase(timestamp time.Time, period time.Duration) float64 {
+	offset := timestamp.UnixNano() % period.Nanoseconds()
+	if offset < 0 {
+		offset += period.Nanoseconds()
+	}
+	return float64(offset) / float64(period.Nanoseconds())
+}
+
+// Returns a StreamGenerator function that reads newline-delimited values from
+// input, and a read-only channel that will receive a Metric for each value,
+// timestamped when it was read. Blank lines are ignored, and lines that are not
+// finite floating point values are logged and skipped. The output channel is
+// closed when the StreamGenerator function returns. Only the WithLogger Option
+// applies to stream generators; the output always blocks until each Metric is
+// accepted, so values are never dropped.
+func NewStreamGenerator(input io.Reader, options ...Option) (StreamGenerator, <-chan Metric, error) {
+	config := &config{
+		logger:         logr.Discard(),
+		calculator:     nil,
+		period:         0,
+		bufferSize:     1,
+		immediate:      false,
+		blocking:       true,
+		virtualStart:   time.Time{},
+		virtualStep:    0,
+		timeZero:       time.Time{},
+		wallClockPhase: false,
+	}
+	for _, option := range options {
+		if err := option(config); err != nil {
+			return nil, nil, err
+		}
+	}
+	config.logger.V(2).Info("Building StreamGenerator and channel")
+	ch := make(chan Metric, config.bufferSize)
+	return func(ctx context.Context) error {
+		defer close(ch)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(line, 64)
+			if err == nil && (math.IsNaN(value) || math.IsInf(value, 0)) {
+				err = strconv.ErrRange
+			}
+			if err != nil {
+				config.logger.Error(err, "Skipping malformed value", "line", line)
+				continue
+			}
+			metric := Metric{
+				Value:     value,
+				Timestamp: time.Now(),
 			}
+			select {
+			case ch <- metric:
+				config.logger.V(2).Info("Wrote new value to output channel", "metric", metric)
+			case <-ctx.Done():
+				config.logger.V(2).Info("Context has been cancelled; exiting")
+				return nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failure reading stream input: %w", err)
 		}
+		config.logger.V(2).Info("Input is exhausted; exiting")
+		return nil
 	}, ch, nil
 }
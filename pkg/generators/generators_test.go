@@ -2,8 +2,13 @@ package generators_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -96,6 +101,343 @@ func TestPeriodicGeneratorTimeout(t *testing.T) {
 	}
 }
 
+// Verify that the periodic generator function will emit a value at the start of
+// the cycle before any tick is received when WithEmitImmediately is used.
+func TestPeriodicGeneratorEmitImmediately(t *testing.T) {
+	t.Parallel()
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+		generators.WithLogger(logr.Discard()),
+		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(1.0, 10.0, generators.Sawtooth)),
+		generators.WithPeriod(1*time.Minute),
+		generators.WithEmitImmediately(),
+	)
+	if err != nil {
+		t.Fatalf("NewPeriodicGenerator raised an error: %v", err)
+	}
+	// The ticker channel never fires, so any value must be the immediate one.
+	ticker := make(chan time.Time)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	start := time.Now()
+	go periodicGenerator(ctx, ticker)
+	metric, ok := <-reader
+	if !ok {
+		t.Fatal("Expected an immediate value before the reader channel was closed")
+	}
+	if metric.Timestamp.Before(start) {
+		t.Errorf("Expected immediate value timestamp to be after %v, got %v", start, metric.Timestamp)
+	}
+	if math.Abs(metric.Value-1.0) > generatorTolerance {
+		t.Errorf("Expected immediate value to be %f, got %f", 1.0, metric.Value)
+	}
+}
+
+// The phase of the first value should be calculated from the time zero, so that
+// a restarted generator continues the waveform.
+func TestPeriodicGeneratorTimeZero(t *testing.T) {
+	t.Parallel()
+	timeZero := time.Unix(1_700_000_000, 0)
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(0.0, 10.0, generators.Sawtooth)),
+		generators.WithPeriod(10*time.Minute),
+		generators.WithTimeZero(timeZero),
+	)
+	if err != nil {
+		t.Fatalf("NewPeriodicGenerator raised an error: %v", err)
+	}
+	ticker := make(chan time.Time, 2)
+	ticker <- timeZero.Add(23 * time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	go periodicGenerator(ctx, ticker)
+	metric, ok := <-reader
+	if !ok {
+		t.Fatal("Expected a value before the reader channel was closed")
+	}
+	if math.Abs(metric.Value-3.0) > generatorTolerance {
+		t.Errorf("Expected first value to continue the waveform at %f, got %f", 3.0, metric.Value)
+	}
+}
+
+// Verify that the phase of every value is calculated from the wall-clock time of
+// its tick when WithWallClockPhase is used, so that a jittered first tick does
+// not shift the phase of later values.
+func TestPeriodicGeneratorWallClockPhase(t *testing.T) {
+	// The nominal tick times are one minute apart, starting at the beginning of
+	// a 10 minute cycle since the Unix epoch.
+	start := time.Unix(1_700_000_400, 0)
+	jitter := []time.Duration{700 * time.Millisecond, -300 * time.Millisecond, 1200 * time.Millisecond, -50 * time.Millisecond, 0, 400 * time.Millisecond}
+	tests := []struct {
+		name           string
+		wallClockPhase bool
+		// The offset of the phase of each value from its nominal tick time,
+		// in seconds.
+		expectedOffset func(i int) float64
+	}{
+		{
+			// The phase is relative to the first tick, so every value is offset
+			// by the jitter of its own tick less the jitter of the first tick.
+			name: "first-tick",
+			expectedOffset: func(i int) float64 {
+				return (jitter[i] - jitter[0]).Seconds()
+			},
+		},
+		{
+			// The phase only depends on the jitter of each tick.
+			name:           "wall-clock",
+			wallClockPhase: true,
+			expectedOffset: func(i int) float64 {
+				return jitter[i].Seconds()
+			},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options := []generators.Option{
+				generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(0.0, 10.0, generators.Sawtooth)),
+				generators.WithPeriod(10 * time.Minute),
+				generators.WithBlockingOutput(true),
+			}
+			if tst.wallClockPhase {
+				options = append(options, generators.WithWallClockPhase())
+			}
+			periodicGenerator, reader, err := generators.NewPeriodicGenerator(options...)
+			if err != nil {
+				t.Fatalf("NewPeriodicGenerator raised an error: %v", err)
+			}
+			ticker := make(chan time.Time, len(jitter))
+			for i, offset := range jitter {
+				ticker <- start.Add(time.Duration(i)*time.Minute + offset)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			go periodicGenerator(ctx, ticker)
+			for i := range jitter {
+				metric, ok := <-reader
+				if !ok {
+					t.Fatalf("Reader channel was closed after %d values, expected %d", i, len(jitter))
+				}
+				// The sawtooth rises by 1.0 every minute of the 10 minute period.
+				expected := math.Mod(float64(i)+tst.expectedOffset(i)/60.0+10.0, 10.0)
+				if math.Abs(metric.Value-expected) > generatorTolerance {
+					t.Errorf("Expected value %d to be %f, got %f", i, expected, metric.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestWithWallClockPhaseInvalidPeriod(t *testing.T) {
+	t.Parallel()
+	if _, _, err := generators.NewPeriodicGenerator(generators.WithPeriod(0), generators.WithWallClockPhase()); !errors.Is(err, generators.ErrInvalidPeriod) {
+		t.Errorf("Expected NewPeriodicGenerator to raise %v, got %v", generators.ErrInvalidPeriod, err)
+	}
+}
+
+// Verify that the periodic generator function waits for a slow reader, without
+// dropping any values, when WithBlockingOutput is used.
+func TestPeriodicGeneratorBlockingOutput(t *testing.T) {
+	t.Parallel()
+	const tickCount = 10
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+		generators.WithLogger(logr.Discard()),
+		generators.WithValueCalculator(generators.Sawtooth.ValueCalculator()),
+		generators.WithPeriod(1*time.Minute),
+		generators.WithBlockingOutput(true),
+	)
+	if err != nil {
+		t.Fatalf("NewPeriodicGenerator raised an error: %v", err)
+	}
+	ticker := make(chan time.Time)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go periodicGenerator(ctx, ticker)
+	start := time.Now()
+	go func() {
+		for i := range tickCount {
+			select {
+			case <-ctx.Done():
+				return
+			case ticker <- start.Add(time.Duration(i) * time.Second):
+			}
+		}
+	}()
+	for i := range tickCount {
+		// Read slower than the ticks are sent.
+		time.Sleep(20 * time.Millisecond)
+		metric, ok := <-reader
+		if !ok {
+			t.Fatalf("Reader channel was closed after %d values, expected %d", i, tickCount)
+		}
+		if expected := start.Add(time.Duration(i) * time.Second); !metric.Timestamp.Equal(expected) {
+			t.Errorf("Expected value %d to have timestamp %v, got %v", i, expected, metric.Timestamp)
+		}
+	}
+	cancel()
+	if _, ok := <-reader; ok {
+		t.Error("Expected reader channel to be closed")
+	}
+}
+
+// Verify that the periodic generator function produces the same values and
+// timestamps regardless of the time of each tick when WithVirtualClock is used.
+func TestPeriodicGeneratorVirtualClock(t *testing.T) {
+	t.Parallel()
+	const tickCount = 10
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected := []float64{0.0, 10.0, 20.0, 30.0, 40.0, 50.0, 60.0, 70.0, 80.0, 90.0}
+	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
+		generators.WithLogger(logr.Discard()),
+		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(0.0, 100.0, generators.Sawtooth)),
+		generators.WithPeriod(10*time.Minute),
+		generators.WithBlockingOutput(true),
+		generators.WithVirtualClock(start, time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewPeriodicGenerator raised an error: %v", err)
+	}
+	ticker := make(chan time.Time)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go periodicGenerator(ctx, ticker)
+	go func() {
+		// The wall-clock ticks are irregular, and unrelated to the virtual
+		// clock.
+		for i := range tickCount {
+			select {
+			case <-ctx.Done():
+				return
+			case ticker <- time.Now().Add(time.Duration(i*i) * time.Millisecond):
+			}
+		}
+	}()
+	values := make([]float64, 0, tickCount)
+	for i := range tickCount {
+		metric, ok := <-reader
+		if !ok {
+			t.Fatalf("Reader channel was closed after %d values, expected %d", i, tickCount)
+		}
+		if expectedTimestamp := start.Add(time.Duration(i) * time.Minute); !metric.Timestamp.Equal(expectedTimestamp) {
+			t.Errorf("Expected value %d to have timestamp %v, got %v", i, expectedTimestamp, metric.Timestamp)
+		}
+		values = append(values, math.Round(metric.Value*1e6)/1e6)
+	}
+	if !slices.Equal(values, expected) {
+		t.Errorf("Expected values %v, got %v", expected, values)
+	}
+}
+
+func TestWithVirtualClockInvalidStep(t *testing.T) {
+	t.Parallel()
+	if _, _, err := generators.NewPeriodicGenerator(generators.WithVirtualClock(time.Now(), 0)); !errors.Is(err, generators.ErrInvalidSampleInterval) {
+		t.Errorf("Expected NewPeriodicGenerator to raise %v, got %v", generators.ErrInvalidSampleInterval, err)
+	}
+}
+
+// Verify that NewRangeMetrics returns every sample in the range, in ascending
+// order, with phase calculated from the start of the range.
+func TestNewRangeMetrics(t *testing.T) {
+	t.Parallel()
+	start := time.Unix(1000, 0)
+	end := start.Add(10 * time.Minute)
+	metrics, err := generators.NewRangeMetrics(start, end, time.Minute,
+		generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(0.0, 10.0, generators.Sawtooth)),
+		generators.WithPeriod(10*time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewRangeMetrics raised an error: %v", err)
+	}
+	if len(metrics) != 11 {
+		t.Fatalf("Expected 11 metrics, got %d", len(metrics))
+	}
+	for i, metric := range metrics {
+		expectedTimestamp := start.Add(time.Duration(i) * time.Minute)
+		if !metric.Timestamp.Equal(expectedTimestamp) {
+			t.Errorf("Expected metric %d to have timestamp %v, got %v", i, expectedTimestamp, metric.Timestamp)
+		}
+		expectedValue := float64(i % 10)
+		if math.Abs(metric.Value-expectedValue) > generatorTolerance {
+			t.Errorf("Expected metric %d to have value %f, got %f", i, expectedValue, metric.Value)
+		}
+	}
+}
+
+func TestNewRangeMetricsErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		start         time.Time
+		end           time.Time
+		sample        time.Duration
+		expectedError error
+	}{
+		{
+			name:          "zero-sample",
+			start:         time.Unix(0, 0),
+			end:           time.Unix(60, 0),
+			sample:        0,
+			expectedError: generators.ErrInvalidSampleInterval,
+		},
+		{
+			name:          "negative-sample",
+			start:         time.Unix(0, 0),
+			end:           time.Unix(60, 0),
+			sample:        -time.Second,
+			expectedError: generators.ErrInvalidSampleInterval,
+		},
+		{
+			name:          "reversed",
+			start:         time.Unix(60, 0),
+			end:           time.Unix(0, 0),
+			sample:        time.Second,
+			expectedError: generators.ErrInvalidTimeRange,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := generators.NewRangeMetrics(tst.start, tst.end, tst.sample)
+			if !errors.Is(err, tst.expectedError) {
+				t.Errorf("Expected NewRangeMetrics to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Values from NewMetric should follow the waveform with the phase relative to
+// the Unix epoch.
+func TestNewMetric(t *testing.T) {
+	t.Parallel()
+	for _, seconds := range []int64{0, 150, 600, 1_700_000_450, -150} {
+		timestamp := time.Unix(seconds, 0)
+		metric, err := generators.NewMetric(timestamp,
+			generators.WithValueCalculator(generators.NewPeriodicRangeCalculator(0.0, 10.0, generators.Sawtooth)),
+			generators.WithPeriod(10*time.Minute),
+		)
+		if err != nil {
+			t.Fatalf("NewMetric raised an error: %v", err)
+		}
+		if !metric.Timestamp.Equal(timestamp) {
+			t.Errorf("Expected metric to have timestamp %v, got %v", timestamp, metric.Timestamp)
+		}
+		expectedValue := float64(((seconds%600)+600)%600) / 60.0
+		if math.Abs(metric.Value-expectedValue) > generatorTolerance {
+			t.Errorf("Expected metric at %d to have value %f, got %f", seconds, expectedValue, metric.Value)
+		}
+	}
+}
+
+func TestNewMetricInvalidPeriod(t *testing.T) {
+	t.Parallel()
+	if _, err := generators.NewMetric(time.Now(), generators.WithPeriod(0)); !errors.Is(err, generators.ErrInvalidPeriod) {
+		t.Errorf("Expected NewMetric to raise %v, got %v", generators.ErrInvalidPeriod, err)
+	}
+}
+
 func Example() { //nolint:testableexamples // The output would include a timestamp
 	// Create the timestamped value generator
 	periodicGenerator, reader, err := generators.NewPeriodicGenerator(
@@ -124,3 +466,61 @@ loop:
 		}
 	}
 }
+
+func TestStreamGenerator(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reader, writer := io.Pipe()
+	generator, metrics, err := generators.NewStreamGenerator(reader)
+	if err != nil {
+		t.Fatalf("NewStreamGenerator raised an unexpected error: %v", err)
+	}
+	go func() {
+		// Malformed, blank, and non-finite lines must be skipped.
+		_, err := io.WriteString(writer, "1\nnot-a-number\n\n2.5\nNaN\n -3 \n")
+		_ = writer.CloseWithError(err)
+	}()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- generator(ctx)
+	}()
+	values := []float64{}
+	for metric := range metrics {
+		if metric.Timestamp.IsZero() {
+			t.Errorf("Expected metric to have a timestamp: %v", metric)
+		}
+		values = append(values, metric.Value)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Generator raised an unexpected error: %v", err)
+	}
+	expected := []float64{1.0, 2.5, -3.0}
+	if !slices.Equal(expected, values) {
+		t.Errorf("Expected values %v, got %v", expected, values)
+	}
+}
+
+func TestStreamGeneratorCancel(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	// The output channel is never read, so the generator must exit when the
+	// context is cancelled.
+	generator, _, err := generators.NewStreamGenerator(strings.NewReader("1\n2\n3\n"))
+	if err != nil {
+		t.Fatalf("NewStreamGenerator raised an unexpected error: %v", err)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- generator(ctx)
+	}()
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Generator raised an unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Generator did not exit after the context was cancelled")
+	}
+}
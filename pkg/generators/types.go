@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"sync"
+	"time"
 )
 
 // Defines the periodic function generators known to the package.
@@ -13,6 +16,11 @@ type PeriodicType int
 // phase of the cycle.
 type ValueCalculator func(phase float64) float64
 
+// Defines a function that will return a float64 value for the given phase of the
+// cycle and the absolute time of the value, for calculators that depend on the
+// time of day or week.
+type TimedValueCalculator func(phase float64, timestamp time.Time) float64
+
 const (
 	// Represents an unrecognised periodic function that will return 0.0 on
 	// all calls.
@@ -32,9 +40,40 @@ const (
 	// linearly from 0.0 to 1.0 over first half cycle, then falling linearly
 	// to 0.0 for second half of cycle.
 	Triangle
+	// Represents a function that rises linearly from 0.0 to 1.0 over the first
+	// cycle, then holds at 1.0; unlike the other types it does not repeat.
+	Ramp
+	// Represents a periodic function that generates a trapezoid wave, rising
+	// linearly from 0.0 to 1.0, holding at 1.0, falling linearly to 0.0, then
+	// holding at 0.0 for the rest of the cycle. The ValueCalculator of the type
+	// spends DefaultRiseFraction of each cycle rising and falling, and
+	// DefaultHoldFraction holding at 1.0; use NewTrapezoidCalculator for other
+	// shapes.
+	Trapezoid
+)
+
+const (
+	// The default fraction of a trapezoid cycle spent rising, and spent falling.
+	DefaultRiseFraction = 0.25
+	// The default fraction of a trapezoid cycle spent holding at the maximum.
+	DefaultHoldFraction = 0.25
 )
 
-var ErrInvalidPeriodicType = errors.New("invalid PeriodicType name")
+var (
+	ErrInvalidPeriodicType   = errors.New("invalid PeriodicType name")
+	ErrInvalidTrapezoidShape = errors.New("trapezoid rise and hold fractions must not be negative, and twice the rise plus the hold must not exceed 1")
+)
+
+// Returns every valid PeriodicType known to the package, in declaration order,
+// e.g. to list the available waveforms in a user interface.
+func AllPeriodicTypes() []PeriodicType {
+	return []PeriodicType{Sawtooth, Sine, Square, Triangle, Ramp, Trapezoid}
+}
+
+// Returns true if the PeriodicType is one of AllPeriodicTypes.
+func (pt PeriodicType) IsValid() bool {
+	return slices.Contains(AllPeriodicTypes(), pt)
+}
 
 // Returns a string identifier for the PeriodicType, or "unknown" if it is an
 // unrecognised type.
@@ -50,6 +89,10 @@ func (pt PeriodicType) String() string {
 		return "square"
 	case Triangle:
 		return "triangle"
+	case Ramp:
+		return "ramp"
+	case Trapezoid:
+		return "trapezoid"
 	default:
 		return "unknown"
 	}
@@ -84,6 +127,14 @@ func (pt PeriodicType) ValueCalculator() ValueCalculator {
 		return func(phase float64) float64 {
 			return math.Abs(2.0 * (phase - math.Floor(0.5+(phase))))
 		}
+	case Ramp:
+		return func(phase float64) float64 {
+			// Clamp the phase so the value holds at 1.0 after the first
+			// cycle.
+			return math.Min(math.Max(phase, 0.0), 1.0)
+		}
+	case Trapezoid:
+		return trapezoidCalculator(DefaultRiseFraction, DefaultHoldFraction)
 	default:
 		return func(_ float64) float64 {
 			return 0.0
@@ -103,18 +154,144 @@ func ParsePeriodicType(name string) (PeriodicType, error) {
 		return Square, nil
 	case "triangle":
 		return Triangle, nil
+	case "ramp":
+		return Ramp, nil
+	case "trapezoid":
+		return Trapezoid, nil
 	default:
 		return Invalid, fmt.Errorf("error parsing %q to PeriodicType: %w", name, ErrInvalidPeriodicType)
 	}
 }
 
 // Creates a new wrapped ValueCalculator from a PeriodicType that returns values
-// in the range a through b.
+// in the range a through b. The function panics with an error that wraps
+// ErrInvalidPeriodicType if the periodic type is not one of AllPeriodicTypes, as
+// the calculator would silently return a constant a; use ParsePeriodicType or
+// IsValid to check types from user input.
 func NewPeriodicRangeCalculator(a, b float64, periodicType PeriodicType) ValueCalculator {
+	if !periodicType.IsValid() {
+		panic(fmt.Errorf("cannot create a range calculator for PeriodicType %d: %w", int(periodicType), ErrInvalidPeriodicType))
+	}
+	return NewRangeCalculator(a, b, periodicType.ValueCalculator())
+}
+
+// Creates a new wrapped ValueCalculator that scales the values of unitCalculator,
+// which must be in the range 0.0 through 1.0, to the range a through b.
+func NewRangeCalculator(a, b float64, unitCalculator ValueCalculator) ValueCalculator {
 	minimumValue := math.Min(a, b)
 	delta := math.Abs(a - b)
-	unitCalculator := periodicType.ValueCalculator()
 	return func(phase float64) float64 {
 		return delta*unitCalculator(phase) + minimumValue
 	}
 }
+
+// Defines an option that changes the shape of a trapezoid wave.
+type TrapezoidOption func(*trapezoidShape)
+
+// The fractions of a trapezoid cycle spent rising, and falling, and holding at
+// the maximum value.
+type trapezoidShape struct {
+	rise float64
+	hold float64
+}
+
+// Sets the fraction of each trapezoid cycle spent rising from 0.0 to 1.0, which
+// is also the fraction spent falling back to 0.0.
+func WithRiseFraction(fraction float64) TrapezoidOption {
+	return func(shape *trapezoidShape) {
+		shape.rise = fraction
+	}
+}
+
+// Sets the fraction of each trapezoid cycle spent holding at 1.0, between rising
+// and falling.
+func WithHoldFraction(fraction float64) TrapezoidOption {
+	return func(shape *trapezoidShape) {
+		shape.hold = fraction
+	}
+}
+
+// Creates a new ValueCalculator for a trapezoid wave in the range 0.0 through 1.0,
+// with a shape of DefaultRiseFraction and DefaultHoldFraction unless changed by
+// the options. The wave holds at 0.0 for whatever is left of each cycle after
+// rising, holding at 1.0, and falling; ErrInvalidTrapezoidShape is returned if a
+// fraction is negative or they add up to more than a cycle.
+func NewTrapezoidCalculator(options ...TrapezoidOption) (ValueCalculator, error) {
+	shape := &trapezoidShape{
+		rise: DefaultRiseFraction,
+		hold: DefaultHoldFraction,
+	}
+	for _, option := range options {
+		option(shape)
+	}
+	if shape.rise < 0.0 || shape.hold < 0.0 || 2.0*shape.rise+shape.hold > 1.0 {
+		return nil, fmt.Errorf("%w: rise %v, hold %v", ErrInvalidTrapezoidShape, shape.rise, shape.hold)
+	}
+	return trapezoidCalculator(shape.rise, shape.hold), nil
+}
+
+// Returns a ValueCalculator for a trapezoid wave with a valid shape.
+func trapezoidCalculator(rise, hold float64) ValueCalculator {
+	return func(phase float64) float64 {
+		position := phase - math.Floor(phase)
+		switch {
+		case position < rise:
+			return position / rise
+		case position < rise+hold:
+			return 1.0
+		case position < 2.0*rise+hold:
+			return 1.0 - (position-rise-hold)/rise
+		default:
+			return 0.0
+		}
+	}
+}
+
+// Creates a new TimedValueCalculator that modulates the amplitude of the values
+// returned by base with daily and weekly seasonal cycles, to mimic real traffic.
+// The daily factor varies between 1-dailyAmp at midnight and 1+dailyAmp at noon,
+// and the weekly factor between 1-weeklyAmp at midnight between Saturday and
+// Sunday and 1+weeklyAmp at noon on Wednesday; the value is the product of the
+// base value and both factors. Amplitudes are clamped to the range 0 through 1,
+// so an amplitude of 0 disables that cycle. Times of day are taken from the
+// location of each timestamp.
+func NewSeasonalCalculator(base ValueCalculator, dailyAmp, weeklyAmp float64) TimedValueCalculator {
+	dailyAmp = math.Max(0.0, math.Min(1.0, dailyAmp))
+	weeklyAmp = math.Max(0.0, math.Min(1.0, weeklyAmp))
+	return func(phase float64, timestamp time.Time) float64 {
+		return base(phase) * seasonalFactor(timestamp, dailyAmp, weeklyAmp)
+	}
+}
+
+// Creates a new TimedValueCalculator that returns a monotonically increasing
+// count, which grows by rate units per second of time elapsed since the first
+// timestamp the calculator is called with; the phase is ignored. The count never
+// decreases, even if a timestamp is earlier than a previous one, so the values
+// are suitable for CUMULATIVE metrics. A negative rate is treated as zero.
+func NewCounterCalculator(rate float64) TimedValueCalculator {
+	rate = math.Max(0.0, rate)
+	var mu sync.Mutex
+	var start time.Time
+	count := 0.0
+	return func(_ float64, timestamp time.Time) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if start.IsZero() {
+			start = timestamp
+		}
+		count = math.Max(count, rate*timestamp.Sub(start).Seconds())
+		return count
+	}
+}
+
+// Returns the product of the daily and weekly seasonal factors at the timestamp.
+func seasonalFactor(timestamp time.Time, dailyAmp, weeklyAmp float64) float64 {
+	// Use the wall clock time of day, so that the cycle follows local time
+	// across daylight saving time changes.
+	secondOfDay := float64(timestamp.Hour()*3600+timestamp.Minute()*60+timestamp.Second()) + float64(timestamp.Nanosecond())/1e9
+	dayFraction := secondOfDay / (24 * 60 * 60)
+	weekFraction := (float64(timestamp.Weekday()) + dayFraction) / 7
+	daily := 1.0 - dailyAmp*math.Cos(2*math.Pi*dayFraction)
+	weekly := 1.0 - weeklyAmp*math.Cos(2*math.Pi*weekFraction)
+	return daily * weekly
+}
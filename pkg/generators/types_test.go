@@ -4,7 +4,9 @@ package generators_test
 import (
 	"errors"
 	"math"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/memes/gce-metric/pkg/generators"
 )
@@ -44,6 +46,16 @@ func TestPeriodicTypeString(t *testing.T) {
 			periodicType: generators.Triangle,
 			expected:     "triangle",
 		},
+		{
+			name:         "ramp",
+			periodicType: generators.Ramp,
+			expected:     "ramp",
+		},
+		{
+			name:         "trapezoid",
+			periodicType: generators.Trapezoid,
+			expected:     "trapezoid",
+		},
 	}
 	t.Parallel()
 	for _, test := range tests {
@@ -96,6 +108,16 @@ func TestParsePeriodicType(t *testing.T) {
 			value:    "triangle",
 			expected: generators.Triangle,
 		},
+		{
+			name:     "ramp",
+			value:    "ramp",
+			expected: generators.Ramp,
+		},
+		{
+			name:     "trapezoid",
+			value:    "trapezoid",
+			expected: generators.Trapezoid,
+		},
 	}
 	t.Parallel()
 	for _, test := range tests {
@@ -115,6 +137,46 @@ func TestParsePeriodicType(t *testing.T) {
 	}
 }
 
+func TestAllPeriodicTypes(t *testing.T) {
+	t.Parallel()
+	types := generators.AllPeriodicTypes()
+	if len(types) == 0 {
+		t.Fatal("Expected AllPeriodicTypes to return at least one type")
+	}
+	invalid := generators.Invalid.ValueCalculator()
+	for i, periodicType := range types {
+		t.Run(periodicType.String(), func(t *testing.T) {
+			t.Parallel()
+			if periodicType != generators.PeriodicType(i+1) {
+				t.Errorf("Expected types in declaration order, got %v at index %d", periodicType, i)
+			}
+			parsed, err := generators.ParsePeriodicType(periodicType.String())
+			switch {
+			case err != nil:
+				t.Errorf("Received an unexpected error parsing %q: %v", periodicType.String(), err)
+			case parsed != periodicType:
+				t.Errorf("Expected %v to round-trip, got %v", periodicType, parsed)
+			}
+			calculator := periodicType.ValueCalculator()
+			differs := false
+			for phase := 0.0; phase < 1.0; phase += 0.05 {
+				if calculator(phase) != invalid(phase) {
+					differs = true
+					break
+				}
+			}
+			if !differs {
+				t.Errorf("Expected %v to have a calculator that differs from Invalid", periodicType)
+			}
+		})
+	}
+	// A type declared after the last returned type must be unrecognised, or it
+	// has not been added to AllPeriodicTypes.
+	if next := generators.PeriodicType(len(types) + 1); next.String() != generators.Invalid.String() {
+		t.Errorf("Expected AllPeriodicTypes to include %v", next)
+	}
+}
+
 // Executes the supplied valueCalculator function with argument phase and verifies
 // that the return value matches expectation within tolerance.
 func testValueCalculator(t *testing.T, phase, expected float64, valueCalculator generators.ValueCalculator) {
@@ -377,6 +439,147 @@ func TestTrianglePeriodicGenerator(t *testing.T) {
 	}
 }
 
+func TestRampPeriodicGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    float64
+		expected float64
+	}{
+		{
+			name:     "0",
+			phase:    0.0,
+			expected: 0.0,
+		},
+		{
+			name:     "ϕ/4",
+			phase:    0.25,
+			expected: 0.25,
+		},
+		{
+			name:     "ϕ/2",
+			phase:    0.5,
+			expected: 0.5,
+		},
+		{
+			name:     "ϕ",
+			phase:    1.0,
+			expected: 1.0,
+		},
+		{
+			name:     "3ϕ/2",
+			phase:    1.5,
+			expected: 1.0,
+		},
+		{
+			name:     "2ϕ",
+			phase:    2.0,
+			expected: 1.0,
+		},
+		{
+			name:     "100ϕ",
+			phase:    100.0,
+			expected: 1.0,
+		},
+	}
+	t.Parallel()
+	calculator := generators.Ramp.ValueCalculator()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			testValueCalculator(t, tst.phase, tst.expected, calculator)
+		})
+	}
+}
+
+// A ramp between floor and ceiling should reach the midpoint half way through the
+// first period, and hold at the ceiling afterwards.
+func TestRampPeriodicRangeGenerator(t *testing.T) {
+	t.Parallel()
+	calculator := generators.NewPeriodicRangeCalculator(10.0, 20.0, generators.Ramp)
+	testValueCalculator(t, 0.5, 15.0, calculator)
+	testValueCalculator(t, 2.0, 20.0, calculator)
+}
+
+// The default trapezoid should rise for the first quarter of each cycle, hold at
+// 1.0 for the second, fall for the third, and hold at 0.0 for the fourth.
+func TestTrapezoidPeriodicGenerator(t *testing.T) {
+	t.Parallel()
+	calculator := generators.Trapezoid.ValueCalculator()
+	for phase, expected := range map[float64]float64{
+		0.0:   0.0,
+		0.125: 0.5,
+		0.25:  1.0,
+		0.375: 1.0,
+		0.5:   1.0,
+		0.625: 0.5,
+		0.75:  0.0,
+		0.875: 0.0,
+		1.125: 0.5,
+	} {
+		testValueCalculator(t, phase, expected, calculator)
+	}
+}
+
+// A trapezoid shaped by the options should change slope at the end of the rise,
+// the end of the hold, and the end of the fall.
+func TestTrapezoidCalculator(t *testing.T) {
+	t.Parallel()
+	calculator, err := generators.NewTrapezoidCalculator(generators.WithRiseFraction(0.1), generators.WithHoldFraction(0.5))
+	if err != nil {
+		t.Fatalf("NewTrapezoidCalculator raised an error: %v", err)
+	}
+	for phase, expected := range map[float64]float64{
+		0.0:  0.0,
+		0.05: 0.5,
+		0.1:  1.0,
+		0.6:  1.0,
+		0.65: 0.5,
+		0.7:  0.0,
+		0.99: 0.0,
+	} {
+		testValueCalculator(t, phase, expected, calculator)
+	}
+	// A trapezoid without a hold at the floor or ceiling is a triangle.
+	triangle, err := generators.NewTrapezoidCalculator(generators.WithRiseFraction(0.5), generators.WithHoldFraction(0.0))
+	if err != nil {
+		t.Fatalf("NewTrapezoidCalculator raised an error: %v", err)
+	}
+	for _, phase := range []float64{0.0, 0.2, 0.5, 0.7, 0.9} {
+		testValueCalculator(t, phase, generators.Triangle.ValueCalculator()(phase), triangle)
+	}
+}
+
+func TestTrapezoidCalculatorInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []generators.TrapezoidOption
+	}{
+		{
+			name:    "negative-rise",
+			options: []generators.TrapezoidOption{generators.WithRiseFraction(-0.1)},
+		},
+		{
+			name:    "negative-hold",
+			options: []generators.TrapezoidOption{generators.WithHoldFraction(-0.1)},
+		},
+		{
+			name:    "too-long",
+			options: []generators.TrapezoidOption{generators.WithRiseFraction(0.4), generators.WithHoldFraction(0.3)},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := generators.NewTrapezoidCalculator(tst.options...); !errors.Is(err, generators.ErrInvalidTrapezoidShape) {
+				t.Errorf("Expected NewTrapezoidCalculator to raise %v, got %v", generators.ErrInvalidTrapezoidShape, err)
+			}
+		})
+	}
+}
+
 //nolint:funlen // The tests table makes the function longer seem longer to linter
 func TestPeriodicRangeGenerator(t *testing.T) {
 	low := 10.0
@@ -505,3 +708,175 @@ func TestInvalidPeriodicGenerator(t *testing.T) {
 		})
 	}
 }
+
+func TestPeriodicRangeCalculatorInvalid(t *testing.T) {
+	t.Parallel()
+	for _, periodicType := range []generators.PeriodicType{generators.Invalid, generators.PeriodicType(-1), generators.PeriodicType(1000)} {
+		t.Run(strconv.Itoa(int(periodicType)), func(t *testing.T) {
+			t.Parallel()
+			if periodicType.IsValid() {
+				t.Errorf("Expected %d to be invalid", int(periodicType))
+			}
+			defer func() {
+				recovered := recover()
+				err, ok := recovered.(error)
+				if !ok || !errors.Is(err, generators.ErrInvalidPeriodicType) {
+					t.Errorf("Expected NewPeriodicRangeCalculator to panic with %v, got %v", generators.ErrInvalidPeriodicType, recovered)
+				}
+			}()
+			generators.NewPeriodicRangeCalculator(1.0, 10.0, periodicType)
+		})
+	}
+}
+
+func TestSeasonalCalculator(t *testing.T) {
+	constant := func(float64) float64 { return 10.0 }
+	// 2024-01-03 is a Wednesday and 2024-01-07 is a Sunday.
+	tests := []struct {
+		name      string
+		dailyAmp  float64
+		weeklyAmp float64
+		timestamp time.Time
+		expected  float64
+	}{
+		{
+			name:      "no-seasonality",
+			timestamp: time.Date(2024, time.January, 3, 12, 0, 0, 0, time.UTC),
+			expected:  10.0,
+		},
+		{
+			name:      "daily-midnight",
+			dailyAmp:  0.5,
+			timestamp: time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC),
+			expected:  5.0,
+		},
+		{
+			name:      "daily-6am",
+			dailyAmp:  0.5,
+			timestamp: time.Date(2024, time.January, 3, 6, 0, 0, 0, time.UTC),
+			expected:  10.0,
+		},
+		{
+			name:      "daily-noon",
+			dailyAmp:  0.5,
+			timestamp: time.Date(2024, time.January, 3, 12, 0, 0, 0, time.UTC),
+			expected:  15.0,
+		},
+		{
+			name:      "daily-6pm",
+			dailyAmp:  0.5,
+			timestamp: time.Date(2024, time.January, 3, 18, 0, 0, 0, time.UTC),
+			expected:  10.0,
+		},
+		{
+			name:      "weekly-sunday-midnight",
+			weeklyAmp: 0.2,
+			timestamp: time.Date(2024, time.January, 7, 0, 0, 0, 0, time.UTC),
+			expected:  8.0,
+		},
+		{
+			name:      "weekly-wednesday-noon",
+			weeklyAmp: 0.2,
+			timestamp: time.Date(2024, time.January, 3, 12, 0, 0, 0, time.UTC),
+			expected:  12.0,
+		},
+		{
+			name:      "combined-wednesday-noon",
+			dailyAmp:  0.5,
+			weeklyAmp: 0.2,
+			timestamp: time.Date(2024, time.January, 3, 12, 0, 0, 0, time.UTC),
+			expected:  18.0,
+		},
+		{
+			name:      "combined-sunday-midnight",
+			dailyAmp:  0.5,
+			weeklyAmp: 0.2,
+			timestamp: time.Date(2024, time.January, 7, 0, 0, 0, 0, time.UTC),
+			expected:  4.0,
+		},
+		{
+			name:      "clamped-amplitude",
+			dailyAmp:  2.0,
+			timestamp: time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC),
+			expected:  0.0,
+		},
+		{
+			name:      "local-time-of-day",
+			dailyAmp:  0.5,
+			timestamp: time.Date(2024, time.January, 3, 12, 0, 0, 0, time.FixedZone("UTC-5", -5*60*60)),
+			expected:  15.0,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			calculator := generators.NewSeasonalCalculator(constant, tst.dailyAmp, tst.weeklyAmp)
+			if actual := calculator(0.0, tst.timestamp); math.Abs(actual-tst.expected) > generatorTolerance {
+				t.Errorf("Expected %v, got %v", tst.expected, actual)
+			}
+		})
+	}
+}
+
+// The seasonal calculator should receive the timestamp of each value from the
+// range generator.
+func TestRangeMetricsTimedValueCalculator(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC)
+	metrics, err := generators.NewRangeMetrics(start, start.Add(12*time.Hour), 6*time.Hour,
+		generators.WithTimedValueCalculator(generators.NewSeasonalCalculator(func(float64) float64 { return 10.0 }, 0.5, 0.0)),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewRangeMetrics: %v", err)
+	}
+	expected := []float64{5.0, 10.0, 15.0}
+	if len(metrics) != len(expected) {
+		t.Fatalf("Expected %d metrics, got %d", len(expected), len(metrics))
+	}
+	for i, metric := range metrics {
+		if math.Abs(metric.Value-expected[i]) > generatorTolerance {
+			t.Errorf("Expected metric %d to be %v, got %v", i, expected[i], metric.Value)
+		}
+	}
+}
+
+func TestCounterCalculator(t *testing.T) {
+	t.Parallel()
+	calculator := generators.NewCounterCalculator(2.5)
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for _, tst := range []struct {
+		offset   time.Duration
+		expected float64
+	}{
+		{offset: 0, expected: 0.0},
+		{offset: time.Second, expected: 2.5},
+		{offset: 10 * time.Second, expected: 25.0},
+		// An earlier timestamp must not decrease the count.
+		{offset: 5 * time.Second, expected: 25.0},
+		{offset: time.Minute, expected: 150.0},
+	} {
+		if got := calculator(0.0, start.Add(tst.offset)); math.Abs(got-tst.expected) > generatorTolerance {
+			t.Errorf("Expected counter value at %v to be %f, got %f", tst.offset, tst.expected, got)
+		}
+	}
+}
+
+func TestCounterCalculatorMonotonic(t *testing.T) {
+	t.Parallel()
+	metrics, err := generators.NewRangeMetrics(time.Unix(0, 0), time.Unix(600, 0), 10*time.Second,
+		generators.WithTimedValueCalculator(generators.NewCounterCalculator(1.0)),
+	)
+	if err != nil {
+		t.Fatalf("NewRangeMetrics raised an error: %v", err)
+	}
+	for i := 1; i < len(metrics); i++ {
+		if metrics[i].Value <= metrics[i-1].Value {
+			t.Errorf("Expected value %d to be greater than %f, got %f", i, metrics[i-1].Value, metrics[i].Value)
+		}
+	}
+	if last := metrics[len(metrics)-1].Value; math.Abs(last-600.0) > generatorTolerance {
+		t.Errorf("Expected final value to be 600, got %f", last)
+	}
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	distributionpb "google.golang.org/genproto/googleapis/api/distribution"
+)
+
+var (
+	ErrInvalidGrowthFactor = errors.New("exponential bucket growth factor must be greater than 1")
+	ErrInvalidScale        = errors.New("exponential bucket scale must be greater than 0")
+	ErrInvalidNumBuckets   = errors.New("number of finite buckets must be greater than 0")
+	ErrInvalidBounds       = errors.New("explicit bucket bounds must be non-empty and strictly increasing")
+	ErrNilBucketOptions    = errors.New("bucket options must not be nil")
+)
+
+// Returns distribution bucket options with numFiniteBuckets exponentially sized
+// buckets, where the lower bound of bucket i (1 <= i <= numFiniteBuckets) is
+// scale * growthFactor^(i-1).
+func NewExponentialBucketOptions(growthFactor, scale float64, numFiniteBuckets int32) (*distributionpb.Distribution_BucketOptions, error) {
+	if !(growthFactor > 1.0) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrowthFactor, growthFactor)
+	}
+	if !(scale > 0.0) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScale, scale)
+	}
+	if numFiniteBuckets < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidNumBuckets, numFiniteBuckets)
+	}
+	return &distributionpb.Distribution_BucketOptions{
+		Options: &distributionpb.Distribution_BucketOptions_ExponentialBuckets{
+			ExponentialBuckets: &distributionpb.Distribution_BucketOptions_Exponential{
+				NumFiniteBuckets: numFiniteBuckets,
+				GrowthFactor:     growthFactor,
+				Scale:            scale,
+			},
+		},
+	}, nil
+}
+
+// Returns distribution bucket options with explicit bucket boundaries; bucket i
+// (1 <= i < len(bounds)) covers the range bounds[i-1] <= x < bounds[i].
+func NewExplicitBucketOptions(bounds []float64) (*distributionpb.Distribution_BucketOptions, error) {
+	if len(bounds) == 0 {
+		return nil, ErrInvalidBounds
+	}
+	for i := 1; i < len(bounds); i++ {
+		if !(bounds[i] > bounds[i-1]) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBounds, bounds)
+		}
+	}
+	return &distributionpb.Distribution_BucketOptions{
+		Options: &distributionpb.Distribution_BucketOptions_ExplicitBuckets{
+			ExplicitBuckets: &distributionpb.Distribution_BucketOptions_Explicit{
+				Bounds: append([]float64{}, bounds...),
+			},
+		},
+	}, nil
+}
+
+// Returns the total number of buckets, including underflow and overflow, and the
+// index of the bucket that contains value.
+func bucketIndex(options *distributionpb.Distribution_BucketOptions, value float64) (int, int) {
+	switch {
+	case options.GetExponentialBuckets() != nil:
+		exponential := options.GetExponentialBuckets()
+		count := int(exponential.NumFiniteBuckets) + 2
+		if value < exponential.Scale {
+			return count, 0
+		}
+		index := int(math.Floor(math.Log(value/exponential.Scale)/math.Log(exponential.GrowthFactor))) + 1
+		return count, min(index, count-1)
+	case options.GetLinearBuckets() != nil:
+		linear := options.GetLinearBuckets()
+		count := int(linear.NumFiniteBuckets) + 2
+		if value < linear.Offset {
+			return count, 0
+		}
+		index := int(math.Floor((value-linear.Offset)/linear.Width)) + 1
+		return count, min(index, count-1)
+	case options.GetExplicitBuckets() != nil:
+		bounds := options.GetExplicitBuckets().Bounds
+		return len(bounds) + 1, sort.Search(len(bounds), func(i int) bool { return bounds[i] > value })
+	default:
+		return 1, 0
+	}
+}
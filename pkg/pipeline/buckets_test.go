@@ -0,0 +1,110 @@
+package pipeline_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/memes/gce-metric/pkg/pipeline"
+)
+
+func TestNewExponentialBucketOptions(t *testing.T) {
+	tests := []struct {
+		name             string
+		growthFactor     float64
+		scale            float64
+		numFiniteBuckets int32
+		expectedError    error
+	}{
+		{
+			name:             "valid",
+			growthFactor:     2.0,
+			scale:            1.0,
+			numFiniteBuckets: 10,
+		},
+		{
+			name:             "growth-factor",
+			growthFactor:     1.0,
+			scale:            1.0,
+			numFiniteBuckets: 10,
+			expectedError:    pipeline.ErrInvalidGrowthFactor,
+		},
+		{
+			name:             "scale",
+			growthFactor:     2.0,
+			scale:            0.0,
+			numFiniteBuckets: 10,
+			expectedError:    pipeline.ErrInvalidScale,
+		},
+		{
+			name:             "num-buckets",
+			growthFactor:     2.0,
+			scale:            1.0,
+			numFiniteBuckets: 0,
+			expectedError:    pipeline.ErrInvalidNumBuckets,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options, err := pipeline.NewExponentialBucketOptions(tst.growthFactor, tst.scale, tst.numFiniteBuckets)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("NewExponentialBucketOptions raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected NewExponentialBucketOptions to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && options.GetExponentialBuckets().GetNumFiniteBuckets() != tst.numFiniteBuckets:
+				t.Errorf("Expected %d finite buckets, got %+v", tst.numFiniteBuckets, options)
+			}
+		})
+	}
+}
+
+func TestNewExplicitBucketOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		bounds        []float64
+		expectedError error
+	}{
+		{
+			name:          "nil",
+			bounds:        nil,
+			expectedError: pipeline.ErrInvalidBounds,
+		},
+		{
+			name:   "single",
+			bounds: []float64{1.0},
+		},
+		{
+			name:   "increasing",
+			bounds: []float64{1.0, 2.0, 5.0},
+		},
+		{
+			name:          "duplicate",
+			bounds:        []float64{1.0, 2.0, 2.0},
+			expectedError: pipeline.ErrInvalidBounds,
+		},
+		{
+			name:          "decreasing",
+			bounds:        []float64{5.0, 2.0, 1.0},
+			expectedError: pipeline.ErrInvalidBounds,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options, err := pipeline.NewExplicitBucketOptions(tst.bounds)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("NewExplicitBucketOptions raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected NewExplicitBucketOptions to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && len(options.GetExplicitBuckets().GetBounds()) != len(tst.bounds):
+				t.Errorf("Expected %d bounds, got %+v", len(tst.bounds), options)
+			}
+		})
+	}
+}
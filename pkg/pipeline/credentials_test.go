@@ -0,0 +1,138 @@
+package pipeline //nolint:testpackage // These tests need access to the private credentials helper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Starts a TLS server that supports HTTP/2, as required by gRPC. The server uses
+// the supplied certificates, or the httptest certificate if there are none.
+func newTestTLSServer(t *testing.T, certificates ...tls.Certificate) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	if len(certificates) > 0 {
+		server.TLS = &tls.Config{
+			Certificates: certificates,
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	server.EnableHTTP2 = true
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Returns a new self-signed certificate for example.com.
+func newSelfSignedCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Writes the certificate of the test server to a PEM file, and returns the path.
+func writeCACertFile(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write CA certificate file: %v", err)
+	}
+	return path
+}
+
+// The credentials built from a CA file should complete a TLS handshake with a
+// server whose certificate is issued by that CA, and reject a server that uses a
+// different CA.
+func TestNewCATransportCredentials(t *testing.T) {
+	t.Parallel()
+	server := newTestTLSServer(t)
+	other := newTestTLSServer(t, newSelfSignedCertificate(t))
+	creds, err := newCATransportCredentials(writeCACertFile(t, server))
+	if err != nil {
+		t.Fatalf("Unexpected error building credentials: %v", err)
+	}
+	if protocol := creds.Info().SecurityProtocol; protocol != "tls" {
+		t.Errorf("Expected tls security protocol, got %q", protocol)
+	}
+	handshake := func(addr string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			t.Fatalf("Failed to connect to test server: %v", err)
+		}
+		defer conn.Close()
+		tlsConn, _, err := creds.ClientHandshake(ctx, "example.com", conn)
+		if err == nil {
+			_ = tlsConn.Close()
+		}
+		return err //nolint:wrapcheck // The error is only checked for nil
+	}
+	if err := handshake(server.Listener.Addr().String()); err != nil {
+		t.Errorf("Expected TLS handshake to succeed, got %v", err)
+	}
+	if err := handshake(other.Listener.Addr().String()); err == nil {
+		t.Error("Expected TLS handshake with a server using a different CA to fail")
+	}
+}
+
+func TestNewCATransportCredentialsInvalid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not-pem.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.pem"), notPEM} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Parallel()
+			if _, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithCACertFile(path)); !errors.Is(err, ErrInvalidCACert) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidCACert, err)
+			}
+		})
+	}
+}
+
+func TestWithEndpointInvalid(t *testing.T) {
+	t.Parallel()
+	if _, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithEndpoint("")); !errors.Is(err, ErrInvalidEndpoint) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidEndpoint, err)
+	}
+}
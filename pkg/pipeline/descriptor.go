@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	labelpb "google.golang.org/genproto/googleapis/api/label"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Creates the metric descriptor for each metric type in req the first time it is
+// called, if a display name or description has been set, or the descriptor is to
+// be recreated; otherwise Cloud Monitoring will create the descriptors
+// automatically on the first write. Each descriptor is built from the first
+// time-series of its type, so a metric written for several resources is only
+// deleted and created once. The descriptors are created again on the next call
+// if creation fails.
+func (p *Pipeline) ensureDescriptor(ctx context.Context, client timeSeriesClient, req *monitoringpb.CreateTimeSeriesRequest) error {
+	if !p.recreateDescriptor && p.displayName == "" && p.description == "" {
+		return nil
+	}
+	p.descriptorMu.Lock()
+	defer p.descriptorMu.Unlock()
+	if p.descriptorCreated || len(req.GetTimeSeries()) == 0 {
+		return nil
+	}
+	metricTypes := []string{}
+	seriesByType := map[string]*monitoringpb.TimeSeries{}
+	for _, series := range req.TimeSeries {
+		metricType := series.GetMetric().GetType()
+		if _, ok := seriesByType[metricType]; ok {
+			continue
+		}
+		metricTypes = append(metricTypes, metricType)
+		seriesByType[metricType] = series
+	}
+	for _, metricType := range metricTypes {
+		if err := p.createDescriptor(ctx, client, req.Name, seriesByType[metricType]); err != nil {
+			return err
+		}
+	}
+	p.descriptorCreated = true
+	return nil
+}
+
+// Creates the metric descriptor for the time-series, deleting any existing
+// descriptor first if it is to be recreated.
+func (p *Pipeline) createDescriptor(ctx context.Context, client timeSeriesClient, name string, series *monitoringpb.TimeSeries) error {
+	descriptor := p.buildDescriptor(series)
+	if p.recreateDescriptor {
+		p.logger.V(0).Info("Deleting metric descriptor before recreating it", "type", descriptor.Type)
+		if err := client.DeleteMetricDescriptor(ctx, &monitoringpb.DeleteMetricDescriptorRequest{
+			Name: name + "/metricDescriptors/" + descriptor.Type,
+		}); err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failure deleting metric descriptor for %s: %w", descriptor.Type, err)
+		}
+	}
+	p.logger.V(1).Info("Creating metric descriptor", "type", descriptor.Type, "displayName", descriptor.DisplayName)
+	if _, err := client.CreateMetricDescriptor(ctx, &monitoringpb.CreateMetricDescriptorRequest{
+		Name:             name,
+		MetricDescriptor: descriptor,
+	}); err != nil {
+		return fmt.Errorf("failure creating metric descriptor for %s: %w", descriptor.Type, err)
+	}
+	return nil
+}
+
+// Returns a metric descriptor that matches the metric type, kind, value type,
+// and metric labels of the time-series, with the display name and description
+// of the pipeline.
+func (p *Pipeline) buildDescriptor(series *monitoringpb.TimeSeries) *metricpb.MetricDescriptor {
+	keys := make([]string, 0, len(series.GetMetric().GetLabels()))
+	for key := range series.GetMetric().GetLabels() {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	labels := make([]*labelpb.LabelDescriptor, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, &labelpb.LabelDescriptor{
+			Key:       key,
+			ValueType: labelpb.LabelDescriptor_STRING,
+		})
+	}
+	return &metricpb.MetricDescriptor{
+		Type:        series.GetMetric().GetType(),
+		MetricKind:  series.GetMetricKind(),
+		ValueType:   pointValueType(series),
+		Labels:      labels,
+		DisplayName: p.displayName,
+		Description: p.description,
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// The path of the Google Cloud Managed Service for Prometheus remote-write API,
+// relative to the Cloud Monitoring REST endpoint; %s is replaced by the project
+// identifier.
+const gmpWritePath = "v1/projects/%s/location/global/prometheus/api/v1/write"
+
+// Send the time-series in each request to Google Cloud Managed Service for
+// Prometheus with the Prometheus remote-write protocol, instead of Cloud
+// Monitoring's CreateTimeSeries, authenticating with Application Default
+// Credentials or the credentials of the pipeline's client options. The samples
+// are written to the project, or to the pipeline's project if projectID is empty,
+// and are mapped to Prometheus series as for WithPrometheusRemoteWriteEmitter.
+//
+// The endpoint and user-agent of the pipeline's client options are used, but
+// options that only apply to gRPC connections are ignored, as for
+// WithRESTTransport. Samples that cannot be sent are kept and sent with the next
+// request, and closing the pipeline flushes any that are still pending.
+func WithGMPEmitter(projectID string) Option {
+	return func(p *Pipeline) error {
+		rest := &restWriter{
+			mu:       sync.Mutex{},
+			client:   nil,
+			endpoint: "",
+		}
+		writer := &remoteWriter{
+			endpoint: "",
+			client:   nil,
+			connect: func() (*http.Client, string, error) {
+				client, endpoint, err := rest.httpClient(p.clientOptions)
+				if err != nil {
+					return nil, "", err
+				}
+				project := projectID
+				if project == "" {
+					project = p.projectID
+				}
+				return client, endpoint + fmt.Sprintf(gmpWritePath, project), nil
+			},
+			mu:      sync.Mutex{},
+			pending: []remoteWriteSeries{},
+		}
+		p.emitter = func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+			p.logger.V(2).Info("Emitting time-series request to Google Cloud Managed Service for Prometheus")
+			series, err := remoteWriteSeriesFromRequest(req)
+			if err != nil {
+				return err
+			}
+			if err := writer.write(ctx, series); err != nil {
+				return err
+			}
+			p.recordLastPoints(req)
+			return nil
+		}
+		p.closer = func() error {
+			p.logger.V(2).Info("Flushing pending samples to Google Cloud Managed Service for Prometheus")
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRPCTimeout)
+			defer cancel()
+			return writer.write(ctx, nil)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package pipeline //nolint:testpackage // These tests need access to the private emitter and client options
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/auth"
+	"github.com/memes/gce-metric/pkg/generators"
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+)
+
+const testGMPToken = "test-token"
+
+// A token provider that always returns the same access token.
+type staticTokenProvider struct{}
+
+func (staticTokenProvider) Token(_ context.Context) (*auth.Token, error) {
+	return &auth.Token{
+		Value: testGMPToken,
+		Type:  "Bearer",
+	}, nil
+}
+
+// Authenticate requests with a static access token, as the fake server does not
+// need real credentials. The credentials are only used by the new auth library.
+func withStaticToken() Option {
+	return func(p *Pipeline) error {
+		p.clientOptions = append(p.clientOptions,
+			internaloption.EnableNewAuthLibrary(),
+			option.WithAuthCredentials(auth.NewCredentials(&auth.CredentialsOptions{
+				TokenProvider: staticTokenProvider{},
+			})),
+		)
+		return nil
+	}
+}
+
+func TestGMPEmitter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		projectID    string
+		expectedPath string
+	}{
+		{
+			name:         "pipeline-project",
+			expectedPath: "/v1/projects/" + testProjectID + "/location/global/prometheus/api/v1/write",
+		},
+		{
+			name:         "explicit-project",
+			projectID:    "other-project",
+			expectedPath: "/v1/projects/other-project/location/global/prometheus/api/v1/write",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeRemoteWriteServer(t)
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithEndpoint(server.URL), withStaticToken(), WithMetricLabels(map[string]string{"test.key": "value"}), WithGMPEmitter(tst.projectID))
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			timestamp := time.Unix(1700000000, 0)
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     2.5,
+				Timestamp: timestamp,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+			}
+			if err := pipeline.emitter(context.Background(), req); err != nil {
+				t.Fatalf("Unexpected error returned from emitter: %v", err)
+			}
+			if err := pipeline.Close(); err != nil {
+				t.Errorf("Unexpected error returned from Close: %v", err)
+			}
+			requests, samples := server.received()
+			if len(requests) != 1 {
+				t.Fatalf("Expected 1 remote-write request, got %d", len(requests))
+			}
+			if path := requests[0].URL.Path; path != tst.expectedPath {
+				t.Errorf("Expected request path %q, got %q", tst.expectedPath, path)
+			}
+			if got := requests[0].Header.Get("Authorization"); got != "Bearer "+testGMPToken {
+				t.Errorf("Expected Authorization header %q, got %q", "Bearer "+testGMPToken, got)
+			}
+			if got := requests[0].Header.Get("Content-Encoding"); got != "snappy" {
+				t.Errorf("Expected Content-Encoding header %q, got %q", "snappy", got)
+			}
+			if len(samples) != 1 {
+				t.Fatalf("Expected 1 sample, got %d", len(samples))
+			}
+			if samples[0].value != 2.5 || samples[0].timestamp != timestamp.UnixMilli() {
+				t.Errorf("Expected sample 2.5 at %d, got %v at %d", timestamp.UnixMilli(), samples[0].value, samples[0].timestamp)
+			}
+			labels := map[string]string{}
+			for _, label := range samples[0].labels {
+				labels[label[0]] = label[1]
+			}
+			for name, expected := range map[string]string{
+				"__name__":   "custom_googleapis_com_gce_metric",
+				"test_key":   "value",
+				"project_id": testProjectID,
+			} {
+				if labels[name] != expected {
+					t.Errorf("Expected label %s to be %q, got %q", name, expected, labels[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// Holds the InfluxDB line-protocol lines that have not yet been accepted by the
+// write API.
+type influxWriter struct {
+	endpoint string
+	token    string
+	client   *http.Client
+	mu       sync.Mutex
+	pending  []string
+}
+
+// Send the time-series in each request to the InfluxDB v2 write API at the URL,
+// e.g. http://influxdb.example.com:8086, instead of Google Cloud Monitoring.
+// Each point is written as a line with the metric type as the measurement, the
+// metric and monitored resource labels as tags, and the value in a field named
+// "value"; metric labels take precedence over resource labels with the same
+// key. Distributions are written as their mean.
+//
+// Lines that cannot be written because of a network error, rate limiting, or a
+// server error are kept and sent with the next request, up to MaxPendingPoints,
+// and closing the pipeline flushes any that are still pending. Lines that are
+// rejected with any other client error, e.g. a line-protocol error, are dropped.
+func WithInfluxEmitter(influxURL, bucket, org, token string) Option {
+	return func(p *Pipeline) error {
+		parsed, err := url.Parse(influxURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidInfluxURL, influxURL)
+		}
+		if bucket == "" || org == "" {
+			return ErrMissingInfluxParameter
+		}
+		parsed = parsed.JoinPath("api", "v2", "write")
+		parsed.RawQuery = url.Values{
+			"bucket":    []string{bucket},
+			"org":       []string{org},
+			"precision": []string{"ns"},
+		}.Encode()
+		writer := &influxWriter{
+			endpoint: parsed.String(),
+			token:    token,
+			client:   &http.Client{},
+			mu:       sync.Mutex{},
+			pending:  []string{},
+		}
+		p.emitter = func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+			p.logger.V(2).Info("Emitting time-series request to InfluxDB", "bucket", bucket, "org", org)
+			if err := writer.write(ctx, influxLines(req)); err != nil {
+				return err
+			}
+			p.recordLastPoints(req)
+			return nil
+		}
+		p.closer = func() error {
+			p.logger.V(2).Info("Flushing pending lines to InfluxDB")
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRPCTimeout)
+			defer cancel()
+			return writer.write(ctx, nil)
+		}
+		return nil
+	}
+}
+
+// Add the lines to the pending lines and send them all to the write API. The
+// pending lines are discarded if the write API accepts them, or rejects them with
+// an error that is not retryable.
+func (w *influxWriter) write(ctx context.Context, lines []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, lines...)
+	if len(w.pending) == 0 {
+		return nil
+	}
+	body := strings.Join(w.pending, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return fmt.Errorf("failure creating InfluxDB write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.pending, err = retainPending(w.pending, fmt.Errorf("failure sending InfluxDB write request: %w", err))
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		w.pending = w.pending[:0]
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%w: %s: %s", ErrInfluxWrite, resp.Status, strings.TrimSpace(string(message)))
+	if !retryableStatus(resp.StatusCode) {
+		w.pending = w.pending[:0]
+		return err
+	}
+	w.pending, err = retainPending(w.pending, err)
+	return err
+}
+
+// Returns a line-protocol line for each point with a value in the request, with
+// the tags sorted by key as recommended by InfluxDB. Tags with empty values are
+// omitted, as InfluxDB rejects them.
+func influxLines(req *monitoringpb.CreateTimeSeriesRequest) []string {
+	lines := []string{}
+	for _, series := range req.GetTimeSeries() {
+		tags := maps.Clone(series.GetResource().GetLabels())
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		maps.Copy(tags, series.GetMetric().GetLabels())
+		var prefix strings.Builder
+		prefix.WriteString(influxMeasurementEscaper.Replace(series.GetMetric().GetType()))
+		for _, key := range slices.Sorted(maps.Keys(tags)) {
+			if tags[key] == "" {
+				continue
+			}
+			prefix.WriteString("," + influxTagEscaper.Replace(key) + "=" + influxTagEscaper.Replace(tags[key]))
+		}
+		for _, point := range series.GetPoints() {
+			field, ok := influxFieldValue(point.GetValue())
+			if !ok {
+				continue
+			}
+			lines = append(lines, prefix.String()+" value="+field+" "+strconv.FormatInt(point.GetInterval().GetEndTime().AsTime().UnixNano(), 10))
+		}
+	}
+	return lines
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxStringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// Returns the value formatted as a line-protocol field value, and false if the
+// point does not have a value.
+func influxFieldValue(value *monitoringpb.TypedValue) (string, bool) {
+	switch v := typedValue(value).(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return `"` + influxStringEscaper.Replace(v) + `"`, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,210 @@
+package pipeline //nolint:testpackage // These tests need access to the private functions to emulate a non-GCP environment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/generators"
+)
+
+// A fake InfluxDB v2 write API that records the line protocol body of each
+// request.
+func newFakeInfluxServer(t *testing.T) *fakeHTTPServer[string] {
+	t.Helper()
+	return newFakeHTTPServer(t, func(_ *http.Request, body []byte) ([]string, error) {
+		return []string{string(body)}, nil
+	})
+}
+
+func TestWithInfluxEmitterInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		url         string
+		bucket      string
+		org         string
+		expectedErr error
+	}{
+		{
+			name:        "empty-url",
+			bucket:      "bucket",
+			org:         "org",
+			expectedErr: ErrInvalidInfluxURL,
+		},
+		{
+			name:        "no-scheme",
+			url:         "influxdb.example.com:8086",
+			bucket:      "bucket",
+			org:         "org",
+			expectedErr: ErrInvalidInfluxURL,
+		},
+		{
+			name:        "missing-bucket",
+			url:         "http://influxdb.example.com:8086",
+			org:         "org",
+			expectedErr: ErrMissingInfluxParameter,
+		},
+		{
+			name:        "missing-org",
+			url:         "http://influxdb.example.com:8086",
+			bucket:      "bucket",
+			expectedErr: ErrMissingInfluxParameter,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithInfluxEmitter(tst.url, tst.bucket, tst.org, "token"))
+			if !errors.Is(err, tst.expectedErr) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", tst.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestInfluxEmitter(t *testing.T) {
+	t.Parallel()
+	server := newFakeInfluxServer(t)
+	p, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithResourceType(GlobalResourceType),
+		WithMetricLabels(map[string]string{"test key": "a,b=c", "empty": ""}),
+		WithInfluxEmitter(server.URL, "test-bucket", "test-org", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	timestamp := time.Unix(1700000000, 123456789)
+	metrics := make(chan generators.Metric, 2)
+	metrics <- generators.Metric{Value: 1.5, Timestamp: timestamp}
+	metrics <- generators.Metric{Value: 2.0, Timestamp: timestamp.Add(time.Minute)}
+	close(metrics)
+	if err := p.Processor()(context.Background(), metrics); err != nil {
+		t.Fatalf("Unexpected error returned from Processor: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	requests, bodies := server.received()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 write requests, got %d", len(requests))
+	}
+	req := requests[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/api/v2/write" {
+		t.Errorf("Expected POST /api/v2/write, got %s %s", req.Method, req.URL.Path)
+	}
+	expectedQuery := url.Values{"bucket": []string{"test-bucket"}, "org": []string{"test-org"}, "precision": []string{"ns"}}
+	if req.URL.RawQuery != expectedQuery.Encode() {
+		t.Errorf("Expected query %q, got %q", expectedQuery.Encode(), req.URL.RawQuery)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("Expected token authorization, got %q", auth)
+	}
+	tags := `project_id=` + testProjectID + `,test\ key=a\,b\=c`
+	for i, expected := range []string{
+		DefaultMetricType + "," + tags + " value=1.5 1700000000123456789\n",
+		DefaultMetricType + "," + tags + " value=2 1700000060123456789\n",
+	} {
+		if bodies[i] != expected {
+			t.Errorf("Expected body %d\n%q\ngot\n%q", i, expected, bodies[i])
+		}
+	}
+}
+
+// Lines that are rejected by the write API should be resent with the next
+// request, or when the pipeline is closed.
+func TestInfluxEmitterFlushesPending(t *testing.T) {
+	t.Parallel()
+	server := newFakeInfluxServer(t)
+	server.setStatus(http.StatusServiceUnavailable)
+	p, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithResourceType(GlobalResourceType),
+		WithInfluxEmitter(server.URL, "test-bucket", "test-org", ""),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	metrics := make(chan generators.Metric, 1)
+	metrics <- generators.Metric{Value: 3, Timestamp: time.Unix(1700000000, 0)}
+	close(metrics)
+	if err := p.Processor()(context.Background(), metrics); !errors.Is(err, ErrInfluxWrite) {
+		t.Errorf("Expected Processor to raise %v, got %v", ErrInfluxWrite, err)
+	}
+	server.setStatus(0)
+	if err := p.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	requests, bodies := server.received()
+	if len(bodies) != 1 || bodies[0] != DefaultMetricType+",project_id="+testProjectID+" value=3 1700000000000000000\n" {
+		t.Errorf("Expected the pending line to be flushed on close, got %q", bodies)
+	}
+	if auth := requests[0].Header.Get("Authorization"); auth != "" {
+		t.Errorf("Expected no authorization without a token, got %q", auth)
+	}
+}
+
+// Lines that are rejected with a client error should be dropped, and lines that
+// are rate limited or rejected with a server error should be resent.
+func TestInfluxEmitterRetryableStatus(t *testing.T) {
+	t.Parallel()
+	tests := map[int]string{
+		http.StatusBadRequest:         "value=4 1700000060000000000\n",
+		http.StatusTooManyRequests:    "value=3 1700000000000000000\n" + DefaultMetricType + ",project_id=" + testProjectID + " value=4 1700000060000000000\n",
+		http.StatusServiceUnavailable: "value=3 1700000000000000000\n" + DefaultMetricType + ",project_id=" + testProjectID + " value=4 1700000060000000000\n",
+	}
+	for code, expected := range tests {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			t.Parallel()
+			server := newFakeInfluxServer(t)
+			p, err := newNonGCPTestPipeline(t,
+				WithProjectID(testProjectID),
+				WithResourceType(GlobalResourceType),
+				WithInfluxEmitter(server.URL, "test-bucket", "test-org", ""),
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			for i, value := range []float64{3, 4} {
+				if i == 0 {
+					server.setStatus(code)
+				} else {
+					server.setStatus(0)
+				}
+				req, err := p.BuildRequest(generators.Metric{Value: value, Timestamp: time.Unix(1700000000+int64(i)*60, 0)})
+				if err != nil {
+					t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+				}
+				err = p.emitter(context.Background(), req)
+				if i == 0 && !errors.Is(err, ErrInfluxWrite) {
+					t.Errorf("Expected emitter to raise %v, got %v", ErrInfluxWrite, err)
+				}
+				if i == 1 && err != nil {
+					t.Errorf("Unexpected error returned from emitter: %v", err)
+				}
+			}
+			_, bodies := server.received()
+			if expected := DefaultMetricType + ",project_id=" + testProjectID + " " + expected; len(bodies) != 1 || bodies[0] != expected {
+				t.Errorf("Expected body\n%q\ngot\n%q", expected, bodies)
+			}
+		})
+	}
+}
+
+// The InfluxDB emitter does not need a Cloud Monitoring client, or the
+// credentials to create one.
+func TestInfluxEmitterWithoutMetricClient(t *testing.T) {
+	t.Parallel()
+	p, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithInfluxEmitter("http://influxdb.example.com:8086", "test-bucket", "test-org", ""), withNewMetricClient(failNewMetricClient))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	if p.client != nil {
+		t.Errorf("Expected no metric client to be created, got %v", p.client)
+	}
+}
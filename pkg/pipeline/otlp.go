@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+)
+
+const (
+	// The name of the instrumentation scope of metrics written as OTLP.
+	OTLPScopeName = "github.com/memes/gce-metric"
+	// The OTLP resource attribute that holds the monitored resource type.
+	OTLPResourceTypeAttribute = "gcp.resource_type"
+)
+
+// OTLP aggregation temporality values, from the OpenTelemetry metrics proto.
+const (
+	otlpTemporalityDelta      = 1
+	otlpTemporalityCumulative = 2
+)
+
+// The subset of the OTLP ExportMetricsServiceRequest that is needed to represent
+// Cloud Monitoring time-series, with field names that match the OTLP/JSON
+// encoding.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	// OTLP/JSON encodes 64-bit integers as strings.
+	AsInt string `json:"asInt,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// Write each time-series request to the writer as an OTLP/JSON metrics export
+// request on a single line, instead of sending it to Google Cloud Monitoring,
+// e.g. to inspect the OpenTelemetry representation of the values or to replay
+// them to an OpenTelemetry Collector. This is equivalent to
+// WithFormattedWriterEmitter with WriterFormatOTLPJSON.
+func WithOTLPJSONWriterEmitter(writer io.Writer) Option {
+	return WithFormattedWriterEmitter(writer, WriterFormatOTLPJSON)
+}
+
+// Returns the request as a single line of OTLP/JSON. Each time-series becomes a
+// resource with the monitored resource type and labels as attributes, and a
+// metric named after the metric type with the metric labels as data point
+// attributes. GAUGE time-series become OTLP gauges, and CUMULATIVE and DELTA
+// time-series become monotonic sums. Boolean values are written as 0 or 1 and
+// distributions as their mean; string values are not supported by OTLP and are
+// rejected with ErrUnsupportedOTLPValue.
+func formatOTLPJSON(req *monitoringpb.CreateTimeSeriesRequest) (string, error) {
+	request := otlpRequest{
+		ResourceMetrics: make([]otlpResourceMetrics, 0, len(req.GetTimeSeries())),
+	}
+	for _, series := range req.GetTimeSeries() {
+		dataPoints := make([]otlpDataPoint, 0, len(series.GetPoints()))
+		for _, point := range series.GetPoints() {
+			dataPoint, err := otlpDataPointFromPoint(point, series.GetMetric().GetLabels())
+			if err != nil {
+				return "", fmt.Errorf("%w: %s", err, series.GetMetric().GetType())
+			}
+			dataPoints = append(dataPoints, dataPoint)
+		}
+		metric := otlpMetric{
+			Name:  series.GetMetric().GetType(),
+			Gauge: nil,
+			Sum:   nil,
+		}
+		switch series.GetMetricKind() {
+		case metricpb.MetricDescriptor_CUMULATIVE:
+			metric.Sum = &otlpSum{DataPoints: dataPoints, AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+		case metricpb.MetricDescriptor_DELTA:
+			metric.Sum = &otlpSum{DataPoints: dataPoints, AggregationTemporality: otlpTemporalityDelta, IsMonotonic: true}
+		case metricpb.MetricDescriptor_GAUGE, metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED:
+			fallthrough
+		default:
+			metric.Gauge = &otlpGauge{DataPoints: dataPoints}
+		}
+		resourceAttributes := append([]otlpAttribute{{Key: OTLPResourceTypeAttribute, Value: otlpAttributeValue{StringValue: series.GetResource().GetType()}}}, otlpAttributes(series.GetResource().GetLabels())...)
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource: otlpResource{Attributes: resourceAttributes},
+			ScopeMetrics: []otlpScopeMetrics{
+				{
+					Scope:   otlpScope{Name: OTLPScopeName},
+					Metrics: []otlpMetric{metric},
+				},
+			},
+		})
+	}
+	output, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failure marshaling OTLP request: %w", err)
+	}
+	return string(output) + "\n", nil
+}
+
+// Returns the OTLP data point for the Cloud Monitoring point, with the labels as
+// attributes.
+func otlpDataPointFromPoint(point *monitoringpb.Point, labels map[string]string) (otlpDataPoint, error) {
+	dataPoint := otlpDataPoint{
+		Attributes:        otlpAttributes(labels),
+		StartTimeUnixNano: "",
+		TimeUnixNano:      strconv.FormatInt(point.GetInterval().GetEndTime().AsTime().UnixNano(), 10),
+		AsDouble:          nil,
+		AsInt:             "",
+	}
+	if start := point.GetInterval().GetStartTime(); start != nil {
+		dataPoint.StartTimeUnixNano = strconv.FormatInt(start.AsTime().UnixNano(), 10)
+	}
+	switch v := typedValue(point.GetValue()).(type) {
+	case float64:
+		dataPoint.AsDouble = &v
+	case int64:
+		dataPoint.AsInt = strconv.FormatInt(v, 10)
+	case bool:
+		dataPoint.AsInt = "0"
+		if v {
+			dataPoint.AsInt = "1"
+		}
+	default:
+		return dataPoint, fmt.Errorf("%w: %T", ErrUnsupportedOTLPValue, v)
+	}
+	return dataPoint, nil
+}
+
+// Returns the labels as OTLP string attributes, sorted by key.
+func otlpAttributes(labels map[string]string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: labels[key]}})
+	}
+	return attributes
+}
@@ -0,0 +1,149 @@
+package pipeline //nolint:testpackage // These tests need access to the private functions to emulate a non-GCP environment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/memes/gce-metric/pkg/generators"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestOTLPJSONWriterEmitter(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithMetricType("custom.googleapis.com/otlp"), WithMetricLabels(map[string]string{"test.key": "value"}), WithOTLPJSONWriterEmitter(&buffer))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	timestamp := time.Unix(1700000000, 0)
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     2.5,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+	}
+	if err := pipeline.emitter(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error returned from emitter: %v", err)
+	}
+	var result otlpRequest
+	if err := json.Unmarshal(buffer.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse OTLP/JSON %q: %v", buffer.String(), err)
+	}
+	if len(result.ResourceMetrics) != 1 || len(result.ResourceMetrics[0].ScopeMetrics) != 1 || len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics) != 1 {
+		t.Fatalf("Expected a single metric, got %s", buffer.String())
+	}
+	attributes := result.ResourceMetrics[0].Resource.Attributes
+	if len(attributes) == 0 || attributes[0].Key != OTLPResourceTypeAttribute || attributes[0].Value.StringValue != "generic_node" {
+		t.Errorf("Expected resource attributes to start with the generic_node resource type, got %v", attributes)
+	}
+	metric := result.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	if metric.Name != "custom.googleapis.com/otlp" {
+		t.Errorf("Expected metric name %q, got %q", "custom.googleapis.com/otlp", metric.Name)
+	}
+	if metric.Gauge == nil || metric.Sum != nil || len(metric.Gauge.DataPoints) != 1 {
+		t.Fatalf("Expected a gauge with a single data point, got %s", buffer.String())
+	}
+	point := metric.Gauge.DataPoints[0]
+	if point.AsDouble == nil || *point.AsDouble != 2.5 {
+		t.Errorf("Expected value 2.5, got %s", buffer.String())
+	}
+	if expected := strconv.FormatInt(timestamp.UnixNano(), 10); point.TimeUnixNano != expected {
+		t.Errorf("Expected time %s, got %s", expected, point.TimeUnixNano)
+	}
+	if len(point.Attributes) != 1 || point.Attributes[0].Key != "test.key" || point.Attributes[0].Value.StringValue != "value" {
+		t.Errorf("Expected a test.key=value attribute, got %v", point.Attributes)
+	}
+}
+
+func TestFormatOTLPJSON(t *testing.T) {
+	t.Parallel()
+	start := time.Unix(1700000000, 0)
+	end := start.Add(time.Minute)
+	interval := &monitoringpb.TimeInterval{
+		StartTime: timestamppb.New(start),
+		EndTime:   timestamppb.New(end),
+	}
+	tests := []struct {
+		name                string
+		kind                metricpb.MetricDescriptor_MetricKind
+		value               *monitoringpb.TypedValue
+		expectedTemporality int
+		expectedInt         string
+		expectedError       error
+	}{
+		{
+			name:                "cumulative-int",
+			kind:                metricpb.MetricDescriptor_CUMULATIVE,
+			value:               &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 42}},
+			expectedTemporality: otlpTemporalityCumulative,
+			expectedInt:         "42",
+		},
+		{
+			name:                "delta-bool",
+			kind:                metricpb.MetricDescriptor_DELTA,
+			value:               &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_BoolValue{BoolValue: true}},
+			expectedTemporality: otlpTemporalityDelta,
+			expectedInt:         "1",
+		},
+		{
+			name:          "string",
+			kind:          metricpb.MetricDescriptor_GAUGE,
+			value:         &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_StringValue{StringValue: "invalid"}},
+			expectedError: ErrUnsupportedOTLPValue,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			output, err := formatOTLPJSON(&monitoringpb.CreateTimeSeriesRequest{
+				Name: "projects/" + testProjectID,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric:     &metricpb.Metric{Type: tst.name},
+						MetricKind: tst.kind,
+						Points: []*monitoringpb.Point{
+							{
+								Interval: interval,
+								Value:    tst.value,
+							},
+						},
+					},
+				},
+			})
+			if tst.expectedError != nil {
+				if !errors.Is(err, tst.expectedError) {
+					t.Errorf("Expected formatOTLPJSON to raise %v, got %v", tst.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("formatOTLPJSON raised an unexpected error: %v", err)
+			}
+			var result otlpRequest
+			if err := json.Unmarshal([]byte(output), &result); err != nil {
+				t.Fatalf("Failed to parse OTLP/JSON %q: %v", output, err)
+			}
+			sum := result.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Sum
+			switch {
+			case sum == nil || len(sum.DataPoints) != 1:
+				t.Errorf("Expected a sum with a single data point, got %s", output)
+			case sum.AggregationTemporality != tst.expectedTemporality || !sum.IsMonotonic:
+				t.Errorf("Expected a monotonic sum with temporality %d, got %s", tst.expectedTemporality, output)
+			case sum.DataPoints[0].AsInt != tst.expectedInt:
+				t.Errorf("Expected value %s, got %s", tst.expectedInt, output)
+			case sum.DataPoints[0].StartTimeUnixNano != strconv.FormatInt(start.UnixNano(), 10):
+				t.Errorf("Expected start time %d, got %s", start.UnixNano(), output)
+			}
+		})
+	}
+}
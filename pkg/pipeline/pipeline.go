@@ -2,18 +2,36 @@ package pipeline
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/memes/gce-metric/pkg/generators"
+	"google.golang.org/api/option"
 	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
 )
 
@@ -21,11 +39,154 @@ const (
 	DefaultMetricType = "custom.googleapis.com/gce_metric"
 	DefaultLocation   = "global"
 	DefaultNamespace  = "github.com/memes/gce-metric"
+	// The default number of attempts that will be made to retrieve a value from
+	// GCE metadata.
+	DefaultMetadataAttempts = 3
+	// The default delay before the first retry of a failed metadata request;
+	// the delay is doubled for each subsequent attempt.
+	DefaultMetadataBackoff = 500 * time.Millisecond
+	// The default maximum duration of a single request to emit a time-series.
+	DefaultRPCTimeout = 30 * time.Second
+	// The default maximum duration to wait for the emitter to close.
+	DefaultCloseTimeout = 10 * time.Second
+	// The time to wait for a response to a keepalive ping before the connection
+	// is considered broken.
+	DefaultKeepaliveTimeout = 20 * time.Second
+	// The minimum interval between points written to the same time-series that
+	// will be accepted by Cloud Monitoring.
+	MinimumPointInterval = 5 * time.Second
+	// The monitored resource type for project-scoped metrics.
+	GlobalResourceType = "global"
+	// The domain of user-defined custom metrics.
+	CustomMetricDomain = "custom.googleapis.com"
+	// The domain of metrics written by Ops Agent workloads, which has different
+	// quota characteristics to custom metrics.
+	WorkloadMetricDomain = "workload.googleapis.com"
+	// The maximum number of time-series that Cloud Monitoring accepts in a
+	// single CreateTimeSeries request.
+	MaxTimeSeriesPerRequest = 200
 )
 
-// This error will be returned if a pipeline function requires a Google Cloud
-// execution environment.
-var errNotGCP = errors.New("not running on Google Cloud")
+// Matches the names of Google Cloud regions, e.g. us-central1.
+var regionPattern = regexp.MustCompile(`^[a-z]+(-[a-z]+)+[0-9]+$`)
+
+// Matches the index of a time-series rejected by Cloud Monitoring in the message
+// of a partial failure error, e.g. "timeSeries[3]: ...".
+var rejectedSeriesPattern = regexp.MustCompile(`timeSeries\[(\d+)\]`)
+
+// Defines the format used by the writer emitter to write time-series requests.
+type WriterFormat string
+
+const (
+	// Multi-line protobuf text format; this is the default.
+	WriterFormatText WriterFormat = "text"
+	// Indented, multi-line protobuf JSON.
+	WriterFormatProtoJSON WriterFormat = "protojson"
+	// Compact protobuf JSON with one request per line.
+	WriterFormatJSON WriterFormat = "json"
+	// One line per point with the end time, metric type, monitored resource
+	// type, and value.
+	WriterFormatSummary WriterFormat = "summary"
+	// Compact OTLP/JSON metrics export requests with one request per line.
+	WriterFormatOTLPJSON WriterFormat = "otlp-json"
+)
+
+var (
+	// This error will be returned if a pipeline function requires a Google Cloud
+	// execution environment.
+	errNotGCP = errors.New("not running on Google Cloud")
+	// This error will be returned if the number of metadata attempts is less
+	// than one.
+	ErrInvalidMetadataAttempts = errors.New("metadata attempts must be at least one")
+	// This error will be returned by the default emitter if the pipeline has
+	// been closed and not reconnected.
+	ErrPipelineClosed = errors.New("pipeline metric client is closed")
+	// This error will be returned if a monitored resource type cannot be used in
+	// place of the detected resource.
+	ErrUnsupportedResourceType = errors.New("unsupported monitored resource type")
+	// This error will be returned if the keepalive interval is negative.
+	ErrInvalidKeepalive = errors.New("keepalive interval must not be negative")
+	// This error will be returned if the RPC timeout is negative.
+	ErrInvalidRPCTimeout = errors.New("RPC timeout must not be negative")
+	// This error will be returned if the close timeout is negative.
+	ErrInvalidCloseTimeout = errors.New("close timeout must not be negative")
+	// This error will be returned by Close if the emitter has not closed before
+	// the close timeout.
+	ErrCloseTimeout = errors.New("timed out waiting for the emitter to close")
+	// This error will be returned if the processor concurrency is less than one.
+	ErrInvalidConcurrency = errors.New("concurrency must be at least one")
+	// This error will be returned if the batch size is less than one, or more
+	// than MaxTimeSeriesPerRequest.
+	ErrInvalidBatchSize = errors.New("batch size must be between 1 and 200")
+	// This error will be returned if the metric kind is not one that can be
+	// written to Google Cloud Monitoring.
+	ErrUnsupportedMetricKind = errors.New("metric kind must be GAUGE, DELTA, or CUMULATIVE")
+	// This error will be returned if the metric type is not in a domain that
+	// supports user-defined metrics.
+	ErrUnsupportedMetricDomain = errors.New("metric type must be in the custom.googleapis.com or workload.googleapis.com domain")
+	// This error will be returned if the writer emitter format is not one of the
+	// WriterFormat constants.
+	ErrUnsupportedWriterFormat = errors.New("writer format must be text, protojson, json, summary, or otlp-json")
+	// This error will be returned if the region for a regional endpoint is not a
+	// valid Google Cloud region name.
+	ErrInvalidRegion = errors.New("region must be a Google Cloud region name, e.g. us-central1")
+	// This error will be returned if the proxy URL cannot be parsed, or is not an
+	// http or https URL with a host.
+	ErrInvalidProxy = errors.New("proxy must be an http or https URL, e.g. http://proxy.example.com:3128")
+	// This error will be returned if metadata detection is skipped and a project
+	// ID has not been provided.
+	ErrMissingProjectID = errors.New("project ID must be provided when metadata detection is skipped")
+	// This error will be returned by the default emitter if a point is not later
+	// than the last point written to the same time-series.
+	ErrPointOutOfOrder = errors.New("points must be written to a time-series in order; the point is not later than the last point written")
+	// This error will be returned by the default emitter if a point is less than
+	// MinimumPointInterval, or the minimum series interval if that is longer,
+	// after the last point written to the same time-series.
+	ErrPointTooFrequent = errors.New("points must be written to a time-series at least the minimum point interval apart; increase the sample interval")
+	// This error will be returned if the endpoint is empty.
+	ErrInvalidEndpoint = errors.New("endpoint must be a host and port, e.g. monitoring.example.com:443")
+	// This error will be returned if the CA certificate file cannot be read, or
+	// does not contain any PEM encoded certificates.
+	ErrInvalidCACert = errors.New("CA certificate file must contain PEM encoded certificates")
+	// This error will be returned if the proxy does not accept the request to
+	// tunnel a connection to Cloud Monitoring.
+	ErrProxyConnect = errors.New("proxy CONNECT request failed")
+	// This error will be returned if a metric definition has the same metric
+	// type as the pipeline, or as another definition.
+	ErrDuplicateMetricType = errors.New("metric definitions must have distinct metric types")
+	// This error will be returned if the Prometheus remote-write URL cannot be
+	// parsed, or is not an http or https URL with a host.
+	ErrInvalidRemoteWriteURL = errors.New("remote-write URL must be an http or https URL, e.g. http://mimir.example.com/api/v1/push")
+	// This error will be returned if the Prometheus remote-write endpoint does
+	// not accept the samples.
+	ErrRemoteWrite = errors.New("remote-write request failed")
+	// This error will be returned if a point cannot be sent as a Prometheus
+	// sample.
+	ErrUnsupportedRemoteWriteValue = errors.New("remote-write samples must have a numeric, boolean, or distribution value")
+	// This error will be returned if the InfluxDB URL cannot be parsed, or is
+	// not an http or https URL with a host.
+	ErrInvalidInfluxURL = errors.New("InfluxDB URL must be an http or https URL, e.g. http://influxdb.example.com:8086")
+	// This error will be returned if the InfluxDB bucket or organization is
+	// empty.
+	ErrMissingInfluxParameter = errors.New("InfluxDB bucket and organization must be provided")
+	// This error will be returned if the InfluxDB write API does not accept the
+	// lines.
+	ErrInfluxWrite = errors.New("InfluxDB write request failed")
+	// This error will be returned by the OTLP/JSON writer if a time-series has
+	// a value that cannot be represented as an OTLP number data point.
+	ErrUnsupportedOTLPValue = errors.New("OTLP data points must have a numeric, boolean, or distribution value")
+	// This error will be returned if the Cloud Monitoring REST API does not
+	// accept the time-series.
+	ErrRESTWrite = errors.New("REST time-series request failed")
+	// This error will be returned if the minimum series interval is negative.
+	ErrInvalidMinSeriesInterval = errors.New("minimum series interval must not be negative")
+	// This error will be returned if the cumulative start time is not before
+	// the end time of a point in a CUMULATIVE time-series.
+	ErrInvalidCumulativeStartTime = errors.New("cumulative start time must be before the end time of every point")
+	// This error will be returned if an active window is not in the form
+	// HH:MM-HH:MM, or starts and ends at the same time.
+	ErrInvalidActiveWindow = errors.New("active window must be a start and end time of day in the form HH:MM-HH:MM, e.g. 09:00-17:00")
+)
 
 type metadataClient interface {
 	ProjectID() (string, error)
@@ -38,54 +199,189 @@ type Emitter func(context.Context, *monitoringpb.CreateTimeSeriesRequest) error
 
 type Closer func() error
 
+// Defines a function that will be called by the Processor after each attempt to
+// emit a time-series request, with the error returned by the Emitter.
+type EmitObserver func(*monitoringpb.CreateTimeSeriesRequest, error)
+
+// Defines a function that will be called by the Processor after each attempt to
+// emit a time-series request, with the time taken by the Emitter and the error it
+// returned.
+type LatencyObserver func(time.Duration, error)
+
 type Processor func(context.Context, <-chan generators.Metric) error
 
 type Option func(*Pipeline) error
 
+// Defines an additional metric to be emitted by a pipeline. Each request built
+// by the pipeline has a time-series for every definition alongside the
+// time-series for the pipeline's own metric type and kind.
+type MetricDefinition struct {
+	// The metric type, which is subject to the same domain rules as
+	// WithMetricType.
+	Type string
+	// The metric kind; GAUGE, DELTA, or CUMULATIVE.
+	Kind metricpb.MetricDescriptor_MetricKind
+	// Transformers applied to the definition's time-series after the pipeline
+	// transformers, e.g. a value transformer to emit integer values for this
+	// metric only.
+	Transformers []Transformer
+}
+
+// Defines the subset of Cloud Monitoring MetricClient functions that are used by
+// the default emitter and closer, including creation of the metric descriptor,
+// and by Validate, so that tests can substitute a fake client.
+type timeSeriesClient interface {
+	DescriptorClient
+	CreateTimeSeries(context.Context, *monitoringpb.CreateTimeSeriesRequest, ...gax.CallOption) error
+	CreateServiceTimeSeries(context.Context, *monitoringpb.CreateTimeSeriesRequest, ...gax.CallOption) error
+	CreateMetricDescriptor(context.Context, *monitoringpb.CreateMetricDescriptorRequest, ...gax.CallOption) (*metricpb.MetricDescriptor, error)
+	DeleteMetricDescriptor(context.Context, *monitoringpb.DeleteMetricDescriptorRequest, ...gax.CallOption) error
+	Close() error
+}
+
 type Pipeline struct {
 	logger                     logr.Logger
 	projectID                  string
+	requestName                string
 	metricType                 string
+	autoPrefix                 bool
+	metricKind                 metricpb.MetricDescriptor_MetricKind
+	cumulativeStartTime        time.Time
+	metricDefinitions          []MetricDefinition
+	activeWindow               *ActiveWindow
 	metricLabels               map[string]string
+	location                   string
+	namespace                  string
+	metadataAttempts           int
+	metadataBackoff            time.Duration
+	seed                       int64
+	skipMetadata               bool
+	instanceID                 string
+	zone                       string
+	resourceType               string
+	concurrency                int
+	batchSize                  int
+	continueOnPartialFailure   bool
+	rpcTimeout                 time.Duration
+	closeTimeout               time.Duration
+	serviceTimeSeries          bool
+	displayName                string
+	description                string
+	recreateDescriptor         bool
+	logRounding                bool
+	validateLabelKeys          bool
+	descriptorMu               sync.Mutex
+	descriptorCreated          bool
+	lastPointsMu               sync.Mutex
+	lastPoints                 map[string]time.Time
+	minSeriesInterval          time.Duration
+	emitterPointInterval       time.Duration
+	clientOptions              []option.ClientOption
 	excludeDefaultTransformers bool
 	transformers               []Transformer
+	observers                  []EmitObserver
+	latencyObservers           []LatencyObserver
 	emitter                    Emitter
 	closer                     Closer
-	client                     *monitoring.MetricClient
+	clientMu                   sync.RWMutex
+	client                     timeSeriesClient
 	// Allow unit tests to emulate a GCP environment
-	onGCE          func() bool
-	metadataClient metadataClient
+	onGCE           func() bool
+	metadataClient  metadataClient
+	newMetricClient func(context.Context, ...option.ClientOption) (timeSeriesClient, error)
 }
 
+// Close the emitter, waiting no longer than the close timeout. If the emitter
+// has not closed in time, e.g. because the connection to Cloud Monitoring is
+// wedged, an ErrCloseTimeout error is returned and the close continues in the
+// background, so that a shutting down process is not blocked.
 func (p *Pipeline) Close() error {
 	if p.closer == nil {
 		return nil
 	}
-	return p.closer()
+	if p.closeTimeout <= 0 {
+		return p.closer()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- p.closer()
+	}()
+	timer := time.NewTimer(p.closeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		p.logger.V(0).Info("Emitter did not close before the timeout; abandoning close", "timeout", p.closeTimeout)
+		return fmt.Errorf("%w: %v", ErrCloseTimeout, p.closeTimeout)
+	}
 }
 
+// Returns a new CreateTimeSeriesRequest for the metric, built by the pipeline
+// transformers. Each request has its own time-series, metric, and points, but
+// the metric labels and monitored resource may be shared with other requests
+// built by the pipeline; they must be treated as read-only, so that requests can
+// be emitted concurrently. If metric definitions have been added, the request has
+// a time-series for each definition following the pipeline's own time-series.
 func (p *Pipeline) BuildRequest(metric generators.Metric) (*monitoringpb.CreateTimeSeriesRequest, error) {
-	p.logger.V(2).Info("Building request", "metric", metric)
+	if p.logger.V(2).Enabled() {
+		p.logger.V(2).Info("Building request", "metric", metric)
+	}
 	req := &monitoringpb.CreateTimeSeriesRequest{
-		Name: "projects/" + p.projectID,
-		TimeSeries: []*monitoringpb.TimeSeries{
-			{
-				Metric: &metricpb.Metric{
-					Type:   p.metricType,
-					Labels: p.metricLabels,
-				},
-				MetricKind: metricpb.MetricDescriptor_GAUGE,
-			},
-		},
+		Name:       p.requestName,
+		TimeSeries: make([]*monitoringpb.TimeSeries, 0, 1+len(p.metricDefinitions)),
+	}
+	req.TimeSeries = append(req.TimeSeries, p.newTimeSeries(p.metricType, p.metricKind))
+	for _, definition := range p.metricDefinitions {
+		req.TimeSeries = append(req.TimeSeries, p.newTimeSeries(definition.Type, definition.Kind))
 	}
 	for _, transformer := range p.transformers {
 		if err := transformer(req, metric); err != nil {
 			return req, err
 		}
 	}
+	for i, definition := range p.metricDefinitions {
+		if len(definition.Transformers) == 0 {
+			continue
+		}
+		// The definition transformers only see the definition's own
+		// time-series.
+		definitionReq := &monitoringpb.CreateTimeSeriesRequest{
+			Name:       req.Name,
+			TimeSeries: []*monitoringpb.TimeSeries{req.TimeSeries[i+1]},
+		}
+		for _, transformer := range definition.Transformers {
+			if err := transformer(definitionReq, metric); err != nil {
+				return req, err
+			}
+		}
+		if !p.excludeDefaultTransformers {
+			if err := NewMetricKindValueTypeTransformer()(definitionReq, metric); err != nil {
+				return req, err
+			}
+		}
+	}
+	if !p.cumulativeStartTime.IsZero() {
+		if err := NewCumulativeStartTimeTransformer(p.cumulativeStartTime)(req, metric); err != nil {
+			return req, err
+		}
+	}
 	return req, nil
 }
 
+// Returns a new time-series for the metric type and kind.
+func (p *Pipeline) newTimeSeries(metricType string, kind metricpb.MetricDescriptor_MetricKind) *monitoringpb.TimeSeries {
+	return &monitoringpb.TimeSeries{
+		Metric: &metricpb.Metric{
+			Type: metricType,
+			// Each request gets its own copy of the base labels, so that an
+			// errant transformer cannot modify them for every request.
+			Labels: maps.Clone(p.metricLabels),
+		},
+		MetricKind: kind,
+	}
+}
+
 func WithLogger(logger logr.Logger) Option {
 	return func(p *Pipeline) error {
 		p.logger = logger
@@ -102,6 +398,9 @@ func WithProjectID(projectID string) Option {
 	}
 }
 
+// Use the supplied metric type for time-series. The metric type must be in the
+// custom.googleapis.com or workload.googleapis.com domain, e.g.
+// "custom.googleapis.com/my-metric", unless WithAutoPrefix is also used.
 func WithMetricType(metricType string) Option {
 	return func(p *Pipeline) error {
 		p.metricType = metricType
@@ -109,6 +408,267 @@ func WithMetricType(metricType string) Option {
 	}
 }
 
+// Use the supplied metric kind for time-series in preference to GAUGE. The value
+// transformers set the interval of each point to match the metric kind.
+func WithMetricKind(kind metricpb.MetricDescriptor_MetricKind) Option {
+	return func(p *Pipeline) error {
+		switch kind {
+		case metricpb.MetricDescriptor_GAUGE, metricpb.MetricDescriptor_DELTA, metricpb.MetricDescriptor_CUMULATIVE:
+			p.metricKind = kind
+			return nil
+		case metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED:
+		}
+		return fmt.Errorf("%w: %s", ErrUnsupportedMetricKind, kind)
+	}
+}
+
+// Use the supplied start time for the points of every CUMULATIVE time-series,
+// instead of one second before the timestamp of the first metric, so that the
+// start time is stable for the life of the time-series, e.g. across restarts of
+// the generator. The start time must be before the timestamp of every metric.
+// The option has no effect on GAUGE and DELTA time-series.
+func WithCumulativeStartTime(start time.Time) Option {
+	return func(p *Pipeline) error {
+		p.cumulativeStartTime = start
+		return nil
+	}
+}
+
+// Add metric definitions to the pipeline, so that every request has a
+// time-series for each definition as well as for the pipeline's metric type and
+// kind. The pipeline transformers are applied to every time-series, followed by
+// the definition's own transformers. The metric types must be distinct.
+func WithMetricDefinitions(definitions ...MetricDefinition) Option {
+	return func(p *Pipeline) error {
+		for _, definition := range definitions {
+			switch definition.Kind {
+			case metricpb.MetricDescriptor_GAUGE, metricpb.MetricDescriptor_DELTA, metricpb.MetricDescriptor_CUMULATIVE:
+				p.metricDefinitions = append(p.metricDefinitions, definition)
+				continue
+			case metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED:
+			}
+			return fmt.Errorf("%w: %s", ErrUnsupportedMetricKind, definition.Kind)
+		}
+		return nil
+	}
+}
+
+// Prefix the metric type with "custom.googleapis.com/" if it is not already in a
+// supported domain, instead of returning an error from NewPipeline.
+func WithAutoPrefix() Option {
+	return func(p *Pipeline) error {
+		p.autoPrefix = true
+		return nil
+	}
+}
+
+// Use the supplied location for generic_node resources when a Google Cloud
+// environment is not detected.
+func WithLocation(location string) Option {
+	return func(p *Pipeline) error {
+		p.location = location
+		return nil
+	}
+}
+
+// Use the supplied namespace for generic_node resources when a Google Cloud
+// environment is not detected.
+func WithNamespace(namespace string) Option {
+	return func(p *Pipeline) error {
+		p.namespace = namespace
+		return nil
+	}
+}
+
+// Set the number of attempts that will be made to retrieve each value from GCE
+// metadata, and the initial delay between attempts. The delay is doubled after
+// each failed attempt. This allows the pipeline to tolerate a metadata server
+// that is briefly unavailable, e.g. during boot of a new VM.
+func WithMetadataRetry(attempts int, backoff time.Duration) Option {
+	return func(p *Pipeline) error {
+		if attempts < 1 {
+			return ErrInvalidMetadataAttempts
+		}
+		p.metadataAttempts = attempts
+		p.metadataBackoff = backoff
+		return nil
+	}
+}
+
+// Use the supplied seed for any random values generated by the pipeline, such as
+// the node_id of a generic_node resource, so that repeated runs are reproducible.
+// A seed of zero, the default, will use a random source.
+func WithSeed(seed int64) Option {
+	return func(p *Pipeline) error {
+		p.seed = seed
+		return nil
+	}
+}
+
+// Use the supplied instance identifier for the gce_instance and gke_container
+// resources in preference to the value from the GCE metadata server, e.g. to
+// write metrics on behalf of a different instance. The option has no effect
+// when the pipeline is not running on Google Cloud; an empty string restores
+// the metadata value.
+func WithInstanceID(instanceID string) Option {
+	return func(p *Pipeline) error {
+		p.instanceID = instanceID
+		return nil
+	}
+}
+
+// Use the supplied zone for the gce_instance and gke_container resources in
+// preference to the value from the GCE metadata server. The option has no effect
+// when the pipeline is not running on Google Cloud; an empty string restores the
+// metadata value.
+func WithZone(zone string) Option {
+	return func(p *Pipeline) error {
+		p.zone = zone
+		return nil
+	}
+}
+
+// Use the supplied monitored resource type for time-series in preference to the
+// resource detected from the execution environment. Only the global resource
+// type is supported; an empty string restores detection.
+func WithResourceType(resourceType string) Option {
+	return func(p *Pipeline) error {
+		switch resourceType {
+		case "", GlobalResourceType:
+			p.resourceType = resourceType
+			return nil
+		default:
+			return fmt.Errorf("%w: %q", ErrUnsupportedResourceType, resourceType)
+		}
+	}
+}
+
+// Never query the GCE metadata server, even when running on Google Cloud; the
+// project ID must be set with WithProjectID, and the default transformers will
+// attach a generic_node resource instead of the detected GCE or GKE resource.
+// Use this when writing metrics on behalf of a different resource.
+func WithSkipMetadata() Option {
+	return func(p *Pipeline) error {
+		p.skipMetadata = true
+		return nil
+	}
+}
+
+// Round double values to the nearest integer when they are logged, without
+// changing the values that are written; e.g. to keep smooth values in Cloud
+// Monitoring while keeping the logs readable. Use NewIntegerTransformer or
+// NewRoundTransformer to change the values that are written.
+func WithLogRounding() Option {
+	return func(p *Pipeline) error {
+		p.logRounding = true
+		return nil
+	}
+}
+
+// Reject time-series with a metric or resource label key that Google Cloud
+// Monitoring will not accept, naming the key, before the request is emitted. The
+// keys are checked after every other transformer has been applied; see
+// NewLabelKeyValidationTransformer.
+func WithLabelKeyValidation() Option {
+	return func(p *Pipeline) error {
+		p.validateLabelKeys = true
+		return nil
+	}
+}
+
+// Set the user-agent reported by the Cloud Monitoring client, so that writes from
+// the pipeline can be identified in audit logs.
+func WithUserAgent(userAgent string) Option {
+	return func(p *Pipeline) error {
+		p.clientOptions = append(p.clientOptions, option.WithUserAgent(userAgent))
+		return nil
+	}
+}
+
+// Send requests to the regional Cloud Monitoring endpoint for the region, e.g.
+// monitoring.europe-west3.rep.googleapis.com, so that metric data is handled in
+// that region to meet data residency requirements.
+func WithRegionalEndpoint(region string) Option {
+	return func(p *Pipeline) error {
+		if !regionPattern.MatchString(region) {
+			return fmt.Errorf("%w: %q", ErrInvalidRegion, region)
+		}
+		p.clientOptions = append(p.clientOptions, option.WithEndpoint(regionalEndpoint(region)))
+		return nil
+	}
+}
+
+// Send requests to the Cloud Monitoring API at endpoint, a host and port, instead
+// of the default public endpoint; e.g. a Private Service Connect endpoint or an
+// internal proxy.
+func WithEndpoint(endpoint string) Option {
+	return func(p *Pipeline) error {
+		if endpoint == "" {
+			return ErrInvalidEndpoint
+		}
+		p.clientOptions = append(p.clientOptions, option.WithEndpoint(endpoint))
+		return nil
+	}
+}
+
+// Verify the TLS certificate of the Cloud Monitoring endpoint against the CA
+// certificates in the PEM encoded file at path, instead of the system roots.
+// Use this with WithEndpoint when the endpoint is fronted by a proxy with a
+// certificate issued by a private CA.
+func WithCACertFile(path string) Option {
+	return func(p *Pipeline) error {
+		creds, err := newCATransportCredentials(path)
+		if err != nil {
+			return err
+		}
+		p.clientOptions = append(p.clientOptions, option.WithGRPCDialOption(grpc.WithTransportCredentials(creds)))
+		return nil
+	}
+}
+
+// Connect to Cloud Monitoring through the HTTP proxy at proxyURL, using an HTTP
+// CONNECT tunnel. The gRPC transport already honors the HTTPS_PROXY and NO_PROXY
+// environment variables; use this option when the proxy should only apply to
+// this pipeline, or cannot be set in the environment. Credentials in the URL
+// are sent to the proxy with basic authentication.
+func WithProxy(proxyURL string) Option {
+	return func(p *Pipeline) error {
+		proxy, err := parseProxyURL(proxyURL)
+		if err != nil {
+			return err
+		}
+		p.clientOptions = append(p.clientOptions, option.WithGRPCDialOption(grpc.WithContextDialer(newProxyDialer(proxy))))
+		return nil
+	}
+}
+
+// Returns the host and port of the regional Cloud Monitoring endpoint for the
+// region.
+func regionalEndpoint(region string) string {
+	return "monitoring." + region + ".rep.googleapis.com:443"
+}
+
+// Send gRPC keepalive pings on the Cloud Monitoring connection after it has been
+// idle for the supplied interval, so that intermediaries do not drop the
+// connection between infrequent writes. An interval of zero, the default,
+// disables keepalive pings.
+func WithKeepalive(interval time.Duration) Option {
+	return func(p *Pipeline) error {
+		if interval < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidKeepalive, interval)
+		}
+		if interval == 0 {
+			return nil
+		}
+		p.clientOptions = append(p.clientOptions, option.WithGRPCDialOption(grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                interval,
+			Timeout:             DefaultKeepaliveTimeout,
+			PermitWithoutStream: true,
+		})))
+		return nil
+	}
+}
+
 func WithoutDefaultTransformers() Option {
 	return func(p *Pipeline) error {
 		p.excludeDefaultTransformers = true
@@ -123,13 +683,230 @@ func WithTransformers(transformers []Transformer) Option {
 	}
 }
 
+// Add the supplied labels to the metric of every time-series. Labels added by
+// transformers take precedence over these labels if the keys are the same.
+func WithMetricLabels(labels map[string]string) Option {
+	return func(p *Pipeline) error {
+		if p.metricLabels == nil {
+			p.metricLabels = make(map[string]string, len(labels))
+		}
+		maps.Copy(p.metricLabels, labels)
+		return nil
+	}
+}
+
+// Add the supplied labels to the monitored resource of every time-series, after
+// the resource has been added by the default transformers, replacing any
+// existing values. The labels must be defined for the monitored resource type,
+// e.g. to override the detected node_id of a generic_node.
+func WithResourceLabels(labels map[string]string) Option {
+	return func(p *Pipeline) error {
+		p.transformers = append(p.transformers, NewResourceLabelsTransformer(labels))
+		return nil
+	}
+}
+
+// Write every time-series once for each of the resource transformers, e.g.
+// NewGCEMonitoredResourceTransformer and NewGenericMonitoredResourceTransformer,
+// instead of only with the monitored resource added by the default
+// transformers. See NewMultiResourceTransformer.
+func WithResourceTransformers(transformers ...Transformer) Option {
+	return func(p *Pipeline) error {
+		p.transformers = append(p.transformers, NewMultiResourceTransformer(transformers...))
+		return nil
+	}
+}
+
+// Copy the resource labels with the supplied keys to the metric labels of every
+// time-series, after the monitored resource has been added by the default
+// transformers. E.g. WithPromoteResourceLabels("zone") allows metrics to be
+// aggregated by zone across resources.
+func WithPromoteResourceLabels(keys ...string) Option {
+	return func(p *Pipeline) error {
+		p.transformers = append(p.transformers, NewPromoteResourceLabelsTransformer(keys))
+		return nil
+	}
+}
+
+// Emit time-series requests from a pool of concurrency workers, so that RPC
+// latency does not limit the rate of emission when generating many time-series.
+// Requests for the same time-series are always emitted in order. When
+// concurrency is greater than one, the emitter and any EmitObservers must be
+// safe for concurrent use.
+func WithConcurrency(concurrency int) Option {
+	return func(p *Pipeline) error {
+		if concurrency < 1 {
+			return fmt.Errorf("%w: %d", ErrInvalidConcurrency, concurrency)
+		}
+		p.concurrency = concurrency
+		return nil
+	}
+}
+
+// Set the maximum number of time-series sent to Cloud Monitoring in a single
+// request. Requests with more time-series, e.g. from many metric definitions or
+// resource transformers, are split and sent as several requests. The default,
+// and the largest size accepted, is MaxTimeSeriesPerRequest.
+func WithBatchSize(size int) Option {
+	return func(p *Pipeline) error {
+		if size < 1 || size > MaxTimeSeriesPerRequest {
+			return fmt.Errorf("%w: %d", ErrInvalidBatchSize, size)
+		}
+		p.batchSize = size
+		return nil
+	}
+}
+
+// Treat a request that Cloud Monitoring only partly accepts as written, logging
+// the points that were rejected, instead of returning an error that stops the
+// pipeline; e.g. so that one time-series with an invalid label doesn't stop the
+// others from being written. Requests that are rejected entirely still return an
+// error. The option only applies to the default emitter, as the REST transport
+// does not report partial failures.
+func WithContinueOnPartialFailure() Option {
+	return func(p *Pipeline) error {
+		p.continueOnPartialFailure = true
+		return nil
+	}
+}
+
+// Set the maximum duration of each attempt to emit a time-series request, so that
+// a stuck request cannot block the processor until the context is cancelled.
+// The default is DefaultRPCTimeout; a timeout of zero removes the limit.
+func WithRPCTimeout(timeout time.Duration) Option {
+	return func(p *Pipeline) error {
+		if timeout < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidRPCTimeout, timeout)
+		}
+		p.rpcTimeout = timeout
+		return nil
+	}
+}
+
+// Set the maximum duration that Close will wait for the emitter to close, so that
+// a wedged connection cannot stop a process from shutting down. The default is
+// DefaultCloseTimeout; a timeout of zero removes the limit.
+func WithCloseTimeout(timeout time.Duration) Option {
+	return func(p *Pipeline) error {
+		if timeout < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidCloseTimeout, timeout)
+		}
+		p.closeTimeout = timeout
+		return nil
+	}
+}
+
+// Drop any time-series from a request that has a point less than interval after
+// the last point successfully emitted to the same time-series, instead of
+// sending points that Google Cloud Monitoring will reject for being written too
+// frequently; a request is not emitted if every time-series is dropped. A
+// time-series is identified by its metric type, metric labels, and monitored
+// resource. If the emitter writes to Cloud Monitoring, MinimumPointInterval is
+// used when it is longer than interval. The default interval of zero disables
+// dropping, and the Cloud Monitoring emitters return ErrPointTooFrequent or
+// ErrPointOutOfOrder instead.
+func WithMinSeriesInterval(interval time.Duration) Option {
+	return func(p *Pipeline) error {
+		if interval < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidMinSeriesInterval, interval)
+		}
+		p.minSeriesInterval = interval
+		return nil
+	}
+}
+
+// When enabled, the default emitter will write time-series with the
+// CreateServiceTimeSeries API instead of CreateTimeSeries. This API is intended
+// for service and system metrics written by Google Cloud agents, and has
+// different quotas and semantics; most custom metrics should use the default.
+// The option has no effect if a different emitter is used.
+func WithServiceTimeSeries(enabled bool) Option {
+	return func(p *Pipeline) error {
+		p.serviceTimeSeries = enabled
+		return nil
+	}
+}
+
+// Set the display name of the metric descriptor, so that the metric is easier to
+// find in the Cloud Console. When a display name or description is set, the
+// default emitter creates the metric descriptor before the first time-series is
+// written, instead of relying on Cloud Monitoring to create it automatically.
+func WithDisplayName(displayName string) Option {
+	return func(p *Pipeline) error {
+		p.displayName = displayName
+		return nil
+	}
+}
+
+// Set the description of the metric descriptor; see WithDisplayName.
+func WithDescription(description string) Option {
+	return func(p *Pipeline) error {
+		p.description = description
+		return nil
+	}
+}
+
+// When enabled, the default emitter deletes the metric descriptor, if it exists,
+// and creates it again to match the time-series before the first point is
+// written. Use this when the existing descriptor has an incompatible kind, value
+// type, or labels.
+//
+// NOTE: Deleting a metric descriptor deletes all of the metric's data.
+func WithRecreateDescriptor(enabled bool) Option {
+	return func(p *Pipeline) error {
+		p.recreateDescriptor = enabled
+		return nil
+	}
+}
+
+// Add the supplied EmitObservers to the pipeline; they will be called in order
+// after every attempt to emit a time-series request.
+func WithEmitObservers(observers []EmitObserver) Option {
+	return func(p *Pipeline) error {
+		p.observers = append(p.observers, observers...)
+		return nil
+	}
+}
+
+// Add the supplied LatencyObservers to the pipeline; they will be called in order
+// after every attempt to emit a time-series request, after any EmitObservers.
+func WithLatencyObservers(observers []LatencyObserver) Option {
+	return func(p *Pipeline) error {
+		p.latencyObservers = append(p.latencyObservers, observers...)
+		return nil
+	}
+}
+
+// Write each time-series request to the writer instead of sending it to Google
+// Cloud Monitoring, and log the value and time of every point at Info level.
+// Use io.Discard as the writer to only log the values. The requests are written
+// in protobuf text format; use WithFormattedWriterEmitter to choose a different
+// format.
 func WithWriterEmitter(writer io.Writer) Option {
+	return WithFormattedWriterEmitter(writer, WriterFormatText)
+}
+
+// Write each time-series request to the writer in the supplied format instead of
+// sending it to Google Cloud Monitoring, and log the value and time of every
+// point at Info level.
+func WithFormattedWriterEmitter(writer io.Writer, format WriterFormat) Option {
 	return func(p *Pipeline) error {
+		switch format {
+		case WriterFormatText, WriterFormatProtoJSON, WriterFormatJSON, WriterFormatSummary, WriterFormatOTLPJSON:
+		default:
+			return fmt.Errorf("%w: %q", ErrUnsupportedWriterFormat, format)
+		}
 		p.emitter = func(_ context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
-			p.logger.V(2).Info("Emitting time-series request to writer")
-			if _, err := fmt.Fprintf(writer, "%s\n", prototext.Format(req)); err != nil {
+			p.logger.V(2).Info("Emitting time-series request to writer", "format", format)
+			output, err := formatRequest(req, format)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(writer, output); err != nil {
 				return fmt.Errorf("failure writing time-series request: %w", err)
 			}
+			p.recordLastPoints(req)
+			p.logWrittenPoints(0, req)
 			return nil
 		}
 		p.closer = func() error {
@@ -140,96 +917,539 @@ func WithWriterEmitter(writer io.Writer) Option {
 	}
 }
 
+// Returns the request formatted for the writer emitter, with a trailing newline.
+func formatRequest(req *monitoringpb.CreateTimeSeriesRequest, format WriterFormat) (string, error) {
+	switch format {
+	case WriterFormatProtoJSON:
+		return protojson.MarshalOptions{Multiline: true, Indent: "  "}.Format(req) + "\n", nil
+	case WriterFormatJSON:
+		output, err := protojson.Marshal(req)
+		if err != nil {
+			return "", fmt.Errorf("failure marshaling time-series request: %w", err)
+		}
+		return string(output) + "\n", nil
+	case WriterFormatSummary:
+		var builder strings.Builder
+		for _, series := range req.GetTimeSeries() {
+			for _, point := range series.GetPoints() {
+				fmt.Fprintf(&builder, "%s %s %s %v\n", point.GetInterval().GetEndTime().AsTime().Format(time.RFC3339), series.GetMetric().GetType(), series.GetResource().GetType(), typedValue(point.GetValue()))
+			}
+		}
+		return builder.String(), nil
+	case WriterFormatOTLPJSON:
+		return formatOTLPJSON(req)
+	case WriterFormatText:
+		fallthrough
+	default:
+		return prototext.Format(req) + "\n", nil
+	}
+}
+
 func NewPipeline(ctx context.Context, options ...Option) (*Pipeline, error) {
 	pipeline := &Pipeline{
 		logger:                     logr.Discard(),
 		projectID:                  "",
+		requestName:                "",
 		metricType:                 DefaultMetricType,
+		autoPrefix:                 false,
+		metricKind:                 metricpb.MetricDescriptor_GAUGE,
+		cumulativeStartTime:        time.Time{},
+		metricDefinitions:          []MetricDefinition{},
+		activeWindow:               nil,
 		metricLabels:               nil,
+		location:                   DefaultLocation,
+		namespace:                  DefaultNamespace,
+		metadataAttempts:           DefaultMetadataAttempts,
+		metadataBackoff:            DefaultMetadataBackoff,
+		seed:                       0,
+		skipMetadata:               false,
+		instanceID:                 "",
+		zone:                       "",
+		resourceType:               "",
+		concurrency:                1,
+		batchSize:                  MaxTimeSeriesPerRequest,
+		continueOnPartialFailure:   false,
+		rpcTimeout:                 DefaultRPCTimeout,
+		closeTimeout:               DefaultCloseTimeout,
+		serviceTimeSeries:          false,
+		displayName:                "",
+		description:                "",
+		recreateDescriptor:         false,
+		logRounding:                false,
+		validateLabelKeys:          false,
+		descriptorMu:               sync.Mutex{},
+		descriptorCreated:          false,
+		lastPointsMu:               sync.Mutex{},
+		lastPoints:                 map[string]time.Time{},
+		minSeriesInterval:          0,
+		emitterPointInterval:       0,
+		clientOptions:              []option.ClientOption{},
 		excludeDefaultTransformers: false,
 		transformers:               []Transformer{},
+		observers:                  []EmitObserver{},
+		latencyObservers:           []LatencyObserver{},
 		emitter:                    nil,
 		closer:                     nil,
+		clientMu:                   sync.RWMutex{},
 		client:                     nil,
 		onGCE:                      metadata.OnGCE,
 		metadataClient:             metadata.NewClient(nil),
+		newMetricClient:            newMonitoringMetricClient,
 	}
 	for _, option := range options {
 		if err := option(pipeline); err != nil {
 			return nil, err
 		}
 	}
+	metricType, err := qualifyMetricType(pipeline.metricType, pipeline.autoPrefix)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.metricType = metricType
+	metricTypes := map[string]struct{}{metricType: {}}
+	for i, definition := range pipeline.metricDefinitions {
+		definitionType, err := qualifyMetricType(definition.Type, pipeline.autoPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := metricTypes[definitionType]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMetricType, definitionType)
+		}
+		metricTypes[definitionType] = struct{}{}
+		pipeline.metricDefinitions[i].Type = definitionType
+	}
 	if pipeline.projectID == "" {
+		if pipeline.skipMetadata {
+			return nil, ErrMissingProjectID
+		}
 		if !pipeline.onGCE() {
 			return nil, errNotGCP
 		}
-		projectID, err := pipeline.metadataClient.ProjectID()
+		projectID, err := pipeline.retryMetadata(ctx, pipeline.metadataClient.ProjectID)
 		if err != nil {
 			return nil, fmt.Errorf("failure getting project identifier from metadataClient: %w", err)
 		}
 		pipeline.projectID = projectID
 	}
+	pipeline.requestName = "projects/" + pipeline.projectID
 	if !pipeline.excludeDefaultTransformers {
 		defaultTransformers, err := pipeline.defaultTransformers(ctx)
 		if err != nil {
 			return nil, err
 		}
 		pipeline.transformers = append(defaultTransformers, pipeline.transformers...)
+		// Verify the value type of the finished request is valid for the
+		// metric kind.
+		pipeline.transformers = append(pipeline.transformers, NewMetricKindValueTypeTransformer())
 	}
-	if pipeline.emitter == nil {
-		pipeline.emitter = pipeline.defaultEmitter
+	if pipeline.validateLabelKeys {
+		pipeline.transformers = append(pipeline.transformers, NewLabelKeyValidationTransformer())
 	}
 	if pipeline.closer == nil {
 		pipeline.closer = pipeline.defaultCloser
 	}
-	if pipeline.client == nil {
-		client, err := monitoring.NewMetricClient(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failure creating new metric client: %w", err)
+	// Only the default emitter uses the Cloud Monitoring client; an alternative
+	// emitter may be used without Google Cloud credentials.
+	if pipeline.emitter == nil {
+		pipeline.emitter = pipeline.defaultEmitter
+		pipeline.emitterPointInterval = MinimumPointInterval
+		if pipeline.client == nil {
+			client, err := pipeline.newMetricClient(ctx, pipeline.clientOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("failure creating new metric client: %w", err)
+			}
+			pipeline.client = client
 		}
-		pipeline.client = client
 	}
 	return pipeline, nil
 }
 
+// Returns a new Cloud Monitoring metric client as a timeSeriesClient; this is the
+// default function used by Pipeline to create clients.
+func newMonitoringMetricClient(ctx context.Context, opts ...option.ClientOption) (timeSeriesClient, error) { //nolint:ireturn // Tests substitute a fake client
+	client, err := monitoring.NewMetricClient(ctx, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // The error is wrapped by the callers
+	}
+	return client, nil
+}
+
+// Returns the Cloud Monitoring client options set by the supplied options, e.g.
+// WithEndpoint and WithProxy, so that other Cloud Monitoring clients can be
+// created with the same endpoint and transport as a pipeline. Options that do
+// not change the client options are ignored.
+func ClientOptions(options ...Option) ([]option.ClientOption, error) {
+	pipeline := &Pipeline{clientOptions: []option.ClientOption{}} //nolint:exhaustruct // Only the client options are used
+	for _, option := range options {
+		if err := option(pipeline); err != nil {
+			return nil, err
+		}
+	}
+	return pipeline.clientOptions, nil
+}
+
 func (p *Pipeline) defaultEmitter(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
 	p.logger.V(2).Info("Emitting time-series request to GCP")
-	if err := p.client.CreateTimeSeries(ctx, req); err != nil {
-		return fmt.Errorf("failure sending create time-series request: %w", err)
+	p.clientMu.RLock()
+	defer p.clientMu.RUnlock()
+	if p.client == nil {
+		return ErrPipelineClosed
 	}
+	if err := p.ensureDescriptor(ctx, p.client, req); err != nil {
+		return err
+	}
+	if err := p.writeTimeSeries(ctx, p.client, req); err != nil {
+		return err
+	}
+	p.logWrittenPoints(1, req)
 	return nil
 }
 
+// Returns the interval that must separate points written to the same
+// time-series; the minimum series interval, or the interval required by the
+// emitter if that is longer. An interval of zero disables the check.
+func (p *Pipeline) pointInterval() time.Duration {
+	return max(p.minSeriesInterval, p.emitterPointInterval)
+}
+
+// Verify that every point in the request is later than, and at least the point
+// interval after, the last point successfully written to the same time-series by
+// this pipeline. If a minimum series interval is set the time-series that fail
+// the check are dropped from the request, and nil is returned if every
+// time-series is dropped. Otherwise an error is returned; Cloud Monitoring
+// rejects points that are out of order or too frequent with an error that
+// doesn't say why, and this check replaces it with one that does.
+func (p *Pipeline) checkPointInterval(req *monitoringpb.CreateTimeSeriesRequest) (*monitoringpb.CreateTimeSeriesRequest, error) {
+	interval := p.pointInterval()
+	if interval == 0 {
+		return req, nil
+	}
+	p.lastPointsMu.Lock()
+	defer p.lastPointsMu.Unlock()
+	series := make([]*monitoringpb.TimeSeries, 0, len(req.GetTimeSeries()))
+	for _, ts := range req.GetTimeSeries() {
+		err := p.checkSeriesPoints(ts, interval)
+		switch {
+		case err == nil:
+			series = append(series, ts)
+		case p.minSeriesInterval > 0:
+			p.logger.V(0).Info("Dropping time-series written too frequently", "metricType", ts.GetMetric().GetType(), "interval", interval, "err", err)
+		default:
+			return nil, err
+		}
+	}
+	switch len(series) {
+	case 0:
+		return nil, nil //nolint:nilnil // Every time-series has been dropped
+	case len(req.GetTimeSeries()):
+		return req, nil
+	default:
+		return &monitoringpb.CreateTimeSeriesRequest{
+			Name:       req.GetName(),
+			TimeSeries: series,
+		}, nil
+	}
+}
+
+// Returns an error if a point in the time-series is not later than, or is less
+// than interval after, the last point written to the time-series. The caller
+// must hold lastPointsMu.
+func (p *Pipeline) checkSeriesPoints(series *monitoringpb.TimeSeries, interval time.Duration) error {
+	last, ok := p.lastPoints[singleSeriesIdentity(series)]
+	if !ok {
+		return nil
+	}
+	for _, point := range series.GetPoints() {
+		end := point.GetInterval().GetEndTime().AsTime()
+		switch {
+		case !end.After(last):
+			return fmt.Errorf("%w: %s at %s, last point at %s", ErrPointOutOfOrder, series.GetMetric().GetType(), end.Format(time.RFC3339Nano), last.Format(time.RFC3339Nano))
+		case end.Sub(last) < interval:
+			return fmt.Errorf("%w: %s at %s is %s after the last point", ErrPointTooFrequent, series.GetMetric().GetType(), end.Format(time.RFC3339Nano), end.Sub(last))
+		}
+	}
+	return nil
+}
+
+// Record the latest end time of the points in each time-series of the request,
+// for checkPointInterval. Emitters call this for each request or batch that is
+// written successfully; nothing is recorded if the point interval check is
+// disabled.
+func (p *Pipeline) recordLastPoints(req *monitoringpb.CreateTimeSeriesRequest) {
+	if p.pointInterval() == 0 {
+		return
+	}
+	p.lastPointsMu.Lock()
+	defer p.lastPointsMu.Unlock()
+	for _, series := range req.GetTimeSeries() {
+		identity := singleSeriesIdentity(series)
+		for _, point := range series.GetPoints() {
+			if end := point.GetInterval().GetEndTime().AsTime(); end.After(p.lastPoints[identity]) {
+				p.lastPoints[identity] = end
+			}
+		}
+	}
+}
+
+// Returns a string that identifies a single time-series, as seriesIdentity does
+// for a request.
+func singleSeriesIdentity(series *monitoringpb.TimeSeries) string {
+	return seriesIdentity(&monitoringpb.CreateTimeSeriesRequest{
+		TimeSeries: []*monitoringpb.TimeSeries{series},
+	})
+}
+
+// Send the request with the client function chosen by WithServiceTimeSeries,
+// split into batches of no more than the batch size. Every batch is sent even if
+// an earlier batch fails, and the errors from all batches are returned together.
+// Partial failures are logged and ignored if WithContinueOnPartialFailure is set.
+func (p *Pipeline) writeTimeSeries(ctx context.Context, client timeSeriesClient, req *monitoringpb.CreateTimeSeriesRequest) error {
+	var errs []error
+	for _, batch := range splitRequest(req, p.batchSize) {
+		var err error
+		if p.serviceTimeSeries {
+			if err = client.CreateServiceTimeSeries(ctx, batch); err != nil {
+				err = fmt.Errorf("failure sending create service time-series request: %w", err)
+			}
+		} else if err = client.CreateTimeSeries(ctx, batch); err != nil {
+			err = fmt.Errorf("failure sending create time-series request: %w", err)
+		}
+		if err == nil {
+			p.recordLastPoints(batch)
+			continue
+		}
+		if summary := partialFailure(err); summary != nil && p.continueOnPartialFailure {
+			p.logPartialFailure(summary, err)
+			p.recordLastPoints(acceptedSeries(batch, err))
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the summary of a CreateTimeSeries error in which Cloud Monitoring
+// accepted some of the points, or nil if the error is not a partial failure.
+func partialFailure(err error) *monitoringpb.CreateTimeSeriesSummary {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if summary, ok := detail.(*monitoringpb.CreateTimeSeriesSummary); ok && summary.GetSuccessPointCount() > 0 {
+			return summary
+		}
+	}
+	return nil
+}
+
+// Log the points rejected in a partial failure, with the reason for each group
+// of points; Cloud Monitoring identifies the rejected time-series by index in
+// the error message.
+func (p *Pipeline) logPartialFailure(summary *monitoringpb.CreateTimeSeriesSummary, err error) {
+	p.logger.V(0).Info("Some points were rejected by Cloud Monitoring; continuing", "total", summary.GetTotalPointCount(), "success", summary.GetSuccessPointCount(), "err", err)
+	for _, pointError := range summary.GetErrors() {
+		p.logger.V(0).Info("Rejected points", "count", pointError.GetPointCount(), "code", codes.Code(pointError.GetStatus().GetCode()).String(), "message", pointError.GetStatus().GetMessage()) //nolint:gosec // Status codes are small positive numbers
+	}
+}
+
+// Returns a request with the time-series of req that were accepted in a partial
+// failure, omitting those that Cloud Monitoring identified by index in the error
+// message. If the message does not identify any time-series, none can be known
+// to have been accepted and the returned request is empty.
+func acceptedSeries(req *monitoringpb.CreateTimeSeriesRequest, err error) *monitoringpb.CreateTimeSeriesRequest {
+	accepted := &monitoringpb.CreateTimeSeriesRequest{
+		Name:       req.GetName(),
+		TimeSeries: []*monitoringpb.TimeSeries{},
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return accepted
+	}
+	rejected := map[int]struct{}{}
+	for _, match := range rejectedSeriesPattern.FindAllStringSubmatch(st.Message(), -1) {
+		if index, err := strconv.Atoi(match[1]); err == nil {
+			rejected[index] = struct{}{}
+		}
+	}
+	if len(rejected) == 0 {
+		return accepted
+	}
+	for i, series := range req.GetTimeSeries() {
+		if _, ok := rejected[i]; !ok {
+			accepted.TimeSeries = append(accepted.TimeSeries, series)
+		}
+	}
+	return accepted
+}
+
+// Returns the request split into requests for the same name with no more than
+// size time-series each, in order. The request is returned unchanged if it is
+// already small enough.
+func splitRequest(req *monitoringpb.CreateTimeSeriesRequest, size int) []*monitoringpb.CreateTimeSeriesRequest {
+	if size < 1 || len(req.GetTimeSeries()) <= size {
+		return []*monitoringpb.CreateTimeSeriesRequest{req}
+	}
+	batches := make([]*monitoringpb.CreateTimeSeriesRequest, 0, (len(req.GetTimeSeries())+size-1)/size)
+	for chunk := range slices.Chunk(req.GetTimeSeries(), size) {
+		batches = append(batches, &monitoringpb.CreateTimeSeriesRequest{
+			Name:       req.GetName(),
+			TimeSeries: chunk,
+		})
+	}
+	return batches
+}
+
 func (p *Pipeline) defaultCloser() error {
 	p.logger.V(2).Info("Closing time-series emitter")
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
 	if p.client == nil {
 		return nil
 	}
-	if err := p.client.Close(); err != nil {
+	client := p.client
+	p.client = nil
+	if err := client.Close(); err != nil {
 		return fmt.Errorf("failure closing metric client: %w", err)
 	}
 	return nil
 }
 
-func (p *Pipeline) defaultTransformers(_ context.Context) ([]Transformer, error) {
+// Replaces the Cloud Monitoring client used by the default emitter with a new
+// client, closing the existing client if it is still open. This allows a
+// long-running embedder to recover from a connection-level failure, or to resume
+// emitting after Close, without rebuilding the pipeline.
+func (p *Pipeline) Reconnect(ctx context.Context) error {
+	p.logger.V(1).Info("Reconnecting metric client")
+	client, err := p.newMetricClient(ctx, p.clientOptions...)
+	if err != nil {
+		return fmt.Errorf("failure creating new metric client: %w", err)
+	}
+	p.clientMu.Lock()
+	previous := p.client
+	p.client = client
+	p.clientMu.Unlock()
+	if previous == nil {
+		return nil
+	}
+	if err := previous.Close(); err != nil {
+		p.logger.V(2).Info("Error closing previous metric client; ignoring", "err", err)
+	}
+	return nil
+}
+
+// Log the value and timestamp of each point in the request at the verbosity
+// level, rounding double values to the nearest integer if log rounding is
+// enabled.
+func (p *Pipeline) logWrittenPoints(level int, req *monitoringpb.CreateTimeSeriesRequest) {
+	logger := p.logger.V(level)
+	if !logger.Enabled() {
+		return
+	}
+	for _, series := range req.GetTimeSeries() {
+		for _, point := range series.GetPoints() {
+			value := typedValue(point.GetValue())
+			if v, ok := value.(float64); ok && p.logRounding {
+				value = math.Round(v)
+			}
+			logger.Info("Wrote time-series point", "value", value, "timestamp", point.GetInterval().GetEndTime().AsTime())
+		}
+	}
+}
+
+// Returns the value held by the TypedValue as a native type, or the mean of a
+// distribution value, so that it can be logged concisely.
+func typedValue(value *monitoringpb.TypedValue) any {
+	switch v := value.GetValue().(type) {
+	case *monitoringpb.TypedValue_DoubleValue:
+		return v.DoubleValue
+	case *monitoringpb.TypedValue_Int64Value:
+		return v.Int64Value
+	case *monitoringpb.TypedValue_BoolValue:
+		return v.BoolValue
+	case *monitoringpb.TypedValue_StringValue:
+		return v.StringValue
+	case *monitoringpb.TypedValue_DistributionValue:
+		return v.DistributionValue.GetMean()
+	default:
+		return nil
+	}
+}
+
+// Returns the metric type if it is in a domain that supports user-defined
+// metrics. If autoPrefix is true, a metric type in any other domain is prefixed
+// with the custom metric domain, otherwise an error is returned.
+func qualifyMetricType(metricType string, autoPrefix bool) (string, error) {
+	domain, name, _ := strings.Cut(metricType, "/")
+	if (domain == CustomMetricDomain || domain == WorkloadMetricDomain) && name != "" {
+		return metricType, nil
+	}
+	if autoPrefix && metricType != "" {
+		return CustomMetricDomain + "/" + metricType, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedMetricDomain, metricType)
+}
+
+// Calls the supplied metadata function until it succeeds, the configured number
+// of attempts has been made, or the context is cancelled.
+func (p *Pipeline) retryMetadata(ctx context.Context, fn func() (string, error)) (string, error) {
+	return RetryWithBackoff(ctx, p.logger, p.metadataAttempts, p.metadataBackoff, func(_ context.Context) (string, error) {
+		return fn()
+	})
+}
+
+// Calls fn until it succeeds, or it has been called attempts times, waiting for
+// backoff before the second call and doubling the wait for each subsequent call.
+// The error from the last call is returned if every call fails, or with the
+// context error if the context is done while waiting.
+func RetryWithBackoff[T any](ctx context.Context, logger logr.Logger, attempts int, backoff time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil || attempt >= attempts {
+			return value, err
+		}
+		logger.V(1).Info("Attempt failed; retrying", "attempt", attempt, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return value, fmt.Errorf("context cancelled while retrying: %w", errors.Join(err, ctx.Err()))
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (p *Pipeline) defaultTransformers(ctx context.Context) ([]Transformer, error) {
 	p.logger.V(1).Info("Collecting default transformers")
 	transformers := []Transformer{}
-	if p.onGCE() { //nolint:nestif // Determining the correct Google Cloud environment is a set of cascading tests
+	if p.resourceType == GlobalResourceType {
+		p.logger.V(2).Info("Adding global transformer to pipeline")
+		transformers = append(transformers, NewGlobalMonitoredResourceTransformer(p.projectID), NewDoubleTypedValueTransformer())
+		return transformers, nil
+	}
+	if !p.skipMetadata && p.onGCE() { //nolint:nestif // Determining the correct Google Cloud environment is a set of cascading tests
 		p.logger.V(2).Info("Detected we're running on GCE")
-		instanceID, err := p.metadataClient.InstanceID()
-		if err != nil {
-			return nil, fmt.Errorf("failure getting instance identifier from metadata client: %w", err)
+		instanceID := p.instanceID
+		if instanceID == "" {
+			var err error
+			if instanceID, err = p.retryMetadata(ctx, p.metadataClient.InstanceID); err != nil {
+				return nil, fmt.Errorf("failure getting instance identifier from metadata client: %w", err)
+			}
 		}
-		zone, err := p.metadataClient.Zone()
-		if err != nil {
-			return nil, fmt.Errorf("failure getting zone from metadata client: %w", err)
+		zone := p.zone
+		if zone == "" {
+			var err error
+			if zone, err = p.retryMetadata(ctx, p.metadataClient.Zone); err != nil {
+				return nil, fmt.Errorf("failure getting zone from metadata client: %w", err)
+			}
 		}
 		p.logger.V(2).Info("Retrieved GCE metadata", "instanceID", instanceID, "zone", zone)
 		if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
 			// Use a transformer that add a gke_container resource type to
 			// the request.
 			p.logger.V(2).Info("Looks like GKE", "instanceID", instanceID, "zone", zone)
-			clusterName, err := p.metadataClient.InstanceAttributeValue("cluster_name")
+			clusterName, err := p.retryMetadata(ctx, func() (string, error) {
+				return p.metadataClient.InstanceAttributeValue("cluster_name")
+			})
 			if err != nil {
 				return nil, fmt.Errorf("failure getting 'cluster_name' attribute from metadataClient: %w", err)
 			}
@@ -241,16 +1461,42 @@ func (p *Pipeline) defaultTransformers(_ context.Context) ([]Transformer, error)
 			transformers = append(transformers, NewGCEMonitoredResourceTransformer(p.projectID, instanceID, zone))
 		}
 	} else {
-		p.logger.V(2).Info("GCE not detected, adding generic_node transformer to pipeline")
+		p.logger.V(2).Info("GCE not detected or metadata skipped, adding generic_node transformer to pipeline", "skipMetadata", p.skipMetadata)
 		// Use a transformer that adds a generic_node resource type to
 		// the request.
-		transformers = append(transformers, NewGenericMonitoredResourceTransformer(p.projectID, DefaultLocation, DefaultNamespace, uuid.New().String()))
+		nodeID, err := p.newUUID()
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, NewGenericMonitoredResourceTransformer(p.projectID, p.location, p.namespace, nodeID.String()))
 	}
 	transformers = append(transformers, NewDoubleTypedValueTransformer())
 	return transformers, nil
 }
 
+// Returns a new random UUID, which will be deterministic if the pipeline has a
+// non-zero seed.
+func (p *Pipeline) newUUID() (uuid.UUID, error) {
+	if p.seed == 0 {
+		return uuid.New(), nil
+	}
+	var seed [32]byte
+	binary.LittleEndian.PutUint64(seed[:], uint64(p.seed))
+	id, err := uuid.NewRandomFromReader(rand.NewChaCha8(seed))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failure generating seeded UUID: %w", err)
+	}
+	return id, nil
+}
+
+// Returns a Processor that builds a time-series request for every Metric read
+// from the input channel, and emits it. If the pipeline has a concurrency
+// greater than one, requests are emitted by a pool of workers; requests for the
+// same time-series are always emitted by the same worker, preserving their order.
 func (p *Pipeline) Processor() Processor {
+	if p.concurrency > 1 {
+		return p.concurrentProcessor()
+	}
 	return func(ctx context.Context, input <-chan generators.Metric) error {
 		p.logger.V(2).Info("Launching pipeline processor")
 		for {
@@ -263,14 +1509,197 @@ func (p *Pipeline) Processor() Processor {
 					p.logger.V(2).Info("Input channel is closed; exiting")
 					return nil
 				}
+				if !p.isActive(value) {
+					continue
+				}
 				req, err := p.BuildRequest(value)
 				if err != nil {
 					return err
 				}
-				if err := p.emitter(ctx, req); err != nil {
+				if err := p.emit(ctx, req); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Build a time-series request for the Metric and emit it, without launching a
+// Processor; use this to send a single value, e.g. from a scheduled job. The
+// Metric is ignored if it is outside the active window.
+func (p *Pipeline) Emit(ctx context.Context, metric generators.Metric) error {
+	if !p.isActive(metric) {
+		return nil
+	}
+	req, err := p.BuildRequest(metric)
+	if err != nil {
+		return err
+	}
+	return p.emit(ctx, req)
+}
+
+// Build a time-series request for each Metric and emit them, in order, combined
+// into as few requests as possible; use this to write a range of historical
+// values, e.g. a backfill. Cloud Monitoring accepts a single point for each
+// time-series in a request, so each request holds no more than the batch size
+// of time-series, and a new request is started when a time-series already has a
+// point in the current request. Metrics outside the active window are ignored.
+func (p *Pipeline) EmitBatch(ctx context.Context, metrics []generators.Metric) error {
+	var batch *monitoringpb.CreateTimeSeriesRequest
+	identities := map[string]struct{}{}
+	for _, metric := range metrics {
+		if !p.isActive(metric) {
+			continue
+		}
+		req, err := p.BuildRequest(metric)
+		if err != nil {
+			return err
+		}
+		for _, series := range req.GetTimeSeries() {
+			identity := singleSeriesIdentity(series)
+			if _, ok := identities[identity]; ok || (batch != nil && len(batch.TimeSeries) >= p.batchSize) {
+				if err := p.emit(ctx, batch); err != nil {
 					return err
 				}
+				batch = nil
+				clear(identities)
 			}
+			if batch == nil {
+				batch = &monitoringpb.CreateTimeSeriesRequest{
+					Name: req.GetName(),
+				}
+			}
+			batch.TimeSeries = append(batch.TimeSeries, series)
+			identities[identity] = struct{}{}
 		}
 	}
+	if batch == nil {
+		return nil
+	}
+	return p.emit(ctx, batch)
+}
+
+// Returns true if the metric should be emitted, because there is no active window
+// or its timestamp is inside the active window.
+func (p *Pipeline) isActive(metric generators.Metric) bool {
+	if p.activeWindow == nil || p.activeWindow.Contains(metric.Timestamp) {
+		return true
+	}
+	p.logger.V(2).Info("Skipping metric outside the active window", "timestamp", metric.Timestamp)
+	return false
+}
+
+// Emit the request and notify the observers of the outcome and latency.
+func (p *Pipeline) emit(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+	req, err := p.checkPointInterval(req)
+	if err != nil || req == nil {
+		return err
+	}
+	if p.rpcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.rpcTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err = p.emitter(ctx, req)
+	latency := time.Since(start)
+	for _, observer := range p.observers {
+		observer(req, err)
+	}
+	for _, observer := range p.latencyObservers {
+		observer(latency, err)
+	}
+	return err
+}
+
+// Returns a Processor that dispatches requests to a pool of workers, each of
+// which emits requests in the order received. The first emit error cancels the
+// remaining workers and is returned.
+func (p *Pipeline) concurrentProcessor() Processor {
+	return func(ctx context.Context, input <-chan generators.Metric) error {
+		p.logger.V(2).Info("Launching concurrent pipeline processor", "concurrency", p.concurrency)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		errs := make(chan error, p.concurrency)
+		workers := make([]chan *monitoringpb.CreateTimeSeriesRequest, p.concurrency)
+		var wg sync.WaitGroup
+		for i := range workers {
+			workers[i] = make(chan *monitoringpb.CreateTimeSeriesRequest)
+			wg.Add(1)
+			go func(requests <-chan *monitoringpb.CreateTimeSeriesRequest) {
+				defer wg.Done()
+				for req := range requests {
+					if err := p.emit(ctx, req); err != nil {
+						errs <- err
+						cancel()
+						return
+					}
+				}
+			}(workers[i])
+		}
+		err := p.dispatch(ctx, input, workers)
+		for _, worker := range workers {
+			close(worker)
+		}
+		wg.Wait()
+		close(errs)
+		if err != nil {
+			return err
+		}
+		return <-errs
+	}
+}
+
+// Build a request for each Metric read from the input channel and send it to the
+// worker selected by the identity of the time-series, until the input channel
+// is closed or the context is cancelled.
+func (p *Pipeline) dispatch(ctx context.Context, input <-chan generators.Metric, workers []chan *monitoringpb.CreateTimeSeriesRequest) error {
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.V(2).Info("Context has been cancelled; exiting")
+			return nil
+		case value, ok := <-input:
+			if !ok {
+				p.logger.V(2).Info("Input channel is closed; exiting")
+				return nil
+			}
+			if !p.isActive(value) {
+				continue
+			}
+			req, err := p.BuildRequest(value)
+			if err != nil {
+				return err
+			}
+			hash := fnv.New32a()
+			_, _ = hash.Write([]byte(seriesIdentity(req)))
+			select {
+			case <-ctx.Done():
+				return nil
+			case workers[hash.Sum32()%uint32(len(workers))] <- req: //nolint:gosec // The number of workers is always positive
+			}
+		}
+	}
+}
+
+// Returns a string that identifies the time-series in the request by metric type,
+// metric labels, and monitored resource.
+func seriesIdentity(req *monitoringpb.CreateTimeSeriesRequest) string {
+	var builder strings.Builder
+	for _, series := range req.GetTimeSeries() {
+		builder.WriteString(series.GetMetric().GetType())
+		writeLabels(&builder, series.GetMetric().GetLabels())
+		builder.WriteString(series.GetResource().GetType())
+		writeLabels(&builder, series.GetResource().GetLabels())
+	}
+	return builder.String()
+}
+
+// Write the labels to the builder in key order.
+func writeLabels(builder *strings.Builder, labels map[string]string) {
+	builder.WriteByte('{')
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		builder.WriteString(key + "=" + labels[key] + ",")
+	}
+	builder.WriteByte('}')
 }
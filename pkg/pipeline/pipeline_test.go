@@ -1,20 +1,38 @@
 package pipeline //nolint:testpackage // These tests need access to the private functions to emulate GCP environment
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
 	"github.com/google/uuid"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/memes/gce-metric/pkg/generators"
+	"google.golang.org/api/option"
 	metricpb "google.golang.org/genproto/googleapis/api/metric"
 	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -27,6 +45,8 @@ const (
 	testPodName       = "test-pod"
 	testContainerName = "test-container"
 	testHost          = "test-host"
+	testLocation      = "us-west1"
+	testUserAgent     = "gce-metric/test"
 )
 
 // Define an object to override GCP metadata client for testing.
@@ -57,6 +77,128 @@ func (t *testClient) InstanceAttributeValue(name string) (string, error) {
 	return t.attributes[name], nil
 }
 
+var (
+	errTestMetadata   = errors.New("test metadata failure")
+	errTestClose      = errors.New("test close failure")
+	errTestFirstBatch = errors.New("test first batch failure")
+	errTestLastBatch  = errors.New("test last batch failure")
+	errTestNewClient  = errors.New("test metric client failure")
+)
+
+// Define a metadata client that will return an error for the first failures
+// calls, before delegating to the embedded testClient.
+type flakyTestClient struct {
+	testClient
+	failures int
+}
+
+func (f *flakyTestClient) fail() bool {
+	if f.failures > 0 {
+		f.failures--
+		return true
+	}
+	return false
+}
+
+// Implements the metadataClient interface requirement for ProjectID.
+func (f *flakyTestClient) ProjectID() (string, error) {
+	if f.fail() {
+		return "", errTestMetadata
+	}
+	return f.testClient.ProjectID()
+}
+
+// Implements the metadataClient interface requirement for InstanceID.
+func (f *flakyTestClient) InstanceID() (string, error) {
+	if f.fail() {
+		return "", errTestMetadata
+	}
+	return f.testClient.InstanceID()
+}
+
+// Implements the metadataClient interface requirement for Zone.
+func (f *flakyTestClient) Zone() (string, error) {
+	if f.fail() {
+		return "", errTestMetadata
+	}
+	return f.testClient.Zone()
+}
+
+// Define a timeSeriesClient that records the requests it receives, which of the
+// create functions was called, and whether it has been closed.
+type testTimeSeriesClient struct {
+	mu          sync.Mutex
+	called      string
+	requests    []*monitoringpb.CreateTimeSeriesRequest
+	descriptors []*monitoringpb.CreateMetricDescriptorRequest
+	deleted     []string
+	closed      bool
+	closeErr    error
+}
+
+// Implements the timeSeriesClient interface requirement for CreateTimeSeries.
+func (c *testTimeSeriesClient) CreateTimeSeries(_ context.Context, req *monitoringpb.CreateTimeSeriesRequest, _ ...gax.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.called = "CreateTimeSeries"
+	c.requests = append(c.requests, req)
+	return nil
+}
+
+// Implements the timeSeriesClient interface requirement for CreateServiceTimeSeries.
+func (c *testTimeSeriesClient) CreateServiceTimeSeries(_ context.Context, req *monitoringpb.CreateTimeSeriesRequest, _ ...gax.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.called = "CreateServiceTimeSeries"
+	c.requests = append(c.requests, req)
+	return nil
+}
+
+// Implements the timeSeriesClient interface requirement for CreateMetricDescriptor.
+func (c *testTimeSeriesClient) CreateMetricDescriptor(_ context.Context, req *monitoringpb.CreateMetricDescriptorRequest, _ ...gax.CallOption) (*metricpb.MetricDescriptor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.descriptors = append(c.descriptors, req)
+	return req.MetricDescriptor, nil
+}
+
+// Implements the timeSeriesClient interface requirement for DeleteMetricDescriptor.
+func (c *testTimeSeriesClient) DeleteMetricDescriptor(_ context.Context, req *monitoringpb.DeleteMetricDescriptorRequest, _ ...gax.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, req.Name)
+	return nil
+}
+
+// Implements the timeSeriesClient interface requirement for GetMetricDescriptor;
+// the fake client has no descriptors.
+func (c *testTimeSeriesClient) GetMetricDescriptor(_ context.Context, req *monitoringpb.GetMetricDescriptorRequest, _ ...gax.CallOption) (*metricpb.MetricDescriptor, error) {
+	return nil, status.Error(codes.NotFound, req.Name)
+}
+
+// Implements the timeSeriesClient interface requirement for
+// GetMonitoredResourceDescriptor; the fake client has no descriptors.
+func (c *testTimeSeriesClient) GetMonitoredResourceDescriptor(_ context.Context, req *monitoringpb.GetMonitoredResourceDescriptorRequest, _ ...gax.CallOption) (*monitoredrespb.MonitoredResourceDescriptor, error) {
+	return nil, status.Error(codes.NotFound, req.Name)
+}
+
+// Implements the timeSeriesClient interface requirement for Close.
+func (c *testTimeSeriesClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.closeErr
+}
+
+// Implement an Option that allows replacing the Cloud Monitoring client used by
+// the default emitter and closer.
+func withTimeSeriesClient(client timeSeriesClient) Option {
+	return func(p *Pipeline) error {
+		p.client = client
+		return nil
+	}
+}
+
 // Implement an Option that allows changing the OnGCE function used by Pipeline
 // to determine if it is executing in a Google Cloud environment.
 func withOnGCE(onGCE bool) Option {
@@ -68,95 +210,2025 @@ func withOnGCE(onGCE bool) Option {
 
 // Implement an Option that allows changing the metadata client used by Pipeline
 // to query Google Cloud environment.
-func withMetadataClient(client *testClient) Option {
+func withMetadataClient(client metadataClient) Option {
 	return func(p *Pipeline) error {
 		p.metadataClient = client
 		return nil
 	}
 }
 
-// Helper function to create a new Pipeline object that will appear to be running
-// outside of GCP.
-func newNonGCPTestPipeline(t *testing.T, options ...Option) (*Pipeline, error) {
-	t.Helper()
-	client := &testClient{
-		projectID:  "",
-		instanceID: "",
-		zone:       "",
-		attributes: map[string]string{},
+// Implement an Option that allows changing the function used by Pipeline to
+// create Cloud Monitoring clients.
+func withNewMetricClient(fn func(context.Context, ...option.ClientOption) (timeSeriesClient, error)) Option {
+	return func(p *Pipeline) error {
+		p.newMetricClient = fn
+		return nil
+	}
+}
+
+// A function for withNewMetricClient that always fails, as if there are no Google
+// Cloud credentials.
+func failNewMetricClient(context.Context, ...option.ClientOption) (timeSeriesClient, error) {
+	return nil, errTestNewClient
+}
+
+// A fake HTTP write endpoint that records each request it receives, and the
+// values decoded from the body of each accepted request. Requests are rejected
+// with the status code set by setStatus, if any, or if the body cannot be
+// decoded.
+type fakeHTTPServer[T any] struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	values   []T
+}
+
+func newFakeHTTPServer[T any](t *testing.T, decode func(*http.Request, []byte) ([]T, error)) *fakeHTTPServer[T] {
+	t.Helper()
+	server := &fakeHTTPServer[T]{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.requests = append(server.requests, r)
+		if server.status != 0 {
+			http.Error(w, http.StatusText(server.status), server.status)
+			return
+		}
+		values, err := decode(r, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.values = append(server.values, values...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Rejects requests with the status code, or accepts them if code is 0.
+func (f *fakeHTTPServer[T]) setStatus(code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = code
+}
+
+func (f *fakeHTTPServer[T]) received() ([]*http.Request, []T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests), slices.Clone(f.values)
+}
+
+// Helper function to create a new Pipeline object that will appear to be running
+// outside of GCP.
+func newNonGCPTestPipeline(t *testing.T, options ...Option) (*Pipeline, error) {
+	t.Helper()
+	client := &testClient{
+		projectID:  "",
+		instanceID: "",
+		zone:       "",
+		attributes: map[string]string{},
+	}
+	return NewPipeline(context.Background(), append(options, withOnGCE(false), withMetadataClient(client))...)
+}
+
+func TestNonGCPDefault(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t)
+	switch {
+	case err != nil && !errors.Is(err, errNotGCP):
+		t.Errorf("Expected NewPipeline to raise %v, got %v", errNotGCP, err)
+	case err == nil:
+		t.Errorf("Expected NewPipeline to raise %v, but it didn't", errNotGCP)
+	}
+}
+
+func TestNonGCPExplicitProjectID(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	metric := generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	expectedWithoutNodeID := &monitoringpb.CreateTimeSeriesRequest{
+		Name: "projects/" + testProjectID,
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Metric: &metricpb.Metric{
+					Type: DefaultMetricType,
+				},
+				MetricKind: metricpb.MetricDescriptor_GAUGE,
+				Resource: &monitoredrespb.MonitoredResource{
+					Type: "generic_node",
+					Labels: map[string]string{
+						"project_id": testProjectID,
+						"location":   DefaultLocation,
+						"namespace":  DefaultNamespace,
+					},
+				},
+				Points: []*monitoringpb.Point{
+					{
+						Interval: &monitoringpb.TimeInterval{
+							EndTime: timestamppb.New(metric.Timestamp),
+						},
+						Value: &monitoringpb.TypedValue{
+							Value: &monitoringpb.TypedValue_DoubleValue{
+								DoubleValue: metric.Value,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	req, err := pipeline.BuildRequest(metric)
+	if err != nil {
+		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	}
+	_, err = uuid.Parse(req.TimeSeries[0].Resource.Labels["node_id"])
+	if err != nil {
+		t.Errorf("Error parsing UUID from resource label 'node_id': %v", err)
+	}
+	delete(req.TimeSeries[0].Resource.Labels, "node_id")
+	if !reflect.DeepEqual(req, expectedWithoutNodeID) {
+		t.Errorf("Expected %+v, got %+v", expectedWithoutNodeID, req)
+	}
+}
+
+func TestNonGCPWithLocation(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithLocation(testLocation))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	}
+	if req.TimeSeries[0].Resource.Type != "generic_node" {
+		t.Errorf("Expected resource type %q, got %q", "generic_node", req.TimeSeries[0].Resource.Type)
+	}
+	if location := req.TimeSeries[0].Resource.Labels["location"]; location != testLocation {
+		t.Errorf("Expected location label %q, got %q", testLocation, location)
+	}
+}
+
+func TestWithMetricLabels(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithMetricLabels(map[string]string{"env": "test", "host": "ignored"}),
+		WithTransformers([]Transformer{NewMetricLabelTransformer("host", testHost)}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+	}
+	expected := map[string]string{"env": "test", "host": testHost}
+	if labels := req.TimeSeries[0].Metric.Labels; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected metric labels %v, got %v", expected, labels)
+	}
+}
+
+func TestNonGCPWithNamespace(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		options  []Option
+		expected string
+	}{
+		{
+			name:     "default",
+			options:  []Option{WithProjectID(testProjectID)},
+			expected: DefaultNamespace,
+		},
+		{
+			name:     "override",
+			options:  []Option{WithProjectID(testProjectID), WithNamespace(testNamespace)},
+			expected: testNamespace,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newNonGCPTestPipeline(t, tst.options...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			if namespace := req.TimeSeries[0].Resource.Labels["namespace"]; namespace != tst.expected {
+				t.Errorf("Expected namespace label %q, got %q", tst.expected, namespace)
+			}
+		})
+	}
+}
+
+func TestEmitObservers(t *testing.T) {
+	t.Parallel()
+	observed := make(chan error, 2)
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithWriterEmitter(io.Discard),
+		WithEmitObservers([]EmitObserver{
+			func(_ *monitoringpb.CreateTimeSeriesRequest, err error) {
+				observed <- err
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 2)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	input <- generators.Metric{
+		Value:     2.2,
+		Timestamp: time.Now(),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	close(observed)
+	count := 0
+	for err := range observed {
+		count++
+		if err != nil {
+			t.Errorf("Expected observer to receive nil error, got %v", err)
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected observer to be called 2 times, got %d", count)
+	}
+}
+
+// Values outside the active window should be skipped by the processor, for
+// either processor implementation.
+func TestWithActiveWindow(t *testing.T) {
+	t.Parallel()
+	window, err := NewActiveWindow("22:00-02:00", time.UTC)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewActiveWindow: %v", err)
+	}
+	for _, concurrency := range []int{1, 2} {
+		t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+			t.Parallel()
+			emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, 6)
+			pipeline, err := newNonGCPTestPipeline(t,
+				WithProjectID(testProjectID),
+				WithConcurrency(concurrency),
+				WithActiveWindow(window),
+				withSlowEmitter(0, emitted),
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			start := time.Date(2024, time.January, 1, 21, 0, 0, 0, time.UTC)
+			input := make(chan generators.Metric, 6)
+			for hour := range 6 {
+				input <- generators.Metric{
+					Value:     float64(hour),
+					Timestamp: start.Add(time.Duration(hour) * time.Hour),
+				}
+			}
+			close(input)
+			if err := pipeline.Processor()(context.Background(), input); err != nil {
+				t.Fatalf("Unexpected error from Processor: %v", err)
+			}
+			close(emitted)
+			timestamps := []time.Time{}
+			for req := range emitted {
+				timestamps = append(timestamps, req.GetTimeSeries()[0].GetPoints()[0].GetInterval().GetEndTime().AsTime())
+			}
+			expected := []time.Time{start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour), start.Add(4 * time.Hour)}
+			if !slices.EqualFunc(timestamps, expected, time.Time.Equal) {
+				t.Errorf("Expected points at %v, got %v", expected, timestamps)
+			}
+		})
+	}
+}
+
+// Emit should send exactly one request for the metric, unless it is outside the
+// active window.
+func TestEmit(t *testing.T) {
+	t.Parallel()
+	window, err := NewActiveWindow("09:00-17:00", time.UTC)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewActiveWindow: %v", err)
+	}
+	emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, 2)
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithActiveWindow(window),
+		withSlowEmitter(0, emitted),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	ctx := context.Background()
+	inside := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if err := pipeline.Emit(ctx, generators.Metric{Value: 1.0, Timestamp: inside}); err != nil {
+		t.Fatalf("Unexpected error from Emit: %v", err)
+	}
+	if err := pipeline.Emit(ctx, generators.Metric{Value: 2.0, Timestamp: inside.Add(12 * time.Hour)}); err != nil {
+		t.Fatalf("Unexpected error from Emit: %v", err)
+	}
+	close(emitted)
+	requests := []*monitoringpb.CreateTimeSeriesRequest{}
+	for req := range emitted {
+		requests = append(requests, req)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	if value := requests[0].GetTimeSeries()[0].GetPoints()[0].GetValue().GetDoubleValue(); value != 1.0 {
+		t.Errorf("Expected emitted value 1.0, got %v", value)
+	}
+}
+
+// EmitBatch must combine points for different time-series into requests of no
+// more than the batch size, and never put two points for the same time-series in
+// one request.
+func TestEmitBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		expected []int
+	}{
+		{
+			name:     "single-series",
+			expected: []int{1, 1, 1},
+		},
+		{
+			name:     "unique-series",
+			options:  []Option{WithTransformers([]Transformer{NewSequenceLabelTransformer("seq")})},
+			expected: []int{3},
+		},
+		{
+			name:     "batch-size",
+			options:  []Option{WithTransformers([]Transformer{NewSequenceLabelTransformer("seq")}), WithBatchSize(2)},
+			expected: []int{2, 1},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, 3)
+			pipeline, err := newNonGCPTestPipeline(t, append([]Option{WithProjectID(testProjectID), withSlowEmitter(0, emitted)}, tst.options...)...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			start := time.Unix(1700000000, 0)
+			metrics := []generators.Metric{}
+			for i := range 3 {
+				metrics = append(metrics, generators.Metric{
+					Value:     float64(i),
+					Timestamp: start.Add(time.Duration(i) * time.Minute),
+				})
+			}
+			if err := pipeline.EmitBatch(context.Background(), metrics); err != nil {
+				t.Fatalf("Unexpected error from EmitBatch: %v", err)
+			}
+			close(emitted)
+			counts := []int{}
+			values := []float64{}
+			for req := range emitted {
+				counts = append(counts, len(req.GetTimeSeries()))
+				for _, series := range req.GetTimeSeries() {
+					values = append(values, series.GetPoints()[0].GetValue().GetDoubleValue())
+				}
+			}
+			if !slices.Equal(counts, tst.expected) {
+				t.Errorf("Expected requests with %v time-series, got %v", tst.expected, counts)
+			}
+			if !slices.Equal(values, []float64{0, 1, 2}) {
+				t.Errorf("Expected values to be emitted in order, got %v", values)
+			}
+		})
+	}
+}
+
+// A point written to the same time-series within the minimum series interval of
+// the last point should be dropped.
+func TestWithMinSeriesInterval(t *testing.T) {
+	t.Parallel()
+	emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, 3)
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithMinSeriesInterval(10*time.Second),
+		withSlowEmitter(0, emitted),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	ctx := context.Background()
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 2 * time.Second, 10 * time.Second} {
+		if err := pipeline.Emit(ctx, generators.Metric{Value: 1.0, Timestamp: start.Add(offset)}); err != nil {
+			t.Fatalf("Unexpected error from Emit: %v", err)
+		}
+	}
+	close(emitted)
+	timestamps := []time.Time{}
+	for req := range emitted {
+		timestamps = append(timestamps, req.GetTimeSeries()[0].GetPoints()[0].GetInterval().GetEndTime().AsTime())
+	}
+	expected := []time.Time{start, start.Add(10 * time.Second)}
+	if !slices.EqualFunc(timestamps, expected, time.Time.Equal) {
+		t.Errorf("Expected points at %v, got %v", expected, timestamps)
+	}
+}
+
+func TestWithMinSeriesIntervalInvalid(t *testing.T) {
+	t.Parallel()
+	if _, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithMinSeriesInterval(-time.Second)); !errors.Is(err, ErrInvalidMinSeriesInterval) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidMinSeriesInterval, err)
+	}
+}
+
+func TestLatencyObservers(t *testing.T) {
+	t.Parallel()
+	delay := 10 * time.Millisecond
+	emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, 1)
+	var latencies []time.Duration
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		withSlowEmitter(delay, emitted),
+		WithLatencyObservers([]LatencyObserver{
+			func(latency time.Duration, err error) {
+				if err != nil {
+					t.Errorf("Expected observer to receive nil error, got %v", err)
+				}
+				latencies = append(latencies, latency)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 1)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	if len(latencies) != 1 {
+		t.Fatalf("Expected observer to be called 1 time, got %d", len(latencies))
+	}
+	if latencies[0] < delay {
+		t.Errorf("Expected latency of at least %v, got %v", delay, latencies[0])
+	}
+}
+
+// Implement an Option that replaces the emitter with one that sleeps for delay
+// before recording the request.
+func withSlowEmitter(delay time.Duration, emitted chan<- *monitoringpb.CreateTimeSeriesRequest) Option {
+	return func(p *Pipeline) error {
+		p.emitter = func(_ context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+			time.Sleep(delay)
+			emitted <- req
+			p.recordLastPoints(req)
+			return nil
+		}
+		p.closer = func() error { return nil }
+		return nil
+	}
+}
+
+func TestWriterEmitterLogsValues(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	var output bytes.Buffer
+	logger := stdr.NewWithOptions(log.New(&logs, "", 0), stdr.Options{LogCaller: stdr.None, Depth: 0})
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithLogger(logger),
+		WithProjectID(testProjectID),
+		WithWriterEmitter(&output),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 1)
+	input <- generators.Metric{
+		Value:     2.5,
+		Timestamp: time.Unix(1700000000, 0),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	if output.Len() == 0 {
+		t.Error("Expected the time-series request to be written")
+	}
+	expected := `"msg"="Wrote time-series point" "value"=2.5 "timestamp"="2023-11-14 22:13:20 +0000 UTC"`
+	if !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected log to contain %q, got %q", expected, logs.String())
+	}
+}
+
+func TestWriterEmitterLogRounding(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	var output bytes.Buffer
+	logger := stdr.NewWithOptions(log.New(&logs, "", 0), stdr.Options{LogCaller: stdr.None, Depth: 0})
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithLogger(logger),
+		WithProjectID(testProjectID),
+		WithLogRounding(),
+		WithFormattedWriterEmitter(&output, WriterFormatProtoJSON),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 1)
+	input <- generators.Metric{
+		Value:     2.6,
+		Timestamp: time.Unix(1700000000, 0),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	var req monitoringpb.CreateTimeSeriesRequest
+	if err := protojson.Unmarshal(output.Bytes(), &req); err != nil {
+		t.Fatalf("Failed to parse written request %q: %v", output.String(), err)
+	}
+	if value, ok := req.GetTimeSeries()[0].GetPoints()[0].GetValue().GetValue().(*monitoringpb.TypedValue_DoubleValue); !ok || value.DoubleValue != 2.6 {
+		t.Errorf("Expected the written value to be the double 2.6, got %v", req.GetTimeSeries()[0].GetPoints()[0].GetValue())
+	}
+	expected := `"msg"="Wrote time-series point" "value"=3 "timestamp"="2023-11-14 22:13:20 +0000 UTC"`
+	if !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected log to contain %q, got %q", expected, logs.String())
+	}
+}
+
+func TestFormattedWriterEmitter(t *testing.T) {
+	const metricType = "custom.googleapis.com/writer"
+	timestamp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		format WriterFormat
+		verify func(t *testing.T, output string)
+	}{
+		{
+			name:   "text",
+			format: WriterFormatText,
+			verify: func(t *testing.T, output string) {
+				t.Helper()
+				var req monitoringpb.CreateTimeSeriesRequest
+				if err := prototext.Unmarshal([]byte(output), &req); err != nil {
+					t.Fatalf("Expected output to be protobuf text, got error %v: %q", err, output)
+				}
+				if value := req.TimeSeries[0].Points[0].Value.GetDoubleValue(); value != 2.5 {
+					t.Errorf("Expected value to be 2.5, got %v", value)
+				}
+			},
+		},
+		{
+			name:   "protojson",
+			format: WriterFormatProtoJSON,
+			verify: func(t *testing.T, output string) {
+				t.Helper()
+				if lines := strings.Count(output, "\n"); lines < 2 {
+					t.Errorf("Expected indented multi-line output, got %d lines: %q", lines, output)
+				}
+				var req monitoringpb.CreateTimeSeriesRequest
+				if err := protojson.Unmarshal([]byte(output), &req); err != nil {
+					t.Fatalf("Expected output to be protobuf JSON, got error %v: %q", err, output)
+				}
+				if value := req.TimeSeries[0].Points[0].Value.GetDoubleValue(); value != 2.5 {
+					t.Errorf("Expected value to be 2.5, got %v", value)
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: WriterFormatJSON,
+			verify: func(t *testing.T, output string) {
+				t.Helper()
+				if lines := strings.Count(output, "\n"); lines != 1 {
+					t.Errorf("Expected a single line of output, got %d lines: %q", lines, output)
+				}
+				var req monitoringpb.CreateTimeSeriesRequest
+				if err := protojson.Unmarshal([]byte(output), &req); err != nil {
+					t.Fatalf("Expected output to be protobuf JSON, got error %v: %q", err, output)
+				}
+				if value := req.TimeSeries[0].Points[0].Value.GetDoubleValue(); value != 2.5 {
+					t.Errorf("Expected value to be 2.5, got %v", value)
+				}
+			},
+		},
+		{
+			name:   "summary",
+			format: WriterFormatSummary,
+			verify: func(t *testing.T, output string) {
+				t.Helper()
+				expected := timestamp.UTC().Format(time.RFC3339) + " " + metricType + " generic_node 2.5\n"
+				if output != expected {
+					t.Errorf("Expected %q, got %q", expected, output)
+				}
+			},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var output bytes.Buffer
+			pipeline, err := newNonGCPTestPipeline(t,
+				WithProjectID(testProjectID),
+				WithMetricType(metricType),
+				WithFormattedWriterEmitter(&output, tst.format),
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			input := make(chan generators.Metric, 1)
+			input <- generators.Metric{
+				Value:     2.5,
+				Timestamp: timestamp,
+			}
+			close(input)
+			if err := pipeline.Processor()(context.Background(), input); err != nil {
+				t.Fatalf("Unexpected error from Processor: %v", err)
+			}
+			tst.verify(t, output.String())
+		})
+	}
+}
+
+func TestFormattedWriterEmitterUnsupported(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithFormattedWriterEmitter(io.Discard, "yaml"))
+	if !errors.Is(err, ErrUnsupportedWriterFormat) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrUnsupportedWriterFormat, err)
+	}
+}
+
+func TestWithRPCTimeout(t *testing.T) {
+	t.Parallel()
+	const timeout = 100 * time.Millisecond
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithRPCTimeout(timeout),
+		// The emitter will not complete before the timeout, but will respect
+		// cancellation of the context.
+		func(p *Pipeline) error {
+			p.emitter = func(ctx context.Context, _ *monitoringpb.CreateTimeSeriesRequest) error {
+				select {
+				case <-time.After(10 * timeout):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			p.closer = func() error { return nil }
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 1)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	close(input)
+	start := time.Now()
+	err = pipeline.Processor()(context.Background(), input)
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Processor to raise %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed >= 5*timeout {
+		t.Errorf("Expected emit to be cancelled after %v, took %v", timeout, elapsed)
+	}
+}
+
+func TestWithRPCTimeoutInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithRPCTimeout(-1*time.Second))
+	if !errors.Is(err, ErrInvalidRPCTimeout) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidRPCTimeout, err)
+	}
+}
+
+func TestWithServiceTimeSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		expected string
+	}{
+		{
+			name:     "default",
+			options:  []Option{},
+			expected: "CreateTimeSeries",
+		},
+		{
+			name:     "disabled",
+			options:  []Option{WithServiceTimeSeries(false)},
+			expected: "CreateTimeSeries",
+		},
+		{
+			name:     "enabled",
+			options:  []Option{WithServiceTimeSeries(true)},
+			expected: "CreateServiceTimeSeries",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newNonGCPTestPipeline(t, append(tst.options, WithProjectID(testProjectID))...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+			}
+			client := &testTimeSeriesClient{}
+			if err := pipeline.writeTimeSeries(context.Background(), client, req); err != nil {
+				t.Errorf("Unexpected error returned from writeTimeSeries: %v", err)
+			}
+			if client.called != tst.expected {
+				t.Errorf("Expected %s to be called, got %q", tst.expected, client.called)
+			}
+		})
+	}
+}
+
+func TestDefaultEmitter(t *testing.T) {
+	t.Parallel()
+	client := &testTimeSeriesClient{}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withTimeSeriesClient(client))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	input := make(chan generators.Metric, 2)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	input <- generators.Metric{
+		Value:     2.2,
+		Timestamp: time.Now().Add(time.Minute),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error returned from Processor: %v", err)
+	}
+	if len(client.requests) != 2 {
+		t.Fatalf("Expected 2 requests to be forwarded to the client, got %d", len(client.requests))
+	}
+	for i, expected := range []float64{1.1, 2.2} {
+		req := client.requests[i]
+		if req.Name != "projects/"+testProjectID {
+			t.Errorf("Expected request %d name to be %q, got %q", i, "projects/"+testProjectID, req.Name)
+		}
+		if value := req.TimeSeries[0].Points[0].Value.GetDoubleValue(); value != expected {
+			t.Errorf("Expected request %d value to be %v, got %v", i, expected, value)
+		}
+	}
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	if !client.closed {
+		t.Error("Expected Close to close the client")
+	}
+	// The pipeline must not use the client after it has been closed.
+	if err := pipeline.emitter(context.Background(), client.requests[0]); !errors.Is(err, ErrPipelineClosed) {
+		t.Errorf("Expected emitter to raise %v, got %v", ErrPipelineClosed, err)
+	}
+	if len(client.requests) != 2 {
+		t.Errorf("Expected no more requests after Close, got %d", len(client.requests))
+	}
+}
+
+// The default emitter should reject points that are not later than, or are too
+// soon after, the last point written to the same time-series, without sending
+// them to Cloud Monitoring; with a minimum series interval they are dropped, and
+// MinimumPointInterval applies if it is longer.
+func TestDefaultEmitterPointOrder(t *testing.T) {
+	t.Parallel()
+	start := time.Now().Truncate(time.Second)
+	tests := []struct {
+		name          string
+		first         time.Time
+		second        time.Time
+		secondLabels  map[string]string
+		options       []Option
+		expectDropped bool
+		expectedError error
+	}{
+		{
+			name:   "in-order",
+			first:  start,
+			second: start.Add(MinimumPointInterval),
+		},
+		{
+			name:          "out-of-order",
+			first:         start,
+			second:        start.Add(-time.Minute),
+			expectedError: ErrPointOutOfOrder,
+		},
+		{
+			name:          "same-time",
+			first:         start,
+			second:        start,
+			expectedError: ErrPointOutOfOrder,
+		},
+		{
+			name:          "too-frequent",
+			first:         start,
+			second:        start.Add(time.Second),
+			expectedError: ErrPointTooFrequent,
+		},
+		{
+			name:          "too-frequent-dropped",
+			first:         start,
+			second:        start.Add(3 * time.Second),
+			options:       []Option{WithMinSeriesInterval(time.Second)},
+			expectDropped: true,
+		},
+		{
+			name:          "out-of-order-dropped",
+			first:         start,
+			second:        start.Add(-time.Minute),
+			options:       []Option{WithMinSeriesInterval(time.Second)},
+			expectDropped: true,
+		},
+		{
+			name:         "different-series",
+			first:        start,
+			second:       start.Add(-time.Minute),
+			secondLabels: map[string]string{"color": "blue"},
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := &testTimeSeriesClient{}
+			pipeline, err := newNonGCPTestPipeline(t, append(tst.options, WithProjectID(testProjectID), WithSeed(1), withTimeSeriesClient(client))...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			first, err := pipeline.BuildRequest(generators.Metric{Value: 1.1, Timestamp: tst.first})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			if err := pipeline.emit(context.Background(), first); err != nil {
+				t.Fatalf("Unexpected error from emit: %v", err)
+			}
+			second, err := pipeline.BuildRequest(generators.Metric{Value: 2.2, Timestamp: tst.second})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			if tst.secondLabels != nil {
+				second.TimeSeries[0].Metric.Labels = tst.secondLabels
+			}
+			err = pipeline.emit(context.Background(), second)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Unexpected error from emit: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected emit to raise %v, got %v", tst.expectedError, err)
+			}
+			expectedRequests := 2
+			if tst.expectedError != nil || tst.expectDropped {
+				expectedRequests = 1
+			}
+			if len(client.requests) != expectedRequests {
+				t.Errorf("Expected %d requests to be forwarded to the client, got %d", expectedRequests, len(client.requests))
+			}
+		})
+	}
+}
+
+func TestWithDisplayNameAndDescription(t *testing.T) {
+	t.Parallel()
+	const displayName = "Synthetic metric"
+	const description = "A synthetic metric for testing"
+	client := &testTimeSeriesClient{}
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithDisplayName(displayName),
+		WithDescription(description),
+		WithTransformers([]Transformer{NewMetricLabelTransformer("host", testHost)}),
+		withTimeSeriesClient(client),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 2)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	input <- generators.Metric{
+		Value:     2.2,
+		Timestamp: time.Now().Add(time.Minute),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error returned from Processor: %v", err)
+	}
+	if len(client.descriptors) != 1 {
+		t.Fatalf("Expected the descriptor to be created once, got %d", len(client.descriptors))
+	}
+	req := client.descriptors[0]
+	if req.Name != "projects/"+testProjectID {
+		t.Errorf("Expected descriptor request name %q, got %q", "projects/"+testProjectID, req.Name)
+	}
+	descriptor := req.MetricDescriptor
+	if descriptor.DisplayName != displayName {
+		t.Errorf("Expected display name %q, got %q", displayName, descriptor.DisplayName)
+	}
+	if descriptor.Description != description {
+		t.Errorf("Expected description %q, got %q", description, descriptor.Description)
+	}
+	if descriptor.Type != DefaultMetricType {
+		t.Errorf("Expected type %q, got %q", DefaultMetricType, descriptor.Type)
+	}
+	if descriptor.MetricKind != metricpb.MetricDescriptor_GAUGE {
+		t.Errorf("Expected metric kind %v, got %v", metricpb.MetricDescriptor_GAUGE, descriptor.MetricKind)
+	}
+	if descriptor.ValueType != metricpb.MetricDescriptor_DOUBLE {
+		t.Errorf("Expected value type %v, got %v", metricpb.MetricDescriptor_DOUBLE, descriptor.ValueType)
+	}
+	if len(descriptor.Labels) != 1 || descriptor.Labels[0].Key != "host" {
+		t.Errorf("Expected a single host label, got %v", descriptor.Labels)
+	}
+	if len(client.requests) != 2 {
+		t.Errorf("Expected 2 time-series requests, got %d", len(client.requests))
+	}
+}
+
+// Verify that a sine waveform between -5 and +5 is written with correctly
+// signed double and int64 points, and a descriptor with the matching value type.
+func TestNegativeSineWaveform(t *testing.T) {
+	tests := []struct {
+		name              string
+		transformers      []Transformer
+		expectedValueType metricpb.MetricDescriptor_ValueType
+	}{
+		{
+			name:              "double",
+			transformers:      []Transformer{},
+			expectedValueType: metricpb.MetricDescriptor_DOUBLE,
+		},
+		{
+			name:              "int64",
+			transformers:      []Transformer{NewIntegerTypedValueTransformer()},
+			expectedValueType: metricpb.MetricDescriptor_INT64,
+		},
+	}
+	calculator := generators.NewPeriodicRangeCalculator(-5.0, 5.0, generators.Sine)
+	start := time.Unix(1700000000, 0)
+	values := make([]float64, 0, 11)
+	for i := range 11 {
+		values = append(values, calculator(float64(i)/10.0))
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := &testTimeSeriesClient{}
+			pipeline, err := newNonGCPTestPipeline(t,
+				WithProjectID(testProjectID),
+				WithDescription("A negative-going sine"),
+				WithTransformers(tst.transformers),
+				withTimeSeriesClient(client),
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			input := make(chan generators.Metric, len(values))
+			for i, value := range values {
+				input <- generators.Metric{
+					Value:     value,
+					Timestamp: start.Add(time.Duration(i) * time.Minute),
+				}
+			}
+			close(input)
+			if err := pipeline.Processor()(context.Background(), input); err != nil {
+				t.Fatalf("Unexpected error returned from Processor: %v", err)
+			}
+			if len(client.descriptors) != 1 {
+				t.Fatalf("Expected the descriptor to be created once, got %d", len(client.descriptors))
+			}
+			if valueType := client.descriptors[0].GetMetricDescriptor().GetValueType(); valueType != tst.expectedValueType {
+				t.Errorf("Expected descriptor value type %v, got %v", tst.expectedValueType, valueType)
+			}
+			if len(client.requests) != len(values) {
+				t.Fatalf("Expected %d requests, got %d", len(values), len(client.requests))
+			}
+			for i, req := range client.requests {
+				expected := values[i]
+				var got float64
+				switch value := req.GetTimeSeries()[0].GetPoints()[0].GetValue().GetValue().(type) {
+				case *monitoringpb.TypedValue_DoubleValue:
+					got = value.DoubleValue
+				case *monitoringpb.TypedValue_Int64Value:
+					expected = math.Round(expected)
+					got = float64(value.Int64Value)
+				}
+				if math.Abs(got-expected) > 1e-9 || math.Signbit(got) != math.Signbit(expected) {
+					t.Errorf("Expected point %d to be %v, got %v", i, expected, req.GetTimeSeries()[0].GetPoints()[0].GetValue())
+				}
+			}
+		})
+	}
+}
+
+func TestWithLabelKeyValidation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		options     []Option
+		expectedErr error
+	}{
+		{
+			name:    "valid",
+			options: []Option{WithMetricLabels(map[string]string{"host": "a"}), WithLabelKeyValidation()},
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithMetricLabels(map[string]string{"Host": "a"})},
+		},
+		{
+			name:        "metric-label",
+			options:     []Option{WithLabelKeyValidation(), WithMetricLabels(map[string]string{"Host": "a"})},
+			expectedErr: ErrInvalidLabelKey,
+		},
+		{
+			// Resource labels added by a later option are still validated.
+			name:        "resource-label",
+			options:     []Option{WithLabelKeyValidation(), WithResourceLabels(map[string]string{"1zone": "a"})},
+			expectedErr: ErrInvalidLabelKey,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newNonGCPTestPipeline(t, append([]Option{WithProjectID(testProjectID)}, tst.options...)...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			_, err = pipeline.BuildRequest(generators.Metric{
+				Value:     1.0,
+				Timestamp: time.Now(),
+			})
+			switch {
+			case tst.expectedErr != nil && !errors.Is(err, tst.expectedErr):
+				t.Errorf("Expected BuildRequest to raise %v, got %v", tst.expectedErr, err)
+			case tst.expectedErr == nil && err != nil:
+				t.Errorf("BuildRequest raised an unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithResourceTransformers(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithResourceTransformers(
+		NewGCEMonitoredResourceTransformer(testProjectID, "test-instance", "test-zone"),
+		NewGenericMonitoredResourceTransformer(testProjectID, "test-location", "test-namespace", "test-node"),
+	))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.5,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+	}
+	series := req.GetTimeSeries()
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 time-series, got %d", len(series))
+	}
+	if series[0].GetResource().GetType() != "gce_instance" || series[1].GetResource().GetType() != "generic_node" {
+		t.Errorf("Expected gce_instance and generic_node resources, got %v and %v", series[0].GetResource(), series[1].GetResource())
+	}
+	if series[0].GetMetric().GetType() != series[1].GetMetric().GetType() || series[0].GetPoints()[0].GetValue().GetDoubleValue() != series[1].GetPoints()[0].GetValue().GetDoubleValue() {
+		t.Errorf("Expected identical metrics and values, got %v and %v", series[0], series[1])
+	}
+}
+
+// The descriptor must only be deleted and recreated when WithRecreateDescriptor
+// is enabled, and then only once before the first point, even when the request
+// has a time-series for each of several resources.
+func TestWithRecreateDescriptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		options  []Option
+		expected []string
+	}{
+		{
+			name:     "disabled",
+			enabled:  false,
+			expected: nil,
+		},
+		{
+			name:     "enabled",
+			enabled:  true,
+			expected: []string{"projects/" + testProjectID + "/metricDescriptors/" + DefaultMetricType},
+		},
+		{
+			name:    "multiple-resources",
+			enabled: true,
+			options: []Option{WithResourceTransformers(
+				NewGCEMonitoredResourceTransformer(testProjectID, testInstanceID, testZone),
+				NewGenericMonitoredResourceTransformer(testProjectID, testLocation, testNamespace, testHost),
+			)},
+			expected: []string{"projects/" + testProjectID + "/metricDescriptors/" + DefaultMetricType},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := &testTimeSeriesClient{}
+			pipeline, err := newNonGCPTestPipeline(t, append([]Option{WithProjectID(testProjectID), WithRecreateDescriptor(tst.enabled), withTimeSeriesClient(client)}, tst.options...)...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			input := make(chan generators.Metric, 2)
+			input <- generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			}
+			input <- generators.Metric{
+				Value:     2.2,
+				Timestamp: time.Now().Add(time.Minute),
+			}
+			close(input)
+			if err := pipeline.Processor()(context.Background(), input); err != nil {
+				t.Fatalf("Unexpected error returned from Processor: %v", err)
+			}
+			if !reflect.DeepEqual(client.deleted, tst.expected) {
+				t.Errorf("Expected deleted descriptors %v, got %v", tst.expected, client.deleted)
+			}
+			if len(client.descriptors) != len(tst.expected) {
+				t.Errorf("Expected %d descriptors to be created, got %d", len(tst.expected), len(client.descriptors))
+			}
+			if len(client.requests) != 2 {
+				t.Errorf("Expected 2 requests to be forwarded to the client, got %d", len(client.requests))
+			}
+		})
+	}
+}
+
+func TestWithoutDisplayNameOrDescription(t *testing.T) {
+	t.Parallel()
+	client := &testTimeSeriesClient{}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withTimeSeriesClient(client))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, 1)
+	input <- generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error returned from Processor: %v", err)
+	}
+	if len(client.descriptors) != 0 {
+		t.Errorf("Expected the descriptor to be left to Cloud Monitoring, got %d create requests", len(client.descriptors))
+	}
+}
+
+func TestDefaultCloserError(t *testing.T) {
+	t.Parallel()
+	client := &testTimeSeriesClient{
+		closeErr: errTestClose,
+	}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withTimeSeriesClient(client))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	if err := pipeline.Close(); !errors.Is(err, errTestClose) {
+		t.Errorf("Expected Close to raise %v, got %v", errTestClose, err)
+	}
+}
+
+// A timeSeriesClient with a Close function that blocks until release is closed.
+type blockingCloseClient struct {
+	testTimeSeriesClient
+	release chan struct{}
+}
+
+func (c *blockingCloseClient) Close() error {
+	<-c.release
+	return c.testTimeSeriesClient.Close()
+}
+
+func TestWithCloseTimeout(t *testing.T) {
+	t.Parallel()
+	client := &blockingCloseClient{
+		release: make(chan struct{}),
+	}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withTimeSeriesClient(client), WithCloseTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- pipeline.Close()
+	}()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrCloseTimeout) {
+			t.Errorf("Expected Close to raise %v, got %v", ErrCloseTimeout, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to return after the close timeout")
+	}
+	// The abandoned close completes once the client is released.
+	close(client.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client.mu.Lock()
+		closed := client.closed
+		client.mu.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the client to be closed after it was released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithCloseTimeoutDisabled(t *testing.T) {
+	t.Parallel()
+	client := &blockingCloseClient{
+		release: make(chan struct{}),
+	}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withTimeSeriesClient(client), WithCloseTimeout(0))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	time.AfterFunc(100*time.Millisecond, func() { close(client.release) })
+	start := time.Now()
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected Close to wait for the client without a timeout, returned after %v", elapsed)
+	}
+}
+
+func TestWithCloseTimeoutInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithCloseTimeout(-time.Second))
+	if !errors.Is(err, ErrInvalidCloseTimeout) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidCloseTimeout, err)
+	}
+}
+
+func TestConcurrentProcessor(t *testing.T) {
+	t.Parallel()
+	const concurrency = 4
+	const delay = 200 * time.Millisecond
+	emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, concurrency)
+	// Each metric will be written to a distinct time-series, so the requests can
+	// be emitted concurrently.
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithConcurrency(concurrency),
+		WithTransformers([]Transformer{NewSequenceLabelTransformer("seq")}),
+		withSlowEmitter(delay, emitted),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, concurrency)
+	for i := range concurrency {
+		input <- generators.Metric{
+			Value:     float64(i),
+			Timestamp: time.Now(),
+		}
+	}
+	close(input)
+	start := time.Now()
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	elapsed := time.Since(start)
+	close(emitted)
+	if len(emitted) != concurrency {
+		t.Errorf("Expected %d requests to be emitted, got %d", concurrency, len(emitted))
+	}
+	if elapsed >= 2*delay {
+		t.Errorf("Expected %d concurrent emits to complete in less than %v, took %v", concurrency, 2*delay, elapsed)
+	}
+}
+
+func TestConcurrentProcessorPreservesSeriesOrder(t *testing.T) {
+	t.Parallel()
+	const count = 10
+	emitted := make(chan *monitoringpb.CreateTimeSeriesRequest, count)
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithConcurrency(4),
+		withSlowEmitter(time.Millisecond, emitted),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	input := make(chan generators.Metric, count)
+	for i := range count {
+		input <- generators.Metric{
+			Value:     float64(i),
+			Timestamp: time.Now(),
+		}
+	}
+	close(input)
+	if err := pipeline.Processor()(context.Background(), input); err != nil {
+		t.Fatalf("Unexpected error from Processor: %v", err)
+	}
+	close(emitted)
+	expected := 0.0
+	for req := range emitted {
+		if value := req.TimeSeries[0].Points[0].Value.GetDoubleValue(); value != expected {
+			t.Errorf("Expected value %v, got %v", expected, value)
+		}
+		expected++
+	}
+}
+
+func TestWithConcurrencyInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithConcurrency(0))
+	if !errors.Is(err, ErrInvalidConcurrency) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidConcurrency, err)
+	}
+}
+
+// A timeSeriesClient that fails the CreateTimeSeries calls with the supplied
+// indices, after recording the request.
+type failingBatchClient struct {
+	testTimeSeriesClient
+	failures map[int]error
+}
+
+func (c *failingBatchClient) CreateTimeSeries(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest, opts ...gax.CallOption) error {
+	c.mu.Lock()
+	err := c.failures[len(c.requests)]
+	c.mu.Unlock()
+	if recordErr := c.testTimeSeriesClient.CreateTimeSeries(ctx, req, opts...); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// Returns a request with count time-series of distinct metric types, each with a
+// single point.
+func newBatchTestRequest(count int) *monitoringpb.CreateTimeSeriesRequest {
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name:       "projects/" + testProjectID,
+		TimeSeries: make([]*monitoringpb.TimeSeries, 0, count),
+	}
+	for i := range count {
+		req.TimeSeries = append(req.TimeSeries, &monitoringpb.TimeSeries{
+			Metric: &metricpb.Metric{
+				Type: DefaultMetricType + "_" + strconv.Itoa(i),
+			},
+			Points: []*monitoringpb.Point{
+				{
+					Interval: &monitoringpb.TimeInterval{
+						EndTime: timestamppb.New(time.Unix(1700000000, 0)),
+					},
+				},
+			},
+		})
+	}
+	return req
+}
+
+func TestWriteTimeSeriesBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		count    int
+		expected []int
+	}{
+		{
+			name:     "default",
+			count:    500,
+			expected: []int{200, 200, 100},
+		},
+		{
+			name:     "single",
+			count:    3,
+			expected: []int{3},
+		},
+		{
+			name:     "exact",
+			count:    400,
+			expected: []int{200, 200},
+		},
+		{
+			name:     "batch-size",
+			options:  []Option{WithBatchSize(150)},
+			count:    500,
+			expected: []int{150, 150, 150, 50},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newNonGCPTestPipeline(t, append(tst.options, WithProjectID(testProjectID))...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req := newBatchTestRequest(tst.count)
+			client := &testTimeSeriesClient{}
+			if err := pipeline.writeTimeSeries(context.Background(), client, req); err != nil {
+				t.Fatalf("Unexpected error returned from writeTimeSeries: %v", err)
+			}
+			sizes := make([]int, 0, len(client.requests))
+			sent := make([]*monitoringpb.TimeSeries, 0, tst.count)
+			for _, batch := range client.requests {
+				if batch.GetName() != req.GetName() {
+					t.Errorf("Expected batch name %q, got %q", req.GetName(), batch.GetName())
+				}
+				sizes = append(sizes, len(batch.GetTimeSeries()))
+				sent = append(sent, batch.GetTimeSeries()...)
+			}
+			if !slices.Equal(sizes, tst.expected) {
+				t.Errorf("Expected batches of %v time-series, got %v", tst.expected, sizes)
+			}
+			if !slices.Equal(sent, req.GetTimeSeries()) {
+				t.Errorf("Expected every time-series to be sent once, in order")
+			}
+		})
+	}
+}
+
+func TestWriteTimeSeriesBatchErrors(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	client := &failingBatchClient{
+		failures: map[int]error{0: errTestFirstBatch, 2: errTestLastBatch},
+	}
+	err = pipeline.writeTimeSeries(context.Background(), client, newBatchTestRequest(500))
+	if !errors.Is(err, errTestFirstBatch) || !errors.Is(err, errTestLastBatch) {
+		t.Errorf("Expected writeTimeSeries to raise %v and %v, got %v", errTestFirstBatch, errTestLastBatch, err)
+	}
+	if len(client.requests) != 3 {
+		t.Errorf("Expected every batch to be sent after a failure, got %d requests", len(client.requests))
+	}
+	// Only the time-series of the batch that succeeded should be recorded.
+	req := newBatchTestRequest(500)
+	for i, series := range req.GetTimeSeries() {
+		_, recorded := pipeline.lastPoints[singleSeriesIdentity(series)]
+		if expected := i >= MaxTimeSeriesPerRequest && i < 2*MaxTimeSeriesPerRequest; recorded != expected {
+			t.Errorf("Expected time-series %d to be recorded %t, got %t", i, expected, recorded)
+		}
+	}
+}
+
+// Returns a gRPC error for a CreateTimeSeries request in which success of total
+// points were written, with the remainder rejected as invalid.
+func newPartialFailureError(t *testing.T, total, success int32) error {
+	t.Helper()
+	st, err := status.New(codes.InvalidArgument, "One or more TimeSeries could not be written: timeSeries[3]: invalid label").WithDetails(&monitoringpb.CreateTimeSeriesSummary{
+		TotalPointCount:   total,
+		SuccessPointCount: success,
+		Errors: []*monitoringpb.CreateTimeSeriesSummary_Error{
+			{
+				Status:     status.New(codes.InvalidArgument, "invalid label").Proto(),
+				PointCount: total - success,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add details to status: %v", err)
+	}
+	return st.Err()
+}
+
+func TestWithContinueOnPartialFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		success     int32
+		expectedErr bool
+		expectedLog string
+	}{
+		{
+			name:        "disabled",
+			success:     199,
+			expectedErr: true,
+		},
+		{
+			name:        "partial",
+			options:     []Option{WithContinueOnPartialFailure()},
+			success:     199,
+			expectedLog: `"msg"="Rejected points" "count"=1 "code"="InvalidArgument" "message"="invalid label"`,
+		},
+		{
+			// A request that is rejected entirely is still an error.
+			name:        "total",
+			options:     []Option{WithContinueOnPartialFailure()},
+			success:     0,
+			expectedErr: true,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var logs bytes.Buffer
+			logger := stdr.NewWithOptions(log.New(&logs, "", 0), stdr.Options{LogCaller: stdr.None, Depth: 0})
+			pipeline, err := newNonGCPTestPipeline(t, append(tst.options, WithLogger(logger), WithProjectID(testProjectID))...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			client := &failingBatchClient{
+				failures: map[int]error{0: newPartialFailureError(t, 200, tst.success)},
+			}
+			err = pipeline.writeTimeSeries(context.Background(), client, newBatchTestRequest(500))
+			switch {
+			case tst.expectedErr && err == nil:
+				t.Error("Expected writeTimeSeries to raise an error")
+			case !tst.expectedErr && err != nil:
+				t.Errorf("writeTimeSeries raised an unexpected error: %v", err)
+			}
+			if len(client.requests) != 3 {
+				t.Errorf("Expected every batch to be sent, got %d requests", len(client.requests))
+			}
+			if !strings.Contains(logs.String(), tst.expectedLog) {
+				t.Errorf("Expected log to contain %q, got %q", tst.expectedLog, logs.String())
+			}
+			if tst.expectedErr {
+				return
+			}
+			// Every time-series except the rejected timeSeries[3] should be
+			// recorded as written.
+			for i, series := range newBatchTestRequest(500).GetTimeSeries() {
+				if _, recorded := pipeline.lastPoints[singleSeriesIdentity(series)]; recorded != (i != 3) {
+					t.Errorf("Expected time-series %d to be recorded %t, got %t", i, i != 3, recorded)
+				}
+			}
+		})
+	}
+}
+
+// Only the time-series that are not identified in a partial failure message
+// are accepted; if none are identified, none can be known to be accepted.
+func TestAcceptedSeries(t *testing.T) {
+	t.Parallel()
+	req := newBatchTestRequest(5)
+	tests := map[string]struct {
+		err      error
+		expected []int
+	}{
+		"identified":   {err: status.Error(codes.InvalidArgument, "timeSeries[1]: invalid label; timeSeries[3]: invalid value"), expected: []int{0, 2, 4}},
+		"unidentified": {err: status.Error(codes.InvalidArgument, "invalid request"), expected: []int{}},
+		"not-status":   {err: errTestFirstBatch, expected: []int{}},
+	}
+	for name, tst := range tests {
+		accepted := acceptedSeries(req, tst.err)
+		types := make([]string, 0, len(accepted.GetTimeSeries()))
+		for _, series := range accepted.GetTimeSeries() {
+			types = append(types, series.GetMetric().GetType())
+		}
+		expected := make([]string, 0, len(tst.expected))
+		for _, i := range tst.expected {
+			expected = append(expected, req.GetTimeSeries()[i].GetMetric().GetType())
+		}
+		if !slices.Equal(types, expected) {
+			t.Errorf("%s: Expected accepted time-series %v, got %v", name, expected, types)
+		}
+	}
+}
+
+func TestWithBatchSizeInvalid(t *testing.T) {
+	t.Parallel()
+	for _, size := range []int{0, -1, MaxTimeSeriesPerRequest + 1} {
+		if _, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithBatchSize(size)); !errors.Is(err, ErrInvalidBatchSize) {
+			t.Errorf("Expected NewPipeline with batch size %d to raise %v, got %v", size, ErrInvalidBatchSize, err)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	t.Parallel()
+	clients := []*testTimeSeriesClient{}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), withNewMetricClient(func(_ context.Context, _ ...option.ClientOption) (timeSeriesClient, error) {
+		client := &testTimeSeriesClient{}
+		clients = append(clients, client)
+		return client, nil
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	if err := pipeline.Close(); err != nil {
+		t.Fatalf("Unexpected error returned from Close: %v", err)
+	}
+	if len(clients) != 1 || !clients[0].closed {
+		t.Fatalf("Expected Close to close the only metric client, got %d clients", len(clients))
+	}
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	}
+	if err := pipeline.emitter(context.Background(), req); !errors.Is(err, ErrPipelineClosed) {
+		t.Errorf("Expected emitter to raise %v after Close, got %v", ErrPipelineClosed, err)
+	}
+	if err := pipeline.Reconnect(context.Background()); err != nil {
+		t.Fatalf("Unexpected error returned from Reconnect: %v", err)
+	}
+	if len(clients) != 2 {
+		t.Fatalf("Expected Reconnect to create a new metric client, got %d clients", len(clients))
+	}
+	if err := pipeline.emitter(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error returned from emitter after Reconnect: %v", err)
+	}
+	if len(clients[1].requests) != 1 {
+		t.Errorf("Expected the request to be sent with the new metric client, got %d requests", len(clients[1].requests))
+	}
+	// Reconnecting an open pipeline must close the client it replaces.
+	if err := pipeline.Reconnect(context.Background()); err != nil {
+		t.Fatalf("Unexpected error returned from Reconnect: %v", err)
+	}
+	if len(clients) != 3 {
+		t.Fatalf("Expected Reconnect to create a new metric client, got %d clients", len(clients))
+	}
+	if !clients[1].closed {
+		t.Error("Expected Reconnect to close the replaced metric client")
+	}
+	if clients[2].closed {
+		t.Error("Expected the new metric client to be open")
+	}
+	if err := pipeline.emitter(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error returned from emitter after Reconnect: %v", err)
+	}
+	if len(clients[1].requests) != 1 || len(clients[2].requests) != 1 {
+		t.Errorf("Expected the request to be sent with the newest metric client, got %d and %d requests", len(clients[1].requests), len(clients[2].requests))
+	}
+}
+
+func TestWithUserAgent(t *testing.T) {
+	t.Parallel()
+	calls := [][]option.ClientOption{}
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithUserAgent(testUserAgent), withNewMetricClient(func(ctx context.Context, opts ...option.ClientOption) (timeSeriesClient, error) {
+		calls = append(calls, opts)
+		return monitoring.NewMetricClient(ctx, opts...)
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	if err := pipeline.Reconnect(context.Background()); err != nil {
+		t.Fatalf("Unexpected error returned from Reconnect: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("Expected metric client to be created twice, got %d", len(calls))
+	}
+	expected := []option.ClientOption{option.WithUserAgent(testUserAgent)}
+	for i, opts := range calls {
+		if !reflect.DeepEqual(opts, expected) {
+			t.Errorf("Expected client options %+v for call %d, got %+v", expected, i, opts)
+		}
+	}
+}
+
+// Validate should create its client with the same options as the pipeline, so
+// that it checks the endpoint that time-series are written to.
+func TestValidateClientOptions(t *testing.T) {
+	t.Parallel()
+	var calls [][]option.ClientOption
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithUserAgent(testUserAgent), WithEndpoint("monitoring.example.com:443"), WithWriterEmitter(io.Discard), withNewMetricClient(func(_ context.Context, opts ...option.ClientOption) (timeSeriesClient, error) {
+		calls = append(calls, opts)
+		return nil, errTestNewClient
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	if err := pipeline.Validate(context.Background(), generators.Metric{Value: 1.0, Timestamp: time.Now()}); !errors.Is(err, errTestNewClient) {
+		t.Errorf("Expected Validate to raise %v, got %v", errTestNewClient, err)
+	}
+	expected := [][]option.ClientOption{{option.WithUserAgent(testUserAgent), option.WithEndpoint("monitoring.example.com:443")}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected client options %+v, got %+v", expected, calls)
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+	opts, err := ClientOptions(WithMetricType("custom.googleapis.com/test"), WithRegionalEndpoint("europe-west3"), WithUserAgent(testUserAgent))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from ClientOptions: %v", err)
+	}
+	expected := []option.ClientOption{option.WithEndpoint("monitoring.europe-west3.rep.googleapis.com:443"), option.WithUserAgent(testUserAgent)}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected client options %+v, got %+v", expected, opts)
+	}
+	if _, err := ClientOptions(WithRegionalEndpoint("not a region")); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("Expected ClientOptions to raise %v, got %v", ErrInvalidRegion, err)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		attempts      int
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			name:          "no-failures",
+			failures:      0,
+			attempts:      3,
+			expectedCalls: 1,
+		},
+		{
+			name:          "fail-twice",
+			failures:      2,
+			attempts:      3,
+			expectedCalls: 3,
+		},
+		{
+			name:          "exhausted",
+			failures:      3,
+			attempts:      3,
+			expectedCalls: 3,
+			expectedError: errTestMetadata,
+		},
+		{
+			name:          "single-attempt",
+			failures:      1,
+			attempts:      1,
+			expectedCalls: 1,
+			expectedError: errTestMetadata,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			value, err := RetryWithBackoff(context.Background(), logr.Discard(), tst.attempts, time.Millisecond, func(_ context.Context) (int, error) {
+				calls++
+				if calls <= tst.failures {
+					return 0, errTestMetadata
+				}
+				return 42, nil
+			})
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected RetryWithBackoff to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("RetryWithBackoff raised an unexpected error: %v", err)
+			case tst.expectedError == nil && value != 42:
+				t.Errorf("Expected value 42, got %d", value)
+			}
+			if calls != tst.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tst.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffCancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := RetryWithBackoff(ctx, logr.Discard(), 3, time.Hour, func(_ context.Context) (int, error) {
+		calls++
+		cancel()
+		return 0, errTestMetadata
+	})
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errTestMetadata) {
+		t.Errorf("Expected RetryWithBackoff to raise %v and %v, got %v", context.Canceled, errTestMetadata, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRegionalEndpoint(t *testing.T) {
+	tests := []struct {
+		region   string
+		expected string
+	}{
+		{
+			region:   "us-central1",
+			expected: "monitoring.us-central1.rep.googleapis.com:443",
+		},
+		{
+			region:   "europe-west3",
+			expected: "monitoring.europe-west3.rep.googleapis.com:443",
+		},
+		{
+			region:   "northamerica-northeast2",
+			expected: "monitoring.northamerica-northeast2.rep.googleapis.com:443",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.region, func(t *testing.T) {
+			t.Parallel()
+			if endpoint := regionalEndpoint(tst.region); endpoint != tst.expected {
+				t.Errorf("Expected endpoint %q, got %q", tst.expected, endpoint)
+			}
+			var calls [][]option.ClientOption
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithRegionalEndpoint(tst.region), withNewMetricClient(func(ctx context.Context, opts ...option.ClientOption) (timeSeriesClient, error) {
+				calls = append(calls, opts)
+				return monitoring.NewMetricClient(ctx, opts...)
+			}))
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			expected := [][]option.ClientOption{{option.WithEndpoint(tst.expected)}}
+			if !reflect.DeepEqual(calls, expected) {
+				t.Errorf("Expected client options %+v, got %+v", expected, calls)
+			}
+		})
 	}
-	return NewPipeline(context.Background(), append(options, withOnGCE(false), withMetadataClient(client))...)
 }
 
-func TestNonGCPDefault(t *testing.T) {
+func TestWithRegionalEndpointInvalid(t *testing.T) {
 	t.Parallel()
-	_, err := newNonGCPTestPipeline(t)
-	switch {
-	case err != nil && !errors.Is(err, errNotGCP):
-		t.Errorf("Expected NewPipeline to raise %v, got %v", errNotGCP, err)
-	case err == nil:
-		t.Errorf("Expected NewPipeline to raise %v, but it didn't", errNotGCP)
+	for _, region := range []string{"", "global", "us-central1-a", "US-CENTRAL1", "us-central1.example.com"} {
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithRegionalEndpoint(region))
+			if !errors.Is(err, ErrInvalidRegion) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidRegion, err)
+			}
+		})
 	}
 }
 
-func TestNonGCPExplicitProjectID(t *testing.T) {
+func TestWithKeepalive(t *testing.T) {
 	t.Parallel()
-	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID))
-	if err != nil {
-		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		expectedCount int
+		expectedErr   error
+	}{
+		{
+			name:          "disabled",
+			interval:      0,
+			expectedCount: 0,
+		},
+		{
+			name:          "enabled",
+			interval:      2 * time.Minute,
+			expectedCount: 1,
+		},
+		{
+			name:        "negative",
+			interval:    -1 * time.Second,
+			expectedErr: ErrInvalidKeepalive,
+		},
 	}
-	defer pipeline.Close()
-	metric := generators.Metric{
-		Value:     1.1,
-		Timestamp: time.Now(),
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var clientOptions []option.ClientOption
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithKeepalive(tst.interval), withNewMetricClient(func(ctx context.Context, opts ...option.ClientOption) (timeSeriesClient, error) {
+				clientOptions = opts
+				return monitoring.NewMetricClient(ctx, opts...)
+			}))
+			if tst.expectedErr != nil {
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected NewPipeline to raise %v, got %v", tst.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			if len(clientOptions) != tst.expectedCount {
+				t.Errorf("Expected %d client options, got %d", tst.expectedCount, len(clientOptions))
+			}
+		})
 	}
-	expectedWithoutNodeID := &monitoringpb.CreateTimeSeriesRequest{
-		Name: "projects/" + testProjectID,
-		TimeSeries: []*monitoringpb.TimeSeries{
-			{
-				Metric: &metricpb.Metric{
-					Type: DefaultMetricType,
-				},
-				MetricKind: metricpb.MetricDescriptor_GAUGE,
-				Resource: &monitoredrespb.MonitoredResource{
-					Type: "generic_node",
-					Labels: map[string]string{
-						"project_id": testProjectID,
-						"location":   DefaultLocation,
-						"namespace":  DefaultNamespace,
-					},
-				},
-				Points: []*monitoringpb.Point{
-					{
-						Interval: &monitoringpb.TimeInterval{
-							StartTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
-							EndTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
-						},
-						Value: &monitoringpb.TypedValue{
-							Value: &monitoringpb.TypedValue_DoubleValue{
-								DoubleValue: metric.Value,
-							},
-						},
-					},
-				},
-			},
-		},
+}
+
+func TestNonGCPWithSeed(t *testing.T) {
+	t.Parallel()
+	nodeID := func(seed int64) string {
+		t.Helper()
+		pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithSeed(seed))
+		if err != nil {
+			t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+		}
+		defer pipeline.Close()
+		req, err := pipeline.BuildRequest(generators.Metric{
+			Value:     1.1,
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error from BuildRequest: %v", err)
+		}
+		return req.TimeSeries[0].Resource.Labels["node_id"]
 	}
-	req, err := pipeline.BuildRequest(metric)
-	if err != nil {
-		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	if first, second := nodeID(42), nodeID(42); first != second {
+		t.Errorf("Expected identical node_id for the same seed, got %q and %q", first, second)
 	}
-	_, err = uuid.Parse(req.TimeSeries[0].Resource.Labels["node_id"])
-	if err != nil {
-		t.Errorf("Error parsing UUID from resource label 'node_id': %v", err)
+	if first, second := nodeID(42), nodeID(43); first == second {
+		t.Errorf("Expected different node_id for different seeds, got %q", first)
 	}
-	delete(req.TimeSeries[0].Resource.Labels, "node_id")
-	if !reflect.DeepEqual(req, expectedWithoutNodeID) {
-		t.Errorf("Expected %+v, got %+v", expectedWithoutNodeID, req)
+	if first, second := nodeID(0), nodeID(0); first == second {
+		t.Errorf("Expected random node_id for zero seed, got %q", first)
 	}
 }
 
@@ -203,12 +2275,7 @@ func TestGCEPipelineDefault(t *testing.T) {
 				Points: []*monitoringpb.Point{
 					{
 						Interval: &monitoringpb.TimeInterval{
-							StartTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
-							EndTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
+							EndTime: timestamppb.New(metric.Timestamp),
 						},
 						Value: &monitoringpb.TypedValue{
 							Value: &monitoringpb.TypedValue_DoubleValue{
@@ -229,6 +2296,574 @@ func TestGCEPipelineDefault(t *testing.T) {
 	}
 }
 
+// With metadata detection skipped, a pipeline running on GCE must not query the
+// metadata server, and should attach a generic_node resource.
+func TestGCEPipelineSkipMetadata(t *testing.T) {
+	t.Parallel()
+	client := &flakyTestClient{
+		testClient: testClient{
+			projectID:  testProjectID,
+			instanceID: testInstanceID,
+			zone:       testZone,
+			attributes: map[string]string{},
+		},
+		failures: 100,
+	}
+	options := []Option{WithSkipMetadata(), WithMetadataRetry(1, time.Millisecond), withOnGCE(true), withMetadataClient(client)}
+	if _, err := NewPipeline(context.Background(), options...); !errors.Is(err, ErrMissingProjectID) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrMissingProjectID, err)
+	}
+	pipeline, err := NewPipeline(context.Background(), append(options, WithProjectID(testProjectID))...)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	if client.failures != 100 {
+		t.Errorf("Expected no metadata requests, got %d", 100-client.failures)
+	}
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	}
+	resource := req.GetTimeSeries()[0].GetResource()
+	if resource.GetType() != "generic_node" {
+		t.Errorf("Expected generic_node resource, got %q", resource.GetType())
+	}
+	for key, expected := range map[string]string{"project_id": testProjectID, "location": DefaultLocation, "namespace": DefaultNamespace} {
+		if value := resource.GetLabels()[key]; value != expected {
+			t.Errorf("Expected resource label %s=%q, got %q", key, expected, value)
+		}
+	}
+}
+
+func TestGCEPipelineResourceOverrides(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		options            []Option
+		expectedInstanceID string
+		expectedZone       string
+	}{
+		{
+			name:               "none",
+			options:            []Option{},
+			expectedInstanceID: testInstanceID,
+			expectedZone:       testZone,
+		},
+		{
+			name:               "instance",
+			options:            []Option{WithInstanceID("override-instance")},
+			expectedInstanceID: "override-instance",
+			expectedZone:       testZone,
+		},
+		{
+			name:               "zone",
+			options:            []Option{WithZone("europe-west3-a")},
+			expectedInstanceID: testInstanceID,
+			expectedZone:       "europe-west3-a",
+		},
+		{
+			name:               "both",
+			options:            []Option{WithInstanceID("override-instance"), WithZone("europe-west3-a")},
+			expectedInstanceID: "override-instance",
+			expectedZone:       "europe-west3-a",
+		},
+		{
+			name:               "empty",
+			options:            []Option{WithInstanceID(""), WithZone("")},
+			expectedInstanceID: testInstanceID,
+			expectedZone:       testZone,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newGCETestPipeline(t, tst.options...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			resource := req.GetTimeSeries()[0].GetResource()
+			if resource.GetType() != "gce_instance" {
+				t.Errorf("Expected gce_instance resource, got %q", resource.GetType())
+			}
+			for key, expected := range map[string]string{"project_id": testProjectID, "instance_id": tst.expectedInstanceID, "zone": tst.expectedZone} {
+				if value := resource.GetLabels()[key]; value != expected {
+					t.Errorf("Expected resource label %s=%q, got %q", key, expected, value)
+				}
+			}
+		})
+	}
+}
+
+func TestGCEPipelineMetadataRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		expectedError error
+	}{
+		{
+			name:     "no-failures",
+			failures: 0,
+		},
+		{
+			name:     "fail-once",
+			failures: 1,
+		},
+		{
+			name:     "fail-twice",
+			failures: 2,
+		},
+		{
+			name:          "exhausted",
+			failures:      3,
+			expectedError: errTestMetadata,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := &flakyTestClient{
+				testClient: testClient{
+					projectID:  testProjectID,
+					instanceID: testInstanceID,
+					zone:       testZone,
+					attributes: map[string]string{},
+				},
+				failures: tst.failures,
+			}
+			pipeline, err := NewPipeline(context.Background(), withOnGCE(true), withMetadataClient(client), WithMetadataRetry(3, time.Millisecond))
+			switch {
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected NewPipeline to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError != nil:
+				return
+			case err != nil:
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			if instanceID := req.TimeSeries[0].Resource.Labels["instance_id"]; instanceID != testInstanceID {
+				t.Errorf("Expected instance_id label %q, got %q", testInstanceID, instanceID)
+			}
+		})
+	}
+}
+
+func TestWithMetadataRetryInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := newGCETestPipeline(t, WithMetadataRetry(0, time.Millisecond))
+	if !errors.Is(err, ErrInvalidMetadataAttempts) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidMetadataAttempts, err)
+	}
+}
+
+func TestGCEPipelineWithPromoteResourceLabels(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newGCETestPipeline(t, WithPromoteResourceLabels("zone", "missing"))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from BuildRequest: %v", err)
+	}
+	expected := map[string]string{
+		"zone": testZone,
+	}
+	if !reflect.DeepEqual(req.TimeSeries[0].Metric.Labels, expected) {
+		t.Errorf("Expected metric labels %+v, got %+v", expected, req.TimeSeries[0].Metric.Labels)
+	}
+	if zone := req.TimeSeries[0].Resource.Labels["zone"]; zone != testZone {
+		t.Errorf("Expected resource zone label %q to be unchanged, got %q", testZone, zone)
+	}
+}
+
+func TestWithResourceTypeGlobal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		builder func(*testing.T, ...Option) (*Pipeline, error)
+	}{
+		{
+			name:    "non-gcp",
+			builder: newNonGCPTestPipeline,
+		},
+		{
+			name:    "gce",
+			builder: newGCETestPipeline,
+		},
+	}
+	expected := &monitoredrespb.MonitoredResource{
+		Type: GlobalResourceType,
+		Labels: map[string]string{
+			"project_id": testProjectID,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := tst.builder(t, WithProjectID(testProjectID), WithResourceType(GlobalResourceType))
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.1,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error from BuildRequest: %v", err)
+			}
+			if !reflect.DeepEqual(req.TimeSeries[0].Resource, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, req.TimeSeries[0].Resource)
+			}
+		})
+	}
+}
+
+func TestWithResourceTypeUnsupported(t *testing.T) {
+	t.Parallel()
+	_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithResourceType("unknown"))
+	if !errors.Is(err, ErrUnsupportedResourceType) {
+		t.Errorf("Expected NewPipeline to raise %v, got %v", ErrUnsupportedResourceType, err)
+	}
+}
+
+func TestWithMetricTypeDomain(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		metricType  string
+		autoPrefix  bool
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:       "custom",
+			metricType: "custom.googleapis.com/test",
+			expected:   "custom.googleapis.com/test",
+		},
+		{
+			name:       "workload",
+			metricType: "workload.googleapis.com/test",
+			expected:   "workload.googleapis.com/test",
+		},
+		{
+			name:        "arbitrary",
+			metricType:  "example.com/test",
+			expectedErr: ErrUnsupportedMetricDomain,
+		},
+		{
+			name:        "unqualified",
+			metricType:  "test",
+			expectedErr: ErrUnsupportedMetricDomain,
+		},
+		{
+			name:        "domain-only",
+			metricType:  "custom.googleapis.com/",
+			expectedErr: ErrUnsupportedMetricDomain,
+		},
+		{
+			name:       "custom-auto-prefix",
+			metricType: "custom.googleapis.com/test",
+			autoPrefix: true,
+			expected:   "custom.googleapis.com/test",
+		},
+		{
+			name:       "workload-auto-prefix",
+			metricType: "workload.googleapis.com/test",
+			autoPrefix: true,
+			expected:   "workload.googleapis.com/test",
+		},
+		{
+			name:       "arbitrary-auto-prefix",
+			metricType: "example.com/test",
+			autoPrefix: true,
+			expected:   "custom.googleapis.com/example.com/test",
+		},
+		{
+			name:       "unqualified-auto-prefix",
+			metricType: "test",
+			autoPrefix: true,
+			expected:   "custom.googleapis.com/test",
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			options := []Option{WithProjectID(testProjectID), WithMetricType(tst.metricType)}
+			if tst.autoPrefix {
+				options = append(options, WithAutoPrefix())
+			}
+			pipeline, err := newNonGCPTestPipeline(t, options...)
+			if tst.expectedErr != nil {
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected NewPipeline to raise %v, got %v", tst.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.0,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+			}
+			if metricType := req.GetTimeSeries()[0].GetMetric().GetType(); metricType != tst.expected {
+				t.Errorf("Expected metric type %q, got %q", tst.expected, metricType)
+			}
+		})
+	}
+}
+
+func TestWithMetricKind(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		kind        metricpb.MetricDescriptor_MetricKind
+		expectedErr error
+	}{
+		{
+			name: "gauge",
+			kind: metricpb.MetricDescriptor_GAUGE,
+		},
+		{
+			name: "delta",
+			kind: metricpb.MetricDescriptor_DELTA,
+		},
+		{
+			name: "cumulative",
+			kind: metricpb.MetricDescriptor_CUMULATIVE,
+		},
+		{
+			name:        "unspecified",
+			kind:        metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED,
+			expectedErr: ErrUnsupportedMetricKind,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithMetricKind(tst.kind))
+			if tst.expectedErr != nil {
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected NewPipeline to raise %v, got %v", tst.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     1.0,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+			}
+			series := req.GetTimeSeries()[0]
+			if series.GetMetricKind() != tst.kind {
+				t.Errorf("Expected metric kind %s, got %s", tst.kind, series.GetMetricKind())
+			}
+			if hasStart := series.GetPoints()[0].GetInterval().GetStartTime() != nil; hasStart == (tst.kind == metricpb.MetricDescriptor_GAUGE) {
+				t.Errorf("Unexpected start time presence %t for metric kind %s", hasStart, tst.kind)
+			}
+		})
+	}
+}
+
+// Two metric definitions should add two time-series to every request, each with
+// the metric type and kind of its definition, and values from its own
+// transformers.
+// Every point of a CUMULATIVE time-series should share the configured start
+// time, including those of metric definitions with their own value transformer,
+// while GAUGE time-series are unchanged.
+func TestWithCumulativeStartTime(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithMetricKind(metricpb.MetricDescriptor_CUMULATIVE),
+		WithCumulativeStartTime(start),
+		WithMetricDefinitions(
+			MetricDefinition{
+				Type:         "custom.googleapis.com/cumulative-count",
+				Kind:         metricpb.MetricDescriptor_CUMULATIVE,
+				Transformers: []Transformer{NewIntegerTypedValueTransformer()},
+			},
+			MetricDefinition{
+				Type: "custom.googleapis.com/gauge-value",
+				Kind: metricpb.MetricDescriptor_GAUGE,
+			},
+		),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	for tick := range 3 {
+		timestamp := start.Add(time.Hour + time.Duration(tick)*time.Minute)
+		req, err := pipeline.BuildRequest(generators.Metric{
+			Value:     float64(tick),
+			Timestamp: timestamp,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+		}
+		for i, series := range req.GetTimeSeries() {
+			interval := series.GetPoints()[0].GetInterval()
+			if series.GetMetricKind() == metricpb.MetricDescriptor_GAUGE {
+				if interval.GetStartTime() != nil {
+					t.Errorf("Expected GAUGE time-series %d to have no start time on tick %d, got %v", i, tick, interval.GetStartTime().AsTime())
+				}
+				continue
+			}
+			if got := interval.GetStartTime().AsTime(); !got.Equal(start) {
+				t.Errorf("Expected time-series %d to start at %v on tick %d, got %v", i, start, tick, got)
+			}
+			if got := interval.GetEndTime().AsTime(); !got.Equal(timestamp) {
+				t.Errorf("Expected time-series %d to end at %v on tick %d, got %v", i, timestamp, tick, got)
+			}
+		}
+	}
+	if _, err := pipeline.BuildRequest(generators.Metric{Value: 1.0, Timestamp: start}); !errors.Is(err, ErrInvalidCumulativeStartTime) {
+		t.Errorf("Expected BuildRequest to raise %v, got %v", ErrInvalidCumulativeStartTime, err)
+	}
+}
+
+func TestWithMetricDefinitions(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithAutoPrefix(), WithMetricDefinitions(
+		MetricDefinition{
+			Type:         "cumulative-count",
+			Kind:         metricpb.MetricDescriptor_CUMULATIVE,
+			Transformers: []Transformer{NewIntegerTypedValueTransformer()},
+		},
+		MetricDefinition{
+			Type: "custom.googleapis.com/delta-value",
+			Kind: metricpb.MetricDescriptor_DELTA,
+		},
+	))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	timestamp := time.Now()
+	for tick := range 2 {
+		req, err := pipeline.BuildRequest(generators.Metric{
+			Value:     2.5,
+			Timestamp: timestamp.Add(time.Duration(tick) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+		}
+		if len(req.GetTimeSeries()) != 3 {
+			t.Fatalf("Expected 3 time-series on tick %d, got %d", tick, len(req.GetTimeSeries()))
+		}
+		for i, expected := range []struct {
+			metricType string
+			kind       metricpb.MetricDescriptor_MetricKind
+			valueType  metricpb.MetricDescriptor_ValueType
+		}{
+			{metricType: DefaultMetricType, kind: metricpb.MetricDescriptor_GAUGE, valueType: metricpb.MetricDescriptor_DOUBLE},
+			{metricType: "custom.googleapis.com/cumulative-count", kind: metricpb.MetricDescriptor_CUMULATIVE, valueType: metricpb.MetricDescriptor_INT64},
+			{metricType: "custom.googleapis.com/delta-value", kind: metricpb.MetricDescriptor_DELTA, valueType: metricpb.MetricDescriptor_DOUBLE},
+		} {
+			series := req.GetTimeSeries()[i]
+			if series.GetMetric().GetType() != expected.metricType {
+				t.Errorf("Expected time-series %d to have metric type %q, got %q", i, expected.metricType, series.GetMetric().GetType())
+			}
+			if series.GetMetricKind() != expected.kind {
+				t.Errorf("Expected time-series %d to have metric kind %s, got %s", i, expected.kind, series.GetMetricKind())
+			}
+			if valueType := pointValueType(series); valueType != expected.valueType {
+				t.Errorf("Expected time-series %d to have value type %s, got %s", i, expected.valueType, valueType)
+			}
+			if series.GetResource() == nil {
+				t.Errorf("Expected time-series %d to have a monitored resource", i)
+			}
+			if hasStart := series.GetPoints()[0].GetInterval().GetStartTime() != nil; hasStart == (expected.kind == metricpb.MetricDescriptor_GAUGE) {
+				t.Errorf("Unexpected start time presence %t for time-series %d", hasStart, i)
+			}
+		}
+	}
+}
+
+func TestWithMetricDefinitionsInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		definition  MetricDefinition
+		expectedErr error
+	}{
+		{
+			name: "unspecified-kind",
+			definition: MetricDefinition{
+				Type: "custom.googleapis.com/other",
+			},
+			expectedErr: ErrUnsupportedMetricKind,
+		},
+		{
+			name: "unsupported-domain",
+			definition: MetricDefinition{
+				Type: "example.com/other",
+				Kind: metricpb.MetricDescriptor_GAUGE,
+			},
+			expectedErr: ErrUnsupportedMetricDomain,
+		},
+		{
+			name: "duplicate-type",
+			definition: MetricDefinition{
+				Type: DefaultMetricType,
+				Kind: metricpb.MetricDescriptor_CUMULATIVE,
+			},
+			expectedErr: ErrDuplicateMetricType,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithMetricDefinitions(tst.definition))
+			if !errors.Is(err, tst.expectedErr) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", tst.expectedErr, err)
+			}
+		})
+	}
+}
+
 // Helper function to create a new Pipeline object that will appear to be running
 // in a GKE container.
 func newGKETestPipeline(t *testing.T, options ...Option) (*Pipeline, error) {
@@ -281,12 +2916,7 @@ func TestGKEPipelineDefault(t *testing.T) { //nolint:paralleltest // simulating
 				Points: []*monitoringpb.Point{
 					{
 						Interval: &monitoringpb.TimeInterval{
-							StartTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
-							EndTime: &timestamppb.Timestamp{
-								Seconds: metric.Timestamp.Unix(),
-							},
+							EndTime: timestamppb.New(metric.Timestamp),
 						},
 						Value: &monitoringpb.TypedValue{
 							Value: &monitoringpb.TypedValue_DoubleValue{
@@ -354,3 +2984,92 @@ func Example() {
 	}
 	<-ctx.Done()
 }
+
+// Build requests from many goroutines with transformers that share resources and
+// add labels, then modify each request as an emitter or a later transformer
+// might. Any label map or resource that is shared and modified in place will be
+// reported when the tests are run with -race.
+func TestBuildRequestConcurrent(t *testing.T) {
+	t.Parallel()
+	const goroutines = 8
+	const requests = 50
+	pipeline, err := newNonGCPTestPipeline(t,
+		WithProjectID(testProjectID),
+		WithPromoteResourceLabels("location", "namespace"),
+		WithTransformers([]Transformer{
+			NewMetricLabelTransformer("host", testHost),
+			NewSequenceLabelTransformer("seq"),
+			NewTimeBucketLabelTransformer("hour", "15"),
+		}),
+		withTimeSeriesClient(&testTimeSeriesClient{}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := range goroutines {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := range requests {
+				req, err := pipeline.BuildRequest(generators.Metric{
+					Value:     float64(worker*requests + j),
+					Timestamp: time.Now(),
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, series := range req.TimeSeries {
+					if series.Metric.Labels["host"] != testHost || series.Metric.Labels["location"] != DefaultLocation {
+						errs <- fmt.Errorf("unexpected metric labels %v", series.Metric.Labels)
+						return
+					}
+					series.Metric.Labels["worker"] = strconv.Itoa(worker)
+					series.Points[0].Value.Value = &monitoringpb.TypedValue_Int64Value{Int64Value: int64(j)}
+				}
+				if _, err := prototext.Marshal(req); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func BenchmarkBuildRequest(b *testing.B) {
+	client := &testClient{
+		projectID:  "",
+		instanceID: "",
+		zone:       "",
+		attributes: map[string]string{},
+	}
+	pipeline, err := NewPipeline(context.Background(),
+		WithProjectID(testProjectID),
+		withOnGCE(false),
+		withMetadataClient(client),
+		withTimeSeriesClient(&testTimeSeriesClient{}),
+	)
+	if err != nil {
+		b.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	metric := generators.Metric{
+		Value:     1.1,
+		Timestamp: time.Now(),
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := pipeline.BuildRequest(metric); err != nil {
+			b.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Parses and validates the URL of an HTTP proxy; the URL must use the http or
+// https scheme and include a host.
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+	}
+	if (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxyURL)
+	}
+	return proxy, nil
+}
+
+// Returns a gRPC context dialer that opens a connection to the proxy, using TLS
+// for an https proxy, and asks it to tunnel the connection to the target address
+// with an HTTP CONNECT request.
+func newProxyDialer(proxy *url.URL) func(context.Context, string) (net.Conn, error) {
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failure connecting to proxy %s: %w", proxyAddr, err)
+		}
+		if proxy.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{
+				ServerName: proxy.Hostname(),
+				MinVersion: tls.VersionTLS12,
+			})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("failure negotiating TLS with proxy %s: %w", proxyAddr, err)
+			}
+			conn = tlsConn
+		}
+		if err := connectTunnel(ctx, conn, proxy, addr); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// Sends an HTTP CONNECT request for addr on the proxy connection, and waits for
+// a successful response.
+func connectTunnel(ctx context.Context, conn net.Conn, proxy *url.URL, addr string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("failure setting proxy connection deadline: %w", err)
+		}
+		defer func() {
+			_ = conn.SetDeadline(time.Time{})
+		}()
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := proxy.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("failure sending CONNECT request to proxy: %w", err)
+	}
+	// The response is read through a buffered reader; a conforming proxy will not
+	// send any tunnelled bytes until the client has sent its first request, so no
+	// data is lost when the reader is discarded.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("failure reading CONNECT response from proxy: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: proxy refused CONNECT to %s: %s", ErrProxyConnect, addr, resp.Status)
+	}
+	return nil
+}
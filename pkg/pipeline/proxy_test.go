@@ -0,0 +1,220 @@
+package pipeline //nolint:testpackage // These tests need access to the private proxy dialer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/api/option"
+)
+
+// A fake HTTP proxy that records the CONNECT requests it receives. If status is
+// http.StatusOK, the connection is tunnelled to the requested address,
+// otherwise the request is refused with the status.
+type fakeProxy struct {
+	listener net.Listener
+	status   int
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newFakeProxy(t *testing.T, status int) *fakeProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create proxy listener: %v", err)
+	}
+	proxy := &fakeProxy{
+		listener: listener,
+		status:   status,
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go proxy.serve()
+	return proxy
+}
+
+func (f *fakeProxy) URL(user *url.Userinfo) string {
+	return (&url.URL{Scheme: "http", Host: f.listener.Addr().String(), User: user}).String()
+}
+
+func (f *fakeProxy) Requests() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*http.Request{}, f.requests...)
+}
+
+func (f *fakeProxy) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if f.status != http.StatusOK {
+		_ = (&http.Response{StatusCode: f.status, ProtoMajor: 1, ProtoMinor: 1}).Write(conn)
+		return
+	}
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_ = (&http.Response{StatusCode: http.StatusBadGateway, ProtoMajor: 1, ProtoMinor: 1}).Write(conn)
+		return
+	}
+	defer target.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	go func() {
+		_, _ = io.Copy(target, conn)
+	}()
+	_, _ = io.Copy(conn, target)
+}
+
+// Starts a TCP server that echoes a single line back to the client.
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create echo listener: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(conn, line)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestWithProxyInvalid(t *testing.T) {
+	t.Parallel()
+	for _, proxyURL := range []string{"", "proxy.example.com:3128", "socks5://proxy.example.com:1080", "http://", "http://%zz"} {
+		t.Run(proxyURL, func(t *testing.T) {
+			t.Parallel()
+			_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithProxy(proxyURL))
+			if !errors.Is(err, ErrInvalidProxy) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidProxy, err)
+			}
+		})
+	}
+}
+
+// The proxy dialer should tunnel the connection to the target through the proxy,
+// sending any credentials from the proxy URL.
+func TestProxyDialer(t *testing.T) {
+	t.Parallel()
+	proxy := newFakeProxy(t, http.StatusOK)
+	target := newEchoServer(t)
+	proxyURL, err := parseProxyURL(proxy.URL(url.UserPassword("user", "secret")))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing proxy URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := newProxyDialer(proxyURL)(ctx, target)
+	if err != nil {
+		t.Fatalf("Unexpected error from proxy dialer: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "hello\n"); err != nil {
+		t.Fatalf("Failed to write to tunnel: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read from tunnel: %v", err)
+	}
+	if line != "hello\n" {
+		t.Errorf("Expected echoed line %q, got %q", "hello\n", line)
+	}
+	requests := proxy.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 proxy request, got %d", len(requests))
+	}
+	if requests[0].Method != http.MethodConnect || requests[0].Host != target {
+		t.Errorf("Expected CONNECT %s, got %s %s", target, requests[0].Method, requests[0].Host)
+	}
+	if auth := requests[0].Header.Get("Proxy-Authorization"); auth != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Expected basic proxy authorization, got %q", auth)
+	}
+}
+
+func TestProxyDialerRefused(t *testing.T) {
+	t.Parallel()
+	proxy := newFakeProxy(t, http.StatusForbidden)
+	proxyURL, err := parseProxyURL(proxy.URL(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing proxy URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := newProxyDialer(proxyURL)(ctx, "monitoring.googleapis.com:443"); !errors.Is(err, ErrProxyConnect) {
+		t.Errorf("Expected proxy dialer to raise %v, got %v", ErrProxyConnect, err)
+	}
+}
+
+// A metric client created with the pipeline's options should connect to Cloud
+// Monitoring through the proxy; the fake proxy refuses the tunnel so that no
+// request leaves the test host.
+func TestWithProxyMetricClient(t *testing.T) {
+	t.Parallel()
+	proxy := newFakeProxy(t, http.StatusForbidden)
+	var clientOptions []option.ClientOption
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithProxy(proxy.URL(nil)), withNewMetricClient(func(ctx context.Context, opts ...option.ClientOption) (timeSeriesClient, error) {
+		clientOptions = opts
+		return monitoring.NewMetricClient(ctx, append(opts, option.WithoutAuthentication())...)
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	if len(clientOptions) != 1 {
+		t.Errorf("Expected 1 client option, got %d", len(clientOptions))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pipeline.client.CreateTimeSeries(ctx, &monitoringpb.CreateTimeSeriesRequest{Name: "projects/" + testProjectID}); err == nil {
+		t.Error("Expected CreateTimeSeries to fail when the proxy refuses the connection")
+	}
+	requests := proxy.Requests()
+	if len(requests) == 0 {
+		t.Fatal("Expected the metric client to connect through the proxy")
+	}
+	if requests[0].Method != http.MethodConnect || requests[0].Host != "monitoring.googleapis.com:443" {
+		t.Errorf("Expected CONNECT monitoring.googleapis.com:443, got %s %s", requests[0].Method, requests[0].Host)
+	}
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The remote-write protocol version sent with every request.
+const RemoteWriteVersion = "0.1.0"
+
+// The maximum number of samples or lines that the remote-write and InfluxDB
+// emitters keep to send again while the endpoint is unavailable; the oldest are
+// dropped first.
+const MaxPendingPoints = 10000
+
+// A Prometheus time-series with its labels sorted by name, and a single sample.
+type remoteWriteSeries struct {
+	labels    [][2]string
+	value     float64
+	timestamp int64
+}
+
+// Holds the samples that have not yet been accepted by the remote-write
+// endpoint.
+type remoteWriter struct {
+	endpoint string
+	client   *http.Client
+	// If set, returns the client and endpoint to use when the first request is
+	// sent, e.g. to authenticate with the options of the finished pipeline.
+	connect func() (*http.Client, string, error)
+	mu      sync.Mutex
+	pending []remoteWriteSeries
+}
+
+// Send the time-series in each request to a Prometheus remote-write endpoint,
+// e.g. Grafana Mimir or Cortex, instead of Google Cloud Monitoring. The metric
+// type becomes the metric name, with characters that are not valid in a
+// Prometheus metric name replaced by underscores, and the metric and monitored
+// resource labels become Prometheus labels; metric labels take precedence.
+// Integer and boolean values are sent as floats, and distributions as their mean.
+//
+// Samples that cannot be sent because of a network error, rate limiting, or a
+// server error are kept and sent with the next request, up to MaxPendingPoints,
+// and closing the pipeline flushes any that are still pending. Samples that are
+// rejected with any other client error are dropped, as sending them again would
+// fail in the same way.
+func WithPrometheusRemoteWriteEmitter(endpoint string) Option {
+	return func(p *Pipeline) error {
+		parsed, err := url.Parse(endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidRemoteWriteURL, endpoint)
+		}
+		writer := &remoteWriter{
+			endpoint: parsed.String(),
+			client:   &http.Client{},
+			connect:  nil,
+			mu:       sync.Mutex{},
+			pending:  []remoteWriteSeries{},
+		}
+		p.emitter = func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+			p.logger.V(2).Info("Emitting time-series request to Prometheus remote-write endpoint", "endpoint", writer.endpoint)
+			series, err := remoteWriteSeriesFromRequest(req)
+			if err != nil {
+				return err
+			}
+			if err := writer.write(ctx, series); err != nil {
+				return err
+			}
+			p.recordLastPoints(req)
+			return nil
+		}
+		p.closer = func() error {
+			p.logger.V(2).Info("Flushing pending samples to Prometheus remote-write endpoint")
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRPCTimeout)
+			defer cancel()
+			return writer.write(ctx, nil)
+		}
+		return nil
+	}
+}
+
+// Add the series to the pending samples and send them all to the endpoint. The
+// pending samples are discarded if the endpoint accepts them, or rejects them
+// with an error that is not retryable.
+func (w *remoteWriter) write(ctx context.Context, series []remoteWriteSeries) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, series...)
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if w.client == nil && w.connect != nil {
+		client, endpoint, err := w.connect()
+		if err != nil {
+			w.pending, err = retainPending(w.pending, err)
+			return err
+		}
+		w.client = client
+		w.endpoint = endpoint
+	}
+	body := snappy.Encode(nil, encodeRemoteWriteRequest(w.pending))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failure creating remote-write request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", RemoteWriteVersion)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.pending, err = retainPending(w.pending, fmt.Errorf("failure sending remote-write request: %w", err))
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		w.pending = w.pending[:0]
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%w: %s: %s", ErrRemoteWrite, resp.Status, strings.TrimSpace(string(message)))
+	if !retryableStatus(resp.StatusCode) {
+		w.pending = w.pending[:0]
+		return err
+	}
+	w.pending, err = retainPending(w.pending, err)
+	return err
+}
+
+// Returns true if a request that failed with the HTTP status code may succeed
+// when it is sent again, because the endpoint is rate limiting requests or has
+// a server error.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// Returns the pending values to send again after err, with the oldest removed
+// so that no more than MaxPendingPoints remain, and err with the number of
+// values that were removed, if any.
+func retainPending[T any](pending []T, err error) ([]T, error) {
+	if len(pending) <= MaxPendingPoints {
+		return pending, err
+	}
+	dropped := len(pending) - MaxPendingPoints
+	return append(pending[:0], pending[dropped:]...), fmt.Errorf("%w; dropped %d oldest pending values", err, dropped)
+}
+
+// Returns a Prometheus series for each point in the request.
+func remoteWriteSeriesFromRequest(req *monitoringpb.CreateTimeSeriesRequest) ([]remoteWriteSeries, error) {
+	result := []remoteWriteSeries{}
+	for _, series := range req.GetTimeSeries() {
+		labels := remoteWriteLabels(series)
+		for _, point := range series.GetPoints() {
+			var value float64
+			switch v := typedValue(point.GetValue()).(type) {
+			case float64:
+				value = v
+			case int64:
+				value = float64(v)
+			case bool:
+				if v {
+					value = 1
+				}
+			default:
+				return nil, fmt.Errorf("%w: %s has a %T value", ErrUnsupportedRemoteWriteValue, series.GetMetric().GetType(), v)
+			}
+			result = append(result, remoteWriteSeries{
+				labels:    labels,
+				value:     value,
+				timestamp: point.GetInterval().GetEndTime().AsTime().UnixMilli(),
+			})
+		}
+	}
+	return result, nil
+}
+
+// Returns the Prometheus labels for the time-series, sorted by name as required
+// by the remote-write protocol.
+func remoteWriteLabels(series *monitoringpb.TimeSeries) [][2]string {
+	merged := map[string]string{}
+	for key, value := range series.GetResource().GetLabels() {
+		merged[prometheusName(key)] = value
+	}
+	for key, value := range series.GetMetric().GetLabels() {
+		merged[prometheusName(key)] = value
+	}
+	merged["__name__"] = prometheusName(series.GetMetric().GetType())
+	labels := make([][2]string, 0, len(merged))
+	for name, value := range merged {
+		labels = append(labels, [2]string{name, value})
+	}
+	slices.SortFunc(labels, func(a, b [2]string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	return labels
+}
+
+// Returns the name with every character that is not valid in a Prometheus
+// metric or label name replaced by an underscore, e.g.
+// "custom.googleapis.com/gce-metric" becomes "custom_googleapis_com_gce_metric".
+func prometheusName(name string) string {
+	var builder strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
+
+// Returns the series encoded as a prometheus.WriteRequest protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeRemoteWriteRequest(series []remoteWriteSeries) []byte {
+	var buf []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.labels {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, label[0])
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, label[1])
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp)) //nolint:gosec // Negative int64 values are encoded as two's complement varints
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	return buf
+}
@@ -0,0 +1,351 @@
+package pipeline //nolint:testpackage // These tests need access to the private remote-write encoding
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/golang/snappy"
+	"github.com/memes/gce-metric/pkg/generators"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A fake remote-write endpoint that decodes the samples it receives.
+func newFakeRemoteWriteServer(t *testing.T) *fakeHTTPServer[remoteWriteSeries] {
+	t.Helper()
+	return newFakeHTTPServer(t, func(_ *http.Request, compressed []byte) ([]remoteWriteSeries, error) {
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRemoteWriteRequest(body)
+	})
+}
+
+// Iterates over the length-delimited fields with the number in the message,
+// calling fn with the contents of each.
+func eachMessageField(b []byte, number protowire.Number, fn func([]byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if num == number && typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := fn(value); err != nil {
+				return err
+			}
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Decodes a prometheus.WriteRequest into a remoteWriteSeries for every sample.
+func decodeRemoteWriteRequest(body []byte) ([]remoteWriteSeries, error) {
+	result := []remoteWriteSeries{}
+	err := eachMessageField(body, 1, func(ts []byte) error {
+		labels := [][2]string{}
+		if err := eachMessageField(ts, 1, func(label []byte) error {
+			var name, value string
+			if err := eachMessageField(label, 1, func(b []byte) error {
+				name = string(b)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if err := eachMessageField(label, 2, func(b []byte) error {
+				value = string(b)
+				return nil
+			}); err != nil {
+				return err
+			}
+			labels = append(labels, [2]string{name, value})
+			return nil
+		}); err != nil {
+			return err
+		}
+		return eachMessageField(ts, 2, func(sample []byte) error {
+			series := remoteWriteSeries{labels: labels}
+			for len(sample) > 0 {
+				num, typ, n := protowire.ConsumeTag(sample)
+				if n < 0 {
+					return protowire.ParseError(n)
+				}
+				sample = sample[n:]
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					v, n := protowire.ConsumeFixed64(sample)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					series.value = math.Float64frombits(v)
+					sample = sample[n:]
+				case num == 2 && typ == protowire.VarintType:
+					v, n := protowire.ConsumeVarint(sample)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					series.timestamp = int64(v) //nolint:gosec // Timestamps are encoded as two's complement varints
+					sample = sample[n:]
+				default:
+					return protowire.ParseError(-1)
+				}
+			}
+			result = append(result, series)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func TestWithPrometheusRemoteWriteEmitterInvalid(t *testing.T) {
+	t.Parallel()
+	for _, endpoint := range []string{"", "mimir.example.com/api/v1/push", "grpc://mimir.example.com", "http://", "http://%zz"} {
+		t.Run(endpoint, func(t *testing.T) {
+			t.Parallel()
+			_, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithPrometheusRemoteWriteEmitter(endpoint))
+			if !errors.Is(err, ErrInvalidRemoteWriteURL) {
+				t.Errorf("Expected NewPipeline to raise %v, got %v", ErrInvalidRemoteWriteURL, err)
+			}
+		})
+	}
+}
+
+// The remote-write emitter does not need a Cloud Monitoring client, or the
+// credentials to create one.
+func TestPrometheusRemoteWriteEmitterWithoutMetricClient(t *testing.T) {
+	t.Parallel()
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithPrometheusRemoteWriteEmitter("http://mimir.example.com/api/v1/push"), withNewMetricClient(failNewMetricClient))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	if pipeline.client != nil {
+		t.Errorf("Expected no metric client to be created, got %v", pipeline.client)
+	}
+}
+
+func TestPrometheusRemoteWriteEmitter(t *testing.T) {
+	t.Parallel()
+	server := newFakeRemoteWriteServer(t)
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithMetricLabels(map[string]string{"test.key": "value"}), WithPrometheusRemoteWriteEmitter(server.URL+"/api/v1/push"))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	timestamp := time.Unix(1700000000, 0)
+	ctx := context.Background()
+	for i, value := range []float64{1.5, 2.5} {
+		req, err := pipeline.BuildRequest(generators.Metric{
+			Value:     value,
+			Timestamp: timestamp.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+		}
+		if err := pipeline.emitter(ctx, req); err != nil {
+			t.Fatalf("Unexpected error returned from emitter: %v", err)
+		}
+	}
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	requests, samples := server.received()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 remote-write requests, got %d", len(requests))
+	}
+	for name, expected := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": RemoteWriteVersion,
+	} {
+		if got := requests[0].Header.Get(name); got != expected {
+			t.Errorf("Expected header %s to be %q, got %q", name, expected, got)
+		}
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.value != []float64{1.5, 2.5}[i] {
+			t.Errorf("Expected sample %d value %v, got %v", i, []float64{1.5, 2.5}[i], sample.value)
+		}
+		if expected := timestamp.Add(time.Duration(i) * time.Minute).UnixMilli(); sample.timestamp != expected {
+			t.Errorf("Expected sample %d timestamp %d, got %d", i, expected, sample.timestamp)
+		}
+		if !slices.IsSortedFunc(sample.labels, func(a, b [2]string) int {
+			return strings.Compare(a[0], b[0])
+		}) {
+			t.Errorf("Expected sample %d labels to be sorted, got %v", i, sample.labels)
+		}
+		labels := map[string]string{}
+		for _, label := range sample.labels {
+			labels[label[0]] = label[1]
+		}
+		for name, expected := range map[string]string{
+			"__name__":   "custom_googleapis_com_gce_metric",
+			"test_key":   "value",
+			"project_id": testProjectID,
+		} {
+			if labels[name] != expected {
+				t.Errorf("Expected sample %d label %s to be %q, got %q", i, name, expected, labels[name])
+			}
+		}
+	}
+}
+
+// Samples that are rejected by the endpoint should be resent with the next
+// request, or when the pipeline is closed.
+func TestPrometheusRemoteWriteEmitterFlushesPending(t *testing.T) {
+	t.Parallel()
+	server := newFakeRemoteWriteServer(t)
+	server.setStatus(http.StatusServiceUnavailable)
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithPrometheusRemoteWriteEmitter(server.URL))
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     3.0,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+	}
+	if err := pipeline.emitter(context.Background(), req); !errors.Is(err, ErrRemoteWrite) {
+		t.Errorf("Expected emitter to raise %v, got %v", ErrRemoteWrite, err)
+	}
+	server.setStatus(0)
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	if _, samples := server.received(); len(samples) != 1 || samples[0].value != 3.0 {
+		t.Errorf("Expected the pending sample to be flushed on close, got %v", samples)
+	}
+	// Nothing is pending, so a second close should not send a request.
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Unexpected error returned from Close: %v", err)
+	}
+	if requests, _ := server.received(); len(requests) != 2 {
+		t.Errorf("Expected 2 remote-write requests, got %d", len(requests))
+	}
+}
+
+// Samples that are rejected with a client error should be dropped, and samples
+// that are rate limited or rejected with a server error should be resent.
+func TestPrometheusRemoteWriteEmitterRetryableStatus(t *testing.T) {
+	t.Parallel()
+	tests := map[int][]float64{
+		http.StatusBadRequest:          {4.0},
+		http.StatusTooManyRequests:     {3.0, 4.0},
+		http.StatusServiceUnavailable:  {3.0, 4.0},
+		http.StatusUnprocessableEntity: {4.0},
+	}
+	for code, expected := range tests {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			t.Parallel()
+			server := newFakeRemoteWriteServer(t)
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithPrometheusRemoteWriteEmitter(server.URL))
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			timestamp := time.Now()
+			for i, value := range []float64{3.0, 4.0} {
+				if i == 0 {
+					server.setStatus(code)
+				} else {
+					server.setStatus(0)
+				}
+				req, err := pipeline.BuildRequest(generators.Metric{
+					Value:     value,
+					Timestamp: timestamp.Add(time.Duration(i) * time.Minute),
+				})
+				if err != nil {
+					t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+				}
+				err = pipeline.emitter(context.Background(), req)
+				if i == 0 && !errors.Is(err, ErrRemoteWrite) {
+					t.Errorf("Expected emitter to raise %v, got %v", ErrRemoteWrite, err)
+				}
+				if i == 1 && err != nil {
+					t.Errorf("Unexpected error returned from emitter: %v", err)
+				}
+			}
+			_, samples := server.received()
+			values := make([]float64, 0, len(samples))
+			for _, sample := range samples {
+				values = append(values, sample.value)
+			}
+			if !slices.Equal(values, expected) {
+				t.Errorf("Expected samples %v, got %v", expected, values)
+			}
+		})
+	}
+}
+
+func TestRetainPending(t *testing.T) {
+	t.Parallel()
+	pending := make([]int, MaxPendingPoints+5)
+	for i := range pending {
+		pending[i] = i
+	}
+	retained, err := retainPending(pending, ErrRemoteWrite)
+	if !errors.Is(err, ErrRemoteWrite) {
+		t.Errorf("Expected retainPending to wrap %v, got %v", ErrRemoteWrite, err)
+	}
+	if len(retained) != MaxPendingPoints || retained[0] != 5 || retained[len(retained)-1] != MaxPendingPoints+4 {
+		t.Errorf("Expected the %d newest values to be retained, got %d starting at %d", MaxPendingPoints, len(retained), retained[0])
+	}
+	retained, err = retainPending([]int{1, 2}, ErrRemoteWrite)
+	if err != ErrRemoteWrite || len(retained) != 2 { //nolint:errorlint // The error should be returned unchanged
+		t.Errorf("Expected pending values and error to be unchanged, got %v, %v", retained, err)
+	}
+}
+
+func TestPrometheusRemoteWriteEmitterUnsupportedValue(t *testing.T) {
+	t.Parallel()
+	_, err := remoteWriteSeriesFromRequest(&monitoringpb.CreateTimeSeriesRequest{
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Points: []*monitoringpb.Point{
+					{
+						Value: &monitoringpb.TypedValue{
+							Value: &monitoringpb.TypedValue_StringValue{StringValue: "test"},
+						},
+					},
+				},
+			},
+		},
+	})
+	if !errors.Is(err, ErrUnsupportedRemoteWriteValue) {
+		t.Errorf("Expected %v, got %v", ErrUnsupportedRemoteWriteValue, err)
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	t.Parallel()
+	for name, expected := range map[string]string{
+		"custom.googleapis.com/gce-metric": "custom_googleapis_com_gce_metric",
+		"instance_id":                      "instance_id",
+		"9lives":                           "_lives",
+		"workload.googleapis.com/a:b":      "workload_googleapis_com_a_b",
+	} {
+		if got := prometheusName(name); got != expected {
+			t.Errorf("Expected prometheusName(%q) to be %q, got %q", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+// Describes a monitored resource type that can be attached to time-series by
+// one of the resource transformers, and the label keys that must be provided
+// for it.
+type ResourceType struct {
+	Type   string
+	Labels []string
+}
+
+// Returns the monitored resource types supported by the resource transformers,
+// sorted by type. The label keys are listed in the order they appear in the
+// Cloud Monitoring documentation for the resource.
+func ResourceTypes() []ResourceType {
+	return []ResourceType{
+		{
+			Type:   "gce_instance",
+			Labels: []string{"project_id", "instance_id", "zone"},
+		},
+		{
+			Type:   "generic_node",
+			Labels: []string{"project_id", "location", "namespace", "node_id"},
+		},
+		{
+			Type:   "gke_container",
+			Labels: []string{"project_id", "cluster_name", "namespace_id", "instance_id", "pod_id", "container_name", "zone"},
+		},
+		{
+			Type:   GlobalResourceType,
+			Labels: []string{"project_id"},
+		},
+		{
+			Type:   "k8s_cluster",
+			Labels: []string{"project_id", "location", "cluster_name"},
+		},
+		{
+			Type:   "k8s_container",
+			Labels: []string{"project_id", "location", "cluster_name", "namespace_name", "pod_name", "container_name"},
+		},
+		{
+			Type:   "k8s_node",
+			Labels: []string{"project_id", "location", "cluster_name", "node_name"},
+		},
+		{
+			Type:   "k8s_pod",
+			Labels: []string{"project_id", "location", "cluster_name", "namespace_name", "pod_name"},
+		},
+	}
+}
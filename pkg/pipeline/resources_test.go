@@ -0,0 +1,61 @@
+package pipeline_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/memes/gce-metric/pkg/generators"
+	"github.com/memes/gce-metric/pkg/pipeline"
+)
+
+// Every monitored resource transformer must have a matching entry in the
+// ResourceTypes table, with the same set of label keys, and every entry in the
+// table must be produced by a transformer.
+func TestResourceTypes(t *testing.T) {
+	t.Parallel()
+	transformers := []pipeline.Transformer{
+		pipeline.NewGenericMonitoredResourceTransformer(project, location, namespace, node),
+		pipeline.NewGCEMonitoredResourceTransformer(project, instance, zone),
+		pipeline.NewGlobalMonitoredResourceTransformer(project),
+		pipeline.NewGKEMonitoredResourceTransformer(project, clusterName, namespace, instance, pod, containerName, zone),
+		pipeline.NewGenericKubernetesClusterMonitoredResourceTransformer(project, location, clusterName),
+		pipeline.NewGenericKubernetesContainerMonitoredResourceTransformer(project, location, clusterName, namespace, pod, containerName),
+		pipeline.NewGenericKubernetesNodeMonitoredResourceTransformer(project, location, clusterName, node),
+		pipeline.NewGenericKubernetesPodMonitoredResourceTransformer(project, location, clusterName, namespace, pod),
+	}
+	table := map[string][]string{}
+	for _, resourceType := range pipeline.ResourceTypes() {
+		if _, ok := table[resourceType.Type]; ok {
+			t.Errorf("Resource type %q is listed more than once", resourceType.Type)
+		}
+		table[resourceType.Type] = resourceType.Labels
+	}
+	seen := map[string]struct{}{}
+	for _, transformer := range transformers {
+		req := &monitoringpb.CreateTimeSeriesRequest{
+			TimeSeries: []*monitoringpb.TimeSeries{{}},
+		}
+		if err := transformer(req, generators.Metric{}); err != nil {
+			t.Fatalf("Transformer raised an unexpected exception: %v", err)
+		}
+		resource := req.TimeSeries[0].GetResource()
+		seen[resource.GetType()] = struct{}{}
+		labels, ok := table[resource.GetType()]
+		if !ok {
+			t.Errorf("Resource type %q is missing from ResourceTypes", resource.GetType())
+			continue
+		}
+		expected := slices.Sorted(slices.Values(labels))
+		actual := slices.Sorted(maps.Keys(resource.GetLabels()))
+		if !slices.Equal(expected, actual) {
+			t.Errorf("Resource type %q labels do not match: expected %v, got %v", resource.GetType(), expected, actual)
+		}
+	}
+	for resourceType := range table {
+		if _, ok := seen[resourceType]; !ok {
+			t.Errorf("Resource type %q is not produced by any transformer", resourceType)
+		}
+	}
+}
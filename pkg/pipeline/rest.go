@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// The Cloud Monitoring REST endpoint used by the REST emitter when the
+	// pipeline does not have an explicit or regional endpoint.
+	DefaultRESTEndpoint = "https://monitoring.googleapis.com/"
+	// The OAuth2 scope required to write time-series.
+	monitoringWriteScope = "https://www.googleapis.com/auth/monitoring.write"
+)
+
+// Holds the authenticated HTTP client used by the REST emitter, which is created
+// when the first request is sent so that it uses the client options of the
+// finished pipeline.
+type restWriter struct {
+	mu       sync.Mutex
+	client   *http.Client
+	endpoint string
+}
+
+// Send time-series to Google Cloud Monitoring with the JSON REST API over
+// HTTP/1.1, instead of gRPC, for networks that block HTTP/2 traffic to Google
+// APIs. The endpoint, user-agent, and credentials of the pipeline's client
+// options are used, but options that only apply to gRPC connections, such as
+// WithProxy, WithCACertFile, and WithKeepalive, are ignored; the HTTPS_PROXY
+// environment variable is respected instead.
+//
+// Unlike the default emitter, the REST emitter does not create the metric
+// descriptor, and Cloud Monitoring will create one from the first time-series
+// written.
+func WithRESTTransport() Option {
+	return func(p *Pipeline) error {
+		writer := &restWriter{
+			mu:       sync.Mutex{},
+			client:   nil,
+			endpoint: "",
+		}
+		p.emitterPointInterval = MinimumPointInterval
+		p.emitter = func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+			p.logger.V(2).Info("Emitting time-series request to GCP with REST")
+			method := ""
+			if p.serviceTimeSeries {
+				method = ":createService"
+			}
+			var errs []error
+			for _, batch := range splitRequest(req, p.batchSize) {
+				if err := writer.write(ctx, batch, method, p.clientOptions); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				p.recordLastPoints(batch)
+			}
+			if err := errors.Join(errs...); err != nil {
+				return err
+			}
+			p.logWrittenPoints(1, req)
+			return nil
+		}
+		return nil
+	}
+}
+
+// Send the time-series in the request to the REST method, which is empty for
+// timeSeries.create or ":createService" for timeSeries.createService, creating the HTTP
+// client from the options if this is the first request.
+func (w *restWriter) write(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest, method string, options []option.ClientOption) error {
+	client, endpoint, err := w.httpClient(options)
+	if err != nil {
+		return err
+	}
+	body, err := protojson.Marshal(&monitoringpb.CreateTimeSeriesRequest{
+		TimeSeries: req.GetTimeSeries(),
+	})
+	if err != nil {
+		return fmt.Errorf("failure marshaling time-series request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"v3/"+req.GetName()+"/timeSeries"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failure creating REST time-series request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failure sending REST time-series request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %s: %s", ErrRESTWrite, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Returns the HTTP client and the endpoint URL, with a trailing slash, creating
+// them from the options if necessary. The client is not bound to the context of
+// a request, as it must outlive the request to refresh credentials.
+func (w *restWriter) httpClient(options []option.ClientOption) (*http.Client, string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != nil {
+		return w.client, w.endpoint, nil
+	}
+	options = append([]option.ClientOption{option.WithScopes(monitoringWriteScope)}, options...)
+	client, endpoint, err := htransport.NewClient(context.Background(), options...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failure creating REST client: %w", err)
+	}
+	w.client = client
+	w.endpoint = restEndpoint(endpoint)
+	return w.client, w.endpoint, nil
+}
+
+// Returns the endpoint as an HTTPS URL with a trailing slash; endpoints given as
+// a host and port for gRPC, e.g. monitoring.europe-west3.rep.googleapis.com:443,
+// are accepted.
+func restEndpoint(endpoint string) string {
+	switch {
+	case endpoint == "":
+		return DefaultRESTEndpoint
+	case !strings.Contains(endpoint, "://"):
+		endpoint = "https://" + endpoint
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return endpoint
+}
@@ -0,0 +1,141 @@
+package pipeline //nolint:testpackage // These tests need access to the private emitter and client options
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/memes/gce-metric/pkg/generators"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var errUnexpectedRESTRequest = errors.New("unexpected REST request")
+
+// A fake Cloud Monitoring REST endpoint that decodes the time-series request in
+// the body of each JSON POST.
+func newFakeRESTServer(t *testing.T) *fakeHTTPServer[*monitoringpb.CreateTimeSeriesRequest] {
+	t.Helper()
+	return newFakeHTTPServer(t, func(r *http.Request, body []byte) ([]*monitoringpb.CreateTimeSeriesRequest, error) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			return nil, errUnexpectedRESTRequest
+		}
+		var req monitoringpb.CreateTimeSeriesRequest
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		return []*monitoringpb.CreateTimeSeriesRequest{&req}, nil
+	})
+}
+
+// Send requests without credentials, as the fake server does not need them.
+func withoutAuthentication() Option {
+	return func(p *Pipeline) error {
+		p.clientOptions = append(p.clientOptions, option.WithoutAuthentication())
+		return nil
+	}
+}
+
+func TestRESTTransport(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		serviceTimeSeries bool
+		expectedPath      string
+	}{
+		{
+			name:         "create",
+			expectedPath: "/v3/projects/" + testProjectID + "/timeSeries",
+		},
+		{
+			name:              "createService",
+			serviceTimeSeries: true,
+			expectedPath:      "/v3/projects/" + testProjectID + "/timeSeries:createService",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeRESTServer(t)
+			pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithEndpoint(server.URL), withoutAuthentication(), WithServiceTimeSeries(tst.serviceTimeSeries), WithRESTTransport())
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+			}
+			defer pipeline.Close()
+			req, err := pipeline.BuildRequest(generators.Metric{
+				Value:     2.5,
+				Timestamp: time.Unix(1700000000, 0),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+			}
+			if err := pipeline.emitter(context.Background(), req); err != nil {
+				t.Fatalf("Unexpected error returned from emitter: %v", err)
+			}
+			received, requests := server.received()
+			if len(received) != 1 || len(requests) != 1 {
+				t.Fatalf("Expected 1 REST request, got %d", len(received))
+			}
+			if path := received[0].URL.Path; path != tst.expectedPath {
+				t.Errorf("Expected request path %q, got %q", tst.expectedPath, path)
+			}
+			series := requests[0].GetTimeSeries()
+			if len(series) != 1 || len(series[0].GetPoints()) != 1 {
+				t.Fatalf("Expected 1 time-series with 1 point, got %v", series)
+			}
+			if got := series[0].GetMetric().GetType(); got != req.GetTimeSeries()[0].GetMetric().GetType() {
+				t.Errorf("Expected metric type %q, got %q", req.GetTimeSeries()[0].GetMetric().GetType(), got)
+			}
+			point := series[0].GetPoints()[0]
+			if got := point.GetValue().GetDoubleValue(); got != 2.5 {
+				t.Errorf("Expected value 2.5, got %v", got)
+			}
+			if got := point.GetInterval().GetEndTime().GetSeconds(); got != 1700000000 {
+				t.Errorf("Expected end time 1700000000, got %d", got)
+			}
+		})
+	}
+}
+
+func TestRESTTransportError(t *testing.T) {
+	t.Parallel()
+	server := newFakeRESTServer(t)
+	server.setStatus(http.StatusBadRequest)
+	pipeline, err := newNonGCPTestPipeline(t, WithProjectID(testProjectID), WithEndpoint(server.URL), withoutAuthentication(), WithRESTTransport())
+	if err != nil {
+		t.Fatalf("Unexpected error returned from NewPipeline: %v", err)
+	}
+	defer pipeline.Close()
+	req, err := pipeline.BuildRequest(generators.Metric{
+		Value:     1.0,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error returned from BuildRequest: %v", err)
+	}
+	if err := pipeline.emitter(context.Background(), req); !errors.Is(err, ErrRESTWrite) {
+		t.Errorf("Expected emitter to raise %v, got %v", ErrRESTWrite, err)
+	}
+}
+
+func TestRESTEndpoint(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"":                                   DefaultRESTEndpoint,
+		"monitoring.googleapis.com:443":      "https://monitoring.googleapis.com:443/",
+		"https://monitoring.example.com":     "https://monitoring.example.com/",
+		"http://127.0.0.1:8080/":             "http://127.0.0.1:8080/",
+		"https://monitoring.example.com/v1/": "https://monitoring.example.com/v1/",
+	}
+	for endpoint, expected := range tests {
+		t.Run(endpoint, func(t *testing.T) {
+			t.Parallel()
+			if got := restEndpoint(endpoint); got != expected {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+		})
+	}
+}
@@ -2,106 +2,183 @@ package pipeline
 
 import (
 	"errors"
+	"fmt"
+	"maps"
 	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"github.com/memes/gce-metric/pkg/generators"
+	distributionpb "google.golang.org/genproto/googleapis/api/distribution"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
 	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var ErrNilCreateTimeSeriesRequest = errors.New("transformer received nil as CreateTimeSeriesRequest")
+// The metric label key used by Google's OpenCensus and OpenTelemetry exporters to
+// identify the process writing a time-series.
+const WriterIdentityLabel = "opencensus_task"
+
+// The metric label key used to tag time-series with the version of a deployment.
+const DeployVersionLabel = "deploy_version"
+
+// The maximum length of a metric or resource label key accepted by Google Cloud
+// Monitoring.
+const MaxLabelKeyLength = 100
+
+var (
+	ErrNilCreateTimeSeriesRequest = errors.New("transformer received nil as CreateTimeSeriesRequest")
+	ErrNonFiniteValue             = errors.New("metric value must be a finite number")
+	ErrUnsupportedValueType       = errors.New("value type is not supported for metric kind")
+	ErrInvalidLabelKey            = errors.New("label key is not valid for Google Cloud Monitoring")
+	ErrInvalidValueBuckets        = errors.New("value bucket thresholds must be strictly increasing, with one more label than thresholds")
+)
+
+// Matches the label keys accepted by Google Cloud Monitoring.
+var labelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 
 // Defines a function that mutates a monitoring CreateTimeSeriesRequest object
-// using the supplied moment-in-time Metric object.
+// using the supplied moment-in-time Metric object. Label maps and monitored
+// resources may be shared between requests, so a Transformer must replace them
+// rather than modify them in place.
 type Transformer func(*monitoringpb.CreateTimeSeriesRequest, generators.Metric) error
 
-// Returns a Transformer that will insert a generic_node resource into each
-// time-series value.
-func NewGenericMonitoredResourceTransformer(projectID, location, namespace, nodeID string) Transformer {
+// Returns a Transformer that sets the monitored resource of each time-series to
+// resource. The resource is built once and shared by every request, as it does
+// not change between points; transformers must not modify a resource in place.
+func newResourceTransformer(resource *monitoredrespb.MonitoredResource) Transformer {
 	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
 		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "generic_node",
-				Labels: map[string]string{
-					"project_id": projectID,
-					"location":   location,
-					"namespace":  namespace,
-					"node_id":    nodeID,
-				},
-			}
+			series.Resource = resource
 		}
 		return nil
 	}
 }
 
+// Returns a Transformer that will insert a generic_node resource into each
+// time-series value.
+func NewGenericMonitoredResourceTransformer(projectID, location, namespace, nodeID string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "generic_node",
+		Labels: map[string]string{
+			"project_id": projectID,
+			"location":   location,
+			"namespace":  namespace,
+			"node_id":    nodeID,
+		},
+	})
+}
+
 // Returns a Transformer that will insert a gce_instance resource into each
 // time-series value.
 func NewGCEMonitoredResourceTransformer(projectID, instanceID, zone string) Transformer {
-	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
-		if req == nil {
-			return ErrNilCreateTimeSeriesRequest
-		}
-		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "gce_instance",
-				Labels: map[string]string{
-					"project_id":  projectID,
-					"instance_id": instanceID,
-					"zone":        zone,
-				},
-			}
-		}
-		return nil
-	}
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "gce_instance",
+		Labels: map[string]string{
+			"project_id":  projectID,
+			"instance_id": instanceID,
+			"zone":        zone,
+		},
+	})
+}
+
+// Returns a Transformer that will insert a global resource into each time-series
+// value. The global resource is suitable for project-scoped metrics that are not
+// associated with a specific instance or node.
+func NewGlobalMonitoredResourceTransformer(projectID string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "global",
+		Labels: map[string]string{
+			"project_id": projectID,
+		},
+	})
 }
 
 // Returns a Transformer that will insert a gke_container resource into each
 // time-series value.
 func NewGKEMonitoredResourceTransformer(projectID, clusterName, namespaceID, instanceID, podID, containerName, zone string) Transformer {
-	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "gke_container",
+		Labels: map[string]string{
+			"project_id":     projectID,
+			"cluster_name":   clusterName,
+			"namespace_id":   namespaceID,
+			"instance_id":    instanceID,
+			"pod_id":         podID,
+			"container_name": containerName,
+			"zone":           zone,
+		},
+	})
+}
+
+// Returns a Transformer that replaces each time-series with one copy per
+// resource transformer, with the monitored resource set by that transformer, so
+// that the same values are written under several resource types at once; e.g.
+// to compare how a signal appears when attributed to a gce_instance or to a
+// generic_node resource. The copies are added to the request in the order of the
+// resource transformers.
+func NewMultiResourceTransformer(resourceTransformers ...Transformer) Transformer {
+	resourceTransformers = slices.Clone(resourceTransformers)
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
+		if len(resourceTransformers) == 0 {
+			return nil
+		}
+		timeSeries := make([]*monitoringpb.TimeSeries, 0, len(req.TimeSeries)*len(resourceTransformers))
 		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "gke_container",
-				Labels: map[string]string{
-					"project_id":     projectID,
-					"cluster_name":   clusterName,
-					"namespace_id":   namespaceID,
-					"instance_id":    instanceID,
-					"pod_id":         podID,
-					"container_name": containerName,
-					"zone":           zone,
-				},
+			for _, transformer := range resourceTransformers {
+				clone, ok := proto.Clone(series).(*monitoringpb.TimeSeries)
+				if !ok {
+					continue
+				}
+				sub := &monitoringpb.CreateTimeSeriesRequest{
+					Name:       req.Name,
+					TimeSeries: []*monitoringpb.TimeSeries{clone},
+				}
+				if err := transformer(sub, metric); err != nil {
+					return err
+				}
+				timeSeries = append(timeSeries, sub.TimeSeries...)
 			}
 		}
+		req.TimeSeries = timeSeries
 		return nil
 	}
 }
 
 // Returns a Transformer that replaces the time-series point-in-time record with
-// the embedded value in metric.
+// the embedded value in metric. NaN and infinite values will be rejected with
+// ErrNonFiniteValue. The interval of the point depends on the metric kind of the
+// time-series; see intervalTracker for details.
 func NewDoubleTypedValueTransformer() Transformer {
+	tracker := newIntervalTracker()
 	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
+		if err := checkFinite(metric.Value); err != nil {
+			return err
+		}
+		intervals := tracker.next(metric.Timestamp)
 		for _, series := range req.TimeSeries {
 			series.Points = []*monitoringpb.Point{
 				{
-					Interval: &monitoringpb.TimeInterval{
-						StartTime: &timestamppb.Timestamp{
-							Seconds: metric.Timestamp.Unix(),
-						},
-						EndTime: &timestamppb.Timestamp{
-							Seconds: metric.Timestamp.Unix(),
-						},
-					},
+					Interval: intervals.forKind(series.MetricKind),
 					Value: &monitoringpb.TypedValue{
 						Value: &monitoringpb.TypedValue_DoubleValue{
 							DoubleValue: metric.Value,
@@ -115,26 +192,37 @@ func NewDoubleTypedValueTransformer() Transformer {
 }
 
 // Returns a Transformer that replaces the time-series point-in-time record with
-// the embedded value in metric after rounding to the nearest integer.
+// the embedded value in metric after rounding to the nearest integer, with
+// halves rounded away from zero. Finite values outside the range of an int64 are
+// clamped to math.MaxInt64 or math.MinInt64; NaN and infinite values will be
+// rejected with ErrNonFiniteValue.
 func NewIntegerTypedValueTransformer() Transformer {
+	return NewIntegerTypedValueTransformerWithLogger(logr.Discard())
+}
+
+// Returns a Transformer that behaves like NewIntegerTypedValueTransformer, and
+// logs a warning to logger when a value is clamped to the range of an int64.
+func NewIntegerTypedValueTransformerWithLogger(logger logr.Logger) Transformer {
+	tracker := newIntervalTracker()
 	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
+		if err := checkFinite(metric.Value); err != nil {
+			return err
+		}
+		value, clamped := saturatingInt64(math.Round(metric.Value))
+		if clamped {
+			logger.V(0).Info("Metric value is outside the range of int64; clamping value", "value", metric.Value, "clamped", value)
+		}
+		intervals := tracker.next(metric.Timestamp)
 		for _, series := range req.TimeSeries {
 			series.Points = []*monitoringpb.Point{
 				{
-					Interval: &monitoringpb.TimeInterval{
-						StartTime: &timestamppb.Timestamp{
-							Seconds: metric.Timestamp.Unix(),
-						},
-						EndTime: &timestamppb.Timestamp{
-							Seconds: metric.Timestamp.Unix(),
-						},
-					},
+					Interval: intervals.forKind(series.MetricKind),
 					Value: &monitoringpb.TypedValue{
 						Value: &monitoringpb.TypedValue_Int64Value{
-							Int64Value: int64(math.Round(metric.Value)),
+							Int64Value: value,
 						},
 					},
 				},
@@ -144,44 +232,192 @@ func NewIntegerTypedValueTransformer() Transformer {
 	}
 }
 
-// Returns a Transformer that will insert a k8s_cluster resource into each
-// time-series value.
-func NewGenericKubernetesClusterMonitoredResourceTransformer(projectID, location, clusterName string) Transformer {
+// Tracks the start of the point intervals written by a value transformer, so
+// that each point has an interval appropriate for the metric kind of the
+// time-series:
+//   - GAUGE points only have an end time, which is the metric timestamp.
+//   - CUMULATIVE points share a fixed start time, one second before the first
+//     metric timestamp.
+//   - DELTA points start at the end of the previous point, so that consecutive
+//     points cover adjacent windows; the first point starts one second before
+//     its end time.
+type intervalTracker struct {
+	mu       sync.Mutex
+	start    time.Time
+	previous time.Time
+}
+
+func newIntervalTracker() *intervalTracker {
+	return &intervalTracker{
+		mu:       sync.Mutex{},
+		start:    time.Time{},
+		previous: time.Time{},
+	}
+}
+
+// The intervals that a single metric timestamp may be written with, depending on
+// the metric kind of the time-series.
+type pointIntervals struct {
+	cumulativeStart time.Time
+	deltaStart      time.Time
+	end             time.Time
+}
+
+// Returns the intervals for a metric with the timestamp, and records the
+// timestamp as the end of the latest window.
+func (t *intervalTracker) next(timestamp time.Time) pointIntervals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.start.IsZero() {
+		t.start = timestamp.Add(-time.Second)
+	}
+	deltaStart := t.previous
+	if deltaStart.IsZero() || !deltaStart.Before(timestamp) {
+		deltaStart = timestamp.Add(-time.Second)
+	}
+	t.previous = timestamp
+	return pointIntervals{
+		cumulativeStart: t.start,
+		deltaStart:      deltaStart,
+		end:             timestamp,
+	}
+}
+
+// Returns the TimeInterval for a point in a time-series of the metric kind. An
+// unspecified metric kind is treated as GAUGE.
+func (i pointIntervals) forKind(kind metricpb.MetricDescriptor_MetricKind) *monitoringpb.TimeInterval {
+	interval := &monitoringpb.TimeInterval{
+		StartTime: nil,
+		EndTime:   timestamppb.New(i.end),
+	}
+	switch kind {
+	case metricpb.MetricDescriptor_CUMULATIVE:
+		interval.StartTime = timestamppb.New(i.cumulativeStart)
+	case metricpb.MetricDescriptor_DELTA:
+		interval.StartTime = timestamppb.New(i.deltaStart)
+	case metricpb.MetricDescriptor_GAUGE, metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED:
+	}
+	return interval
+}
+
+// Returns a Transformer that sets the start time of the points in each CUMULATIVE
+// time-series to start, which must be before the end time of every point. It
+// must be added after the transformer that sets the point values.
+func NewCumulativeStartTimeTransformer(start time.Time) Transformer {
 	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
 		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "k8s_cluster",
-				Labels: map[string]string{
-					"project_id":   projectID,
-					"location":     location,
-					"cluster_name": clusterName,
-				},
+			if series.GetMetricKind() != metricpb.MetricDescriptor_CUMULATIVE {
+				continue
+			}
+			for _, point := range series.GetPoints() {
+				if point.GetInterval() == nil {
+					continue
+				}
+				if !start.Before(point.GetInterval().GetEndTime().AsTime()) {
+					return fmt.Errorf("%w: %s is not before %s", ErrInvalidCumulativeStartTime, start.Format(time.RFC3339), point.GetInterval().GetEndTime().AsTime().Format(time.RFC3339))
+				}
+				point.Interval.StartTime = timestamppb.New(start)
 			}
 		}
 		return nil
 	}
 }
 
-// Returns a Transformer that will insert a k8s_container resource into each
-// time-series value.
-func NewGenericKubernetesContainerMonitoredResourceTransformer(projectID, location, clusterName, namespaceID, podID, containerName string) Transformer {
+// Returns a Transformer that verifies the value type of every time-series point
+// is supported by the metric kind of the time-series, so that misconfigurations
+// are reported before the request is sent. Google Cloud Monitoring accepts any
+// value type for GAUGE metrics, but DELTA and CUMULATIVE metrics must have
+// INT64, DOUBLE, or DISTRIBUTION values. Time-series without a metric kind or
+// value are not checked.
+func NewMetricKindValueTypeTransformer() Transformer {
 	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
 		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "k8s_container",
-				Labels: map[string]string{
-					"project_id":     projectID,
-					"location":       location,
-					"cluster_name":   clusterName,
-					"namespace_name": namespaceID,
-					"pod_name":       podID,
-					"container_name": containerName,
+			kind := series.GetMetricKind()
+			valueType := pointValueType(series)
+			if !supportsValueType(kind, valueType) {
+				return fmt.Errorf("%w: %s metric %q cannot have %s values", ErrUnsupportedValueType, kind, series.GetMetric().GetType(), valueType)
+			}
+		}
+		return nil
+	}
+}
+
+// Returns true if Google Cloud Monitoring accepts points of the value type for
+// metrics of the kind, or if either is unspecified.
+func supportsValueType(kind metricpb.MetricDescriptor_MetricKind, valueType metricpb.MetricDescriptor_ValueType) bool {
+	switch {
+	case valueType == metricpb.MetricDescriptor_VALUE_TYPE_UNSPECIFIED:
+		return true
+	case kind == metricpb.MetricDescriptor_DELTA || kind == metricpb.MetricDescriptor_CUMULATIVE:
+		return valueType == metricpb.MetricDescriptor_INT64 || valueType == metricpb.MetricDescriptor_DOUBLE || valueType == metricpb.MetricDescriptor_DISTRIBUTION
+	default:
+		return true
+	}
+}
+
+// Returns an error if the value is NaN or infinite, which Google Cloud Monitoring
+// will reject.
+func checkFinite(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: %v", ErrNonFiniteValue, value)
+	}
+	return nil
+}
+
+// Converts the value to an int64, saturating at math.MaxInt64 and math.MinInt64
+// instead of overflowing. The second return value is true if the value was
+// clamped.
+func saturatingInt64(value float64) (int64, bool) {
+	switch {
+	// float64(math.MaxInt64) rounds up to 2^63, which does not fit in an int64.
+	case value >= math.MaxInt64:
+		return math.MaxInt64, true
+	case value < math.MinInt64:
+		return math.MinInt64, true
+	default:
+		return int64(value), false
+	}
+}
+
+// Returns a Transformer that replaces the time-series point-in-time record with
+// a distribution containing the single embedded value in metric, counted in the
+// appropriate bucket of options.
+func NewDistributionTypedValueTransformer(options *distributionpb.Distribution_BucketOptions) Transformer {
+	tracker := newIntervalTracker()
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		if options == nil {
+			return ErrNilBucketOptions
+		}
+		if err := checkFinite(metric.Value); err != nil {
+			return err
+		}
+		count, index := bucketIndex(options, metric.Value)
+		intervals := tracker.next(metric.Timestamp)
+		for _, series := range req.TimeSeries {
+			bucketCounts := make([]int64, count)
+			bucketCounts[index] = 1
+			series.Points = []*monitoringpb.Point{
+				{
+					Interval: intervals.forKind(series.MetricKind),
+					Value: &monitoringpb.TypedValue{
+						Value: &monitoringpb.TypedValue_DistributionValue{
+							DistributionValue: &distributionpb.Distribution{
+								Count:         1,
+								Mean:          metric.Value,
+								BucketOptions: options,
+								BucketCounts:  bucketCounts,
+							},
+						},
+					},
 				},
 			}
 		}
@@ -189,47 +425,521 @@ func NewGenericKubernetesContainerMonitoredResourceTransformer(projectID, locati
 	}
 }
 
+// Returns a Transformer that will insert a k8s_cluster resource into each
+// time-series value.
+func NewGenericKubernetesClusterMonitoredResourceTransformer(projectID, location, clusterName string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "k8s_cluster",
+		Labels: map[string]string{
+			"project_id":   projectID,
+			"location":     location,
+			"cluster_name": clusterName,
+		},
+	})
+}
+
+// Returns a Transformer that will insert a k8s_container resource into each
+// time-series value.
+func NewGenericKubernetesContainerMonitoredResourceTransformer(projectID, location, clusterName, namespaceID, podID, containerName string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "k8s_container",
+		Labels: map[string]string{
+			"project_id":     projectID,
+			"location":       location,
+			"cluster_name":   clusterName,
+			"namespace_name": namespaceID,
+			"pod_name":       podID,
+			"container_name": containerName,
+		},
+	})
+}
+
 // Returns a Transformer that will insert a k8s_node resource into each
 // time-series value.
 func NewGenericKubernetesNodeMonitoredResourceTransformer(projectID, location, clusterName, nodeName string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "k8s_node",
+		Labels: map[string]string{
+			"project_id":   projectID,
+			"location":     location,
+			"cluster_name": clusterName,
+			"node_name":    nodeName,
+		},
+	})
+}
+
+// Returns a Transformer that will insert a k8s_pod resource into each time-series
+// value.
+func NewGenericKubernetesPodMonitoredResourceTransformer(projectID, location, clusterName, namespaceID, podID string) Transformer {
+	return newResourceTransformer(&monitoredrespb.MonitoredResource{
+		Type: "k8s_pod",
+		Labels: map[string]string{
+			"project_id":     projectID,
+			"location":       location,
+			"cluster_name":   clusterName,
+			"namespace_name": namespaceID,
+			"pod_name":       podID,
+		},
+	})
+}
+
+// Returns a Transformer that will add a metric label with the supplied key and
+// fixed value to each time-series, e.g. to tag every point with the hostname of
+// the machine running the generator.
+func NewMetricLabelTransformer(key, value string) Transformer {
 	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
 		for _, series := range req.TimeSeries {
-			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "k8s_node",
-				Labels: map[string]string{
-					"project_id":   projectID,
-					"location":     location,
-					"cluster_name": clusterName,
-					"node_name":    nodeName,
-				},
+			if series.Metric == nil {
+				continue
 			}
+			// The labels map may be shared with other requests; copy before
+			// adding the label.
+			labels := make(map[string]string, len(series.Metric.Labels)+1)
+			maps.Copy(labels, series.Metric.Labels)
+			labels[key] = value
+			series.Metric.Labels = labels
 		}
 		return nil
 	}
 }
 
-// Returns a Transformer that will insert a k8s_pod resource into each time-series
-// value.
-func NewGenericKubernetesPodMonitoredResourceTransformer(projectID, location, clusterName, namespaceID, podID string) Transformer {
+// Returns an identity for this process that is unique among concurrent writers,
+// in the form go-PID-RANDOM@HOSTNAME. The random component distinguishes
+// processes in containers that share a PID and hostname.
+var writerIdentity = sync.OnceValue(func() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+	return fmt.Sprintf("go-%d-%s@%s", os.Getpid(), uuid.NewString()[:8], hostname)
+})
+
+// Returns a Transformer that will add the WriterIdentityLabel metric label to
+// each time-series, with a value that is unique to this process and the same for
+// every point it writes. When several generators write the same metric and
+// resource, the label keeps their points in separate time-series, avoiding the
+// error that points were written more frequently than the maximum sampling
+// period.
+func NewWriterIdentityLabelTransformer() Transformer {
+	return NewMetricLabelTransformer(WriterIdentityLabel, writerIdentity())
+}
+
+// Returns a Transformer that will add the DeployVersionLabel metric label to each
+// time-series with the supplied version, e.g. a git commit or release tag, so
+// that synthetic load can be correlated with deployments.
+func NewDeployVersionLabelTransformer(version string) Transformer {
+	return NewMetricLabelTransformer(DeployVersionLabel, version)
+}
+
+// Returns a Transformer that will add a metric label with the supplied key to
+// each time-series, with a value that is incremented on every call, starting at
+// zero. Gaps in the sequence of received values indicate lost points.
+//
+// NOTE: Every unique label value creates a new time-series in Cloud Monitoring,
+// so this should only be used for short debugging sessions.
+func NewSequenceLabelTransformer(key string) Transformer {
+	var sequence atomic.Uint64
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		return NewMetricLabelTransformer(key, strconv.FormatUint(sequence.Add(1)-1, 10))(req, metric)
+	}
+}
+
+// Returns a Transformer that will copy the resource labels with the supplied keys
+// to the metric labels of each time-series, so that the values are available
+// when aggregating across resources. Keys that are not present in the resource
+// labels are ignored.
+func NewPromoteResourceLabelsTransformer(keys []string) Transformer {
 	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
 		if req == nil {
 			return ErrNilCreateTimeSeriesRequest
 		}
 		for _, series := range req.TimeSeries {
+			if series.Metric == nil || series.Resource == nil {
+				continue
+			}
+			// The labels map may be shared with other requests; copy before
+			// adding the promoted labels.
+			labels := make(map[string]string, len(series.Metric.Labels)+len(keys))
+			maps.Copy(labels, series.Metric.Labels)
+			for _, key := range keys {
+				if value, ok := series.Resource.Labels[key]; ok {
+					labels[key] = value
+				}
+			}
+			series.Metric.Labels = labels
+		}
+		return nil
+	}
+}
+
+// Returns a Transformer that will add the supplied labels to the monitored
+// resource of each time-series, replacing existing values with the same keys.
+func NewResourceLabelsTransformer(labels map[string]string) Transformer {
+	labels = maps.Clone(labels)
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			if series.Resource == nil {
+				continue
+			}
+			// The resource may be shared with other requests; replace it with
+			// a copy that has the merged labels.
+			merged := make(map[string]string, len(series.Resource.Labels)+len(labels))
+			maps.Copy(merged, series.Resource.Labels)
+			maps.Copy(merged, labels)
 			series.Resource = &monitoredrespb.MonitoredResource{
-				Type: "k8s_pod",
-				Labels: map[string]string{
-					"project_id":     projectID,
-					"location":       location,
-					"cluster_name":   clusterName,
-					"namespace_name": namespaceID,
-					"pod_name":       podID,
-				},
+				Type:   series.Resource.Type,
+				Labels: merged,
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a Transformer that removes the labels with the supplied keys from the
+// metric and monitored resource of each time-series. Labels that are required by
+// the monitored resource type, as listed by ResourceTypes, are never removed; a
+// warning is logged the first time one is requested instead. Add this
+// transformer after any others that add labels.
+func NewLabelFilterTransformer(logger logr.Logger, dropKeys []string) Transformer {
+	required := make(map[string][]string, len(ResourceTypes()))
+	for _, resourceType := range ResourceTypes() {
+		required[resourceType.Type] = resourceType.Labels
+	}
+	var mu sync.Mutex
+	warned := map[string]struct{}{}
+	// Returns true if the key can be removed from a resource of resourceType.
+	removable := func(resourceType, key string) bool {
+		if !slices.Contains(required[resourceType], key) {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := warned[resourceType+"/"+key]; !ok {
+			warned[resourceType+"/"+key] = struct{}{}
+			logger.Info("Not removing a label that is required by the monitored resource type", "resourceType", resourceType, "key", key)
+		}
+		return false
+	}
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			// Label maps and resources may be shared with other requests;
+			// replace them with filtered copies.
+			if series.Metric != nil && hasAnyKey(series.Metric.Labels, dropKeys) {
+				labels := maps.Clone(series.Metric.Labels)
+				for _, key := range dropKeys {
+					delete(labels, key)
+				}
+				series.Metric.Labels = labels
+			}
+			if series.Resource != nil && hasAnyKey(series.Resource.Labels, dropKeys) {
+				labels := maps.Clone(series.Resource.Labels)
+				for _, key := range dropKeys {
+					if _, ok := labels[key]; ok && removable(series.Resource.Type, key) {
+						delete(labels, key)
+					}
+				}
+				series.Resource = &monitoredrespb.MonitoredResource{
+					Type:   series.Resource.Type,
+					Labels: labels,
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// Returns true if labels contains any of the keys.
+func hasAnyKey(labels map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := labels[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a Transformer that will add a metric label with the supplied key to
+// each time-series, with a value derived from the timestamp of metric formatted
+// in UTC with the layout. E.g. NewTimeBucketLabelTransformer("hour", "15") will
+// add an hour label with values 00 through 23, which can be used to test
+// grouping by time of day.
+func NewTimeBucketLabelTransformer(key, layout string) Transformer {
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		return NewMetricLabelTransformer(key, metric.Timestamp.UTC().Format(layout))(req, metric)
+	}
+}
+
+// Returns a Transformer that will add a metric label with the supplied key to
+// each time-series, with a value chosen from labels by the band that the metric
+// value falls into. The thresholds must be strictly increasing, and there must
+// be one more label than thresholds; values below the first threshold use the
+// first label, and values equal to or above a threshold use the label that
+// follows it. E.g. NewValueBucketLabelTransformer("bucket", []float64{50}, []string{"low", "high"})
+// adds bucket=low to values below 50, and bucket=high otherwise. Invalid
+// thresholds or labels are reported with ErrInvalidValueBuckets when the
+// transformer is called.
+func NewValueBucketLabelTransformer(key string, thresholds []float64, labels []string) Transformer {
+	thresholds = slices.Clone(thresholds)
+	labels = slices.Clone(labels)
+	var err error
+	if len(labels) != len(thresholds)+1 {
+		err = fmt.Errorf("%w: %d thresholds and %d labels", ErrInvalidValueBuckets, len(thresholds), len(labels))
+	}
+	for i, threshold := range thresholds {
+		if err == nil && (math.IsNaN(threshold) || (i > 0 && threshold <= thresholds[i-1])) {
+			err = fmt.Errorf("%w: %v", ErrInvalidValueBuckets, thresholds)
+		}
+	}
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		if err != nil {
+			return err
+		}
+		band := sort.Search(len(thresholds), func(i int) bool {
+			return metric.Value < thresholds[i]
+		})
+		return NewMetricLabelTransformer(key, labels[band])(req, metric)
+	}
+}
+
+// Returns a Transformer that will add a metric label with the supplied key to
+// each time-series, with a value that cycles through values, changing every
+// interval of the metric timestamp since the Unix epoch. E.g. a trace_id label
+// that rotates through a handful of trace identifiers every minute simulates
+// exemplars that link points to traces. The first value is always used if every
+// is not positive, and no label is added if values is empty.
+//
+// NOTE: Every unique label value creates a new time-series in Cloud Monitoring,
+// so values should be a short list.
+func NewRotatingLabelTransformer(key string, values []string, every time.Duration) Transformer {
+	values = slices.Clone(values)
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		index := 0
+		if every > 0 {
+			// Round the interval number down, so that timestamps before the
+			// epoch also change value at the start of each interval.
+			interval := metric.Timestamp.UnixNano() / every.Nanoseconds()
+			if metric.Timestamp.UnixNano()%every.Nanoseconds() < 0 {
+				interval--
+			}
+			index = int(interval % int64(len(values)))
+			if index < 0 {
+				index += len(values)
+			}
+		}
+		return NewMetricLabelTransformer(key, values[index])(req, metric)
+	}
+}
+
+// Returns a Transformer that replaces the value of each double or int64 point
+// with the mean of the last window values it has seen for the same time-series,
+// including the current value; int64 means are rounded to the nearest integer.
+// Until the window fills, the mean is of the values received so far. A window is
+// kept for each time-series, identified by its metric type and labels, and its
+// monitored resource type and labels, so the transformer must be added after the
+// transformer that sets the point values, and before any transformer that adds
+// a label that changes with every point. A window of less than two leaves the
+// values unchanged.
+func NewMovingAverageTransformer(window int) Transformer {
+	window = max(window, 1)
+	var mu sync.Mutex
+	windows := map[string][]float64{}
+	// Record value in the window for the series and return the mean of the
+	// window.
+	average := func(key string, value float64) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		values := windows[key]
+		if len(values) == window {
+			values = append(values[:0], values[1:]...)
+		}
+		values = append(values, value)
+		windows[key] = values
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			key := singleSeriesIdentity(series)
+			for _, point := range series.GetPoints() {
+				switch value := point.GetValue().GetValue().(type) {
+				case *monitoringpb.TypedValue_DoubleValue:
+					value.DoubleValue = average(key, value.DoubleValue)
+				case *monitoringpb.TypedValue_Int64Value:
+					value.Int64Value, _ = saturatingInt64(math.Round(average(key, float64(value.Int64Value))))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a Transformer that rounds the value of each double point to the number
+// of decimal places, with halves rounded away from zero; a negative number of
+// decimals rounds to tens, hundreds, etc. Int64 and distribution values are
+// unchanged, as are values that would overflow when scaled. The transformer must
+// be added after the transformer that sets the point values.
+func NewRoundTransformer(decimals int) Transformer {
+	scale := math.Pow10(decimals)
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			for _, point := range series.GetPoints() {
+				value, ok := point.GetValue().GetValue().(*monitoringpb.TypedValue_DoubleValue)
+				if !ok {
+					continue
+				}
+				if scaled := value.DoubleValue * scale; !math.IsInf(scaled, 0) && scale != 0.0 {
+					value.DoubleValue = math.Round(scaled) / scale
+					if value.DoubleValue == 0.0 {
+						// Replace the negative zero that results from rounding
+						// small negative values, e.g. -0.4, with zero.
+						value.DoubleValue = 0.0
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a Transformer that multiplies the value of each double or int64 point
+// by the weight for the value of the metric label with the supplied key, e.g. so
+// that time-series labeled region=us have twice the magnitude of those labeled
+// region=eu. Time-series without the label, or with a label value that has no
+// weight, are unchanged; int64 values are rounded to the nearest integer. The
+// transformer must be added after the transformer that sets the point values.
+func NewLabelWeightedValueTransformer(labelKey string, weights map[string]float64) Transformer {
+	weights = maps.Clone(weights)
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			weight, ok := weights[series.GetMetric().GetLabels()[labelKey]]
+			if !ok {
+				continue
+			}
+			for _, point := range series.GetPoints() {
+				switch value := point.GetValue().GetValue().(type) {
+				case *monitoringpb.TypedValue_DoubleValue:
+					value.DoubleValue *= weight
+				case *monitoringpb.TypedValue_Int64Value:
+					value.Int64Value, _ = saturatingInt64(math.Round(float64(value.Int64Value) * weight))
+				}
 			}
 		}
 		return nil
 	}
 }
+
+// Returns a Transformer that adds an offset to the value of each double or int64
+// point that grows by ratePerHour for every hour since the first metric seen by
+// the transformer, e.g. to simulate the slow rise in baseline of a memory leak;
+// combined with a sine waveform, values oscillate around a rising baseline. The
+// elapsed time is taken from the metric timestamps, so the drift is the same for
+// backfilled and live values. Int64 values are rounded to the nearest integer.
+// The transformer must be added after the transformer that sets the point values.
+func NewDriftTransformer(ratePerHour float64) Transformer {
+	var mu sync.Mutex
+	var start time.Time
+	// Returns the offset for the timestamp, recording the first timestamp seen as
+	// the start of the drift.
+	offset := func(timestamp time.Time) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if start.IsZero() {
+			start = timestamp
+		}
+		return ratePerHour * timestamp.Sub(start).Hours()
+	}
+	return func(req *monitoringpb.CreateTimeSeriesRequest, metric generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		drift := offset(metric.Timestamp)
+		for _, series := range req.TimeSeries {
+			for _, point := range series.GetPoints() {
+				switch value := point.GetValue().GetValue().(type) {
+				case *monitoringpb.TypedValue_DoubleValue:
+					value.DoubleValue += drift
+				case *monitoringpb.TypedValue_Int64Value:
+					value.Int64Value, _ = saturatingInt64(math.Round(float64(value.Int64Value) + drift))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a Transformer that rejects time-series with a metric or monitored
+// resource label key that Google Cloud Monitoring will not accept, with an
+// ErrInvalidLabelKey error that names the key. Valid keys start with a lowercase
+// letter, contain only lowercase letters, digits, and underscores, and are at
+// most MaxLabelKeyLength characters long. Cloud Monitoring rejects invalid keys
+// with an error that doesn't say which key is at fault, so the transformer
+// should be added after any transformers that add labels.
+func NewLabelKeyValidationTransformer() Transformer {
+	return func(req *monitoringpb.CreateTimeSeriesRequest, _ generators.Metric) error {
+		if req == nil {
+			return ErrNilCreateTimeSeriesRequest
+		}
+		for _, series := range req.TimeSeries {
+			for _, key := range slices.Sorted(maps.Keys(series.GetMetric().GetLabels())) {
+				if problem := labelKeyProblem(key); problem != "" {
+					return fmt.Errorf("%w: metric label %q of %s %s", ErrInvalidLabelKey, key, series.GetMetric().GetType(), problem)
+				}
+			}
+			for _, key := range slices.Sorted(maps.Keys(series.GetResource().GetLabels())) {
+				if problem := labelKeyProblem(key); problem != "" {
+					return fmt.Errorf("%w: resource label %q of %s %s", ErrInvalidLabelKey, key, series.GetResource().GetType(), problem)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a description of why the label key is not accepted by Google Cloud
+// Monitoring, or an empty string if it is valid.
+func labelKeyProblem(key string) string {
+	switch {
+	case len(key) > MaxLabelKeyLength:
+		return fmt.Sprintf("is longer than %d characters", MaxLabelKeyLength)
+	case !labelKeyPattern.MatchString(key):
+		return "must start with a lowercase letter and contain only lowercase letters, digits, and underscores"
+	default:
+		return ""
+	}
+}
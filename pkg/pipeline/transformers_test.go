@@ -2,16 +2,27 @@
 package pipeline_test
 
 import (
+	"bytes"
 	"errors"
+	"log"
+	"maps"
+	"math"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
 	"github.com/memes/gce-metric/pkg/generators"
 	"github.com/memes/gce-metric/pkg/pipeline"
+	distributionpb "google.golang.org/genproto/googleapis/api/distribution"
 	metricpb "google.golang.org/genproto/googleapis/api/metric"
 	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -983,6 +994,138 @@ func TestNewGCEMonitoredResourceTransformer(t *testing.T) {
 	}
 }
 
+// Returns a request named name with count time-series, each with a string point
+// at timestamp; resource returns the Resource of each time-series, which may be
+// nil.
+func newResourceTestRequest(name string, count int, timestamp time.Time, resource func(name string) *monitoredrespb.MonitoredResource) *monitoringpb.CreateTimeSeriesRequest {
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name:       name,
+		TimeSeries: []*monitoringpb.TimeSeries{},
+	}
+	for i := range count {
+		seriesName := name + "-" + strconv.Itoa(i)
+		req.TimeSeries = append(req.TimeSeries, &monitoringpb.TimeSeries{
+			Metric: &metricpb.Metric{
+				Type: seriesName,
+			},
+			Resource: resource(seriesName),
+			Points: []*monitoringpb.Point{
+				{
+					Interval: &monitoringpb.TimeInterval{
+						StartTime: &timestamppb.Timestamp{
+							Seconds: timestamp.Unix(),
+						},
+						EndTime: &timestamppb.Timestamp{
+							Seconds: timestamp.Unix(),
+						},
+					},
+					Value: &monitoringpb.TypedValue{
+						Value: &monitoringpb.TypedValue_StringValue{
+							StringValue: "test-value-" + strconv.Itoa(i),
+						},
+					},
+				},
+			},
+		})
+	}
+	return req
+}
+
+// Verifies that the resource transformer inserts or replaces the Resource field
+// of every TimeSeries in a request with expected, and that any existing Metric or
+// Point object remains unchanged.
+func testResourceTransformer(t *testing.T, transformer pipeline.Transformer, expected *monitoredrespb.MonitoredResource) {
+	t.Helper()
+	timestamp := time.Now()
+	none := func(string) *monitoredrespb.MonitoredResource { return nil }
+	existing := func(name string) *monitoredrespb.MonitoredResource {
+		return &monitoredrespb.MonitoredResource{
+			Type: name,
+			Labels: map[string]string{
+				"project_id": name,
+				"location":   name,
+				"namespace":  name,
+				"node_id":    name,
+			},
+		}
+	}
+	replaced := func(string) *monitoredrespb.MonitoredResource { return expected }
+	tests := []struct {
+		name          string
+		req           *monitoringpb.CreateTimeSeriesRequest
+		expected      *monitoringpb.CreateTimeSeriesRequest
+		expectedError error
+	}{
+		{
+			name:          "nil",
+			expectedError: pipeline.ErrNilCreateTimeSeriesRequest,
+		},
+		{
+			name:     "default",
+			req:      &monitoringpb.CreateTimeSeriesRequest{},
+			expected: &monitoringpb.CreateTimeSeriesRequest{},
+		},
+		{
+			name:     "nil-series",
+			req:      &monitoringpb.CreateTimeSeriesRequest{Name: "nil-series"},
+			expected: &monitoringpb.CreateTimeSeriesRequest{Name: "nil-series"},
+		},
+		{
+			name:     "empty-series",
+			req:      newResourceTestRequest("empty-series", 0, timestamp, none),
+			expected: newResourceTestRequest("empty-series", 0, timestamp, none),
+		},
+		{
+			name:     "insert-single-series",
+			req:      newResourceTestRequest("insert-single-series", 1, timestamp, none),
+			expected: newResourceTestRequest("insert-single-series", 1, timestamp, replaced),
+		},
+		{
+			name:     "insert-multiple-series",
+			req:      newResourceTestRequest("insert-multiple-series", 2, timestamp, none),
+			expected: newResourceTestRequest("insert-multiple-series", 2, timestamp, replaced),
+		},
+		{
+			name:     "replace-single-series",
+			req:      newResourceTestRequest("replace-single-series", 1, timestamp, existing),
+			expected: newResourceTestRequest("replace-single-series", 1, timestamp, replaced),
+		},
+		{
+			name:     "replace-multiple-series",
+			req:      newResourceTestRequest("replace-multiple-series", 2, timestamp, existing),
+			expected: newResourceTestRequest("replace-multiple-series", 2, timestamp, replaced),
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			err := transformer(tst.req, generators.Metric{})
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Transformer raised an unexpected exception: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected transform to raise %v, got %v", tst.expectedError, err)
+			case !reflect.DeepEqual(tst.expected, tst.req):
+				t.Errorf("Expected %+v, got %+v", tst.expected, tst.req)
+			}
+		})
+	}
+}
+
+// The NewGlobalMonitoredResourceTransformer is expected to return a function
+// that inserts or replaces the Resource field of every TimeSeries in the slice
+// with a global resource with expected field values.
+func TestNewGlobalMonitoredResourceTransformer(t *testing.T) {
+	t.Parallel()
+	testResourceTransformer(t, pipeline.NewGlobalMonitoredResourceTransformer(project), &monitoredrespb.MonitoredResource{
+		Type: "global",
+		Labels: map[string]string{
+			"project_id": project,
+		},
+	})
+}
+
 // The NewGKEMonitoredResourceTransformer is expected to return a function
 // that inserts or replaces the Resource field of every TimeSeries in the slice
 // with a gke_container resource with expected field values. Any existing Metric
@@ -1575,12 +1718,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1651,12 +1789,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1682,12 +1815,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1761,12 +1889,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1871,12 +1994,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1902,12 +2020,7 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_DoubleValue{
@@ -1920,6 +2033,90 @@ func TestNewDoubleTypedValueTransformer(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "nan",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "nan",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "nan",
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.NaN(),
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "nan",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "nan",
+						},
+					},
+				},
+			},
+			expectedError: pipeline.ErrNonFiniteValue,
+		},
+		{
+			name: "positive-infinity",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "positive-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "positive-infinity",
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.Inf(1),
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "positive-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "positive-infinity",
+						},
+					},
+				},
+			},
+			expectedError: pipeline.ErrNonFiniteValue,
+		},
+		{
+			name: "negative-infinity",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "negative-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "negative-infinity",
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.Inf(-1),
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "negative-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "negative-infinity",
+						},
+					},
+				},
+			},
+			expectedError: pipeline.ErrNonFiniteValue,
+		},
 	}
 	t.Parallel()
 	for _, test := range tests {
@@ -2031,12 +2228,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2107,12 +2299,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2138,12 +2325,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2217,12 +2399,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2327,12 +2504,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2358,12 +2530,7 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
 									Value: &monitoringpb.TypedValue_Int64Value{
@@ -2376,96 +2543,37 @@ func TestNewIntegerTypedValueTransformer(t *testing.T) {
 				},
 			},
 		},
-	}
-	t.Parallel()
-	for _, test := range tests {
-		tst := test
-		t.Run(tst.name, func(t *testing.T) {
-			t.Parallel()
-			err := transformer(tst.req, tst.metric)
-			switch {
-			case tst.expectedError == nil && err != nil:
-				t.Errorf("Transformer raised an unexpected exception: %v", err)
-			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
-				t.Errorf("Expected transform to raise %v, got %v", tst.expectedError, err)
-			case !reflect.DeepEqual(tst.expected, tst.req):
-				t.Errorf("Expected %+v, got %+v", tst.expected, tst.req)
-			}
-		})
-	}
-}
-
-// The NewGenericKubernetesClusterMonitoredResourceTransformer is expected to
-// return a function that inserts or replaces the Resource field of every TimeSeries
-// in the slice with a k8s_cluster resource with expected field values. Any existing
-// Metric or Point object should remain unchanged.
-//
-//nolint:funlen // The test cases/tables add lines to the function
-func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
-	transformer := pipeline.NewGenericKubernetesClusterMonitoredResourceTransformer(project, location, clusterName)
-	timestamp := time.Now()
-	tests := []struct {
-		name          string
-		req           *monitoringpb.CreateTimeSeriesRequest
-		metric        generators.Metric
-		expected      *monitoringpb.CreateTimeSeriesRequest
-		expectedError error
-	}{
-		{
-			name:          "nil",
-			req:           nil,
-			expected:      nil,
-			expectedError: pipeline.ErrNilCreateTimeSeriesRequest,
-		},
-		{
-			name:     "default",
-			req:      &monitoringpb.CreateTimeSeriesRequest{},
-			expected: &monitoringpb.CreateTimeSeriesRequest{},
-		},
 		{
-			name: "nil-series",
+			name: "round-half-up",
 			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name:       "nil-series",
-				TimeSeries: nil,
+				Name: "round-half-up",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "round-half-up",
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     9.5,
+				Timestamp: timestamp,
 			},
 			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name:       "nil-series",
-				TimeSeries: nil,
-			},
-		},
-		{
-			name: "empty-series",
-			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name:       "empty-series",
-				TimeSeries: []*monitoringpb.TimeSeries{},
-			},
-			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name:       "empty-series",
-				TimeSeries: []*monitoringpb.TimeSeries{},
-			},
-		},
-		{
-			name: "insert-single-series",
-			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "insert-single-series",
+				Name: "round-half-up",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-single-series",
+							Type: "round-half-up",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: 10,
 									},
 								},
 							},
@@ -2473,38 +2581,38 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 					},
 				},
 			},
+		},
+		{
+			name: "round-up",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "round-up",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "round-up",
+						},
+					},
+				},
+			},
 			metric: generators.Metric{
-				Value:     1.1,
+				Value:     9.9,
 				Timestamp: timestamp,
 			},
 			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "insert-single-series",
+				Name: "round-up",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-single-series",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
+							Type: "round-up",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: 10,
 									},
 								},
 							},
@@ -2514,49 +2622,36 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 			},
 		},
 		{
-			name: "insert-multiple-series",
+			name: "saturate-above-max",
 			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "insert-multiple-series",
+				Name: "saturate-above-max",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-multiple-series-0",
-						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-0",
-									},
-								},
-							},
+							Type: "saturate-above-max",
 						},
 					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.MaxInt64 * 2.0,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "saturate-above-max",
+				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-multiple-series-1",
+							Type: "saturate-above-max",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-1",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: math.MaxInt64,
 									},
 								},
 							},
@@ -2564,68 +2659,77 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 					},
 				},
 			},
+		},
+		{
+			name: "saturate-max",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "saturate-max",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "saturate-max",
+						},
+					},
+				},
+			},
 			metric: generators.Metric{
-				Value:     2.2,
+				Value:     float64(math.MaxInt64),
 				Timestamp: timestamp,
 			},
 			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "insert-multiple-series",
+				Name: "saturate-max",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-multiple-series-0",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
+							Type: "saturate-max",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-0",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: math.MaxInt64,
 									},
 								},
 							},
 						},
 					},
+				},
+			},
+		},
+		{
+			name: "saturate-below-min",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "saturate-below-min",
+				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "insert-multiple-series-1",
+							Type: "saturate-below-min",
 						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.MinInt64 * 2.0,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "saturate-below-min",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "saturate-below-min",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-1",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: math.MinInt64,
 									},
 								},
 							},
@@ -2635,75 +2739,36 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 			},
 		},
 		{
-			name: "replace-single-series",
+			name: "saturate-min",
 			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "replace-single-series",
+				Name: "saturate-min",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-single-series",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "replace-single-series",
-							Labels: map[string]string{
-								"project_id": "replace-single-series",
-								"location":   "replace-single-series",
-								"namespace":  "replace-single-series",
-								"node_id":    "replace-single-series",
-							},
-						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value",
-									},
-								},
-							},
+							Type: "saturate-min",
 						},
 					},
 				},
 			},
 			metric: generators.Metric{
-				Value:     3.3,
+				Value:     float64(math.MinInt64),
 				Timestamp: timestamp,
 			},
 			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "replace-single-series",
+				Name: "saturate-min",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-single-series",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
+							Type: "saturate-min",
 						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
+									EndTime: timestamppb.New(timestamp),
 								},
 								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value",
+									Value: &monitoringpb.TypedValue_Int64Value{
+										Int64Value: math.MinInt64,
 									},
 								},
 							},
@@ -2713,143 +2778,88 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 			},
 		},
 		{
-			name: "replace-multiple-series",
+			name: "nan",
 			req: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "replace-multiple-series",
+				Name: "nan",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-multiple-series-0",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "replace-multiple-series-0",
-							Labels: map[string]string{
-								"project_id": "replace-multiple-series-0",
-								"location":   "replace-multiple-series-0",
-								"namespace":  "replace-multiple-series-0",
-								"node_id":    "replace-multiple-series-0",
-							},
+							Type: "nan",
 						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-0",
-									},
-								},
-							},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.NaN(),
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "nan",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "nan",
 						},
 					},
+				},
+			},
+			expectedError: pipeline.ErrNonFiniteValue,
+		},
+		{
+			name: "positive-infinity",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "positive-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-multiple-series-1",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "replace-multiple-series-1",
-							Labels: map[string]string{
-								"project_id": "replace-multiple-series-1",
-								"location":   "replace-multiple-series-1",
-								"namespace":  "replace-multiple-series-1",
-								"node_id":    "replace-multiple-series-1",
-							},
-						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-1",
-									},
-								},
-							},
+							Type: "positive-infinity",
 						},
 					},
 				},
 			},
 			metric: generators.Metric{
-				Value:     4.4,
+				Value:     math.Inf(1),
 				Timestamp: timestamp,
 			},
 			expected: &monitoringpb.CreateTimeSeriesRequest{
-				Name: "replace-multiple-series",
+				Name: "positive-infinity",
 				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-multiple-series-0",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
-						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-0",
-									},
-								},
-							},
+							Type: "positive-infinity",
 						},
 					},
+				},
+			},
+			expectedError: pipeline.ErrNonFiniteValue,
+		},
+		{
+			name: "negative-infinity",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "negative-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
 					{
 						Metric: &metricpb.Metric{
-							Type: "replace-multiple-series-1",
-						},
-						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_cluster",
-							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-							},
+							Type: "negative-infinity",
 						},
-						Points: []*monitoringpb.Point{
-							{
-								Interval: &monitoringpb.TimeInterval{
-									StartTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-									EndTime: &timestamppb.Timestamp{
-										Seconds: timestamp.Unix(),
-									},
-								},
-								Value: &monitoringpb.TypedValue{
-									Value: &monitoringpb.TypedValue_StringValue{
-										StringValue: "test-value-1",
-									},
-								},
-							},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     math.Inf(-1),
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "negative-infinity",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "negative-infinity",
 						},
 					},
 				},
 			},
+			expectedError: pipeline.ErrNonFiniteValue,
 		},
 	}
 	t.Parallel()
@@ -2857,7 +2867,7 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 		tst := test
 		t.Run(tst.name, func(t *testing.T) {
 			t.Parallel()
-			err := transformer(tst.req, generators.Metric{})
+			err := transformer(tst.req, tst.metric)
 			switch {
 			case tst.expectedError == nil && err != nil:
 				t.Errorf("Transformer raised an unexpected exception: %v", err)
@@ -2870,14 +2880,200 @@ func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
 	}
 }
 
-// The NewGenericKubernetesContainerMonitoredResourceTransformer is expected to
+// The logger passed to NewIntegerTypedValueTransformerWithLogger should receive
+// a warning when a value is clamped to the range of an int64.
+func TestNewIntegerTypedValueTransformerWithLogger(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	logger := stdr.NewWithOptions(log.New(&logs, "", 0), stdr.Options{LogCaller: stdr.None, Depth: 0})
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		TimeSeries: []*monitoringpb.TimeSeries{{}},
+	}
+	if err := pipeline.NewIntegerTypedValueTransformerWithLogger(logger)(req, generators.Metric{Value: 1e20, Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error returned from transformer: %v", err)
+	}
+	if value := req.GetTimeSeries()[0].GetPoints()[0].GetValue().GetInt64Value(); value != math.MaxInt64 {
+		t.Errorf("Expected value to be clamped to %d, got %d", int64(math.MaxInt64), value)
+	}
+	if expected := "Metric value is outside the range of int64; clamping value"; !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected log to contain %q, got %q", expected, logs.String())
+	}
+}
+
+// The value transformers are expected to set the interval of each point to match
+// the metric kind of the time-series; gauge points only have an end time,
+// cumulative points share a fixed start time, and delta points cover the window
+// since the previous point. The timestamps have sub-second components, which
+// must be preserved.
+//
+//nolint:funlen // The test cases/tables add lines to the function
+func TestValueTransformerIntervals(t *testing.T) {
+	options, err := pipeline.NewExplicitBucketOptions([]float64{0.0, 10.0})
+	if err != nil {
+		t.Fatalf("NewExplicitBucketOptions raised an unexpected error: %v", err)
+	}
+	transformers := map[string]func() pipeline.Transformer{
+		"double":       pipeline.NewDoubleTypedValueTransformer,
+		"integer":      func() pipeline.Transformer { return pipeline.NewIntegerTypedValueTransformer() },
+		"distribution": func() pipeline.Transformer { return pipeline.NewDistributionTypedValueTransformer(options) },
+	}
+	first := time.Unix(1700000000, 123456789)
+	second := first.Add(30*time.Second + 250*time.Millisecond)
+	third := second.Add(500 * time.Millisecond)
+	tests := []struct {
+		name     string
+		kind     metricpb.MetricDescriptor_MetricKind
+		expected []*monitoringpb.TimeInterval
+	}{
+		{
+			name: "unspecified",
+			kind: metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED,
+			expected: []*monitoringpb.TimeInterval{
+				{EndTime: timestamppb.New(first)},
+				{EndTime: timestamppb.New(second)},
+				{EndTime: timestamppb.New(third)},
+			},
+		},
+		{
+			name: "gauge",
+			kind: metricpb.MetricDescriptor_GAUGE,
+			expected: []*monitoringpb.TimeInterval{
+				{EndTime: timestamppb.New(first)},
+				{EndTime: timestamppb.New(second)},
+				{EndTime: timestamppb.New(third)},
+			},
+		},
+		{
+			name: "cumulative",
+			kind: metricpb.MetricDescriptor_CUMULATIVE,
+			expected: []*monitoringpb.TimeInterval{
+				{StartTime: timestamppb.New(first.Add(-time.Second)), EndTime: timestamppb.New(first)},
+				{StartTime: timestamppb.New(first.Add(-time.Second)), EndTime: timestamppb.New(second)},
+				{StartTime: timestamppb.New(first.Add(-time.Second)), EndTime: timestamppb.New(third)},
+			},
+		},
+		{
+			name: "delta",
+			kind: metricpb.MetricDescriptor_DELTA,
+			expected: []*monitoringpb.TimeInterval{
+				{StartTime: timestamppb.New(first.Add(-time.Second)), EndTime: timestamppb.New(first)},
+				{StartTime: timestamppb.New(first), EndTime: timestamppb.New(second)},
+				{StartTime: timestamppb.New(second), EndTime: timestamppb.New(third)},
+			},
+		},
+	}
+	t.Parallel()
+	for transformerName, newTransformer := range transformers {
+		for _, test := range tests {
+			tst := test
+			t.Run(transformerName+"-"+tst.name, func(t *testing.T) {
+				t.Parallel()
+				transformer := newTransformer()
+				for i, timestamp := range []time.Time{first, second, third} {
+					req := &monitoringpb.CreateTimeSeriesRequest{
+						Name: tst.name,
+						TimeSeries: []*monitoringpb.TimeSeries{
+							{
+								Metric: &metricpb.Metric{
+									Type: tst.name,
+								},
+								MetricKind: tst.kind,
+							},
+						},
+					}
+					if err := transformer(req, generators.Metric{
+						Value:     1.0,
+						Timestamp: timestamp,
+					}); err != nil {
+						t.Fatalf("Transformer raised an unexpected error: %v", err)
+					}
+					interval := req.GetTimeSeries()[0].GetPoints()[0].GetInterval()
+					if !proto.Equal(interval, tst.expected[i]) {
+						t.Errorf("Expected point %d interval %v, got %v", i, tst.expected[i], interval)
+					}
+					if end := interval.GetEndTime(); !end.AsTime().Equal(timestamp) || end.GetNanos() != int32(timestamp.Nanosecond()) {
+						t.Errorf("Expected point %d to end at %v, got %v", i, timestamp, end.AsTime())
+					}
+				}
+			})
+		}
+	}
+}
+
+// The NewMetricKindValueTypeTransformer is expected to return a function that
+// rejects value types that are not supported by the metric kind of the
+// time-series.
+func TestNewMetricKindValueTypeTransformer(t *testing.T) {
+	values := map[string]*monitoringpb.TypedValue{
+		"bool": {
+			Value: &monitoringpb.TypedValue_BoolValue{BoolValue: true},
+		},
+		"int64": {
+			Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 1},
+		},
+		"double": {
+			Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: 1.1},
+		},
+		"string": {
+			Value: &monitoringpb.TypedValue_StringValue{StringValue: "value"},
+		},
+		"distribution": {
+			Value: &monitoringpb.TypedValue_DistributionValue{DistributionValue: &distributionpb.Distribution{Count: 1}},
+		},
+	}
+	// The value types that are expected to be rejected for each metric kind.
+	invalid := map[metricpb.MetricDescriptor_MetricKind][]string{
+		metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED: {},
+		metricpb.MetricDescriptor_GAUGE:                   {},
+		metricpb.MetricDescriptor_DELTA:                   {"bool", "string"},
+		metricpb.MetricDescriptor_CUMULATIVE:              {"bool", "string"},
+	}
+	t.Parallel()
+	transformer := pipeline.NewMetricKindValueTypeTransformer()
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	for kind, rejected := range invalid {
+		for name, value := range values {
+			t.Run(kind.String()+"-"+name, func(t *testing.T) {
+				t.Parallel()
+				req := &monitoringpb.CreateTimeSeriesRequest{
+					Name: name,
+					TimeSeries: []*monitoringpb.TimeSeries{
+						{
+							Metric: &metricpb.Metric{
+								Type: name,
+							},
+							MetricKind: kind,
+							Points: []*monitoringpb.Point{
+								{
+									Value: value,
+								},
+							},
+						},
+					},
+				}
+				err := transformer(req, generators.Metric{})
+				expectErr := slices.Contains(rejected, name)
+				switch {
+				case expectErr && !errors.Is(err, pipeline.ErrUnsupportedValueType):
+					t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrUnsupportedValueType, err)
+				case !expectErr && err != nil:
+					t.Errorf("Transformer raised an unexpected exception: %v", err)
+				}
+			})
+		}
+	}
+}
+
+// The NewGenericKubernetesClusterMonitoredResourceTransformer is expected to
 // return a function that inserts or replaces the Resource field of every TimeSeries
-// in the slice with a k8s_container resource with expected field values. Any existing
+// in the slice with a k8s_cluster resource with expected field values. Any existing
 // Metric or Point object should remain unchanged.
 //
 //nolint:funlen // The test cases/tables add lines to the function
-func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T) {
-	transformer := pipeline.NewGenericKubernetesContainerMonitoredResourceTransformer(project, location, clusterName, namespace, pod, containerName)
+func TestNewGenericKubernetesClusterMonitoredResourceTransformer(t *testing.T) {
+	transformer := pipeline.NewGenericKubernetesClusterMonitoredResourceTransformer(project, location, clusterName)
 	timestamp := time.Now()
 	tests := []struct {
 		name          string
@@ -2960,14 +3156,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "insert-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3054,14 +3247,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "insert-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3087,14 +3277,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "insert-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3168,14 +3355,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "replace-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3280,14 +3464,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "replace-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3313,14 +3494,11 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 							Type: "replace-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_container",
+							Type: "k8s_cluster",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
-								"container_name": containerName,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3363,14 +3541,14 @@ func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T)
 	}
 }
 
-// The NewGenericKubernetesNodeMonitoredResourceTransformer is expected to
+// The NewGenericKubernetesContainerMonitoredResourceTransformer is expected to
 // return a function that inserts or replaces the Resource field of every TimeSeries
-// in the slice with a k8s_node resource with expected field values. Any existing
+// in the slice with a k8s_container resource with expected field values. Any existing
 // Metric or Point object should remain unchanged.
 //
 //nolint:funlen // The test cases/tables add lines to the function
-func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
-	transformer := pipeline.NewGenericKubernetesNodeMonitoredResourceTransformer(project, location, clusterName, node)
+func TestNewGenericKubernetesContainerMonitoredResourceTransformer(t *testing.T) {
+	transformer := pipeline.NewGenericKubernetesContainerMonitoredResourceTransformer(project, location, clusterName, namespace, pod, containerName)
 	timestamp := time.Now()
 	tests := []struct {
 		name          string
@@ -3453,12 +3631,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3545,12 +3725,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3576,14 +3758,16 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
-							},
-						},
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
+							},
+						},
 						Points: []*monitoringpb.Point{
 							{
 								Interval: &monitoringpb.TimeInterval{
@@ -3655,12 +3839,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3765,12 +3951,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3796,12 +3984,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_node",
+							Type: "k8s_container",
 							Labels: map[string]string{
-								"project_id":   project,
-								"location":     location,
-								"cluster_name": clusterName,
-								"node_name":    node,
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+								"container_name": containerName,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -3844,14 +4034,14 @@ func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
 	}
 }
 
-// The NewGenericKubernetesPodMonitoredResourceTransformer is expected to
+// The NewGenericKubernetesNodeMonitoredResourceTransformer is expected to
 // return a function that inserts or replaces the Resource field of every TimeSeries
-// in the slice with a k8s_pod resource with expected field values. Any existing
+// in the slice with a k8s_node resource with expected field values. Any existing
 // Metric or Point object should remain unchanged.
 //
 //nolint:funlen // The test cases/tables add lines to the function
-func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
-	transformer := pipeline.NewGenericKubernetesPodMonitoredResourceTransformer(project, location, clusterName, namespace, pod)
+func TestNewGenericKubernetesNodeMonitoredResourceTransformer(t *testing.T) {
+	transformer := pipeline.NewGenericKubernetesNodeMonitoredResourceTransformer(project, location, clusterName, node)
 	timestamp := time.Now()
 	tests := []struct {
 		name          string
@@ -3934,13 +4124,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4027,13 +4216,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4059,13 +4247,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "insert-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4139,13 +4326,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-single-series",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4250,13 +4436,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-multiple-series-0",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4282,13 +4467,12 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 							Type: "replace-multiple-series-1",
 						},
 						Resource: &monitoredrespb.MonitoredResource{
-							Type: "k8s_pod",
+							Type: "k8s_node",
 							Labels: map[string]string{
-								"project_id":     project,
-								"location":       location,
-								"cluster_name":   clusterName,
-								"namespace_name": namespace,
-								"pod_name":       pod,
+								"project_id":   project,
+								"location":     location,
+								"cluster_name": clusterName,
+								"node_name":    node,
 							},
 						},
 						Points: []*monitoringpb.Point{
@@ -4330,3 +4514,1883 @@ func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
 		})
 	}
 }
+
+// The NewGenericKubernetesPodMonitoredResourceTransformer is expected to
+// return a function that inserts or replaces the Resource field of every TimeSeries
+// in the slice with a k8s_pod resource with expected field values. Any existing
+// Metric or Point object should remain unchanged.
+//
+//nolint:funlen // The test cases/tables add lines to the function
+func TestNewGenericKubernetesPodMonitoredResourceTransformer(t *testing.T) {
+	transformer := pipeline.NewGenericKubernetesPodMonitoredResourceTransformer(project, location, clusterName, namespace, pod)
+	timestamp := time.Now()
+	tests := []struct {
+		name          string
+		req           *monitoringpb.CreateTimeSeriesRequest
+		metric        generators.Metric
+		expected      *monitoringpb.CreateTimeSeriesRequest
+		expectedError error
+	}{
+		{
+			name:          "nil",
+			req:           nil,
+			expected:      nil,
+			expectedError: pipeline.ErrNilCreateTimeSeriesRequest,
+		},
+		{
+			name:     "default",
+			req:      &monitoringpb.CreateTimeSeriesRequest{},
+			expected: &monitoringpb.CreateTimeSeriesRequest{},
+		},
+		{
+			name: "nil-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name:       "nil-series",
+				TimeSeries: nil,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name:       "nil-series",
+				TimeSeries: nil,
+			},
+		},
+		{
+			name: "empty-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name:       "empty-series",
+				TimeSeries: []*monitoringpb.TimeSeries{},
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name:       "empty-series",
+				TimeSeries: []*monitoringpb.TimeSeries{},
+			},
+		},
+		{
+			name: "insert-single-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "insert-single-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-single-series",
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     1.1,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "insert-single-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-single-series",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "insert-multiple-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "insert-multiple-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-multiple-series-0",
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-0",
+									},
+								},
+							},
+						},
+					},
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-multiple-series-1",
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-1",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     2.2,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "insert-multiple-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-multiple-series-0",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-0",
+									},
+								},
+							},
+						},
+					},
+					{
+						Metric: &metricpb.Metric{
+							Type: "insert-multiple-series-1",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-1",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "replace-single-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "replace-single-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-single-series",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "replace-single-series",
+							Labels: map[string]string{
+								"project_id": "replace-single-series",
+								"location":   "replace-single-series",
+								"namespace":  "replace-single-series",
+								"node_id":    "replace-single-series",
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     3.3,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "replace-single-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-single-series",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "replace-multiple-series",
+			req: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "replace-multiple-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-multiple-series-0",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "replace-multiple-series-0",
+							Labels: map[string]string{
+								"project_id": "replace-multiple-series-0",
+								"location":   "replace-multiple-series-0",
+								"namespace":  "replace-multiple-series-0",
+								"node_id":    "replace-multiple-series-0",
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-0",
+									},
+								},
+							},
+						},
+					},
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-multiple-series-1",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "replace-multiple-series-1",
+							Labels: map[string]string{
+								"project_id": "replace-multiple-series-1",
+								"location":   "replace-multiple-series-1",
+								"namespace":  "replace-multiple-series-1",
+								"node_id":    "replace-multiple-series-1",
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-1",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			metric: generators.Metric{
+				Value:     4.4,
+				Timestamp: timestamp,
+			},
+			expected: &monitoringpb.CreateTimeSeriesRequest{
+				Name: "replace-multiple-series",
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-multiple-series-0",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-0",
+									},
+								},
+							},
+						},
+					},
+					{
+						Metric: &metricpb.Metric{
+							Type: "replace-multiple-series-1",
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type: "k8s_pod",
+							Labels: map[string]string{
+								"project_id":     project,
+								"location":       location,
+								"cluster_name":   clusterName,
+								"namespace_name": namespace,
+								"pod_name":       pod,
+							},
+						},
+						Points: []*monitoringpb.Point{
+							{
+								Interval: &monitoringpb.TimeInterval{
+									StartTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+									EndTime: &timestamppb.Timestamp{
+										Seconds: timestamp.Unix(),
+									},
+								},
+								Value: &monitoringpb.TypedValue{
+									Value: &monitoringpb.TypedValue_StringValue{
+										StringValue: "test-value-1",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			err := transformer(tst.req, generators.Metric{})
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Transformer raised an unexpected exception: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected transform to raise %v, got %v", tst.expectedError, err)
+			case !reflect.DeepEqual(tst.expected, tst.req):
+				t.Errorf("Expected %+v, got %+v", tst.expected, tst.req)
+			}
+		})
+	}
+}
+
+// The NewMetricLabelTransformer is expected to return a function that adds the
+// fixed label to every time-series, without changing the original metric labels
+// map.
+func TestNewMetricLabelTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewMetricLabelTransformer("host", "test-host")
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	shared := map[string]string{"color": "blue"}
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name: "metric-label",
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Metric: &metricpb.Metric{
+					Type:   "metric-label-0",
+					Labels: shared,
+				},
+			},
+			{
+				Metric: &metricpb.Metric{
+					Type: "metric-label-1",
+				},
+			},
+		},
+	}
+	if err := transformer(req, generators.Metric{}); err != nil {
+		t.Fatalf("Transformer raised an unexpected exception: %v", err)
+	}
+	expected := []map[string]string{
+		{"color": "blue", "host": "test-host"},
+		{"host": "test-host"},
+	}
+	for i, series := range req.TimeSeries {
+		if !reflect.DeepEqual(series.Metric.Labels, expected[i]) {
+			t.Errorf("Expected series %d labels %+v, got %+v", i, expected[i], series.Metric.Labels)
+		}
+	}
+	if _, ok := shared["host"]; ok {
+		t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+	}
+}
+
+// The NewWriterIdentityLabelTransformer is expected to return a function that
+// adds the same process identity label to every time-series, from every
+// transformer instance.
+func TestNewWriterIdentityLabelTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewWriterIdentityLabelTransformer()
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	identity := func(transformer pipeline.Transformer) string {
+		t.Helper()
+		req := &monitoringpb.CreateTimeSeriesRequest{
+			Name: "writer-identity",
+			TimeSeries: []*monitoringpb.TimeSeries{
+				{
+					Metric: &metricpb.Metric{
+						Type:   "writer-identity",
+						Labels: map[string]string{"color": "blue"},
+					},
+				},
+			},
+		}
+		if err := transformer(req, generators.Metric{}); err != nil {
+			t.Fatalf("Transformer raised an unexpected exception: %v", err)
+		}
+		labels := req.TimeSeries[0].Metric.Labels
+		if labels["color"] != "blue" {
+			t.Errorf("Expected existing labels to be kept, got %+v", labels)
+		}
+		return labels[pipeline.WriterIdentityLabel]
+	}
+	first := identity(transformer)
+	if !strings.HasPrefix(first, "go-") || !strings.Contains(first, "@") {
+		t.Errorf("Expected identity in the form go-PID-RANDOM@HOSTNAME, got %q", first)
+	}
+	if second := identity(transformer); second != first {
+		t.Errorf("Expected identity to be stable, got %q and %q", first, second)
+	}
+	if other := identity(pipeline.NewWriterIdentityLabelTransformer()); other != first {
+		t.Errorf("Expected every transformer to use the process identity %q, got %q", first, other)
+	}
+}
+
+func TestNewDeployVersionLabelTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewDeployVersionLabelTransformer("v1.2.3")
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name: "deploy-version",
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Metric: &metricpb.Metric{
+					Type:   "deploy-version",
+					Labels: map[string]string{"color": "blue"},
+				},
+			},
+		},
+	}
+	if err := transformer(req, generators.Metric{}); err != nil {
+		t.Fatalf("Transformer raised an unexpected exception: %v", err)
+	}
+	expected := map[string]string{"color": "blue", pipeline.DeployVersionLabel: "v1.2.3"}
+	if labels := req.TimeSeries[0].Metric.Labels; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+}
+
+// The NewSequenceLabelTransformer is expected to return a function that adds an
+// incrementing sequence number label to the metric of every TimeSeries, without
+// modifying the existing labels map.
+func TestNewSequenceLabelTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewSequenceLabelTransformer("seq")
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	shared := map[string]string{"color": "blue"}
+	for _, expected := range []string{"0", "1", "2"} {
+		req := &monitoringpb.CreateTimeSeriesRequest{
+			Name: "sequence",
+			TimeSeries: []*monitoringpb.TimeSeries{
+				{
+					Metric: &metricpb.Metric{
+						Type:   "sequence",
+						Labels: shared,
+					},
+				},
+			},
+		}
+		if err := transformer(req, generators.Metric{}); err != nil {
+			t.Fatalf("Transformer raised an unexpected exception: %v", err)
+		}
+		labels := req.TimeSeries[0].Metric.Labels
+		if labels["seq"] != expected {
+			t.Errorf("Expected sequence label %q, got %q", expected, labels["seq"])
+		}
+		if labels["color"] != "blue" {
+			t.Errorf("Expected existing label to be preserved, got %+v", labels)
+		}
+	}
+	if _, ok := shared["seq"]; ok {
+		t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+	}
+}
+
+// The NewPromoteResourceLabelsTransformer is expected to return a function that
+// copies the named resource labels to the metric labels, without changing the
+// original metric labels map.
+func TestNewPromoteResourceLabelsTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewPromoteResourceLabelsTransformer([]string{"zone", "instance_id", "missing"})
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	shared := map[string]string{"color": "blue"}
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name: "promote",
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Metric: &metricpb.Metric{
+					Type:   "promote",
+					Labels: shared,
+				},
+				Resource: &monitoredrespb.MonitoredResource{
+					Type: "gce_instance",
+					Labels: map[string]string{
+						"project_id":  project,
+						"instance_id": instance,
+						"zone":        zone,
+					},
+				},
+			},
+			{
+				Metric: &metricpb.Metric{
+					Type: "no-resource",
+				},
+			},
+		},
+	}
+	if err := transformer(req, generators.Metric{}); err != nil {
+		t.Fatalf("Transformer raised an unexpected exception: %v", err)
+	}
+	expected := map[string]string{
+		"color":       "blue",
+		"instance_id": instance,
+		"zone":        zone,
+	}
+	if !reflect.DeepEqual(req.TimeSeries[0].Metric.Labels, expected) {
+		t.Errorf("Expected metric labels %+v, got %+v", expected, req.TimeSeries[0].Metric.Labels)
+	}
+	if req.TimeSeries[1].Metric.Labels != nil {
+		t.Errorf("Expected metric labels of series without a resource to be unchanged, got %+v", req.TimeSeries[1].Metric.Labels)
+	}
+	if len(shared) != 1 {
+		t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+	}
+}
+
+// The NewTimeBucketLabelTransformer is expected to return a function that adds
+// a metric label derived from the timestamp of the metric, in UTC.
+func TestNewTimeBucketLabelTransformer(t *testing.T) {
+	timestamp := time.Date(2024, time.July, 1, 14, 30, 0, 0, time.FixedZone("test", -7*60*60))
+	tests := []struct {
+		name     string
+		layout   string
+		expected string
+	}{
+		{
+			name:     "hour",
+			layout:   "15",
+			expected: "21",
+		},
+		{
+			name:     "weekday",
+			layout:   "Monday",
+			expected: "Monday",
+		},
+		{
+			name:     "date-hour",
+			layout:   "2006-01-02T15",
+			expected: "2024-07-01T21",
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			transformer := pipeline.NewTimeBucketLabelTransformer("bucket", tst.layout)
+			if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+				t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+			}
+			shared := map[string]string{"color": "blue"}
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: shared,
+						},
+					},
+				},
+			}
+			if err := transformer(req, generators.Metric{Value: 1.1, Timestamp: timestamp}); err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			expected := map[string]string{
+				"color":  "blue",
+				"bucket": tst.expected,
+			}
+			if !reflect.DeepEqual(req.TimeSeries[0].Metric.Labels, expected) {
+				t.Errorf("Expected metric labels %+v, got %+v", expected, req.TimeSeries[0].Metric.Labels)
+			}
+			if len(shared) != 1 {
+				t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+			}
+		})
+	}
+}
+
+func TestNewValueBucketLabelTransformer(t *testing.T) {
+	thresholds := []float64{10.0, 50.0, 90.0}
+	labels := []string{"idle", "low", "high", "critical"}
+	tests := []struct {
+		name     string
+		value    float64
+		expected string
+	}{
+		{
+			name:     "below-first",
+			value:    -5.0,
+			expected: "idle",
+		},
+		{
+			name:     "first-band",
+			value:    9.999,
+			expected: "idle",
+		},
+		{
+			// A value equal to a threshold is in the band above it.
+			name:     "first-boundary",
+			value:    10.0,
+			expected: "low",
+		},
+		{
+			name:     "second-band",
+			value:    30.0,
+			expected: "low",
+		},
+		{
+			name:     "second-boundary",
+			value:    50.0,
+			expected: "high",
+		},
+		{
+			name:     "third-band",
+			value:    89.5,
+			expected: "high",
+		},
+		{
+			name:     "last-boundary",
+			value:    90.0,
+			expected: "critical",
+		},
+		{
+			name:     "above-last",
+			value:    1000.0,
+			expected: "critical",
+		},
+	}
+	transformer := pipeline.NewValueBucketLabelTransformer("bucket", thresholds, labels)
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			shared := map[string]string{"color": "blue"}
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: shared,
+						},
+					},
+				},
+			}
+			if err := transformer(req, generators.Metric{Value: tst.value, Timestamp: time.Now()}); err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			expected := map[string]string{
+				"color":  "blue",
+				"bucket": tst.expected,
+			}
+			if !reflect.DeepEqual(req.TimeSeries[0].Metric.Labels, expected) {
+				t.Errorf("Expected metric labels %+v, got %+v", expected, req.TimeSeries[0].Metric.Labels)
+			}
+			if len(shared) != 1 {
+				t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+			}
+		})
+	}
+}
+
+func TestNewValueBucketLabelTransformerInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []float64
+		labels     []string
+	}{
+		{
+			name:       "too-few-labels",
+			thresholds: []float64{10.0, 50.0},
+			labels:     []string{"low", "high"},
+		},
+		{
+			name:       "too-many-labels",
+			thresholds: []float64{10.0},
+			labels:     []string{"low", "medium", "high"},
+		},
+		{
+			name:       "decreasing",
+			thresholds: []float64{50.0, 10.0},
+			labels:     []string{"low", "medium", "high"},
+		},
+		{
+			name:       "duplicate",
+			thresholds: []float64{10.0, 10.0},
+			labels:     []string{"low", "medium", "high"},
+		},
+		{
+			name:       "nan",
+			thresholds: []float64{math.NaN()},
+			labels:     []string{"low", "high"},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			transformer := pipeline.NewValueBucketLabelTransformer("bucket", tst.thresholds, tst.labels)
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: tst.name,
+						},
+					},
+				},
+			}
+			if err := transformer(req, generators.Metric{Value: 1.0, Timestamp: time.Now()}); !errors.Is(err, pipeline.ErrInvalidValueBuckets) {
+				t.Errorf("Expected transformer to raise %v, got %v", pipeline.ErrInvalidValueBuckets, err)
+			}
+		})
+	}
+}
+
+func TestNewRotatingLabelTransformer(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	values := []string{"trace-a", "trace-b", "trace-c"}
+	tests := []struct {
+		name     string
+		values   []string
+		every    time.Duration
+		offsets  []time.Duration
+		expected []string
+	}{
+		{
+			// The value is constant within each minute, and changes at the
+			// start of the next, wrapping after the last value.
+			name:     "every-minute",
+			values:   values,
+			every:    time.Minute,
+			offsets:  []time.Duration{0, 30 * time.Second, 59 * time.Second, time.Minute, 90 * time.Second, 2 * time.Minute, 3 * time.Minute, 4*time.Minute + 10*time.Second},
+			expected: []string{"trace-a", "trace-a", "trace-a", "trace-b", "trace-b", "trace-c", "trace-a", "trace-b"},
+		},
+		{
+			name:     "before-epoch",
+			values:   values,
+			every:    time.Minute,
+			offsets:  []time.Duration{-start.Sub(time.Unix(0, 0)) - time.Minute, -start.Sub(time.Unix(0, 0)) - time.Second},
+			expected: []string{"trace-c", "trace-c"},
+		},
+		{
+			name:     "not-positive",
+			values:   values,
+			every:    0,
+			offsets:  []time.Duration{0, time.Minute, time.Hour},
+			expected: []string{"trace-a", "trace-a", "trace-a"},
+		},
+		{
+			name:     "empty",
+			every:    time.Minute,
+			offsets:  []time.Duration{0, time.Minute},
+			expected: []string{"", ""},
+		},
+	}
+	transformer := pipeline.NewRotatingLabelTransformer("trace_id", values, time.Minute)
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			transformer := pipeline.NewRotatingLabelTransformer("trace_id", tst.values, tst.every)
+			for i, offset := range tst.offsets {
+				shared := map[string]string{"color": "blue"}
+				req := &monitoringpb.CreateTimeSeriesRequest{
+					Name: tst.name,
+					TimeSeries: []*monitoringpb.TimeSeries{
+						{
+							Metric: &metricpb.Metric{
+								Type:   tst.name,
+								Labels: shared,
+							},
+						},
+					},
+				}
+				if err := transformer(req, generators.Metric{Value: 1.0, Timestamp: start.Add(offset)}); err != nil {
+					t.Fatalf("Transformer raised an unexpected exception: %v", err)
+				}
+				value, ok := req.TimeSeries[0].Metric.Labels["trace_id"]
+				if value != tst.expected[i] || ok != (tst.expected[i] != "") {
+					t.Errorf("Expected label %d to be %q, got %q", i, tst.expected[i], value)
+				}
+				if len(shared) != 1 {
+					t.Errorf("Expected shared labels map to be unchanged, got %+v", shared)
+				}
+			}
+		})
+	}
+}
+
+// The NewDistributionTypedValueTransformer is expected to return a function that
+// replaces the points of every TimeSeries with a single-sample distribution, with
+// the value counted in the correct bucket.
+func TestNewDistributionTypedValueTransformer(t *testing.T) {
+	exponential, err := pipeline.NewExponentialBucketOptions(2.0, 1.0, 4)
+	if err != nil {
+		t.Fatalf("NewExponentialBucketOptions raised an unexpected error: %v", err)
+	}
+	explicit, err := pipeline.NewExplicitBucketOptions([]float64{0.0, 10.0, 20.0})
+	if err != nil {
+		t.Fatalf("NewExplicitBucketOptions raised an unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		options  *distributionpb.Distribution_BucketOptions
+		value    float64
+		expected []int64
+	}{
+		{
+			name:     "exponential-underflow",
+			options:  exponential,
+			value:    0.5,
+			expected: []int64{1, 0, 0, 0, 0, 0},
+		},
+		{
+			name:     "exponential-first",
+			options:  exponential,
+			value:    1.0,
+			expected: []int64{0, 1, 0, 0, 0, 0},
+		},
+		{
+			name:     "exponential-third",
+			options:  exponential,
+			value:    5.0,
+			expected: []int64{0, 0, 0, 1, 0, 0},
+		},
+		{
+			name:     "exponential-overflow",
+			options:  exponential,
+			value:    100.0,
+			expected: []int64{0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:     "explicit-underflow",
+			options:  explicit,
+			value:    -1.0,
+			expected: []int64{1, 0, 0, 0},
+		},
+		{
+			name:     "explicit-bound",
+			options:  explicit,
+			value:    10.0,
+			expected: []int64{0, 0, 1, 0},
+		},
+		{
+			name:     "explicit-overflow",
+			options:  explicit,
+			value:    20.0,
+			expected: []int64{0, 0, 0, 1},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: tst.name,
+						},
+					},
+				},
+			}
+			err := pipeline.NewDistributionTypedValueTransformer(tst.options)(req, generators.Metric{
+				Value:     tst.value,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			distribution := req.TimeSeries[0].Points[0].GetValue().GetDistributionValue()
+			switch {
+			case distribution.GetCount() != 1:
+				t.Errorf("Expected count 1, got %d", distribution.GetCount())
+			case distribution.GetMean() != tst.value:
+				t.Errorf("Expected mean %f, got %f", tst.value, distribution.GetMean())
+			case !reflect.DeepEqual(distribution.GetBucketCounts(), tst.expected):
+				t.Errorf("Expected bucket counts %v, got %v", tst.expected, distribution.GetBucketCounts())
+			}
+		})
+	}
+}
+
+func TestNewDistributionTypedValueTransformerErrors(t *testing.T) {
+	t.Parallel()
+	if err := pipeline.NewDistributionTypedValueTransformer(nil)(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	if err := pipeline.NewDistributionTypedValueTransformer(nil)(&monitoringpb.CreateTimeSeriesRequest{}, generators.Metric{}); !errors.Is(err, pipeline.ErrNilBucketOptions) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilBucketOptions, err)
+	}
+	options, err := pipeline.NewExplicitBucketOptions([]float64{0.0, 10.0})
+	if err != nil {
+		t.Fatalf("NewExplicitBucketOptions raised an unexpected error: %v", err)
+	}
+	if err := pipeline.NewDistributionTypedValueTransformer(options)(&monitoringpb.CreateTimeSeriesRequest{}, generators.Metric{Value: math.NaN()}); !errors.Is(err, pipeline.ErrNonFiniteValue) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNonFiniteValue, err)
+	}
+}
+
+// The NewMovingAverageTransformer is expected to return a function that replaces
+// each point value with the mean of a sliding window of values.
+func TestNewMovingAverageTransformer(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    int
+		asInteger bool
+		inputs    []float64
+		expected  []float64
+	}{
+		{
+			name:     "double-window-3",
+			window:   3,
+			inputs:   []float64{1.0, 2.0, 3.0, 4.0, 5.0, 9.0},
+			expected: []float64{1.0, 1.5, 2.0, 3.0, 4.0, 6.0},
+		},
+		{
+			name:     "double-window-1",
+			window:   1,
+			inputs:   []float64{1.0, 5.0, 2.0},
+			expected: []float64{1.0, 5.0, 2.0},
+		},
+		{
+			name:     "double-window-0",
+			window:   0,
+			inputs:   []float64{1.0, 5.0, 2.0},
+			expected: []float64{1.0, 5.0, 2.0},
+		},
+		{
+			name:     "double-larger-window",
+			window:   10,
+			inputs:   []float64{2.0, 4.0, 6.0},
+			expected: []float64{2.0, 3.0, 4.0},
+		},
+		{
+			name:      "integer-window-2",
+			window:    2,
+			asInteger: true,
+			inputs:    []float64{1.0, 2.0, 4.0, 8.0, 8.0},
+			expected:  []float64{1.0, 2.0, 3.0, 6.0, 8.0},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			valueTransformer := pipeline.NewDoubleTypedValueTransformer()
+			if tst.asInteger {
+				valueTransformer = pipeline.NewIntegerTypedValueTransformer()
+			}
+			transformer := pipeline.NewMovingAverageTransformer(tst.window)
+			if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+				t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+			}
+			start := time.Now()
+			for i, input := range tst.inputs {
+				metric := generators.Metric{
+					Value:     input,
+					Timestamp: start.Add(time.Duration(i) * time.Minute),
+				}
+				req := &monitoringpb.CreateTimeSeriesRequest{
+					Name: tst.name,
+					TimeSeries: []*monitoringpb.TimeSeries{
+						{
+							Metric: &metricpb.Metric{
+								Type: tst.name,
+							},
+						},
+					},
+				}
+				if err := valueTransformer(req, metric); err != nil {
+					t.Fatalf("Value transformer raised an unexpected exception: %v", err)
+				}
+				if err := transformer(req, metric); err != nil {
+					t.Fatalf("Transformer raised an unexpected exception: %v", err)
+				}
+				value := req.TimeSeries[0].Points[0].Value
+				var result float64
+				if tst.asInteger {
+					result = float64(value.GetInt64Value())
+				} else {
+					result = value.GetDoubleValue()
+				}
+				if result != tst.expected[i] {
+					t.Errorf("Expected value %d to be %v, got %v", i, tst.expected[i], result)
+				}
+			}
+		})
+	}
+}
+
+// The NewMovingAverageTransformer is expected to keep a separate window for each
+// time-series, so that the values of one series do not change the mean of
+// another.
+func TestNewMovingAverageTransformerPerSeries(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewMovingAverageTransformer(2)
+	newSeries := func(labels map[string]string, resource string, value float64) *monitoringpb.TimeSeries {
+		return &monitoringpb.TimeSeries{
+			Metric: &metricpb.Metric{
+				Type:   "custom.googleapis.com/test",
+				Labels: labels,
+			},
+			Resource: &monitoredrespb.MonitoredResource{
+				Type:   "generic_node",
+				Labels: map[string]string{"node_id": resource},
+			},
+			Points: []*monitoringpb.Point{
+				{
+					Value: &monitoringpb.TypedValue{
+						Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: value},
+					},
+				},
+			},
+		}
+	}
+	inputs := [][]float64{{1.0, 100.0, 10.0}, {3.0, 300.0, 30.0}}
+	expected := [][]float64{{1.0, 100.0, 10.0}, {2.0, 200.0, 20.0}}
+	for i, values := range inputs {
+		req := &monitoringpb.CreateTimeSeriesRequest{
+			TimeSeries: []*monitoringpb.TimeSeries{
+				newSeries(map[string]string{"key": "a"}, node, values[0]),
+				newSeries(map[string]string{"key": "b"}, node, values[1]),
+				newSeries(map[string]string{"key": "a"}, "other-node", values[2]),
+			},
+		}
+		if err := transformer(req, generators.Metric{}); err != nil {
+			t.Fatalf("Transformer raised an unexpected exception: %v", err)
+		}
+		for j, series := range req.GetTimeSeries() {
+			if result := series.GetPoints()[0].GetValue().GetDoubleValue(); result != expected[i][j] {
+				t.Errorf("Expected value %d of series %d to be %v, got %v", i, j, expected[i][j], result)
+			}
+		}
+	}
+}
+
+// The NewLabelWeightedValueTransformer is expected to return a function that
+// scales each point value by the weight of the time-series label value.
+func TestNewLabelWeightedValueTransformer(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		asInteger bool
+		input     float64
+		expected  float64
+	}{
+		{
+			name:     "us",
+			labels:   map[string]string{"region": "us"},
+			input:    3.5,
+			expected: 7.0,
+		},
+		{
+			name:     "eu",
+			labels:   map[string]string{"region": "eu"},
+			input:    3.5,
+			expected: 3.5,
+		},
+		{
+			name:     "unweighted-value",
+			labels:   map[string]string{"region": "apac"},
+			input:    3.5,
+			expected: 3.5,
+		},
+		{
+			name:     "no-label",
+			input:    3.5,
+			expected: 3.5,
+		},
+		{
+			name:      "integer-us",
+			labels:    map[string]string{"region": "us"},
+			asInteger: true,
+			input:     3.0,
+			expected:  6.0,
+		},
+		{
+			name:      "integer-half",
+			labels:    map[string]string{"region": "half"},
+			asInteger: true,
+			input:     3.0,
+			expected:  2.0,
+		},
+	}
+	weights := map[string]float64{
+		"us":   2.0,
+		"eu":   1.0,
+		"half": 0.5,
+	}
+	transformer := pipeline.NewLabelWeightedValueTransformer("region", weights)
+	// Changes to the weights after the transformer is created are ignored.
+	weights["eu"] = 10.0
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			valueTransformer := pipeline.NewDoubleTypedValueTransformer()
+			if tst.asInteger {
+				valueTransformer = pipeline.NewIntegerTypedValueTransformer()
+			}
+			metric := generators.Metric{
+				Value:     tst.input,
+				Timestamp: time.Now(),
+			}
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: tst.labels,
+						},
+					},
+				},
+			}
+			if err := valueTransformer(req, metric); err != nil {
+				t.Fatalf("Value transformer raised an unexpected exception: %v", err)
+			}
+			if err := transformer(req, metric); err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			value := req.TimeSeries[0].Points[0].Value
+			var result float64
+			if tst.asInteger {
+				result = float64(value.GetInt64Value())
+			} else {
+				result = value.GetDoubleValue()
+			}
+			if result != tst.expected {
+				t.Errorf("Expected value to be %v, got %v", tst.expected, result)
+			}
+		})
+	}
+}
+
+// The NewRoundTransformer is expected to return a function that rounds double
+// point values to the number of decimals, and leaves int64 values unchanged.
+func TestNewRoundTransformer(t *testing.T) {
+	tests := []struct {
+		name      string
+		decimals  int
+		asInteger bool
+		input     float64
+		expected  float64
+	}{
+		{
+			name:     "zero",
+			decimals: 0,
+			input:    3.5,
+			expected: 4.0,
+		},
+		{
+			name:     "zero-negative",
+			decimals: 0,
+			input:    -2.5,
+			expected: -3.0,
+		},
+		{
+			name:     "zero-small-negative",
+			decimals: 0,
+			input:    -0.4,
+			expected: 0.0,
+		},
+		{
+			name:     "two",
+			decimals: 2,
+			input:    3.14159,
+			expected: 3.14,
+		},
+		{
+			name:     "two-up",
+			decimals: 2,
+			input:    2.71828,
+			expected: 2.72,
+		},
+		{
+			name:     "minus-one",
+			decimals: -1,
+			input:    1234.5,
+			expected: 1230.0,
+		},
+		{
+			name:     "minus-two",
+			decimals: -2,
+			input:    1250.0,
+			expected: 1300.0,
+		},
+		{
+			name:     "overflow",
+			decimals: 400,
+			input:    1.23456,
+			expected: 1.23456,
+		},
+		{
+			name:      "integer",
+			decimals:  -1,
+			asInteger: true,
+			input:     1234.0,
+			expected:  1234.0,
+		},
+	}
+	transformer := pipeline.NewRoundTransformer(0)
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			valueTransformer := pipeline.NewDoubleTypedValueTransformer()
+			if tst.asInteger {
+				valueTransformer = pipeline.NewIntegerTypedValueTransformer()
+			}
+			metric := generators.Metric{
+				Value:     tst.input,
+				Timestamp: time.Now(),
+			}
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: tst.name,
+						},
+					},
+				},
+			}
+			if err := valueTransformer(req, metric); err != nil {
+				t.Fatalf("Value transformer raised an unexpected exception: %v", err)
+			}
+			if err := pipeline.NewRoundTransformer(tst.decimals)(req, metric); err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			value := req.TimeSeries[0].Points[0].Value
+			if tst.asInteger {
+				if value.GetInt64Value() != int64(tst.expected) {
+					t.Errorf("Expected int64 value to be %v, got %v", tst.expected, value.GetInt64Value())
+				}
+				return
+			}
+			if _, ok := value.GetValue().(*monitoringpb.TypedValue_DoubleValue); !ok {
+				t.Fatalf("Expected a double value, got %v", value)
+			}
+			if math.Abs(value.GetDoubleValue()-tst.expected) > 1e-9 || math.Signbit(value.GetDoubleValue()) != math.Signbit(tst.expected) {
+				t.Errorf("Expected value to be %v, got %v", tst.expected, value.GetDoubleValue())
+			}
+			if tst.decimals != 0 {
+				return
+			}
+			// Rounding to zero decimals matches the integer transformer.
+			integerReq := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type: tst.name,
+						},
+					},
+				},
+			}
+			if err := pipeline.NewIntegerTypedValueTransformer()(integerReq, metric); err != nil {
+				t.Fatalf("Integer transformer raised an unexpected exception: %v", err)
+			}
+			if integer := integerReq.TimeSeries[0].Points[0].Value.GetInt64Value(); float64(integer) != value.GetDoubleValue() {
+				t.Errorf("Expected value to match integer transformer value %d, got %v", integer, value.GetDoubleValue())
+			}
+		})
+	}
+}
+
+// The NewResourceLabelsTransformer is expected to return a function that merges
+// the labels into the monitored resource, without changing the original
+// resource which may be shared with other requests.
+func TestNewResourceLabelsTransformer(t *testing.T) {
+	t.Parallel()
+	transformer := pipeline.NewResourceLabelsTransformer(map[string]string{
+		"node_id":   "override",
+		"namespace": namespace,
+	})
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	original := &monitoredrespb.MonitoredResource{
+		Type: "generic_node",
+		Labels: map[string]string{
+			"project_id": project,
+			"location":   location,
+			"namespace":  "default",
+			"node_id":    node,
+		},
+	}
+	req := &monitoringpb.CreateTimeSeriesRequest{
+		Name: "projects/" + project,
+		TimeSeries: []*monitoringpb.TimeSeries{
+			{
+				Resource: original,
+			},
+			{},
+		},
+	}
+	if err := transformer(req, generators.Metric{}); err != nil {
+		t.Fatalf("Transformer raised an unexpected exception: %v", err)
+	}
+	expected := &monitoredrespb.MonitoredResource{
+		Type: "generic_node",
+		Labels: map[string]string{
+			"project_id": project,
+			"location":   location,
+			"namespace":  namespace,
+			"node_id":    "override",
+		},
+	}
+	if !proto.Equal(req.TimeSeries[0].Resource, expected) {
+		t.Errorf("Expected resource %v, got %v", expected, req.TimeSeries[0].Resource)
+	}
+	if original.Labels["node_id"] != node {
+		t.Errorf("Expected the original resource to be unchanged, got %v", original.Labels)
+	}
+	if req.TimeSeries[1].Resource != nil {
+		t.Errorf("Expected time-series without a resource to be unchanged, got %v", req.TimeSeries[1].Resource)
+	}
+}
+
+// The NewLabelFilterTransformer is expected to return a function that removes
+// the listed metric labels, and resource labels that are not required by the
+// monitored resource type.
+func TestNewLabelFilterTransformer(t *testing.T) {
+	tests := []struct {
+		name             string
+		dropKeys         []string
+		resource         *monitoredrespb.MonitoredResource
+		expectedMetric   map[string]string
+		expectedResource map[string]string
+	}{
+		{
+			name:     "present-metric-label",
+			dropKeys: []string{"host"},
+			resource: &monitoredrespb.MonitoredResource{
+				Type:   "global",
+				Labels: map[string]string{"project_id": project},
+			},
+			expectedMetric:   map[string]string{"env": "test"},
+			expectedResource: map[string]string{"project_id": project},
+		},
+		{
+			name:     "absent-label",
+			dropKeys: []string{"missing"},
+			resource: &monitoredrespb.MonitoredResource{
+				Type:   "global",
+				Labels: map[string]string{"project_id": project},
+			},
+			expectedMetric:   map[string]string{"env": "test", "host": "test-host"},
+			expectedResource: map[string]string{"project_id": project},
+		},
+		{
+			name:     "optional-resource-label",
+			dropKeys: []string{"extra", "env"},
+			resource: &monitoredrespb.MonitoredResource{
+				Type:   "global",
+				Labels: map[string]string{"project_id": project, "extra": "value"},
+			},
+			expectedMetric:   map[string]string{"host": "test-host"},
+			expectedResource: map[string]string{"project_id": project},
+		},
+		{
+			name:     "required-resource-label",
+			dropKeys: []string{"zone", "instance_id"},
+			resource: &monitoredrespb.MonitoredResource{
+				Type:   "gce_instance",
+				Labels: map[string]string{"project_id": project, "instance_id": instance, "zone": zone},
+			},
+			expectedMetric:   map[string]string{"env": "test", "host": "test-host"},
+			expectedResource: map[string]string{"project_id": project, "instance_id": instance, "zone": zone},
+		},
+		{
+			name:     "unknown-resource-type",
+			dropKeys: []string{"zone"},
+			resource: &monitoredrespb.MonitoredResource{
+				Type:   "unknown",
+				Labels: map[string]string{"project_id": project, "zone": zone},
+			},
+			expectedMetric:   map[string]string{"env": "test", "host": "test-host"},
+			expectedResource: map[string]string{"project_id": project},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			transformer := pipeline.NewLabelFilterTransformer(logr.Discard(), tst.dropKeys)
+			if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+				t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+			}
+			metricLabels := map[string]string{"env": "test", "host": "test-host"}
+			resourceLabels := maps.Clone(tst.resource.Labels)
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: "projects/" + project,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: metricLabels,
+						},
+						Resource: tst.resource,
+					},
+				},
+			}
+			if err := transformer(req, generators.Metric{}); err != nil {
+				t.Fatalf("Transformer raised an unexpected exception: %v", err)
+			}
+			if labels := req.TimeSeries[0].Metric.Labels; !reflect.DeepEqual(labels, tst.expectedMetric) {
+				t.Errorf("Expected metric labels %v, got %v", tst.expectedMetric, labels)
+			}
+			if labels := req.TimeSeries[0].Resource.Labels; !reflect.DeepEqual(labels, tst.expectedResource) {
+				t.Errorf("Expected resource labels %v, got %v", tst.expectedResource, labels)
+			}
+			// The original maps may be shared and must not be modified.
+			if len(metricLabels) != 2 {
+				t.Errorf("Expected the original metric labels to be unchanged, got %v", metricLabels)
+			}
+			if !reflect.DeepEqual(tst.resource.Labels, resourceLabels) {
+				t.Errorf("Expected the original resource labels to be unchanged, got %v", tst.resource.Labels)
+			}
+		})
+	}
+}
+
+func TestNewDriftTransformer(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratePerHour float64
+		asInteger   bool
+		expected    []float64
+	}{
+		{
+			name:        "rising",
+			ratePerHour: 2.0,
+			expected:    []float64{10.0, 11.0, 12.0, 13.0, 14.0},
+		},
+		{
+			name:        "falling",
+			ratePerHour: -4.0,
+			expected:    []float64{10.0, 8.0, 6.0, 4.0, 2.0},
+		},
+		{
+			name:        "zero",
+			ratePerHour: 0.0,
+			expected:    []float64{10.0, 10.0, 10.0, 10.0, 10.0},
+		},
+		{
+			name:        "integer",
+			ratePerHour: 3.0,
+			asInteger:   true,
+			expected:    []float64{10.0, 12.0, 13.0, 15.0, 16.0},
+		},
+	}
+	transformer := pipeline.NewDriftTransformer(1.0)
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			valueTransformer := pipeline.NewDoubleTypedValueTransformer()
+			if tst.asInteger {
+				valueTransformer = pipeline.NewIntegerTypedValueTransformer()
+			}
+			transformer := pipeline.NewDriftTransformer(tst.ratePerHour)
+			for i, expected := range tst.expected {
+				// Metrics are 30 minutes apart, so the offset grows by half the
+				// hourly rate each time.
+				metric := generators.Metric{
+					Value:     10.0,
+					Timestamp: start.Add(time.Duration(i) * 30 * time.Minute),
+				}
+				req := &monitoringpb.CreateTimeSeriesRequest{
+					Name: tst.name,
+					TimeSeries: []*monitoringpb.TimeSeries{
+						{
+							Metric: &metricpb.Metric{
+								Type: tst.name,
+							},
+						},
+					},
+				}
+				if err := valueTransformer(req, metric); err != nil {
+					t.Fatalf("Value transformer raised an unexpected exception: %v", err)
+				}
+				if err := transformer(req, metric); err != nil {
+					t.Fatalf("Transformer raised an unexpected exception: %v", err)
+				}
+				value := req.TimeSeries[0].Points[0].Value
+				if tst.asInteger {
+					if value.GetInt64Value() != int64(expected) {
+						t.Errorf("Expected int64 value %d to be %v, got %v", i, expected, value.GetInt64Value())
+					}
+					continue
+				}
+				if math.Abs(value.GetDoubleValue()-expected) > 1e-9 {
+					t.Errorf("Expected value %d to be %v, got %v", i, expected, value.GetDoubleValue())
+				}
+			}
+		})
+	}
+}
+
+func TestNewLabelKeyValidationTransformer(t *testing.T) {
+	tests := []struct {
+		name           string
+		metricLabels   map[string]string
+		resourceLabels map[string]string
+		expectedErr    error
+		expectedKey    string
+	}{
+		{
+			name:           "valid",
+			metricLabels:   map[string]string{"host": "a", "deploy_version": "b", "a1": "c"},
+			resourceLabels: map[string]string{"project_id": "d", "node_id": "e"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:           "max-length",
+			metricLabels:   map[string]string{strings.Repeat("a", pipeline.MaxLabelKeyLength): "a"},
+			resourceLabels: map[string]string{},
+		},
+		{
+			name:         "uppercase",
+			metricLabels: map[string]string{"host": "a", "Host": "b"},
+			expectedErr:  pipeline.ErrInvalidLabelKey,
+			expectedKey:  `"Host"`,
+		},
+		{
+			name:         "leading-digit",
+			metricLabels: map[string]string{"1host": "a"},
+			expectedErr:  pipeline.ErrInvalidLabelKey,
+			expectedKey:  `"1host"`,
+		},
+		{
+			name:         "leading-underscore",
+			metricLabels: map[string]string{"_host": "a"},
+			expectedErr:  pipeline.ErrInvalidLabelKey,
+			expectedKey:  `"_host"`,
+		},
+		{
+			name:         "dot",
+			metricLabels: map[string]string{"test.key": "a"},
+			expectedErr:  pipeline.ErrInvalidLabelKey,
+			expectedKey:  `"test.key"`,
+		},
+		{
+			name:         "over-length",
+			metricLabels: map[string]string{strings.Repeat("a", pipeline.MaxLabelKeyLength+1): "a"},
+			expectedErr:  pipeline.ErrInvalidLabelKey,
+			expectedKey:  strconv.Quote(strings.Repeat("a", pipeline.MaxLabelKeyLength+1)),
+		},
+		{
+			name:           "resource-label",
+			metricLabels:   map[string]string{"host": "a"},
+			resourceLabels: map[string]string{"Zone": "b"},
+			expectedErr:    pipeline.ErrInvalidLabelKey,
+			expectedKey:    `resource label "Zone"`,
+		},
+	}
+	transformer := pipeline.NewLabelKeyValidationTransformer()
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: tst.metricLabels,
+						},
+						Resource: &monitoredrespb.MonitoredResource{
+							Type:   "generic_node",
+							Labels: tst.resourceLabels,
+						},
+					},
+				},
+			}
+			err := transformer(req, generators.Metric{})
+			switch {
+			case tst.expectedErr == nil && err != nil:
+				t.Errorf("Transformer raised an unexpected error: %v", err)
+			case tst.expectedErr != nil && !errors.Is(err, tst.expectedErr):
+				t.Errorf("Expected transformer to raise %v, got %v", tst.expectedErr, err)
+			case tst.expectedErr != nil && !strings.Contains(err.Error(), tst.expectedKey):
+				t.Errorf("Expected error to name %s, got %v", tst.expectedKey, err)
+			}
+		})
+	}
+}
+
+func TestNewMultiResourceTransformer(t *testing.T) {
+	tests := []struct {
+		name                  string
+		resourceTransformers  []pipeline.Transformer
+		expectedResourceTypes []string
+	}{
+		{
+			name: "none",
+			// The request is unchanged without resource transformers.
+			expectedResourceTypes: []string{""},
+		},
+		{
+			name: "single",
+			resourceTransformers: []pipeline.Transformer{
+				pipeline.NewGlobalMonitoredResourceTransformer(project),
+			},
+			expectedResourceTypes: []string{"global"},
+		},
+		{
+			name: "gce-generic",
+			resourceTransformers: []pipeline.Transformer{
+				pipeline.NewGCEMonitoredResourceTransformer(project, instance, zone),
+				pipeline.NewGenericMonitoredResourceTransformer(project, location, namespace, node),
+			},
+			expectedResourceTypes: []string{"gce_instance", "generic_node"},
+		},
+	}
+	transformer := pipeline.NewMultiResourceTransformer()
+	if err := transformer(nil, generators.Metric{}); !errors.Is(err, pipeline.ErrNilCreateTimeSeriesRequest) {
+		t.Errorf("Expected transform to raise %v, got %v", pipeline.ErrNilCreateTimeSeriesRequest, err)
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			metric := generators.Metric{
+				Value:     2.5,
+				Timestamp: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}
+			req := &monitoringpb.CreateTimeSeriesRequest{
+				Name: tst.name,
+				TimeSeries: []*monitoringpb.TimeSeries{
+					{
+						Metric: &metricpb.Metric{
+							Type:   tst.name,
+							Labels: map[string]string{"host": "a"},
+						},
+					},
+				},
+			}
+			if err := pipeline.NewDoubleTypedValueTransformer()(req, metric); err != nil {
+				t.Fatalf("Value transformer raised an unexpected error: %v", err)
+			}
+			if err := pipeline.NewMultiResourceTransformer(tst.resourceTransformers...)(req, metric); err != nil {
+				t.Fatalf("Transformer raised an unexpected error: %v", err)
+			}
+			if len(req.TimeSeries) != len(tst.expectedResourceTypes) {
+				t.Fatalf("Expected %d time-series, got %d", len(tst.expectedResourceTypes), len(req.TimeSeries))
+			}
+			for i, series := range req.TimeSeries {
+				if got := series.GetResource().GetType(); got != tst.expectedResourceTypes[i] {
+					t.Errorf("Expected time-series %d to have resource type %q, got %q", i, tst.expectedResourceTypes[i], got)
+				}
+				if !proto.Equal(series.GetMetric(), req.TimeSeries[0].GetMetric()) {
+					t.Errorf("Expected time-series %d to have metric %v, got %v", i, req.TimeSeries[0].GetMetric(), series.GetMetric())
+				}
+				if len(series.GetPoints()) != 1 || !proto.Equal(series.GetPoints()[0], req.TimeSeries[0].GetPoints()[0]) {
+					t.Errorf("Expected time-series %d to have points %v, got %v", i, req.TimeSeries[0].GetPoints(), series.GetPoints())
+				}
+			}
+			// Each copy must be independent, so that transformers that follow can
+			// change one without affecting the others.
+			if len(req.TimeSeries) > 1 && req.TimeSeries[0].GetPoints()[0] == req.TimeSeries[1].GetPoints()[0] {
+				t.Errorf("Expected each time-series to have a distinct point")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/memes/gce-metric/pkg/generators"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrMetricDescriptorNotFound = errors.New("metric descriptor does not exist")
+	ErrMetricKindMismatch       = errors.New("metric kind does not match descriptor")
+	ErrValueTypeMismatch        = errors.New("value type does not match descriptor")
+	ErrUnknownMetricLabel       = errors.New("metric label is not defined in descriptor")
+	ErrUnknownResourceType      = errors.New("monitored resource type is not known")
+	ErrUnknownResourceLabel     = errors.New("resource label is not defined in monitored resource descriptor")
+)
+
+// Defines the subset of Cloud Monitoring MetricClient functions that are needed
+// to validate a CreateTimeSeriesRequest.
+type DescriptorClient interface {
+	GetMetricDescriptor(context.Context, *monitoringpb.GetMetricDescriptorRequest, ...gax.CallOption) (*metricpb.MetricDescriptor, error)
+	GetMonitoredResourceDescriptor(context.Context, *monitoringpb.GetMonitoredResourceDescriptorRequest, ...gax.CallOption) (*monitoredrespb.MonitoredResourceDescriptor, error)
+}
+
+// Verifies that every time-series in the request is consistent with the metric
+// and monitored resource descriptors known to Google Cloud, without writing any
+// data. All mismatches are returned as a single joined error; API failures other
+// than a missing descriptor are returned immediately.
+func ValidateRequest(ctx context.Context, client DescriptorClient, req *monitoringpb.CreateTimeSeriesRequest) error {
+	if req == nil {
+		return ErrNilCreateTimeSeriesRequest
+	}
+	problems := []error{}
+	for _, series := range req.TimeSeries {
+		metricProblems, err := validateMetric(ctx, client, req.Name, series)
+		if err != nil {
+			return err
+		}
+		resourceProblems, err := validateResource(ctx, client, req.Name, series)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, metricProblems...)
+		problems = append(problems, resourceProblems...)
+	}
+	return errors.Join(problems...)
+}
+
+// Builds a request for the metric and verifies it with ValidateRequest, using a
+// Cloud Monitoring client created with the pipeline's client options so that the
+// endpoint, proxy, CA certificates, and user-agent are the same as those used to
+// write time-series. Nothing is written.
+func (p *Pipeline) Validate(ctx context.Context, metric generators.Metric) error {
+	req, err := p.BuildRequest(metric)
+	if err != nil {
+		return fmt.Errorf("failure building time-series request: %w", err)
+	}
+	client, err := p.newMetricClient(ctx, p.clientOptions...)
+	if err != nil {
+		return fmt.Errorf("failure creating new metric client: %w", err)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			p.logger.V(2).Info("Error closing validation metric client; ignoring", "err", err)
+		}
+	}()
+	return ValidateRequest(ctx, client, req)
+}
+
+// Compares the metric kind, value type, and metric labels of the time-series
+// against the metric descriptor.
+func validateMetric(ctx context.Context, client DescriptorClient, name string, series *monitoringpb.TimeSeries) ([]error, error) {
+	metricType := series.GetMetric().GetType()
+	descriptor, err := client.GetMetricDescriptor(ctx, &monitoringpb.GetMetricDescriptorRequest{
+		Name: name + "/metricDescriptors/" + metricType,
+	})
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return []error{fmt.Errorf("%w: %s", ErrMetricDescriptorNotFound, metricType)}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failure getting metric descriptor for %s: %w", metricType, err)
+	}
+	problems := []error{}
+	if descriptor.MetricKind != metricpb.MetricDescriptor_METRIC_KIND_UNSPECIFIED && series.MetricKind != descriptor.MetricKind {
+		problems = append(problems, fmt.Errorf("%w: %s is %s, request has %s", ErrMetricKindMismatch, metricType, descriptor.MetricKind, series.MetricKind))
+	}
+	valueType := pointValueType(series)
+	if descriptor.ValueType != metricpb.MetricDescriptor_VALUE_TYPE_UNSPECIFIED && valueType != metricpb.MetricDescriptor_VALUE_TYPE_UNSPECIFIED && valueType != descriptor.ValueType {
+		problems = append(problems, fmt.Errorf("%w: %s is %s, request has %s", ErrValueTypeMismatch, metricType, descriptor.ValueType, valueType))
+	}
+	known := make(map[string]struct{}, len(descriptor.Labels))
+	for _, label := range descriptor.Labels {
+		known[label.Key] = struct{}{}
+	}
+	for key := range series.GetMetric().GetLabels() {
+		if _, ok := known[key]; !ok {
+			problems = append(problems, fmt.Errorf("%w: %s has no label %q", ErrUnknownMetricLabel, metricType, key))
+		}
+	}
+	return problems, nil
+}
+
+// Verifies the monitored resource type of the time-series is known, and that
+// all of the resource labels are defined for the type.
+func validateResource(ctx context.Context, client DescriptorClient, name string, series *monitoringpb.TimeSeries) ([]error, error) {
+	resourceType := series.GetResource().GetType()
+	descriptor, err := client.GetMonitoredResourceDescriptor(ctx, &monitoringpb.GetMonitoredResourceDescriptorRequest{
+		Name: name + "/monitoredResourceDescriptors/" + resourceType,
+	})
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return []error{fmt.Errorf("%w: %q", ErrUnknownResourceType, resourceType)}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failure getting monitored resource descriptor for %q: %w", resourceType, err)
+	}
+	known := make(map[string]struct{}, len(descriptor.Labels))
+	for _, label := range descriptor.Labels {
+		known[label.Key] = struct{}{}
+	}
+	problems := []error{}
+	for key := range series.GetResource().GetLabels() {
+		if _, ok := known[key]; !ok {
+			problems = append(problems, fmt.Errorf("%w: %s has no label %q", ErrUnknownResourceLabel, resourceType, key))
+		}
+	}
+	return problems, nil
+}
+
+// Returns the metric descriptor value type that matches the first point in the
+// time-series, or VALUE_TYPE_UNSPECIFIED if it cannot be determined.
+func pointValueType(series *monitoringpb.TimeSeries) metricpb.MetricDescriptor_ValueType {
+	if len(series.Points) == 0 {
+		return metricpb.MetricDescriptor_VALUE_TYPE_UNSPECIFIED
+	}
+	switch series.Points[0].GetValue().GetValue().(type) {
+	case *monitoringpb.TypedValue_BoolValue:
+		return metricpb.MetricDescriptor_BOOL
+	case *monitoringpb.TypedValue_Int64Value:
+		return metricpb.MetricDescriptor_INT64
+	case *monitoringpb.TypedValue_DoubleValue:
+		return metricpb.MetricDescriptor_DOUBLE
+	case *monitoringpb.TypedValue_StringValue:
+		return metricpb.MetricDescriptor_STRING
+	case *monitoringpb.TypedValue_DistributionValue:
+		return metricpb.MetricDescriptor_DISTRIBUTION
+	default:
+		return metricpb.MetricDescriptor_VALUE_TYPE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,208 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/memes/gce-metric/pkg/pipeline"
+	labelpb "google.golang.org/genproto/googleapis/api/label"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoredrespb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errTestAPI = errors.New("test API failure")
+
+// Implements the DescriptorClient interface with fixed descriptors, keyed by the
+// full resource name of the request.
+type fakeDescriptorClient struct {
+	metrics   map[string]*metricpb.MetricDescriptor
+	resources map[string]*monitoredrespb.MonitoredResourceDescriptor
+	err       error
+}
+
+func (f *fakeDescriptorClient) GetMetricDescriptor(_ context.Context, req *monitoringpb.GetMetricDescriptorRequest, _ ...gax.CallOption) (*metricpb.MetricDescriptor, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	descriptor, ok := f.metrics[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, req.Name)
+	}
+	return descriptor, nil
+}
+
+func (f *fakeDescriptorClient) GetMonitoredResourceDescriptor(_ context.Context, req *monitoringpb.GetMonitoredResourceDescriptorRequest, _ ...gax.CallOption) (*monitoredrespb.MonitoredResourceDescriptor, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	descriptor, ok := f.resources[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, req.Name)
+	}
+	return descriptor, nil
+}
+
+func newFakeDescriptorClient() *fakeDescriptorClient {
+	return &fakeDescriptorClient{
+		metrics: map[string]*metricpb.MetricDescriptor{
+			"projects/" + project + "/metricDescriptors/custom.googleapis.com/test": {
+				Type:       "custom.googleapis.com/test",
+				MetricKind: metricpb.MetricDescriptor_GAUGE,
+				ValueType:  metricpb.MetricDescriptor_DOUBLE,
+				Labels: []*labelpb.LabelDescriptor{
+					{Key: "color"},
+				},
+			},
+		},
+		resources: map[string]*monitoredrespb.MonitoredResourceDescriptor{
+			"projects/" + project + "/monitoredResourceDescriptors/generic_node": {
+				Type: "generic_node",
+				Labels: []*labelpb.LabelDescriptor{
+					{Key: "project_id"},
+					{Key: "location"},
+					{Key: "namespace"},
+					{Key: "node_id"},
+				},
+			},
+		},
+		err: nil,
+	}
+}
+
+// Returns a request with a single time-series that is valid for the fake
+// descriptor client, after applying any mutators.
+func newValidateTestRequest(mutators ...func(*monitoringpb.TimeSeries)) *monitoringpb.CreateTimeSeriesRequest {
+	series := &monitoringpb.TimeSeries{
+		Metric: &metricpb.Metric{
+			Type: "custom.googleapis.com/test",
+			Labels: map[string]string{
+				"color": "blue",
+			},
+		},
+		MetricKind: metricpb.MetricDescriptor_GAUGE,
+		Resource: &monitoredrespb.MonitoredResource{
+			Type: "generic_node",
+			Labels: map[string]string{
+				"project_id": project,
+				"location":   location,
+				"namespace":  namespace,
+				"node_id":    node,
+			},
+		},
+		Points: []*monitoringpb.Point{
+			{
+				Value: &monitoringpb.TypedValue{
+					Value: &monitoringpb.TypedValue_DoubleValue{
+						DoubleValue: 1.1,
+					},
+				},
+			},
+		},
+	}
+	for _, mutator := range mutators {
+		mutator(series)
+	}
+	return &monitoringpb.CreateTimeSeriesRequest{
+		Name:       "projects/" + project,
+		TimeSeries: []*monitoringpb.TimeSeries{series},
+	}
+}
+
+//nolint:funlen // The test cases/tables add lines to the function
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		clientErr      error
+		req            *monitoringpb.CreateTimeSeriesRequest
+		expectedErrors []error
+	}{
+		{
+			name:           "nil",
+			req:            nil,
+			expectedErrors: []error{pipeline.ErrNilCreateTimeSeriesRequest},
+		},
+		{
+			name: "valid",
+			req:  newValidateTestRequest(),
+		},
+		{
+			name: "missing-descriptor",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Metric.Type = "custom.googleapis.com/missing"
+			}),
+			expectedErrors: []error{pipeline.ErrMetricDescriptorNotFound},
+		},
+		{
+			name: "metric-kind",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.MetricKind = metricpb.MetricDescriptor_CUMULATIVE
+			}),
+			expectedErrors: []error{pipeline.ErrMetricKindMismatch},
+		},
+		{
+			name: "value-type",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Points[0].Value.Value = &monitoringpb.TypedValue_Int64Value{Int64Value: 1}
+			}),
+			expectedErrors: []error{pipeline.ErrValueTypeMismatch},
+		},
+		{
+			name: "metric-label",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Metric.Labels["shape"] = "round"
+			}),
+			expectedErrors: []error{pipeline.ErrUnknownMetricLabel},
+		},
+		{
+			name: "resource-type",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Resource.Type = "not_a_resource"
+			}),
+			expectedErrors: []error{pipeline.ErrUnknownResourceType},
+		},
+		{
+			name: "resource-label",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Resource.Labels["zone"] = zone
+			}),
+			expectedErrors: []error{pipeline.ErrUnknownResourceLabel},
+		},
+		{
+			name: "multiple",
+			req: newValidateTestRequest(func(series *monitoringpb.TimeSeries) {
+				series.Metric.Labels["shape"] = "round"
+				series.Resource.Labels["zone"] = zone
+			}),
+			expectedErrors: []error{pipeline.ErrUnknownMetricLabel, pipeline.ErrUnknownResourceLabel},
+		},
+		{
+			name:           "api-error",
+			clientErr:      errTestAPI,
+			req:            newValidateTestRequest(),
+			expectedErrors: []error{errTestAPI},
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client := newFakeDescriptorClient()
+			client.err = tst.clientErr
+			err := pipeline.ValidateRequest(context.Background(), client, tst.req)
+			if len(tst.expectedErrors) == 0 && err != nil {
+				t.Errorf("ValidateRequest raised an unexpected error: %v", err)
+			}
+			for _, expected := range tst.expectedErrors {
+				if !errors.Is(err, expected) {
+					t.Errorf("Expected ValidateRequest to raise %v, got %v", expected, err)
+				}
+			}
+		})
+	}
+}
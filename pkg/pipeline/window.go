@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A daily window of time during which values are emitted, e.g. to simulate
+// business-hours traffic. The window starts at the start time of day, inclusive,
+// and ends at the end time of day, exclusive; if the end is before the start
+// the window runs overnight into the next day.
+type ActiveWindow struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+	weekdays []time.Weekday
+}
+
+// Returns a new ActiveWindow for the specification, in the form HH:MM-HH:MM,
+// e.g. "09:00-17:00" or "22:00-02:00". Times of day are in the location, or UTC
+// if location is nil. If weekdays are given the window only starts on those
+// days; an overnight window that starts on one of the days continues into the
+// next day.
+func NewActiveWindow(spec string, location *time.Location, weekdays ...time.Weekday) (*ActiveWindow, error) {
+	startSpec, endSpec, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidActiveWindow, spec)
+	}
+	start, err := parseTimeOfDay(startSpec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidActiveWindow, spec)
+	}
+	end, err := parseTimeOfDay(endSpec)
+	if err != nil || start == end {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidActiveWindow, spec)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &ActiveWindow{
+		start:    start,
+		end:      end,
+		location: location,
+		weekdays: slices.Clone(weekdays),
+	}, nil
+}
+
+// Returns the offset from midnight of a time of day in the form HH:MM.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("failure parsing time of day: %w", err)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// Returns true if the timestamp is inside the window.
+func (w *ActiveWindow) Contains(timestamp time.Time) bool {
+	local := timestamp.In(w.location)
+	// Use the wall clock time of day, which differs from the time elapsed since
+	// midnight on days when daylight saving time changes.
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+	day := local.Weekday()
+	switch {
+	case w.start < w.end:
+		if offset < w.start || offset >= w.end {
+			return false
+		}
+	case offset >= w.start:
+	case offset < w.end:
+		// The overnight window started on the previous day.
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	return len(w.weekdays) == 0 || slices.Contains(w.weekdays, day)
+}
+
+// Only emit values with a timestamp inside the active window; values outside the
+// window are discarded before they reach the transformers, leaving a gap in the
+// time-series.
+func WithActiveWindow(window *ActiveWindow) Option {
+	return func(p *Pipeline) error {
+		p.activeWindow = window
+		return nil
+	}
+}
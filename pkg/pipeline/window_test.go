@@ -0,0 +1,155 @@
+package pipeline_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/memes/gce-metric/pkg/pipeline"
+)
+
+func TestNewActiveWindowInvalid(t *testing.T) {
+	t.Parallel()
+	for _, spec := range []string{"", "09:00", "09:00-", "9am-5pm", "09:00-25:00", "09:00-09:00", "09:00/17:00"} {
+		t.Run(spec, func(t *testing.T) {
+			t.Parallel()
+			if _, err := pipeline.NewActiveWindow(spec, nil); !errors.Is(err, pipeline.ErrInvalidActiveWindow) {
+				t.Errorf("Expected NewActiveWindow to raise %v, got %v", pipeline.ErrInvalidActiveWindow, err)
+			}
+		})
+	}
+}
+
+func TestActiveWindowContains(t *testing.T) {
+	t.Parallel()
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+	// 2024-01-01 is a Monday.
+	at := func(day, hour, minute int, location *time.Location) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, location)
+	}
+	tests := []struct {
+		name      string
+		spec      string
+		location  *time.Location
+		weekdays  []time.Weekday
+		timestamp time.Time
+		expected  bool
+	}{
+		{
+			name:      "before-start",
+			spec:      "09:00-17:00",
+			timestamp: at(1, 8, 59, time.UTC),
+		},
+		{
+			name:      "at-start",
+			spec:      "09:00-17:00",
+			timestamp: at(1, 9, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "before-end",
+			spec:      "09:00-17:00",
+			timestamp: at(1, 16, 59, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "at-end",
+			spec:      "09:00-17:00",
+			timestamp: at(1, 17, 0, time.UTC),
+		},
+		{
+			name:      "timezone-inside",
+			spec:      "09:00-17:00",
+			location:  newYork,
+			timestamp: at(1, 14, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "timezone-outside",
+			spec:      "09:00-17:00",
+			location:  newYork,
+			timestamp: at(1, 22, 0, time.UTC),
+		},
+		{
+			name:      "weekday",
+			spec:      "09:00-17:00",
+			weekdays:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			timestamp: at(5, 12, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "weekend",
+			spec:      "09:00-17:00",
+			weekdays:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			timestamp: at(6, 12, 0, time.UTC),
+		},
+		{
+			name:      "overnight-before-start",
+			spec:      "22:00-02:00",
+			timestamp: at(1, 21, 59, time.UTC),
+		},
+		{
+			name:      "overnight-at-start",
+			spec:      "22:00-02:00",
+			timestamp: at(1, 22, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "overnight-midnight",
+			spec:      "22:00-02:00",
+			timestamp: at(2, 0, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "overnight-before-end",
+			spec:      "22:00-02:00",
+			timestamp: at(2, 1, 59, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "overnight-at-end",
+			spec:      "22:00-02:00",
+			timestamp: at(2, 2, 0, time.UTC),
+		},
+		{
+			name:      "overnight-midday",
+			spec:      "22:00-02:00",
+			timestamp: at(2, 12, 0, time.UTC),
+		},
+		{
+			name:      "overnight-continues-from-friday",
+			spec:      "22:00-02:00",
+			weekdays:  []time.Weekday{time.Friday},
+			timestamp: at(6, 1, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "overnight-not-started-saturday",
+			spec:      "22:00-02:00",
+			weekdays:  []time.Weekday{time.Friday},
+			timestamp: at(6, 23, 0, time.UTC),
+		},
+		{
+			name:      "until-midnight",
+			spec:      "22:00-00:00",
+			timestamp: at(1, 23, 59, time.UTC),
+			expected:  true,
+		},
+	}
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			window, err := pipeline.NewActiveWindow(tst.spec, tst.location, tst.weekdays...)
+			if err != nil {
+				t.Fatalf("Unexpected error returned from NewActiveWindow: %v", err)
+			}
+			if contains := window.Contains(tst.timestamp); contains != tst.expected {
+				t.Errorf("Expected Contains(%s) to be %t, got %t", tst.timestamp, tst.expected, contains)
+			}
+		})
+	}
+}